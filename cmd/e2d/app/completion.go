@@ -1,30 +1,45 @@
 package app
 
 import (
-	"os"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
 
 	"github.com/criticalstack/e2d/pkg/log"
-	"github.com/spf13/cobra"
 )
 
 func newCompletionCmd(rootCmd *cobra.Command) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "completion",
-		Short: "Generates bash completion scripts",
+		Use:   "completion [bash|zsh|fish]",
+		Short: "Generates shell completion scripts",
+		Long: `Generates the completion script for the shell (defaults to bash), which is
+written to stdout. For example, to load the completions in every bash session:
+
+	e2d completion bash > /etc/bash_completion.d/e2d`,
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		Args:      cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			w := os.Stdout
+			shell := "bash"
 			if len(args) > 0 {
-				var err error
-				w, err = os.OpenFile(args[0], os.O_RDWR|os.O_CREATE, 0644)
-				if err != nil {
-					log.Fatal(err)
-				}
-				defer w.Close()
+				shell = args[0]
 			}
-			if err := rootCmd.GenBashCompletion(w); err != nil {
+			if err := genCompletion(rootCmd, cmd, shell); err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
 	return cmd
 }
+
+func genCompletion(rootCmd, cmd *cobra.Command, shell string) error {
+	w := cmd.OutOrStdout()
+	switch shell {
+	case "bash":
+		return rootCmd.GenBashCompletion(w)
+	case "zsh":
+		return rootCmd.GenZshCompletion(w)
+	case "fish":
+		return rootCmd.GenFishCompletion(w, true)
+	default:
+		return errors.Errorf("unsupported shell: %#v", shell)
+	}
+}
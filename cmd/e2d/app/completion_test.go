@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"", "bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			args := []string{"completion"}
+			if shell != "" {
+				args = append(args, shell)
+			}
+			var buf bytes.Buffer
+			cmd := NewRootCmd()
+			cmd.SetOut(&buf)
+			cmd.SetArgs(args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() == 0 {
+				t.Fatalf("expected %s completion script, received no output", shell)
+			}
+		})
+	}
+
+	if err := genCompletion(NewRootCmd(), NewRootCmd(), "tcsh"); err == nil {
+		t.Fatal("expected error for an unsupported shell")
+	}
+}
+
+func TestGenDocs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendocs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"gendocs", dir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"e2d.1", "e2d-run.1", "e2d-snapshot-save.1"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/criticalstack/e2d/pkg/e2db"
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+type dbOptions struct {
+	ClientAddr string
+	Namespace  string
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+func newDBCmd() *cobra.Command {
+	o := &dbOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "inspect e2db databases",
+	}
+
+	cmd.PersistentFlags().StringVar(&o.ClientAddr, "client-addr", "127.0.0.1:2379", "etcd client address")
+	cmd.PersistentFlags().StringVar(&o.Namespace, "namespace", "", "e2db namespace")
+	cmd.PersistentFlags().StringVar(&o.CACert, "ca-cert", "", "etcd trusted ca certificate")
+	cmd.PersistentFlags().StringVar(&o.ClientCert, "client-cert", "", "etcd client certificate")
+	cmd.PersistentFlags().StringVar(&o.ClientKey, "client-key", "", "etcd client private key")
+
+	cmd.AddCommand(
+		newDBTablesCmd(o),
+	)
+	return cmd
+}
+
+func newDBTablesCmd(dbOpts *dbOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tables",
+		Short: "list tables and their row counts",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, err := e2db.New(context.Background(), &e2db.Config{
+				ClientAddr: dbOpts.ClientAddr,
+				Namespace:  dbOpts.Namespace,
+				CAFile:     dbOpts.CACert,
+				CertFile:   dbOpts.ClientCert,
+				KeyFile:    dbOpts.ClientKey,
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer db.Close()
+
+			tables, err := db.Tables()
+			if err != nil {
+				log.Fatal(err)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tROWS\tFIELDS")
+			for _, t := range tables {
+				n, err := db.RowCount(t.Name)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fields := make([]string, 0, len(t.Fields))
+				for name := range t.Fields {
+					fields = append(fields, name)
+				}
+				sort.Strings(fields)
+				fmt.Fprintf(w, "%s\t%d\t%s\n", t.Name, n, strings.Join(fields, ","))
+			}
+			if err := w.Flush(); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	return cmd
+}
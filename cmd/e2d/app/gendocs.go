@@ -0,0 +1,28 @@
+package app
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+func newGenDocsCmd(rootCmd *cobra.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "gendocs <dir>",
+		Short:  "Generates man pages for every command",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := os.MkdirAll(args[0], 0755); err != nil {
+				log.Fatal(err)
+			}
+			if err := doc.GenManTree(rootCmd, &doc.GenManHeader{Title: "E2D", Section: "1"}, args[0]); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	return cmd
+}
@@ -1,21 +1,28 @@
 package app
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/cloudflare/cfssl/csr"
 	"github.com/criticalstack/e2d/pkg/log"
 	"github.com/criticalstack/e2d/pkg/netutil"
 	"github.com/criticalstack/e2d/pkg/pki"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 type pkiOptions struct {
-	CACert string
-	CAKey  string
+	CACert  string
+	CAKey   string
+	KeyAlgo string
+	KeySize int
+}
+
+func (o *pkiOptions) keyConfig() *pki.KeyConfig {
+	return &pki.KeyConfig{Algo: o.KeyAlgo, Size: o.KeySize}
 }
 
 func newPKICmd() *cobra.Command {
@@ -28,18 +35,32 @@ func newPKICmd() *cobra.Command {
 
 	cmd.PersistentFlags().StringVar(&o.CACert, "ca-cert", "", "")
 	cmd.PersistentFlags().StringVar(&o.CAKey, "ca-key", "", "")
+	cmd.PersistentFlags().StringVar(&o.KeyAlgo, "key-algo", pki.KeyAlgoRSA, "algorithm of generated private keys (rsa or ecdsa)")
+	cmd.PersistentFlags().IntVar(&o.KeySize, "key-size", 0, "size of generated private keys (2048, 3072 or 4096 for rsa, defaulting to 2048, or 256, 384 or 521 for ecdsa, defaulting to 256)")
 
 	cmd.AddCommand(
 		newPKIInitCmd(o),
 		newPKIGenCertsCmd(o),
+		newPKIHashCmd(o),
 	)
 	return cmd
 }
 
+type pkiInitOptions struct {
+	ParentCACert string
+	ParentCAKey  string
+}
+
 func newPKIInitCmd(pkiOpts *pkiOptions) *cobra.Command {
+	o := pkiInitOptions{}
+
 	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "initialize a new CA",
+		Long: `Initialize a new CA. By default the CA is a self-signed root, while providing
+--parent-ca-cert and --parent-ca-key creates an intermediate CA signed by the
+parent (such as a corporate CA), and the certificates it generates chain to the
+parent.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			path := filepath.Dir(pkiOpts.CACert)
 			if path != "" {
@@ -47,7 +68,7 @@ func newPKIInitCmd(pkiOpts *pkiOptions) *cobra.Command {
 					log.Fatal(err)
 				}
 			}
-			r, err := pki.NewDefaultRootCA()
+			r, err := newCA(pkiOpts, &o)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -59,11 +80,58 @@ func newPKIInitCmd(pkiOpts *pkiOptions) *cobra.Command {
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&o.ParentCACert, "parent-ca-cert", "", "certificate of the parent CA that signs an intermediate CA (including its chain)")
+	cmd.Flags().StringVar(&o.ParentCAKey, "parent-ca-key", "", "private key of the parent CA that signs an intermediate CA")
+	return cmd
+}
+
+// newCA creates a self-signed root CA, or an intermediate CA when a parent CA
+// is provided.
+func newCA(pkiOpts *pkiOptions, o *pkiInitOptions) (*pki.RootCA, error) {
+	if o.ParentCACert == "" && o.ParentCAKey == "" {
+		return pki.NewDefaultRootCAWithKeyConfig(pkiOpts.keyConfig())
+	}
+	if o.ParentCACert == "" || o.ParentCAKey == "" {
+		return nil, errors.New("must provide both --parent-ca-cert and --parent-ca-key")
+	}
+	certPEM, err := ioutil.ReadFile(o.ParentCACert)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(o.ParentCAKey)
+	if err != nil {
+		return nil, err
+	}
+	cr, err := pki.NewDefaultCACertificateRequest(pkiOpts.keyConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	// the subject must differ from the parent's, which may itself be a
+	// default e2d CA, or the chain cannot be built by name
+	cr.CN = "e2d-intermediate-ca"
+	return pki.NewIntermediateCA(certPEM, keyPEM, cr)
+}
+
+func newPKIHashCmd(pkiOpts *pkiOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hash",
+		Short: "print the hash of the CA certificate used to verify cluster members",
+		Run: func(cmd *cobra.Command, args []string) {
+			h, err := pki.GenerateCertHash(pkiOpts.CACert)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(pki.FormatCertHash(h))
+		},
+	}
 	return cmd
 }
 
 type pkiGenCertsOptions struct {
 	Hosts     string
+	SANs      []string
 	OutputDir string
 }
 
@@ -74,25 +142,36 @@ func newPKIGenCertsCmd(pkiOpts *pkiOptions) *cobra.Command {
 		Use:   "gencerts",
 		Short: "generate certificates/private keys",
 		Run: func(cmd *cobra.Command, args []string) {
-			var hosts []string
+			sans := append([]string{}, o.SANs...)
 			if o.Hosts != "" {
-				hosts = strings.Split(o.Hosts, ",")
+				sans = append(sans, strings.Split(o.Hosts, ",")...)
 			}
 			r, err := pki.NewRootCAFromFile(pkiOpts.CACert, pkiOpts.CAKey)
 			if err != nil {
 				log.Fatal(err)
 			}
+			// IPv6-only hosts use the IPv6 address instead
 			hostIP, err := netutil.DetectHostIPv4()
 			if err != nil {
-				log.Fatal(err)
+				hostIP, err = netutil.DetectHostIPv6()
+				if err != nil {
+					log.Fatal(err)
+				}
 			}
 			if o.OutputDir != "" {
 				if err := os.MkdirAll(o.OutputDir, 0755); err != nil && !os.IsExist(err) {
 					log.Fatal(err)
 				}
 			}
-			hosts = appendHosts(hosts, "127.0.0.1", hostIP)
-			certs, err := r.GenerateCertificates(pki.ServerSigningProfile, newCertificateRequest("etcd server", hosts...))
+			dnsNames, ips, err := pki.ParseSANs(append(sans, "127.0.0.1", hostIP))
+			if err != nil {
+				log.Fatal(err)
+			}
+			cr, err := pki.NewCertificateRequest(pkiOpts.keyConfig(), "etcd server", dnsNames, ips)
+			if err != nil {
+				log.Fatal(err)
+			}
+			certs, err := r.GenerateCertificates(pki.ServerSigningProfile, cr)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -102,7 +181,11 @@ func newPKIGenCertsCmd(pkiOpts *pkiOptions) *cobra.Command {
 			if err := writeFile(filepath.Join(o.OutputDir, "server.key"), certs.KeyPEM, 0600); err != nil {
 				log.Fatal(err)
 			}
-			certs, err = r.GenerateCertificates(pki.PeerSigningProfile, newCertificateRequest("etcd peer", hosts...))
+			cr, err = pki.NewCertificateRequest(pkiOpts.keyConfig(), "etcd peer", dnsNames, ips)
+			if err != nil {
+				log.Fatal(err)
+			}
+			certs, err = r.GenerateCertificates(pki.PeerSigningProfile, cr)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -112,7 +195,11 @@ func newPKIGenCertsCmd(pkiOpts *pkiOptions) *cobra.Command {
 			if err := writeFile(filepath.Join(o.OutputDir, "peer.key"), certs.KeyPEM, 0600); err != nil {
 				log.Fatal(err)
 			}
-			certs, err = r.GenerateCertificates(pki.ClientSigningProfile, newCertificateRequest("etcd client"))
+			cr, err = pki.NewCertificateRequest(pkiOpts.keyConfig(), "etcd client", nil, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			certs, err = r.GenerateCertificates(pki.ClientSigningProfile, cr)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -126,45 +213,13 @@ func newPKIGenCertsCmd(pkiOpts *pkiOptions) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&o.Hosts, "hosts", "", "")
+	cmd.Flags().StringVar(&o.Hosts, "hosts", "", "comma-separated DNS names and IP addresses added to the server and peer certificates")
+	cmd.Flags().StringSliceVar(&o.SANs, "san", nil, "DNS name or IP address added to the subject alternative names of the server and peer certificates (may be repeated)")
 	cmd.Flags().StringVar(&o.OutputDir, "output-dir", "", "")
 
 	return cmd
 }
 
-func appendHosts(hosts []string, newHosts ...string) []string {
-	for _, newHost := range newHosts {
-		if newHost == "" {
-			continue
-		}
-		for _, host := range hosts {
-			if newHost == host {
-				continue
-			}
-		}
-		hosts = append(hosts, newHost)
-	}
-	return hosts
-}
-
-func newCertificateRequest(commonName string, hosts ...string) *csr.CertificateRequest {
-	return &csr.CertificateRequest{
-		Names: []csr.Name{
-			{
-				C:  "US",
-				ST: "Boston",
-				L:  "MA",
-			},
-		},
-		KeyRequest: &csr.KeyRequest{
-			A: "rsa",
-			S: 2048,
-		},
-		Hosts: hosts,
-		CN:    commonName,
-	}
-}
-
 func writeFile(filename string, data []byte, perm os.FileMode) error {
 	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
 		return err
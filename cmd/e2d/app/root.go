@@ -2,23 +2,34 @@ package app
 
 import (
 	"github.com/spf13/cobra"
+
+	"github.com/criticalstack/e2d/pkg/log"
 )
 
 var globalOptions struct {
-	verbose bool
+	verbose   bool
+	logFormat string
 }
 
 func NewRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "e2d",
 		Short: "etcd manager",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return log.SetEncoding(globalOptions.logFormat)
+		},
 	}
 	cmd.PersistentFlags().BoolVarP(&globalOptions.verbose, "verbose", "v", false, "verbose log output (debug)")
+	cmd.PersistentFlags().StringVar(&globalOptions.logFormat, "log-format", log.EncodingConsole, "format {console,json} of the log output, including the etcd and memberlist logs")
 
 	cmd.AddCommand(
 		newCompletionCmd(cmd),
+		newDBCmd(),
+		newGenDocsCmd(cmd),
 		newRunCmd(),
 		newPKICmd(),
+		newSnapshotCmd(),
+		newValidateConfigCmd(),
 		newVersionCmd(),
 	)
 
@@ -2,8 +2,13 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/criticalstack/e2d/pkg/client"
@@ -11,6 +16,7 @@ import (
 	"github.com/criticalstack/e2d/pkg/discovery"
 	"github.com/criticalstack/e2d/pkg/log"
 	"github.com/criticalstack/e2d/pkg/manager"
+	"github.com/criticalstack/e2d/pkg/netutil"
 	"github.com/criticalstack/e2d/pkg/snapshot"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -18,40 +24,102 @@ import (
 )
 
 type runOptions struct {
-	Name       string `env:"E2D_NAME"`
-	DataDir    string `env:"E2D_DATA_DIR"`
-	Host       string `env:"E2D_HOST"`
-	ClientAddr string `env:"E2D_CLIENT_ADDR"`
-	PeerAddr   string `env:"E2D_PEER_ADDR"`
-	GossipAddr string `env:"E2D_GOSSIP_ADDR"`
+	ConfigFile string `env:"E2D_CONFIG"`
+
+	Name                string `env:"E2D_NAME"`
+	DataDir             string `env:"E2D_DATA_DIR"`
+	Host                string `env:"E2D_HOST"`
+	Interface           string `env:"E2D_INTERFACE"`
+	ClientAddr          string `env:"E2D_CLIENT_ADDR"`
+	PeerAddr            string `env:"E2D_PEER_ADDR"`
+	AdvertiseClientAddr string `env:"E2D_ADVERTISE_CLIENT_ADDR"`
+	AdvertisePeerAddr   string `env:"E2D_ADVERTISE_PEER_ADDR"`
+	GossipAddr          string `env:"E2D_GOSSIP_ADDR"`
+	MetricsAddr         string `env:"E2D_METRICS_ADDR"`
+
+	GossipProfile       string        `env:"E2D_GOSSIP_PROFILE"`
+	GossipProbeInterval time.Duration `env:"E2D_GOSSIP_PROBE_INTERVAL"`
+	GossipProbeTimeout  time.Duration `env:"E2D_GOSSIP_PROBE_TIMEOUT"`
+	ClusterID           string        `env:"E2D_CLUSTER_ID"`
+
+	AllowMissingClusterID bool `env:"E2D_ALLOW_MISSING_CLUSTER_ID"`
+
+	CheckDataDir bool   `env:"E2D_CHECK_DATA_DIR"`
+	EtcdLogFile  string `env:"E2D_ETCD_LOG_FILE"`
+	AuditLogFile string `env:"E2D_AUDIT_LOG_FILE"`
 
 	CACert     string `env:"E2D_CA_CERT"`
 	CAKey      string `env:"E2D_CA_KEY"`
+	CACertHash string `env:"E2D_CA_CERT_HASH"`
 	PeerCert   string `env:"E2D_PEER_CERT"`
 	PeerKey    string `env:"E2D_PEER_KEY"`
 	ServerCert string `env:"E2D_SERVER_CERT"`
 	ServerKey  string `env:"E2D_SERVER_KEY"`
 
-	BootstrapAddrs      string `env:"E2D_BOOTSTRAP_ADDRS"`
-	RequiredClusterSize int    `env:"E2D_REQUIRED_CLUSTER_SIZE"`
+	// base64-encoded PEM certificate material, only settable through the
+	// environment to avoid exposing keys in the process arguments
+	CACertData     string `env:"E2D_CA_CERT_DATA"`
+	CAKeyData      string `env:"E2D_CA_KEY_DATA"`
+	PeerCertData   string `env:"E2D_PEER_CERT_DATA"`
+	PeerKeyData    string `env:"E2D_PEER_KEY_DATA"`
+	ServerCertData string `env:"E2D_SERVER_CERT_DATA"`
+	ServerKeyData  string `env:"E2D_SERVER_KEY_DATA"`
+
+	// base64-encoded key used to encrypt the gossip network, only settable
+	// through the environment for the same reason
+	GossipSecretKey string `env:"E2D_GOSSIP_SECRET_KEY"`
+
+	BootstrapAddrs             string        `env:"E2D_BOOTSTRAP_ADDRS"`
+	BootstrapObservationWindow time.Duration `env:"E2D_BOOTSTRAP_OBSERVATION_WINDOW"`
+	BootstrapWarnings          string        `env:"E2D_BOOTSTRAP_WARNINGS"`
+	PreferExisting             bool          `env:"E2D_PREFER_EXISTING"`
+	PreferExistingTimeout      time.Duration `env:"E2D_PREFER_EXISTING_TIMEOUT"`
+	RequiredClusterSize        int           `env:"E2D_REQUIRED_CLUSTER_SIZE"`
+
+	JoinTimeout        time.Duration `env:"E2D_JOIN_TIMEOUT"`
+	JoinRetries        int           `env:"E2D_JOIN_RETRIES"`
+	JoinRetryInterval  time.Duration `env:"E2D_JOIN_RETRY_INTERVAL"`
+	JoinAttemptTimeout time.Duration `env:"E2D_JOIN_ATTEMPT_TIMEOUT"`
+	JoinAsLearner      bool          `env:"E2D_JOIN_AS_LEARNER"`
 
 	HealthCheckInterval time.Duration `env:"E2D_HEALTH_CHECK_INTERVAL"`
 	HealthCheckTimeout  time.Duration `env:"E2D_HEALTH_CHECK_TIMEOUT"`
+	StopTimeout         time.Duration `env:"E2D_STOP_TIMEOUT"`
+	DefragInterval      time.Duration `env:"E2D_DEFRAG_INTERVAL"`
+	QuotaBackendBytes   int64         `env:"E2D_QUOTA_BACKEND_BYTES"`
+	CertRenewBefore     time.Duration `env:"E2D_CERT_RENEW_BEFORE"`
 
-	PeerDiscovery string `env:"E2D_PEER_DISCOVERY"`
+	PeerDiscovery        string        `env:"E2D_PEER_DISCOVERY"`
+	PeerDiscoveryTimeout time.Duration `env:"E2D_PEER_DISCOVERY_TIMEOUT"`
 
 	SnapshotBackupURL   string        `env:"E2D_SNAPSHOT_BACKUP_URL"`
 	SnapshotCompression bool          `env:"E2D_SNAPSHOT_COMPRESSION"`
 	SnapshotEncryption  bool          `env:"E2D_SNAPSHOT_ENCRYPTION"`
 	SnapshotInterval    time.Duration `env:"E2D_SNAPSHOT_INTERVAL"`
+	SnapshotRetention   int           `env:"E2D_SNAPSHOT_RETENTION"`
+	RestoreSnapshotName string        `env:"E2D_RESTORE_SNAPSHOT_NAME"`
+	PreservePrefixes    string        `env:"E2D_PRESERVE_PREFIXES"`
 
-	AWSAccessKey       string `env:"E2D_AWS_ACCESS_KEY"`
-	AWSSecretKey       string `env:"E2D_AWS_SECRET_KEY"`
-	AWSRoleSessionName string `env:"E2D_AWS_ROLE_SESSION_NAME"`
+	Zone                  string `env:"E2D_ZONE"`
+	SnapshotPreferredZone string `env:"E2D_SNAPSHOT_PREFERRED_ZONE"`
+
+	AWSAccessKey         string        `env:"E2D_AWS_ACCESS_KEY"`
+	AWSSecretKey         string        `env:"E2D_AWS_SECRET_KEY"`
+	AWSRoleSessionName   string        `env:"E2D_AWS_ROLE_SESSION_NAME"`
+	AWSUploadPartSize    int64         `env:"E2D_AWS_UPLOAD_PART_SIZE"`
+	AWSUploadConcurrency int           `env:"E2D_AWS_UPLOAD_CONCURRENCY"`
+	AWSUploadTimeout     time.Duration `env:"E2D_AWS_UPLOAD_TIMEOUT"`
+	AWSSSE               string        `env:"E2D_AWS_SSE"`
+	AWSSSEKMSKeyID       string        `env:"E2D_AWS_SSE_KMS_KEY_ID"`
+
+	GCSCredentialsFile string `env:"E2D_GCS_CREDENTIALS_FILE"`
 
 	DOAccessToken  string `env:"E2D_DO_ACCESS_TOKEN"`
 	DOSpacesKey    string `env:"E2D_DO_SPACES_KEY"`
 	DOSpacesSecret string `env:"E2D_DO_SPACES_SECRET"`
+
+	Kubeconfig   string `env:"E2D_KUBECONFIG"`
+	K8sNamespace string `env:"E2D_K8S_NAMESPACE"`
 }
 
 func newRunCmd() *cobra.Command {
@@ -61,99 +129,255 @@ func newRunCmd() *cobra.Command {
 		Use:   "run",
 		Short: "start a managed etcd instance",
 		Run: func(cmd *cobra.Command, args []string) {
-			peerGetter, err := getPeerGetter(o)
-			if err != nil {
-				log.Fatalf("%+v", err)
-			}
-
-			baddrs, err := getInitialBootstrapAddrs(o, peerGetter)
-			if err != nil {
+			if err := setFlagsFromConfigFile(cmd, o); err != nil {
 				log.Fatalf("%+v", err)
 			}
-
-			snapshotter, err := getSnapshotProvider(o)
+			cfg, err := newManagerConfig(o)
 			if err != nil {
 				log.Fatalf("%+v", err)
 			}
-
-			m, err := manager.New(&manager.Config{
-				Name:                o.Name,
-				Dir:                 o.DataDir,
-				Host:                o.Host,
-				ClientAddr:          o.ClientAddr,
-				PeerAddr:            o.PeerAddr,
-				GossipAddr:          o.GossipAddr,
-				BootstrapAddrs:      baddrs,
-				RequiredClusterSize: o.RequiredClusterSize,
-				SnapshotInterval:    o.SnapshotInterval,
-				SnapshotCompression: o.SnapshotCompression,
-				SnapshotEncryption:  o.SnapshotEncryption,
-				HealthCheckInterval: o.HealthCheckInterval,
-				HealthCheckTimeout:  o.HealthCheckTimeout,
-				ClientSecurity: client.SecurityConfig{
-					CertFile:      o.ServerCert,
-					KeyFile:       o.ServerKey,
-					TrustedCAFile: o.CACert,
-				},
-				PeerSecurity: client.SecurityConfig{
-					CertFile:      o.PeerCert,
-					KeyFile:       o.PeerKey,
-					TrustedCAFile: o.CACert,
-				},
-				CACertFile:  o.CACert,
-				CAKeyFile:   o.CAKey,
-				PeerGetter:  peerGetter,
-				Snapshotter: snapshotter,
-				Debug:       globalOptions.verbose,
-			})
+			m, err := manager.New(cfg)
 			if err != nil {
 				log.Fatalf("%+v", err)
 			}
+			go reloadTLSOnSIGHUP(m)
 			if err := m.Run(); err != nil {
 				log.Fatalf("%+v", err)
 			}
 		},
 	}
 
+	addRunFlags(cmd, o)
+	if err := cmdutil.SetEnvs(o); err != nil {
+		log.Debug("cannot set environment variables", zap.Error(err))
+	}
+
+	return cmd
+}
+
+// reloadTLSOnSIGHUP reloads the certificates used by the manager each time
+// the process receives SIGHUP, so that rotated certificates are used without
+// restarting e2d.
+func reloadTLSOnSIGHUP(m *manager.Manager) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		log.Info("received SIGHUP, reloading certificates ...")
+		if err := m.ReloadTLS(); err != nil {
+			log.Error("cannot reload certificates", zap.Error(err))
+		}
+	}
+}
+
+// addRunFlags adds the flags used to configure the manager, which are shared
+// by the run and validate-config commands.
+func addRunFlags(cmd *cobra.Command, o *runOptions) {
+	cmd.Flags().StringVar(&o.ConfigFile, "config", "", "path to a YAML or JSON file setting options by their flag names, which are overridden by flags and environment variables")
 	cmd.Flags().StringVar(&o.Name, "name", "", "specify a name for the node")
 	cmd.Flags().StringVar(&o.DataDir, "data-dir", "", "etcd data-dir")
+	cmd.Flags().BoolVar(&o.CheckDataDir, "check-data-dir", false, "check the integrity of an existing data-dir on startup and recover if it is corrupt")
 	cmd.Flags().StringVar(&o.Host, "host", "", "host IPv4 (defaults to 127.0.0.1 if unset)")
+	cmd.Flags().StringVar(&o.Interface, "interface", "", "network interface whose address is used as the host, in place of --host (defaults to the first non-loopback interface)")
 	cmd.Flags().StringVar(&o.ClientAddr, "client-addr", "0.0.0.0:2379", "etcd client addrress")
 	cmd.Flags().StringVar(&o.PeerAddr, "peer-addr", "0.0.0.0:2380", "etcd peer addrress")
+	cmd.Flags().StringVar(&o.AdvertiseClientAddr, "advertise-client-addr", "", "etcd client address advertised to clients and other members, when it differs from the client address, like behind NAT or a load balancer (defaults to the client address)")
+	cmd.Flags().StringVar(&o.AdvertisePeerAddr, "advertise-peer-addr", "", "etcd peer address advertised to other members, when it differs from the peer address (defaults to the peer address)")
 	cmd.Flags().StringVar(&o.GossipAddr, "gossip-addr", "0.0.0.0:7980", "gossip address")
+	cmd.Flags().StringVar(&o.MetricsAddr, "metrics-addr", "", "optional address to serve the etcd /metrics and /health endpoints over http, which are always served on the client address")
+	cmd.Flags().StringVar(&o.GossipProfile, "gossip-profile", "lan", "memberlist profile {lan,wan,local} selecting the timeouts used to detect failed members")
+	cmd.Flags().DurationVar(&o.GossipProbeInterval, "gossip-probe-interval", 0, "interval between probes of gossip members (defaults to the gossip profile)")
+	cmd.Flags().DurationVar(&o.GossipProbeTimeout, "gossip-probe-timeout", 0, "timeout of each probe of a gossip member (defaults to the gossip profile)")
+	cmd.Flags().StringVar(&o.ClusterID, "cluster-id", "", "identity of the cluster shared over gossip, members of other clusters are not joined (defaults to the hash of the ca certificate)")
+	cmd.Flags().BoolVar(&o.AllowMissingClusterID, "allow-missing-cluster-id", false, "accept gossip members that do not advertise a cluster identity, such as older versions during a rolling upgrade")
+	cmd.Flags().StringVar(&o.EtcdLogFile, "etcd-log-file", "", "file where etcd and memberlist logs are appended (defaults to stderr)")
+	cmd.Flags().StringVar(&o.AuditLogFile, "audit-log-file", "", "file where an audit log of membership changes is appended")
 
 	cmd.Flags().StringVar(&o.CACert, "ca-cert", "", "etcd trusted ca certificate")
 	cmd.Flags().StringVar(&o.CAKey, "ca-key", "", "etcd ca key")
+	cmd.Flags().StringVar(&o.CACertHash, "ca-cert-hash", "", "expected hash of the ca certificate (from 'e2d pki hash'), members with a different ca are not joined")
 	cmd.Flags().StringVar(&o.PeerCert, "peer-cert", "", "etcd peer certificate")
 	cmd.Flags().StringVar(&o.PeerKey, "peer-key", "", "etcd peer private key")
 	cmd.Flags().StringVar(&o.ServerCert, "server-cert", "", "etcd server certificate")
 	cmd.Flags().StringVar(&o.ServerKey, "server-key", "", "etcd server private key")
 
 	cmd.Flags().StringVar(&o.BootstrapAddrs, "bootstrap-addrs", "", "initial addresses used for node discovery")
-	cmd.Flags().IntVarP(&o.RequiredClusterSize, "required-cluster-size", "n", 1, "size of the etcd cluster should be {1,3,5}")
+	cmd.Flags().DurationVar(&o.BootstrapObservationWindow, "bootstrap-observation-window", 0, "minimum time to wait for an existing cluster before forming a new one")
+	cmd.Flags().StringVar(&o.BootstrapWarnings, "bootstrap-warnings", "0.25,0.5,0.75", "fractions of the bootstrap timeout at which to warn that bootstrapping has not succeeded")
+	cmd.Flags().BoolVar(&o.PreferExisting, "prefer-existing", false, "keep trying to join running members of an existing cluster before forming a new one")
+	cmd.Flags().DurationVar(&o.PreferExistingTimeout, "prefer-existing-timeout", 10*time.Minute, "time to wait for running members to be joined before forming a new cluster when --prefer-existing is set")
+	cmd.Flags().IntVarP(&o.RequiredClusterSize, "required-cluster-size", "n", 1, "size of the etcd cluster should be {1,3,5,7}")
+
+	cmd.Flags().DurationVar(&o.JoinTimeout, "join-timeout", 3*time.Second, "time to wait for a peer to respond when joining an existing cluster")
+	cmd.Flags().IntVar(&o.JoinRetries, "join-retries", 2, "number of times to retry a peer when joining an existing cluster (negative disables retries)")
+	cmd.Flags().DurationVar(&o.JoinRetryInterval, "join-retry-interval", 1*time.Second, "time to wait between attempts to join or form a cluster while bootstrapping")
+	cmd.Flags().DurationVar(&o.JoinAttemptTimeout, "join-attempt-timeout", 5*time.Minute, "maximum time for a single attempt to join an existing cluster through a peer")
+	cmd.Flags().BoolVar(&o.JoinAsLearner, "join-as-learner", false, "join an existing cluster as a non-voting learner, promoted once caught up with the leader")
 
 	cmd.Flags().DurationVar(&o.HealthCheckInterval, "health-check-interval", 1*time.Minute, "")
 	cmd.Flags().DurationVar(&o.HealthCheckTimeout, "health-check-timeout", 5*time.Minute, "")
+	cmd.Flags().DurationVar(&o.StopTimeout, "stop-timeout", 1*time.Minute, "maximum time to wait for etcd to stop during shutdown")
+	cmd.Flags().DurationVar(&o.DefragInterval, "defrag-interval", 0, "frequency at which the leader defragments each member of the cluster in turn (0 disables defragmentation)")
+	cmd.Flags().Int64Var(&o.QuotaBackendBytes, "quota-backend-bytes", 0, "size limit of the etcd backend in bytes (defaults to the etcd default of 2GiB)")
+	cmd.Flags().DurationVar(&o.CertRenewBefore, "cert-renew-before", 0, "renew the server and peer certificates with the ca key when they expire within this duration (0 disables renewal)")
 
-	cmd.Flags().StringVar(&o.PeerDiscovery, "peer-discovery", "", "which method {aws-autoscaling-group,ec2-tags,do-tags} to use to discover peers")
+	cmd.Flags().StringVar(&o.PeerDiscovery, "peer-discovery", "", "which method {aws-autoscaling-group,ec2-tags,do-tags,gcp-instance-group,k8s-labels,dns-srv} to use to discover peers")
+	cmd.Flags().DurationVar(&o.PeerDiscoveryTimeout, "peer-discovery-timeout", 5*time.Minute, "maximum time spent retrying peer discovery when the cloud provider API is unavailable")
 
-	cmd.Flags().DurationVar(&o.SnapshotInterval, "snapshot-interval", 1*time.Minute, "frequency of etcd snapshots")
-	cmd.Flags().StringVar(&o.SnapshotBackupURL, "snapshot-backup-url", "", "an absolute path to shared filesystem storage (like file:///etcd-backups) or cloud storage bucket (like s3://etcd-backups) for snapshot backups")
+	cmd.Flags().DurationVar(&o.SnapshotInterval, "snapshot-interval", 1*time.Minute, "frequency of etcd snapshots (0 disables creating snapshots, while still restoring from an existing snapshot backup)")
+	cmd.Flags().StringVar(&o.SnapshotBackupURL, "snapshot-backup-url", "", "an absolute path to shared filesystem storage (like file:///etcd-backups) or cloud storage bucket (like s3://etcd-backups) for snapshot backups, or a comma-separated list to mirror snapshot backups to each")
 	cmd.Flags().BoolVar(&o.SnapshotCompression, "snapshot-compression", false, "compression snapshots with gzip")
 	cmd.Flags().BoolVar(&o.SnapshotEncryption, "snapshot-encryption", false, "encrypt snapshots with aes-256")
+	cmd.Flags().IntVar(&o.SnapshotRetention, "snapshot-retention", 0, "number of timestamped snapshot backups to keep (0 overwrites a single snapshot backup)")
+	cmd.Flags().StringVar(&o.RestoreSnapshotName, "restore-snapshot-name", "", "name of the snapshot backup to restore instead of the latest (like etcd-20200101T120000Z.snapshot)")
+	cmd.Flags().StringVar(&o.Zone, "zone", "", "zone (or rack) of this node, shared with the other nodes over gossip")
+	cmd.Flags().StringVar(&o.SnapshotPreferredZone, "snapshot-preferred-zone", "", "zone of the node that creates snapshot backups, falling back to the leader when no running node is in the zone")
+	cmd.Flags().StringVar(&o.PreservePrefixes, "preserve-prefixes", "", "comma-separated key prefixes within /_e2d/ that are kept when restoring from a snapshot")
 
-	cmd.Flags().StringVar(&o.AWSAccessKey, "aws-access-key", "", "")
-	cmd.Flags().StringVar(&o.AWSSecretKey, "aws-secret-key", "", "")
+	cmd.Flags().StringVar(&o.AWSAccessKey, "aws-access-key", "", "AWS access key used for peer discovery (defaults to the default credential chain)")
+	cmd.Flags().StringVar(&o.AWSSecretKey, "aws-secret-key", "", "AWS secret key used for peer discovery (defaults to the default credential chain)")
 	cmd.Flags().StringVar(&o.AWSRoleSessionName, "aws-role-session-name", "", "")
+	cmd.Flags().Int64Var(&o.AWSUploadPartSize, "aws-upload-part-size", 0, "size in bytes of each part of a snapshot backup uploaded to s3 (defaults to 5MiB)")
+	cmd.Flags().IntVar(&o.AWSUploadConcurrency, "aws-upload-concurrency", 0, "number of parts of a snapshot backup uploaded to s3 concurrently (defaults to 5)")
+	cmd.Flags().DurationVar(&o.AWSUploadTimeout, "aws-upload-timeout", 1*time.Minute, "time a snapshot backup upload to s3 can go without making progress before it is canceled")
+	cmd.Flags().StringVar(&o.AWSSSE, "aws-sse", "", "server-side encryption of snapshot backups uploaded to s3 (AES256 or aws:kms)")
+	cmd.Flags().StringVar(&o.AWSSSEKMSKeyID, "aws-sse-kms-key-id", "", "KMS key used for server-side encryption of snapshot backups uploaded to s3 (implies --aws-sse=aws:kms)")
+
+	cmd.Flags().StringVar(&o.GCSCredentialsFile, "gcs-credentials-file", "", "path to a Google Cloud service account key used for gcs snapshot backups (defaults to application default credentials)")
 
 	cmd.Flags().StringVar(&o.DOAccessToken, "do-access-token", "", "DigitalOcean personal access token")
 	cmd.Flags().StringVar(&o.DOSpacesKey, "do-spaces-key", "", "DigitalOcean spaces access key")
 	cmd.Flags().StringVar(&o.DOSpacesSecret, "do-spaces-secret", "", "DigitalOcean spaces secret")
-	if err := cmdutil.SetEnvs(o); err != nil {
-		log.Debug("cannot set environment variables", zap.Error(err))
+
+	cmd.Flags().StringVar(&o.Kubeconfig, "kubeconfig", "", "path to a kubeconfig used for k8s-labels peer discovery (defaults to the in-cluster service account)")
+	cmd.Flags().StringVar(&o.K8sNamespace, "k8s-namespace", "", "namespace of the pods found by k8s-labels peer discovery (defaults to the namespace of the service account or kubeconfig context)")
+}
+
+// setFlagsFromConfigFile sets the flags of the command from the --config
+// file, if one was provided. Flags that were set on the command line or by
+// their environment variable are not changed.
+func setFlagsFromConfigFile(cmd *cobra.Command, o *runOptions) error {
+	if o.ConfigFile == "" {
+		return nil
 	}
+	cmdutil.SetChangedFromEnv(cmd.Flags(), "E2D_")
+	return cmdutil.SetFlagsFromFile(cmd.Flags(), o.ConfigFile)
+}
 
-	return cmd
+// newManagerConfig creates the manager configuration from the run options,
+// setting up peer discovery and the snapshot provider.
+func newManagerConfig(o *runOptions) (*manager.Config, error) {
+	peerGetter, err := getPeerGetter(o)
+	if err != nil {
+		return nil, err
+	}
+
+	// peer discovery is retried and cached, so that a cloud provider API that
+	// is briefly unavailable does not prevent starting
+	if _, ok := peerGetter.(*discovery.NoopGetter); !ok {
+		peerGetter = discovery.NewCachingPeerGetter(peerGetter)
+	}
+
+	baddrs, err := getInitialBootstrapAddrs(o, peerGetter)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotter, err := getSnapshotProvider(o)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapWarnings, err := parseBootstrapWarnings(o.BootstrapWarnings)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := decodeCertData(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var gossipSecretKey []byte
+	if o.GossipSecretKey != "" {
+		gossipSecretKey, err = base64.StdEncoding.DecodeString(strings.TrimSpace(o.GossipSecretKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode E2D_GOSSIP_SECRET_KEY")
+		}
+	}
+
+	// the flag defaults to 1m, so zero was set explicitly and
+	// disables creating snapshots
+	if o.SnapshotInterval == 0 {
+		o.SnapshotInterval = -1
+	}
+
+	return &manager.Config{
+		Name:                       o.Name,
+		Dir:                        o.DataDir,
+		CheckDataDir:               o.CheckDataDir,
+		Host:                       o.Host,
+		Interface:                  o.Interface,
+		ClientAddr:                 o.ClientAddr,
+		PeerAddr:                   o.PeerAddr,
+		AdvertiseClientAddr:        o.AdvertiseClientAddr,
+		AdvertisePeerAddr:          o.AdvertisePeerAddr,
+		GossipAddr:                 o.GossipAddr,
+		MetricsAddr:                o.MetricsAddr,
+		GossipProfile:              o.GossipProfile,
+		GossipProbeInterval:        o.GossipProbeInterval,
+		GossipProbeTimeout:         o.GossipProbeTimeout,
+		ClusterID:                  o.ClusterID,
+		AllowMissingClusterID:      o.AllowMissingClusterID,
+		EtcdLogFile:                o.EtcdLogFile,
+		AuditLogFile:               o.AuditLogFile,
+		BootstrapAddrs:             baddrs,
+		BootstrapObservationWindow: o.BootstrapObservationWindow,
+		BootstrapWarnings:          bootstrapWarnings,
+		PreferExisting:             o.PreferExisting,
+		PreferExistingTimeout:      o.PreferExistingTimeout,
+		RequiredClusterSize:        o.RequiredClusterSize,
+		JoinTimeout:                o.JoinTimeout,
+		JoinRetries:                o.JoinRetries,
+		JoinRetryInterval:          o.JoinRetryInterval,
+		JoinAttemptTimeout:         o.JoinAttemptTimeout,
+		JoinAsLearner:              o.JoinAsLearner,
+		SnapshotInterval:           o.SnapshotInterval,
+		Zone:                       o.Zone,
+		SnapshotPreferredZone:      o.SnapshotPreferredZone,
+		SnapshotCompression:        o.SnapshotCompression,
+		SnapshotEncryption:         o.SnapshotEncryption,
+		SnapshotName:               o.RestoreSnapshotName,
+		PreservePrefixes:           splitPrefixes(o.PreservePrefixes),
+		HealthCheckInterval:        o.HealthCheckInterval,
+		HealthCheckTimeout:         o.HealthCheckTimeout,
+		StopTimeout:                o.StopTimeout,
+		DefragInterval:             o.DefragInterval,
+		QuotaBackendBytes:          o.QuotaBackendBytes,
+		CertRenewBefore:            o.CertRenewBefore,
+		ClientSecurity: client.SecurityConfig{
+			CertFile:      o.ServerCert,
+			KeyFile:       o.ServerKey,
+			TrustedCAFile: o.CACert,
+		},
+		PeerSecurity: client.SecurityConfig{
+			CertFile:      o.PeerCert,
+			KeyFile:       o.PeerKey,
+			TrustedCAFile: o.CACert,
+		},
+		CACertFile:      o.CACert,
+		CAKeyFile:       o.CAKey,
+		CACertHash:      o.CACertHash,
+		CACert:          certs.CACert,
+		CAKey:           certs.CAKey,
+		PeerCert:        certs.PeerCert,
+		PeerKey:         certs.PeerKey,
+		ServerCert:      certs.ServerCert,
+		ServerKey:       certs.ServerKey,
+		GossipSecretKey: gossipSecretKey,
+		PeerGetter:      peerGetter,
+		Snapshotter:     snapshotter,
+		Debug:           globalOptions.verbose,
+	}, nil
 }
 
 func parsePeerDiscovery(s string) (string, []discovery.KeyValue) {
@@ -175,15 +399,84 @@ func parsePeerDiscovery(s string) (string, []discovery.KeyValue) {
 	return parts[0], kvs
 }
 
+func parseBootstrapWarnings(s string) ([]float64, error) {
+	fractions := make([]float64, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid bootstrap warning: %#v", part)
+		}
+		fractions = append(fractions, f)
+	}
+	return fractions, nil
+}
+
+func splitPrefixes(s string) []string {
+	prefixes := make([]string, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefixes = append(prefixes, part)
+	}
+	return prefixes
+}
+
+// certData is the decoded inline certificate material.
+type certData struct {
+	CACert, CAKey         []byte
+	PeerCert, PeerKey     []byte
+	ServerCert, ServerKey []byte
+}
+
+// decodeCertData decodes any base64-encoded certificate material provided
+// through the environment.
+func decodeCertData(o *runOptions) (*certData, error) {
+	certs := &certData{}
+	vars := []struct {
+		env  string
+		data string
+		dst  *[]byte
+	}{
+		{"E2D_CA_CERT_DATA", o.CACertData, &certs.CACert},
+		{"E2D_CA_KEY_DATA", o.CAKeyData, &certs.CAKey},
+		{"E2D_PEER_CERT_DATA", o.PeerCertData, &certs.PeerCert},
+		{"E2D_PEER_KEY_DATA", o.PeerKeyData, &certs.PeerKey},
+		{"E2D_SERVER_CERT_DATA", o.ServerCertData, &certs.ServerCert},
+		{"E2D_SERVER_KEY_DATA", o.ServerKeyData, &certs.ServerKey},
+	}
+	for _, v := range vars {
+		if v.data == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode %s", v.env)
+		}
+		*v.dst = data
+	}
+	return certs, nil
+}
+
 func getPeerGetter(o *runOptions) (discovery.PeerGetter, error) {
 	method, kvs := parsePeerDiscovery(o.PeerDiscovery)
 	log.Info("peer-discovery", zap.String("method", method), zap.String("kvs", fmt.Sprintf("%v", kvs)))
 	switch strings.ToLower(method) {
 	case "aws-autoscaling-group":
-		// TODO(chris): needs to take access key/secret
-		return discovery.NewAmazonAutoScalingPeerGetter()
+		return discovery.NewAmazonAutoScalingPeerGetter(&discovery.AmazonConfig{
+			AccessKey: o.AWSAccessKey,
+			SecretKey: o.AWSSecretKey,
+		})
 	case "ec2-tags":
-		return discovery.NewAmazonInstanceTagPeerGetter(kvs)
+		return discovery.NewAmazonInstanceTagPeerGetter(&discovery.AmazonConfig{
+			AccessKey: o.AWSAccessKey,
+			SecretKey: o.AWSSecretKey,
+		}, kvs)
 	case "do-tags":
 		if len(kvs) == 0 {
 			return nil, errors.New("must provide at least 1 tag")
@@ -192,8 +485,25 @@ func getPeerGetter(o *runOptions) (discovery.PeerGetter, error) {
 			AccessToken: o.DOAccessToken,
 			TagValue:    kvs[0].Key,
 		})
+	case "gcp-instance-group":
+		return discovery.NewGCPInstanceGroupPeerGetter()
 	case "k8s-labels":
-		return nil, errors.New("peer getter not yet implemented")
+		return discovery.NewKubernetesPeerGetter(&discovery.KubernetesConfig{
+			Kubeconfig: o.Kubeconfig,
+			Namespace:  o.K8sNamespace,
+			Labels:     kvs,
+		})
+	case "dns-srv":
+		if len(kvs) == 0 {
+			return nil, errors.New("must provide a domain")
+		}
+		var service string
+		for _, kv := range kvs[1:] {
+			if kv.Key == "service" {
+				service = kv.Value
+			}
+		}
+		return discovery.NewDNSSRVPeerGetter(kvs[0].Key, service)
 	}
 	return &discovery.NoopGetter{}, nil
 }
@@ -207,13 +517,15 @@ func getInitialBootstrapAddrs(o *runOptions, peerGetter discovery.PeerGetter) ([
 	}
 
 	if o.RequiredClusterSize > 1 && len(baddrs) == 0 {
-		addrs, err := peerGetter.GetAddrs(context.Background())
+		ctx, cancel := context.WithTimeout(context.Background(), o.PeerDiscoveryTimeout)
+		defer cancel()
+		addrs, err := peerGetter.GetAddrs(ctx)
 		if err != nil {
 			return nil, err
 		}
 		log.Debugf("cloud provided addresses: %v", addrs)
 		for _, addr := range addrs {
-			baddrs = append(baddrs, fmt.Sprintf("%s:%d", addr, manager.DefaultGossipPort))
+			baddrs = append(baddrs, netutil.JoinHostPort(addr, manager.DefaultGossipPort))
 		}
 		log.Debugf("bootstrap addrs: %v", baddrs)
 		if len(baddrs) == 0 {
@@ -227,27 +539,62 @@ func getSnapshotProvider(o *runOptions) (snapshot.Snapshotter, error) {
 	if o.SnapshotBackupURL == "" {
 		return nil, nil
 	}
-	u, err := snapshot.ParseSnapshotBackupURL(o.SnapshotBackupURL)
+	if o.SnapshotRetention < 0 {
+		return nil, errors.Errorf("snapshot retention must not be negative: %d", o.SnapshotRetention)
+	}
+	urls := strings.Split(o.SnapshotBackupURL, ",")
+	if len(urls) == 1 {
+		return newSnapshotter(o, urls[0])
+	}
+
+	// snapshots are mirrored to each of multiple urls
+	snapshotters := make([]snapshot.Snapshotter, 0)
+	for _, rawurl := range urls {
+		s, err := newSnapshotter(o, strings.TrimSpace(rawurl))
+		if err != nil {
+			return nil, err
+		}
+		snapshotters = append(snapshotters, s)
+	}
+	return snapshot.NewMultiSnapshotter(snapshotters...), nil
+}
+
+func newSnapshotter(o *runOptions, rawurl string) (snapshot.Snapshotter, error) {
+	u, err := snapshot.ParseSnapshotBackupURL(rawurl)
 	if err != nil {
 		return nil, err
 	}
 
 	switch u.Type {
 	case snapshot.FileType:
-		return snapshot.NewFileSnapshotter(u.Path)
+		return snapshot.NewFileSnapshotter(u.Path, o.SnapshotRetention)
 	case snapshot.S3Type:
 		return snapshot.NewAmazonSnapshotter(&snapshot.AmazonConfig{
-			RoleSessionName: o.AWSRoleSessionName,
+			RoleSessionName:      o.AWSRoleSessionName,
+			Bucket:               u.Bucket,
+			Key:                  u.Path,
+			Retention:            o.SnapshotRetention,
+			PartSize:             o.AWSUploadPartSize,
+			Concurrency:          o.AWSUploadConcurrency,
+			UploadTimeout:        o.AWSUploadTimeout,
+			ServerSideEncryption: o.AWSSSE,
+			SSEKMSKeyID:          o.AWSSSEKMSKeyID,
+		})
+	case snapshot.GCSType:
+		return snapshot.NewGCSSnapshotter(&snapshot.GCSConfig{
 			Bucket:          u.Bucket,
-			Key:             u.Path,
+			Object:          u.Path,
+			CredentialsFile: o.GCSCredentialsFile,
+			Retention:       o.SnapshotRetention,
 		})
 	case snapshot.SpacesType:
 		return snapshot.NewDigitalOceanSnapshotter(&snapshot.DigitalOceanConfig{
-			SpacesURL:       o.SnapshotBackupURL,
+			SpacesURL:       rawurl,
 			SpacesAccessKey: o.DOSpacesKey,
 			SpacesSecretKey: o.DOSpacesSecret,
+			Retention:       o.SnapshotRetention,
 		})
 	default:
-		return nil, errors.Errorf("unsupported snapshot url format: %#v", o.SnapshotBackupURL)
+		return nil, errors.Errorf("unsupported snapshot url format: %#v", rawurl)
 	}
 }
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/cmdutil"
+	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/criticalstack/e2d/pkg/manager"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	// the snapshot backup flags are shared with the run command, so that
+	// getSnapshotProvider can be reused
+	o := &runOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "manage e2d snapshot backups",
+	}
+
+	cmd.PersistentFlags().StringVar(&o.SnapshotBackupURL, "snapshot-backup-url", "", "an absolute path to shared filesystem storage (like file:///etcd-backups) or cloud storage bucket (like s3://etcd-backups) for snapshot backups, or a comma-separated list to mirror snapshot backups to each")
+	cmd.PersistentFlags().IntVar(&o.SnapshotRetention, "snapshot-retention", 0, "number of timestamped snapshot backups to keep (0 overwrites a single snapshot backup)")
+	cmd.PersistentFlags().StringVar(&o.CACert, "ca-cert", "", "")
+	cmd.PersistentFlags().StringVar(&o.CAKey, "ca-key", "", "ca key used to decrypt/encrypt snapshot backups")
+	cmd.PersistentFlags().StringVar(&o.AWSRoleSessionName, "aws-role-session-name", "", "")
+	cmd.PersistentFlags().Int64Var(&o.AWSUploadPartSize, "aws-upload-part-size", 0, "size in bytes of each part of a snapshot backup uploaded to s3 (defaults to 5MiB)")
+	cmd.PersistentFlags().IntVar(&o.AWSUploadConcurrency, "aws-upload-concurrency", 0, "number of parts of a snapshot backup uploaded to s3 concurrently (defaults to 5)")
+	cmd.PersistentFlags().DurationVar(&o.AWSUploadTimeout, "aws-upload-timeout", 1*time.Minute, "time a snapshot backup upload to s3 can go without making progress before it is canceled")
+	cmd.PersistentFlags().StringVar(&o.AWSSSE, "aws-sse", "", "server-side encryption of snapshot backups uploaded to s3 (AES256 or aws:kms)")
+	cmd.PersistentFlags().StringVar(&o.AWSSSEKMSKeyID, "aws-sse-kms-key-id", "", "KMS key used for server-side encryption of snapshot backups uploaded to s3 (implies --aws-sse=aws:kms)")
+	cmd.PersistentFlags().StringVar(&o.GCSCredentialsFile, "gcs-credentials-file", "", "path to a Google Cloud service account key used for gcs snapshot backups (defaults to application default credentials)")
+	cmd.PersistentFlags().StringVar(&o.DOSpacesKey, "do-spaces-key", "", "DigitalOcean spaces access key")
+	cmd.PersistentFlags().StringVar(&o.DOSpacesSecret, "do-spaces-secret", "", "DigitalOcean spaces secret")
+	if err := cmdutil.SetEnvs(o); err != nil {
+		log.Debug("cannot set environment variables", zap.Error(err))
+	}
+
+	cmd.AddCommand(
+		newSnapshotRestoreCmd(o),
+		newSnapshotSaveCmd(o),
+	)
+	return cmd
+}
+
+func newSnapshotRestoreCmd(o *runOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "restore the latest snapshot backup into a new data-dir",
+		Long: `Restore the latest snapshot backup into a new data-dir, without starting etcd.
+An older snapshot backup is restored instead with --restore-snapshot-name. The
+data-dir holds a single member, and is used by starting e2d run with the same
+--name, --data-dir and --peer-addr.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshotter, err := getSnapshotProvider(o)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if snapshotter == nil {
+				log.Fatal("must provide --snapshot-backup-url")
+			}
+			cfg := &manager.Config{
+				Name:     o.Name,
+				Dir:      o.DataDir,
+				Host:     o.Host,
+				PeerAddr: o.PeerAddr,
+
+				// not used for restoring, but must be valid addresses
+				ClientAddr: "0.0.0.0:2379",
+				GossipAddr: fmt.Sprintf("0.0.0.0:%d", manager.DefaultGossipPort),
+
+				PeerSecurity: client.SecurityConfig{
+					CertFile:      o.PeerCert,
+					KeyFile:       o.PeerKey,
+					TrustedCAFile: o.CACert,
+				},
+				CAKeyFile:    o.CAKey,
+				Snapshotter:  snapshotter,
+				SnapshotName: o.RestoreSnapshotName,
+				Debug:        globalOptions.verbose,
+			}
+			if err := manager.RestoreSnapshot(cfg); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			log.Info("restored snapshot",
+				zap.String("name", cfg.Name),
+				zap.String("peer-url", cfg.PeerURL.String()),
+			)
+			fmt.Println(cfg.Dir)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.Name, "name", "", "specify a name for the node")
+	cmd.Flags().StringVar(&o.DataDir, "data-dir", "", "etcd data-dir")
+	cmd.Flags().StringVar(&o.Host, "host", "", "host IPv4 (defaults to 127.0.0.1 if unset)")
+	cmd.Flags().StringVar(&o.PeerAddr, "peer-addr", "0.0.0.0:2380", "etcd peer addrress")
+	cmd.Flags().StringVar(&o.PeerCert, "peer-cert", "", "")
+	cmd.Flags().StringVar(&o.PeerKey, "peer-key", "", "")
+	cmd.Flags().StringVar(&o.RestoreSnapshotName, "restore-snapshot-name", "", "name of the snapshot backup to restore instead of the latest (like etcd-20200101T120000Z.snapshot)")
+	return cmd
+}
+
+func newSnapshotSaveCmd(o *runOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "save a snapshot backup of a running cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshotter, err := getSnapshotProvider(o)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if snapshotter == nil {
+				log.Fatal("must provide --snapshot-backup-url")
+			}
+			rev, err := manager.SaveSnapshot(&manager.Config{
+				ClientAddr: o.ClientAddr,
+
+				// not used for saving, but must be valid addresses
+				PeerAddr:   "0.0.0.0:2380",
+				GossipAddr: fmt.Sprintf("0.0.0.0:%d", manager.DefaultGossipPort),
+
+				ClientSecurity: client.SecurityConfig{
+					CertFile:      o.ServerCert,
+					KeyFile:       o.ServerKey,
+					TrustedCAFile: o.CACert,
+				},
+				CAKeyFile:           o.CAKey,
+				SnapshotCompression: o.SnapshotCompression,
+				SnapshotEncryption:  o.SnapshotEncryption,
+				Snapshotter:         snapshotter,
+				Debug:               globalOptions.verbose,
+			})
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			fmt.Println(rev)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.ClientAddr, "client-addr", "127.0.0.1:2379", "etcd client address")
+	cmd.Flags().StringVar(&o.ServerCert, "client-cert", "", "etcd client certificate")
+	cmd.Flags().StringVar(&o.ServerKey, "client-key", "", "etcd client private key")
+	cmd.Flags().BoolVar(&o.SnapshotCompression, "snapshot-compression", false, "compression snapshots with gzip")
+	cmd.Flags().BoolVar(&o.SnapshotEncryption, "snapshot-encryption", false, "encrypt snapshots with aes-256")
+	return cmd
+}
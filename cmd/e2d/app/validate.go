@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/criticalstack/e2d/pkg/cmdutil"
+	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newValidateConfigCmd() *cobra.Command {
+	o := &runOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "validate-config",
+		Short: "validate the run configuration without starting etcd",
+		Long: `Validate the run configuration without starting etcd. The same flags and
+environment variables as e2d run are accepted. Peer discovery is set up, and the
+client, peer and gossip addresses are checked to be available for binding.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := setFlagsFromConfigFile(cmd, o); err != nil {
+				fmt.Printf("invalid configuration: %v\n", err)
+				os.Exit(1)
+			}
+			if err := validateConfig(o); err != nil {
+				fmt.Printf("invalid configuration: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("configuration is valid")
+		},
+	}
+
+	addRunFlags(cmd, o)
+	if err := cmdutil.SetEnvs(o); err != nil {
+		log.Debug("cannot set environment variables", zap.Error(err))
+	}
+
+	return cmd
+}
+
+func validateConfig(o *runOptions) error {
+	cfg, err := newManagerConfig(o)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	fmt.Printf("name:        %s\n", cfg.Name)
+	fmt.Printf("client-url:  %s\n", cfg.ClientURL.String())
+	fmt.Printf("peer-url:    %s\n", cfg.PeerURL.String())
+	fmt.Printf("gossip-addr: %s\n", cfg.GossipAddr)
+	if cfg.MetricsAddr != "" {
+		fmt.Printf("metrics-url: %s\n", cfg.MetricsURL.String())
+	}
+	fmt.Printf("bootstrap:   %v\n", cfg.BootstrapAddrs)
+	addrs := []struct {
+		name    string
+		network string
+		addr    string
+	}{
+		{"client-addr", "tcp", cfg.ClientAddr},
+		{"peer-addr", "tcp", cfg.PeerAddr},
+		{"gossip-addr", "tcp", cfg.GossipAddr},
+		{"gossip-addr", "udp", cfg.GossipAddr},
+		{"metrics-addr", "tcp", cfg.MetricsAddr},
+	}
+	for _, a := range addrs {
+		if a.addr == "" {
+			continue
+		}
+		if err := checkBindAddr(a.network, a.addr); err != nil {
+			return errors.Wrapf(err, "cannot bind %s %s", a.name, a.addr)
+		}
+	}
+	return nil
+}
+
+// checkBindAddr checks that the address is free by binding it, and releasing
+// it immediately.
+func checkBindAddr(network, addr string) error {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return err
+		}
+		return l.Close()
+	}
+}
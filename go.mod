@@ -6,18 +6,24 @@ require (
 	github.com/aws/aws-sdk-go v1.30.7
 	github.com/cloudflare/cfssl v1.4.1
 	github.com/coreos/go-semver v0.3.0 // indirect
+	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/digitalocean/go-metadata v0.0.0-20180111002115-15bd36e5f6f7
 	github.com/digitalocean/godo v1.34.0
 	github.com/fatih/color v1.7.0
 	github.com/gogo/protobuf v1.3.1
 	github.com/google/go-cmp v0.5.0
+	github.com/hashicorp/go-msgpack v0.5.3
 	github.com/hashicorp/memberlist v0.2.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.0.0
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.3
 	go.etcd.io/bbolt v1.3.5
 	go.etcd.io/etcd v0.5.0-alpha.5.0.20200707173218-d3a702a09d92
 	go.uber.org/zap v1.15.0
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	golang.org/x/text v0.3.3 // indirect
 	google.golang.org/grpc v1.29.1
+	sigs.k8s.io/yaml v1.1.0
 )
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+
+	"go.etcd.io/etcd/clientv3"
+)
+
+// BatchBuilder builds a set of operations that are committed together in a
+// single transaction. Operations are only applied if all of the conditions
+// provided with If are satisfied.
+type BatchBuilder struct {
+	c    *Client
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+// Batch returns a new BatchBuilder for this client.
+func (c *Client) Batch() *BatchBuilder {
+	return &BatchBuilder{c: c}
+}
+
+// If adds conditions that must all be true for the batch to be applied.
+func (b *BatchBuilder) If(cmps ...clientv3.Cmp) *BatchBuilder {
+	b.cmps = append(b.cmps, cmps...)
+	return b
+}
+
+// Put adds setting the value of a key to the batch.
+func (b *BatchBuilder) Put(key, value string) *BatchBuilder {
+	b.ops = append(b.ops, clientv3.OpPut(key, value))
+	return b
+}
+
+// Delete adds deleting a key to the batch.
+func (b *BatchBuilder) Delete(key string) *BatchBuilder {
+	b.ops = append(b.ops, clientv3.OpDelete(key))
+	return b
+}
+
+// Commit applies the batch in a single transaction. It returns false, without
+// applying any operations, when a condition is not satisfied.
+func (b *BatchBuilder) Commit(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.c.cfg.requestTimeout())
+	defer cancel()
+
+	resp, err := b.c.Client.Txn(ctx).If(b.cmps...).Then(b.ops...).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
@@ -0,0 +1,67 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+
+	"github.com/criticalstack/e2d/pkg/client"
+)
+
+func TestBatchConditional(t *testing.T) {
+	c := newTestClient(t)
+
+	if err := c.Set("/batch/a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("/batch/b", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the condition fails so none of the operations are applied
+	ok, err := c.Batch().
+		If(clientv3.Compare(clientv3.Value("/batch/a"), "=", "0")).
+		Put("/batch/c", "3").
+		Delete("/batch/b").
+		Commit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected batch condition to fail")
+	}
+	if _, err := c.Get("/batch/c"); errors.Cause(err) != client.ErrKeyNotFound {
+		t.Fatalf("expected %v, received %v", client.ErrKeyNotFound, err)
+	}
+	if _, err := c.Get("/batch/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = c.Batch().
+		If(clientv3.Compare(clientv3.Value("/batch/a"), "=", "1")).
+		If(clientv3.Compare(clientv3.Version("/batch/c"), "=", 0)).
+		Put("/batch/a", "2").
+		Put("/batch/c", "3").
+		Delete("/batch/b").
+		Commit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected batch condition to succeed")
+	}
+	for key, expected := range map[string]string{"/batch/a": "2", "/batch/c": "3"} {
+		v, err := c.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != expected {
+			t.Fatalf("%s: expected %#v, received %#v", key, expected, string(v))
+		}
+	}
+	if _, err := c.Get("/batch/b"); errors.Cause(err) != client.ErrKeyNotFound {
+		t.Fatalf("expected %v, received %v", client.ErrKeyNotFound, err)
+	}
+}
@@ -22,22 +22,72 @@ var ErrKeyNotFound = errors.New("key not found")
 type Client struct {
 	*clientv3.Client
 	cfg *Config
+
+	// clients for each endpoint, used to hedge reads
+	hedgeClients []*clientv3.Client
 }
 
 func New(cfg *Config) (*Client, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
-	tlsConfig := &tls.Config{InsecureSkipVerify: true} //nolint:gosec
-	if !cfg.SecurityConfig.TLSInfo().Empty() {
-		var err error
-		tlsConfig, err = cfg.SecurityConfig.TLSInfo().ClientConfig()
-		if err != nil {
-			return nil, err
+	tlsConfig, err := newTLSConfig(cfg.SecurityConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClientv3(cfg, cfg.ClientURLs, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		Client: client,
+		cfg:    cfg,
+	}
+	if cfg.HedgeDelay > 0 && len(cfg.ClientURLs) > 1 {
+		endpoints := make([]clientv3.KV, 0)
+		for _, u := range cfg.ClientURLs {
+			hc, err := newClientv3(cfg, []string{u}, tlsConfig)
+			if err != nil {
+				c.Close()
+				return nil, err
+			}
+			c.hedgeClients = append(c.hedgeClients, hc)
+			endpoints = append(endpoints, hc.KV)
+		}
+		client.KV = &hedgedKV{
+			KV:        client.KV,
+			endpoints: endpoints,
+			delay:     cfg.HedgeDelay,
+		}
+	}
+	if cfg.NoLeaderRetryTimeout > 0 {
+		client.KV = &noLeaderRetryKV{
+			KV:             client.KV,
+			timeout:        cfg.NoLeaderRetryTimeout,
+			attemptTimeout: cfg.Timeout,
 		}
 	}
-	client, err := clientv3.New(clientv3.Config{
-		Endpoints:        cfg.ClientURLs,
+	return c, nil
+}
+
+// newTLSConfig returns the TLS configuration used to connect to https
+// endpoints, or nil when TLS is not configured, in which case https endpoints
+// are verified with the system CA pool.
+func newTLSConfig(sc SecurityConfig) (*tls.Config, error) {
+	if !sc.Enabled() && !sc.InsecureSkipVerify {
+		return nil, nil
+	}
+	tlsConfig, err := sc.TLSInfo().ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.InsecureSkipVerify = sc.InsecureSkipVerify || sc.AutoTLS //nolint:gosec
+	return tlsConfig, nil
+}
+
+func newClientv3(cfg *Config, endpoints []string, tlsConfig *tls.Config) (*clientv3.Client, error) {
+	return clientv3.New(clientv3.Config{
+		Endpoints:        endpoints,
 		DialTimeout:      cfg.Timeout,
 		TLS:              tlsConfig,
 		AutoSyncInterval: cfg.AutoSyncInterval,
@@ -48,21 +98,23 @@ func New(cfg *Config) (*Client, error) {
 			OutputPaths:   []string{"/dev/null"},
 		},
 	})
-	if err != nil {
-		return nil, err
-	}
-	c := &Client{
-		Client: client,
-		cfg:    cfg,
-	}
-	return c, nil
 }
 
-func (c *Client) get(key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
-	defer cancel()
+// Close closes the client, including any clients used to hedge reads.
+func (c *Client) Close() error {
+	for _, hc := range c.hedgeClients {
+		if err := hc.Close(); err != nil {
+			log.Debug("cannot close hedge client", zap.Error(err))
+		}
+	}
+	return c.Client.Close()
+}
 
-	resp, err := c.Client.Get(ctx, key, opts...)
+func (c *Client) get(key string, opts ...clientv3.OpOption) (resp *clientv3.GetResponse, err error) {
+	err = c.retry(func(ctx context.Context) error {
+		resp, err = c.Client.Get(ctx, key, opts...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +140,47 @@ func (c *Client) GetN(key string) (int64, error) {
 	return strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64)
 }
 
+// maxTxnOps is the maximum number of operations in a transaction, which is
+// the default limit of the etcd server.
+const maxTxnOps = 128
+
+// GetMany gets the values of the keys in a single transaction, rather than a
+// round trip for each key, returning a map of the keys found to their values.
+// Keys that are not found are omitted rather than returning ErrKeyNotFound.
+// More than maxTxnOps keys are read in several transactions, all at the
+// revision of the first, so that the values are consistent with each other.
+func (c *Client) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	var rev int64
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		ops := make([]clientv3.Op, 0, n)
+		for _, key := range keys[:n] {
+			ops = append(ops, clientv3.OpGet(key, clientv3.WithRev(rev)))
+		}
+		keys = keys[n:]
+
+		var resp *clientv3.TxnResponse
+		err := c.retry(func(ctx context.Context) (err error) {
+			resp, err = c.Client.Txn(ctx).Then(ops...).Commit()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		rev = resp.Header.Revision
+		for _, r := range resp.Responses {
+			for _, kv := range r.GetResponseRange().Kvs {
+				values[string(kv.Key)] = kv.Value
+			}
+		}
+	}
+	return values, nil
+}
+
 // MustGet blocks until the context is cancelled, an error is received, or
 // the key is present. Returns the value of the key once present.
 func (c *Client) MustGet(ctx context.Context, key string) ([]byte, error) {
@@ -121,11 +214,10 @@ func (c *Client) MustGet(ctx context.Context, key string) ([]byte, error) {
 }
 
 func (c *Client) Set(key, value string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
-	defer cancel()
-
-	_, err := c.Client.Put(ctx, key, value)
-	return err
+	return c.retry(func(ctx context.Context) error {
+		_, err := c.Client.Put(ctx, key, value)
+		return err
+	})
 }
 
 func (c *Client) SetOnce(ctx context.Context, key, value string) (bool, error) {
@@ -140,6 +232,21 @@ func (c *Client) SetOnce(ctx context.Context, key, value string) (bool, error) {
 	return resp.Succeeded, nil
 }
 
+// CompareAndSwap sets the key to newVal only if its current value is oldVal,
+// returning whether the value was swapped. A key that does not exist is never
+// swapped, since it has no value to compare (see SetOnce).
+func (c *Client) CompareAndSwap(ctx context.Context, key, oldVal, newVal string) (bool, error) {
+	resp, err := c.Client.Txn(ctx).If(
+		clientv3.Compare(clientv3.Value(key), "=", oldVal),
+	).Then(
+		clientv3.OpPut(key, newVal),
+	).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
 func (c *Client) Count(key string) (int64, error) {
 	resp, err := c.get(key, clientv3.WithPrefix(), clientv3.WithCountOnly())
 	if err != nil && errors.Cause(err) != ErrKeyNotFound {
@@ -164,6 +271,29 @@ func (c *Client) Prefix(key string) ([]*mvccpb.KeyValue, error) {
 	return resp.Kvs, nil
 }
 
+// Delete deletes the key. Deleting a key that does not exist is not an error.
+func (c *Client) Delete(key string) error {
+	_, err := c.delete(key)
+	return err
+}
+
+// DeletePrefix deletes every key with the prefix, returning the number of keys
+// deleted.
+func (c *Client) DeletePrefix(key string) (int64, error) {
+	return c.delete(key, clientv3.WithPrefix())
+}
+
+func (c *Client) delete(key string, opts ...clientv3.OpOption) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.requestTimeout())
+	defer cancel()
+
+	resp, err := c.Client.Delete(ctx, key, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}
+
 func (c *Client) Lock(key string, timeout time.Duration) (context.CancelFunc, error) {
 	// The session uses a low TTL to ensure that keep alives are sent more
 	// frequently than the default. This ensures that a failed node with
@@ -189,26 +319,95 @@ func (c *Client) Lock(key string, timeout time.Duration) (context.CancelFunc, er
 	return unlock, nil
 }
 
+// number of times Incr attempts the increment transaction before falling back
+// to a lock, so that contending clients take turns
+const incrAttempts = 3
+
+// Incr increments the integer value of the key, starting from 1 for a key that
+// does not exist, and returns the new value. The value is read and then
+// written in a transaction that only succeeds when the key is unchanged, so
+// an uncontended increment takes two round trips without a session or lock.
+// When the key is changed by another client between the two, the transaction
+// returns the current value to retry with, and after incrAttempts the
+// increment is retried while holding a lock on the key.
 func (c *Client) Incr(key string, timeout time.Duration) (int64, error) {
-	unlock, err := c.Lock(key, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := c.Client.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	var kv *mvccpb.KeyValue
+	if len(resp.Kvs) > 0 {
+		kv = resp.Kvs[0]
+	}
+	for i := 0; i < incrAttempts; i++ {
+		id, ok, current, err := c.incr(ctx, key, kv)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return id, nil
+		}
+		kv = current
+	}
+	deadline, _ := ctx.Deadline()
+	unlock, err := c.Lock(key, time.Until(deadline))
 	if err != nil {
 		return 0, err
 	}
 	defer unlock()
 
-	id, err := c.GetN(key)
-	if err != nil && errors.Cause(err) != ErrKeyNotFound {
-		return 0, err
+	// the lock is only held by clients falling back to it, so the increment
+	// can still be contended by clients on their first attempts
+	for {
+		id, ok, current, err := c.incr(ctx, key, kv)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return id, nil
+		}
+		kv = current
+	}
+}
+
+// incr increments the value of kv, which is nil when the key does not exist,
+// in a transaction that only succeeds when the key has not been modified
+// since it was read. The new value is returned when it succeeds, otherwise
+// the current kv is returned so that it can be retried.
+func (c *Client) incr(ctx context.Context, key string, kv *mvccpb.KeyValue) (int64, bool, *mvccpb.KeyValue, error) {
+	var id int64
+	cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+	if kv != nil {
+		var err error
+		id, err = strconv.ParseInt(string(kv.Value), 10, 64)
+		if err != nil {
+			return 0, false, nil, errors.Wrapf(err, "cannot increment value of %#v", key)
+		}
+		cmp = clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)
 	}
 	id++
-	if err := c.Set(key, strconv.FormatInt(id, 10)); err != nil {
-		return 0, err
+	resp, err := c.Client.Txn(ctx).If(cmp).Then(
+		clientv3.OpPut(key, strconv.FormatInt(id, 10)),
+	).Else(
+		clientv3.OpGet(key),
+	).Commit()
+	if err != nil {
+		return 0, false, nil, err
+	}
+	if resp.Succeeded {
+		return id, true, nil, nil
+	}
+	if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+		return 0, false, kvs[0], nil
 	}
-	return id, nil
+	return 0, false, nil, nil
 }
 
 func (c *Client) IsHealthy(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.requestTimeout())
 	defer cancel()
 
 	_, err := c.Client.Get(ctx, "health", clientv3.WithSerializable())
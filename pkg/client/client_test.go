@@ -0,0 +1,409 @@
+package client_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/criticalstack/e2d/pkg/manager"
+)
+
+var testLong = flag.Bool("test.long", false, "enable running larger tests")
+
+func init() {
+	for _, arg := range os.Args[1:] {
+		if arg == "-test.long" {
+			*testLong = true
+		}
+	}
+	log.SetLevel(zapcore.DebugLevel)
+}
+
+var (
+	testServerOnce sync.Once
+	testServerErr  error
+)
+
+// startTestServer starts a single-node cluster shared by all tests and waits
+// for it to become healthy.
+func startTestServer() error {
+	if err := os.RemoveAll("testdata"); err != nil {
+		return err
+	}
+	m, err := manager.New(&manager.Config{
+		Name:                "node1",
+		ClientAddr:          ":2679",
+		PeerAddr:            ":2680",
+		GossipAddr:          ":7990",
+		Dir:                 filepath.Join("testdata", "node1"),
+		RequiredClusterSize: 1,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+		EtcdLogLevel:        zapcore.WarnLevel,
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := m.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	c, err := client.New(&client.Config{
+		ClientURLs: []string{"http://127.0.0.1:2679"},
+		Timeout:    5 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for {
+		if err := c.IsHealthy(ctx); err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// newTestClient returns a client connected to the shared test server, which
+// is started on first use. Tests using it are only run with -test.long.
+func newTestClient(t testing.TB) *client.Client {
+	if !*testLong {
+		t.Skip()
+	}
+	testServerOnce.Do(func() {
+		testServerErr = startTestServer()
+	})
+	if testServerErr != nil {
+		t.Fatal(testServerErr)
+	}
+	c, err := client.New(&client.Config{
+		ClientURLs: []string{"http://127.0.0.1:2679"},
+		Timeout:    5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestDelete(t *testing.T) {
+	c := newTestClient(t)
+
+	if err := c.Set("/delete/a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("/delete/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("/delete/a"); errors.Cause(err) != client.ErrKeyNotFound {
+		t.Fatalf("expected %v, received %v", client.ErrKeyNotFound, err)
+	}
+
+	// deleting a key that does not exist is not an error
+	if err := c.Delete("/delete/a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	c := newTestClient(t)
+
+	for _, key := range []string{"/prefix/a", "/prefix/b", "/prefix/c/d", "/prefixed"} {
+		if err := c.Set(key, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := c.DeletePrefix("/prefix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 keys deleted, received %d", n)
+	}
+	n, err = c.DeletePrefix("/prefix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected 0 keys deleted, received %d", n)
+	}
+	if _, err := c.Get("/prefixed"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	c := newTestClient(t)
+
+	ctx := context.Background()
+	ok, err := c.CompareAndSwap(ctx, "/cas/a", "", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected a key that does not exist to not be swapped")
+	}
+	if err := c.Set("/cas/a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		oldVal   string
+		newVal   string
+		expected bool
+		value    string
+	}{
+		{"mismatch", "2", "3", false, "1"},
+		{"match", "1", "2", true, "2"},
+		{"stale", "1", "3", false, "2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := c.CompareAndSwap(ctx, "/cas/a", tc.oldVal, tc.newVal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.expected {
+				t.Fatalf("expected swapped %v, received %v", tc.expected, ok)
+			}
+			v, err := c.Get("/cas/a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(v) != tc.value {
+				t.Fatalf("expected %#v, received %#v", tc.value, string(v))
+			}
+		})
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	c := newTestClient(t)
+
+	for key, value := range map[string]string{"/getmany/a": "1", "/getmany/b": "2", "/getmany/c": "3"} {
+		if err := c.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	values, err := c.GetMany([]string{"/getmany/a", "/getmany/missing", "/getmany/c", "/getmany/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{
+		"/getmany/a": []byte("1"),
+		"/getmany/c": []byte("3"),
+	}
+	if diff := cmp.Diff(expected, values); diff != "" {
+		t.Errorf("values: (-want +got)\n%s", diff)
+	}
+
+	values, err = c.GetMany(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 0 {
+		t.Fatalf("expected no values, received %v", values)
+	}
+}
+
+func TestGetManyExceedsMaxTxnOps(t *testing.T) {
+	c := newTestClient(t)
+
+	keys := make([]string, 0)
+	expected := make(map[string][]byte)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("/getmany/large/%03d", i)
+		keys = append(keys, key)
+
+		// every third key is missing
+		if i%3 == 0 {
+			continue
+		}
+		if err := c.Set(key, strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		expected[key] = []byte(strconv.Itoa(i))
+	}
+	values, err := c.GetMany(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expected, values); diff != "" {
+		t.Errorf("values: (-want +got)\n%s", diff)
+	}
+}
+
+// incrLocked is the implementation of Incr before it used a transaction,
+// holding a lock on the key while the value is read and written.
+func incrLocked(c *client.Client, key string, timeout time.Duration) (int64, error) {
+	unlock, err := c.Lock(key, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	id, err := c.GetN(key)
+	if err != nil && errors.Cause(err) != client.ErrKeyNotFound {
+		return 0, err
+	}
+	id++
+	if err := c.Set(key, strconv.FormatInt(id, 10)); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func TestIncr(t *testing.T) {
+	c := newTestClient(t)
+
+	if _, err := c.DeletePrefix("/incr/"); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		id, err := c.Incr("/incr/a", 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != i {
+			t.Fatalf("expected %d, received %d", i, id)
+		}
+	}
+
+	// a deleted key starts again from 1
+	if err := c.Delete("/incr/a"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := c.Incr("/incr/a", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatalf("expected %d, received %d", 1, id)
+	}
+
+	if err := c.Set("/incr/b", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Incr("/incr/b", 5*time.Second); err == nil {
+		t.Fatal("expected error incrementing a value that is not an integer")
+	}
+}
+
+func TestIncrConcurrent(t *testing.T) {
+	c := newTestClient(t)
+
+	if err := c.Delete("/incr/concurrent"); err != nil {
+		t.Fatal(err)
+	}
+
+	// each goroutine has its own client, so that the increments are not
+	// serialized by a single connection
+	const goroutines, increments = 10, 20
+	var wg sync.WaitGroup
+	ids := make(chan int64, goroutines*increments)
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(c *client.Client) {
+			defer wg.Done()
+
+			for j := 0; j < increments; j++ {
+				id, err := c.Incr("/incr/concurrent", 30*time.Second)
+				if err != nil {
+					errs <- err
+					return
+				}
+				ids <- id
+			}
+		}(newTestClient(t))
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// every value is returned exactly once, with none skipped
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("value %d returned more than once", id)
+		}
+		seen[id] = true
+	}
+	for i := int64(1); i <= goroutines*increments; i++ {
+		if !seen[i] {
+			t.Fatalf("value %d not returned", i)
+		}
+	}
+	n, err := c.GetN("/incr/concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != goroutines*increments {
+		t.Fatalf("expected %d, received %d", goroutines*increments, n)
+	}
+}
+
+func BenchmarkIncr(b *testing.B) {
+	c := newTestClient(b)
+
+	b.Run("txn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := c.Incr("/incr/bench/txn", 5*time.Second); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("lock", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := incrLocked(c, "/incr/bench/lock", 5*time.Second); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("txn parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := c.Incr("/incr/bench/txn-parallel", 30*time.Second); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("lock parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := incrLocked(c, "/incr/bench/lock-parallel", 30*time.Second); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
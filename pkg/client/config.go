@@ -13,6 +13,13 @@ type SecurityConfig struct {
 	CertAuth      bool
 	TrustedCAFile string
 	AutoTLS       bool
+
+	// InsecureSkipVerify disables verifying the certificate chain and host
+	// name of the server when connecting over TLS. Otherwise, the server is
+	// verified with TrustedCAFile, or the system CA pool when not set.
+	// Verification is always skipped with AutoTLS, since the certificates it
+	// generates are self-signed.
+	InsecureSkipVerify bool
 }
 
 func (sc SecurityConfig) Enabled() bool {
@@ -35,6 +42,22 @@ func (sc SecurityConfig) TLSInfo() transport.TLSInfo {
 	}
 }
 
+// RetryConfig configures retrying reads and writes that fail with a transient
+// error, such as during a leader election or a brief loss of connection. The
+// wait between attempts starts at InitialBackoff and doubles after each
+// attempt, up to MaxBackoff.
+type RetryConfig struct {
+	// total number of attempts, including the first, where zero or one
+	// disables retrying
+	MaxAttempts int
+
+	// defaults to 100ms
+	InitialBackoff time.Duration
+
+	// defaults to 2s
+	MaxBackoff time.Duration
+}
+
 type Config struct {
 	ClientURLs     []string
 	SecurityConfig SecurityConfig
@@ -45,11 +68,41 @@ type Config struct {
 	// not directly accessible (e.g. a terminating load balancer). This is
 	// disabled by default and can be enabled by passed a non-zero duration.
 	AutoSyncInterval time.Duration
+
+	// amount of time to retry requests that fail because the cluster has no
+	// leader, which is expected briefly during leader elections. Disabled
+	// when zero. Each attempt is still bounded by Timeout, so requests can
+	// take up to Timeout+NoLeaderRetryTimeout.
+	NoLeaderRetryTimeout time.Duration
+
+	// amount of time to wait for a read before sending the same read to a
+	// second endpoint, returning whichever responds first. This reduces tail
+	// latency when a member is slow. Reads are only hedged when there are
+	// multiple ClientURLs, and hedging is disabled when zero.
+	HedgeDelay time.Duration
+
+	// Retry configures retrying Get and Set (and the other methods built on
+	// them) on transient failures. Requests are not retried by default.
+	Retry RetryConfig
+}
+
+// requestTimeout is the total amount of time allowed for a request, including
+// any retries while the cluster has no leader.
+func (c *Config) requestTimeout() time.Duration {
+	return c.Timeout + c.NoLeaderRetryTimeout
 }
 
 func (c *Config) validate() error {
 	if c.Timeout == 0 {
 		c.Timeout = 2 * time.Second
 	}
+	if c.Retry.MaxAttempts > 1 {
+		if c.Retry.InitialBackoff == 0 {
+			c.Retry.InitialBackoff = 100 * time.Millisecond
+		}
+		if c.Retry.MaxBackoff == 0 {
+			c.Retry.MaxBackoff = 2 * time.Second
+		}
+	}
 	return nil
 }
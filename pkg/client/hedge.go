@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// hedgedKV wraps a clientv3.KV to hedge reads. A read is sent to one endpoint,
+// and if it has not responded within delay (or fails), the same read is sent
+// to the next endpoint. Whichever response succeeds first is returned. Only
+// Get is hedged, all other requests use the wrapped KV.
+type hedgedKV struct {
+	clientv3.KV
+	endpoints []clientv3.KV
+	delay     time.Duration
+
+	// used to spread the first attempt of reads across the endpoints
+	next uint32
+}
+
+type hedgedResult struct {
+	resp *clientv3.GetResponse
+	err  error
+}
+
+func (kv *hedgedKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the results channel is buffered so that the slower request does not
+	// block once a response has been returned
+	results := make(chan hedgedResult, 2)
+	first := int(atomic.AddUint32(&kv.next, 1))
+	send := func(i int) {
+		endpoint := kv.endpoints[(first+i)%len(kv.endpoints)]
+		go func() {
+			resp, err := endpoint.Get(ctx, key, opts...)
+			results <- hedgedResult{resp, err}
+		}()
+	}
+	send(0)
+	sent, received := 1, 0
+
+	timer := time.NewTimer(kv.delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case r := <-results:
+			received++
+			if r.err == nil {
+				return r.resp, nil
+			}
+			if sent < 2 {
+				log.Debug("read failed, hedging to another endpoint", zap.String("key", key), zap.Error(r.err))
+				send(sent)
+				sent++
+				continue
+			}
+			if received == sent {
+				return nil, r.err
+			}
+		case <-timer.C:
+			if sent < 2 {
+				log.Debug("read is slow, hedging to another endpoint", zap.String("key", key), zap.Duration("delay", kv.delay))
+				send(sent)
+				sent++
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
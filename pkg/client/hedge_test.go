@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+)
+
+// endpointKV is a fake KV for a single endpoint that responds to reads after
+// delay.
+type endpointKV struct {
+	clientv3.KV
+	name  string
+	delay time.Duration
+	err   error
+	calls int32
+}
+
+func (kv *endpointKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	atomic.AddInt32(&kv.calls, 1)
+	select {
+	case <-time.After(kv.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if kv.err != nil {
+		return nil, kv.err
+	}
+	return &clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Key: []byte(key), Value: []byte(kv.name)}}}, nil
+}
+
+func TestHedgedKV(t *testing.T) {
+	cases := []struct {
+		name     string
+		first    *endpointKV
+		second   *endpointKV
+		expected string
+		hedged   bool
+	}{
+		{
+			name:     "fast first endpoint",
+			first:    &endpointKV{name: "first", delay: 0},
+			second:   &endpointKV{name: "second", delay: 0},
+			expected: "first",
+		},
+		{
+			name:     "slow first endpoint",
+			first:    &endpointKV{name: "first", delay: 5 * time.Second},
+			second:   &endpointKV{name: "second", delay: 0},
+			expected: "second",
+			hedged:   true,
+		},
+		{
+			name:     "failed first endpoint",
+			first:    &endpointKV{name: "first", err: errors.New("unavailable")},
+			second:   &endpointKV{name: "second", delay: 0},
+			expected: "second",
+			hedged:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// next is incremented before the first attempt, so starting from
+			// the last endpoint sends the first attempt to endpoints[0]
+			kv := &hedgedKV{
+				endpoints: []clientv3.KV{tc.first, tc.second},
+				delay:     50 * time.Millisecond,
+				next:      1,
+			}
+			start := time.Now()
+			resp, err := kv.Get(context.Background(), "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 1*time.Second {
+				t.Fatalf("expected hedged read to return quickly, took %v", elapsed)
+			}
+			if v := string(resp.Kvs[0].Value); v != tc.expected {
+				t.Fatalf("expected response from %#v, received %#v", tc.expected, v)
+			}
+			if hedged := atomic.LoadInt32(&tc.second.calls) > 0; hedged != tc.hedged {
+				t.Fatalf("expected hedged %v, received %v", tc.hedged, hedged)
+			}
+		})
+	}
+}
+
+func TestHedgedKVAllFailed(t *testing.T) {
+	kv := &hedgedKV{
+		endpoints: []clientv3.KV{
+			&endpointKV{err: errors.New("unavailable")},
+			&endpointKV{err: errors.New("unavailable")},
+		},
+		delay: 50 * time.Millisecond,
+	}
+	if _, err := kv.Get(context.Background(), "key"); err == nil {
+		t.Fatal("expected error when all endpoints fail")
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Member is a member of the etcd cluster.
+type Member struct {
+	ID         uint64
+	Name       string
+	PeerURLs   []string
+	ClientURLs []string
+
+	// whether the member is a non-voting raft learner
+	IsLearner bool
+}
+
+// Members returns the members of the etcd cluster. A member that was added but
+// has not yet started has no name or client URLs.
+func (c *Client) Members(ctx context.Context) ([]Member, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.requestTimeout())
+	defer cancel()
+
+	resp, err := c.MemberList(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list members")
+	}
+	members := make([]Member, 0, len(resp.Members))
+	for _, m := range resp.Members {
+		members = append(members, Member{
+			ID:         m.ID,
+			Name:       m.Name,
+			PeerURLs:   m.PeerURLs,
+			ClientURLs: m.ClientURLs,
+			IsLearner:  m.IsLearner,
+		})
+	}
+	return members, nil
+}
+
+// EndpointHealth checks the health of the client URL of every member of the
+// cluster, returning a map of each client URL to the error found, which is
+// nil when the endpoint is healthy. An endpoint is unhealthy when it cannot be
+// reached within the client timeout, has no leader, or has raised an alarm.
+// Members that have not yet started have no client URLs, so are not included.
+// An error is only returned when the members cannot be listed.
+func (c *Client) EndpointHealth(ctx context.Context) (map[string]error, error) {
+	members, err := c.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error)
+	)
+	for _, m := range members {
+		for _, u := range m.ClientURLs {
+			wg.Add(1)
+			go func(u string) {
+				defer wg.Done()
+
+				err := c.endpointHealth(ctx, u)
+				mu.Lock()
+				results[u] = err
+				mu.Unlock()
+			}(u)
+		}
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (c *Client) endpointHealth(ctx context.Context, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	resp, err := c.Status(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+
+	// the member reports having no leader, or any alarms raised, as errors
+	if len(resp.Errors) > 0 {
+		return errors.New(strings.Join(resp.Errors, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMembers(t *testing.T) {
+	c := newTestClient(t)
+
+	members, err := c.Members(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 {
+		t.Fatalf("expected 1 member, received %d: %+v", len(members), members)
+	}
+	m := members[0]
+	if m.Name != "node1" {
+		t.Fatalf("expected member %#v, received %#v", "node1", m.Name)
+	}
+	if m.ID == 0 || len(m.PeerURLs) != 1 || len(m.ClientURLs) != 1 {
+		t.Fatalf("expected member ID and URLs to be set: %+v", m)
+	}
+	if m.IsLearner {
+		t.Fatal("expected member to not be a learner")
+	}
+}
+
+func TestEndpointHealth(t *testing.T) {
+	c := newTestClient(t)
+
+	members, err := c.Members(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	health, err := c.EndpointHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(health) != 1 {
+		t.Fatalf("expected health of 1 endpoint, received %v", health)
+	}
+	u := members[0].ClientURLs[0]
+	err, ok := health[u]
+	if !ok {
+		t.Fatalf("expected health of endpoint %#v, received %v", u, health)
+	}
+	if err != nil {
+		t.Fatalf("expected endpoint %#v to be healthy, received %v", u, err)
+	}
+}
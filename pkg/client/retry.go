@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+var noLeaderRetryInterval = 250 * time.Millisecond
+
+// IsNoLeader returns true if the error was caused by the cluster not having a
+// leader, which is expected to resolve once a leader election completes.
+func IsNoLeader(err error) bool {
+	if err == nil {
+		return false
+	}
+	return rpctypes.Error(err) == rpctypes.ErrNoLeader
+}
+
+// IsTransient returns true if the error is expected to resolve on its own,
+// such as when the leader changed or the connection to the member was lost,
+// so the request can be retried.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch rpctypes.Error(err) {
+	case rpctypes.ErrLeaderChanged, rpctypes.ErrTimeout, rpctypes.ErrTimeoutDueToLeaderFail, rpctypes.ErrTimeoutDueToConnectionLost:
+		return true
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// transient, or the attempts configured with RetryConfig are exhausted. Each
+// attempt is given its own request timeout.
+func (c *Client) retry(fn func(context.Context) error) error {
+	backoff := c.cfg.Retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), c.cfg.requestTimeout())
+		err := fn(ctx)
+		cancel()
+		if !IsTransient(err) || attempt >= c.cfg.Retry.MaxAttempts {
+			return err
+		}
+		log.Debug("transient failure, retrying request",
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > c.cfg.Retry.MaxBackoff {
+			backoff = c.cfg.Retry.MaxBackoff
+		}
+	}
+}
+
+// noLeaderRetryKV wraps a clientv3.KV to retry requests that fail because the
+// cluster has no leader. Requests rejected for this reason were never
+// proposed, so they are safe to retry, including writes. Each attempt is
+// bounded by attemptTimeout, while retries continue for up to timeout.
+type noLeaderRetryKV struct {
+	clientv3.KV
+	timeout        time.Duration
+	attemptTimeout time.Duration
+}
+
+func (kv *noLeaderRetryKV) retry(ctx context.Context, fn func(context.Context) error) error {
+	deadline := time.Now().Add(kv.timeout)
+	for {
+		err := kv.attempt(ctx, fn)
+		if !IsNoLeader(err) || time.Now().After(deadline) {
+			return err
+		}
+		log.Debug("cluster has no leader, retrying request", zap.Error(err))
+		select {
+		case <-time.After(noLeaderRetryInterval):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (kv *noLeaderRetryKV) attempt(ctx context.Context, fn func(context.Context) error) error {
+	if kv.attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, kv.attemptTimeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+func (kv *noLeaderRetryKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (resp *clientv3.PutResponse, err error) {
+	err = kv.retry(ctx, func(ctx context.Context) error {
+		resp, err = kv.KV.Put(ctx, key, val, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (kv *noLeaderRetryKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.GetResponse, err error) {
+	err = kv.retry(ctx, func(ctx context.Context) error {
+		resp, err = kv.KV.Get(ctx, key, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (kv *noLeaderRetryKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.DeleteResponse, err error) {
+	err = kv.retry(ctx, func(ctx context.Context) error {
+		resp, err = kv.KV.Delete(ctx, key, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (kv *noLeaderRetryKV) Do(ctx context.Context, op clientv3.Op) (resp clientv3.OpResponse, err error) {
+	err = kv.retry(ctx, func(ctx context.Context) error {
+		resp, err = kv.KV.Do(ctx, op)
+		return err
+	})
+	return resp, err
+}
+
+func (kv *noLeaderRetryKV) Txn(ctx context.Context) clientv3.Txn {
+	return &noLeaderRetryTxn{ctx: ctx, kv: kv}
+}
+
+// noLeaderRetryTxn records the comparisons and operations of a transaction so
+// that a new transaction, with its own attempt timeout, can be committed for
+// each attempt.
+type noLeaderRetryTxn struct {
+	ctx     context.Context
+	kv      *noLeaderRetryKV
+	cmps    []clientv3.Cmp
+	thenOps []clientv3.Op
+	elseOps []clientv3.Op
+}
+
+func (t *noLeaderRetryTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *noLeaderRetryTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.thenOps = append(t.thenOps, ops...)
+	return t
+}
+
+func (t *noLeaderRetryTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.elseOps = append(t.elseOps, ops...)
+	return t
+}
+
+func (t *noLeaderRetryTxn) Commit() (resp *clientv3.TxnResponse, err error) {
+	err = t.kv.retry(t.ctx, func(ctx context.Context) error {
+		resp, err = t.kv.KV.Txn(ctx).If(t.cmps...).Then(t.thenOps...).Else(t.elseOps...).Commit()
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// noLeaderKV is a fake KV that fails the first failures requests with
+// ErrGRPCNoLeader, waiting delay before each response.
+type noLeaderKV struct {
+	clientv3.KV
+	failures int
+	calls    int
+	delay    time.Duration
+}
+
+func (kv *noLeaderKV) wait(ctx context.Context) error {
+	select {
+	case <-time.After(kv.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (kv *noLeaderKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if err := kv.wait(ctx); err != nil {
+		return nil, err
+	}
+	kv.calls++
+	if kv.calls <= kv.failures {
+		return nil, rpctypes.ErrGRPCNoLeader
+	}
+	return &clientv3.PutResponse{}, nil
+}
+
+func (kv *noLeaderKV) Txn(ctx context.Context) clientv3.Txn {
+	return &noLeaderTxn{ctx: ctx, kv: kv}
+}
+
+type noLeaderTxn struct {
+	clientv3.Txn
+	ctx context.Context
+	kv  *noLeaderKV
+}
+
+func (t *noLeaderTxn) If(cs ...clientv3.Cmp) clientv3.Txn { return t }
+
+func (t *noLeaderTxn) Then(ops ...clientv3.Op) clientv3.Txn { return t }
+
+func (t *noLeaderTxn) Else(ops ...clientv3.Op) clientv3.Txn { return t }
+
+func (t *noLeaderTxn) Commit() (*clientv3.TxnResponse, error) {
+	if err := t.kv.wait(t.ctx); err != nil {
+		return nil, err
+	}
+	t.kv.calls++
+	if t.kv.calls <= t.kv.failures {
+		return nil, rpctypes.ErrNoLeader
+	}
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func TestNoLeaderRetryKV(t *testing.T) {
+	defer func(d time.Duration) { noLeaderRetryInterval = d }(noLeaderRetryInterval)
+	noLeaderRetryInterval = 10 * time.Millisecond
+
+	cases := []struct {
+		name      string
+		failures  int
+		timeout   time.Duration
+		expectErr bool
+		calls     int
+	}{
+		{name: "transient no leader", failures: 2, timeout: 1 * time.Second, calls: 3},
+		{name: "no leader beyond timeout", failures: 1000, timeout: 50 * time.Millisecond, expectErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, op := range []struct {
+				name string
+				fn   func(clientv3.KV) error
+			}{
+				{"put", func(kv clientv3.KV) error {
+					_, err := kv.Put(context.Background(), "key", "value")
+					return err
+				}},
+				{"txn", func(kv clientv3.KV) error {
+					_, err := kv.Txn(context.Background()).Then(clientv3.OpPut("key", "value")).Commit()
+					return err
+				}},
+			} {
+				fake := &noLeaderKV{failures: tc.failures}
+				err := op.fn(&noLeaderRetryKV{KV: fake, timeout: tc.timeout})
+				if tc.expectErr {
+					if !IsNoLeader(err) {
+						t.Fatalf("%s: expected no leader error, received %v", op.name, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", op.name, err)
+				}
+				if fake.calls != tc.calls {
+					t.Fatalf("%s: expected %d calls, received %d", op.name, tc.calls, fake.calls)
+				}
+			}
+		})
+	}
+}
+
+func TestNoLeaderRetryBeyondTimeout(t *testing.T) {
+	defer func(d time.Duration) { noLeaderRetryInterval = d }(noLeaderRetryInterval)
+	noLeaderRetryInterval = 10 * time.Millisecond
+
+	cfg := &Config{
+		Timeout:              50 * time.Millisecond,
+		NoLeaderRetryTimeout: 1 * time.Second,
+	}
+	fake := &noLeaderKV{failures: 5, delay: 10 * time.Millisecond}
+	c := &Client{
+		Client: &clientv3.Client{
+			KV: &noLeaderRetryKV{
+				KV:             fake,
+				timeout:        cfg.NoLeaderRetryTimeout,
+				attemptTimeout: cfg.Timeout,
+			},
+		},
+		cfg: cfg,
+	}
+	start := time.Now()
+	if err := c.Set("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed <= cfg.Timeout {
+		t.Fatalf("expected retries to outlast Timeout %v, finished in %v", cfg.Timeout, elapsed)
+	}
+	if fake.calls != 6 {
+		t.Fatalf("expected 6 calls, received %d", fake.calls)
+	}
+}
+
+// transientKV is a fake KV that fails the first failures requests with err.
+type transientKV struct {
+	clientv3.KV
+	err      error
+	failures int
+	calls    int
+}
+
+func (kv *transientKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	kv.calls++
+	if kv.calls <= kv.failures {
+		return nil, kv.err
+	}
+	return &clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Key: []byte(key), Value: []byte("value")}}}, nil
+}
+
+func (kv *transientKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	kv.calls++
+	if kv.calls <= kv.failures {
+		return nil, kv.err
+	}
+	return &clientv3.PutResponse{}, nil
+}
+
+func TestRetry(t *testing.T) {
+	cases := []struct {
+		name      string
+		retry     RetryConfig
+		err       error
+		expectErr bool
+		calls     int
+	}{
+		{
+			name:  "leader changed",
+			retry: RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			err:   rpctypes.ErrGRPCLeaderChanged,
+			calls: 3,
+		},
+		{
+			name:  "timeout",
+			retry: RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			err:   rpctypes.ErrGRPCTimeout,
+			calls: 3,
+		},
+		{
+			name:  "unavailable",
+			retry: RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			err:   status.Error(codes.Unavailable, "connection refused"),
+			calls: 3,
+		},
+		{
+			name:      "attempts exhausted",
+			retry:     RetryConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			err:       rpctypes.ErrGRPCLeaderChanged,
+			expectErr: true,
+			calls:     2,
+		},
+		{
+			name:      "not transient",
+			retry:     RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			err:       rpctypes.ErrGRPCPermissionDenied,
+			expectErr: true,
+			calls:     1,
+		},
+		{
+			name:      "disabled by default",
+			err:       rpctypes.ErrGRPCLeaderChanged,
+			expectErr: true,
+			calls:     1,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, op := range []struct {
+				name string
+				fn   func(*Client) error
+			}{
+				{"get", func(c *Client) error {
+					_, err := c.Get("key")
+					return err
+				}},
+				{"set", func(c *Client) error {
+					return c.Set("key", "value")
+				}},
+			} {
+				cfg := &Config{Retry: tc.retry}
+				if err := cfg.validate(); err != nil {
+					t.Fatal(err)
+				}
+				fake := &transientKV{err: tc.err, failures: 2}
+				c := &Client{
+					Client: &clientv3.Client{KV: fake},
+					cfg:    cfg,
+				}
+				err := op.fn(c)
+				if tc.expectErr && err == nil {
+					t.Fatalf("%s: expected error", op.name)
+				}
+				if !tc.expectErr && err != nil {
+					t.Fatalf("%s: %v", op.name, err)
+				}
+				if fake.calls != tc.calls {
+					t.Fatalf("%s: expected %d calls, received %d", op.name, tc.calls, fake.calls)
+				}
+			}
+		})
+	}
+}
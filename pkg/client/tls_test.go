@@ -0,0 +1,66 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// the self-signed certificate of the server
+	f, err := ioutil.TempFile("", "ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		sc        SecurityConfig
+		expectErr bool
+	}{
+		{"rejected by default", SecurityConfig{}, true},
+		{"insecure skip verify", SecurityConfig{InsecureSkipVerify: true}, false},
+		{"auto tls", SecurityConfig{AutoTLS: true}, false},
+		{"trusted ca", SecurityConfig{TrustedCAFile: f.Name()}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsConfig, err := newTLSConfig(tc.sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tlsConfig == nil {
+				// the same as clientv3, which uses the system CA pool
+				tlsConfig = &tls.Config{}
+			}
+			conn, err := tls.Dial("tcp", strings.TrimPrefix(srv.URL, "https://"), tlsConfig)
+			if err == nil {
+				conn.Close()
+			}
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("expected self-signed server certificate to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+var watchRetryInterval = 250 * time.Millisecond
+
+// WatchEventType is the type of change to a watched key.
+type WatchEventType int
+
+const (
+	WatchPut WatchEventType = iota
+	WatchDelete
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	case WatchPut:
+		return "PUT"
+	case WatchDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// WatchEvent is a change to a key, received from WatchKey or WatchPrefix.
+type WatchEvent struct {
+	Type  WatchEventType
+	Key   string
+	Value []byte
+
+	// Revision is the revision of the change, which is the mod revision of
+	// the key for WatchPut.
+	Revision int64
+}
+
+// WatchKey streams the changes to the key, starting from the current revision,
+// until the context is cancelled. Unlike a clientv3 watch, the watch is
+// resumed from the last received revision when it is cancelled by the server.
+// If the revision has been compacted, the watch resumes from the compacted
+// revision, so changes made before it are not received. The channel is closed
+// when the context is cancelled, or the watch fails with an error that cannot
+// be resumed from, which is logged.
+func (c *Client) WatchKey(ctx context.Context, key string) (<-chan WatchEvent, error) {
+	return c.watch(ctx, key)
+}
+
+// WatchPrefix streams the changes to all keys with the prefix, the same as
+// WatchKey.
+func (c *Client) WatchPrefix(ctx context.Context, prefix string) (<-chan WatchEvent, error) {
+	return c.watch(ctx, prefix, clientv3.WithPrefix())
+}
+
+func (c *Client) watch(ctx context.Context, key string, opts ...clientv3.OpOption) (<-chan WatchEvent, error) {
+	// The current revision is read first, so that the changes received are
+	// consistent with a read made before the watch was started.
+	resp, err := c.get(key, append([]clientv3.OpOption{clientv3.WithCountOnly()}, opts...)...)
+	if err != nil && errors.Cause(err) != ErrKeyNotFound {
+		return nil, err
+	}
+	rev := resp.Header.Revision + 1
+
+	ch := make(chan WatchEvent)
+	go func() {
+		defer close(ch)
+
+		for {
+			wctx, cancel := context.WithCancel(ctx)
+			wch := c.Client.Watch(wctx, key, append([]clientv3.OpOption{clientv3.WithRev(rev)}, opts...)...)
+			var ok bool
+			rev, ok = c.forwardWatch(ctx, key, rev, wch, ch)
+			cancel()
+			if !ok || ctx.Err() != nil {
+				return
+			}
+			log.Debug("resuming watch", zap.String("key", key), zap.Int64("revision", rev))
+			select {
+			case <-time.After(watchRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// forwardWatch sends the events received from wch to ch until the watch ends,
+// returning the revision to resume the watch from, and whether the watch can
+// be resumed.
+func (c *Client) forwardWatch(ctx context.Context, key string, rev int64, wch clientv3.WatchChan, ch chan<- WatchEvent) (int64, bool) {
+	for resp := range wch {
+		if resp.CompactRevision != 0 {
+			log.Warn("watched revision has been compacted, changes may have been missed",
+				zap.String("key", key),
+				zap.Int64("revision", rev),
+				zap.Int64("compact-revision", resp.CompactRevision),
+			)
+			return resp.CompactRevision, true
+		}
+		if resp.Canceled {
+			log.Debug("watch cancelled", zap.String("key", key), zap.Error(resp.Err()))
+			return rev, true
+		}
+		if err := resp.Err(); err != nil {
+			log.Error("watch failed", zap.String("key", key), zap.Error(err))
+			return rev, false
+		}
+		for _, ev := range resp.Events {
+			e := WatchEvent{
+				Key:      string(ev.Kv.Key),
+				Value:    ev.Kv.Value,
+				Revision: ev.Kv.ModRevision,
+			}
+			if ev.Type == clientv3.EventTypeDelete {
+				e.Type = WatchDelete
+			}
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return rev, false
+			}
+			rev = ev.Kv.ModRevision + 1
+		}
+	}
+
+	// the watch channel is closed without being cancelled by the server when
+	// the context is cancelled
+	return rev, true
+}
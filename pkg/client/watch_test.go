@@ -0,0 +1,141 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.etcd.io/etcd/clientv3"
+
+	"github.com/criticalstack/e2d/pkg/client"
+)
+
+// cancelingWatcher cancels the first watch after forwarding one response, the
+// same as the server cancelling a watch.
+type cancelingWatcher struct {
+	clientv3.Watcher
+	watches int
+}
+
+func (w *cancelingWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	w.watches++
+	wch := w.Watcher.Watch(ctx, key, opts...)
+	if w.watches > 1 {
+		return wch
+	}
+	ch := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(ch)
+
+		for resp := range wch {
+			if len(resp.Events) == 0 {
+				continue
+			}
+			ch <- resp
+			ch <- clientv3.WatchResponse{Canceled: true}
+			return
+		}
+	}()
+	return ch
+}
+
+func TestWatchKey(t *testing.T) {
+	c := newTestClient(t)
+
+	if err := c.Set("/watch/a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	w := &cancelingWatcher{Watcher: c.Watcher}
+	c.Watcher = w
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := c.WatchKey(ctx, "/watch/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes to other keys with the same prefix are not received
+	if err := c.Set("/watch/ab", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("/watch/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("/watch/a", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("/watch/a", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct {
+		Type  client.WatchEventType
+		Key   string
+		Value string
+	}
+	expected := []event{
+		{client.WatchDelete, "/watch/a", ""},
+		{client.WatchPut, "/watch/a", "2"},
+		{client.WatchPut, "/watch/a", "3"},
+	}
+	events := make([]event, 0)
+	var rev int64
+	for range expected {
+		select {
+		case e := <-ch:
+			if e.Revision <= rev {
+				t.Fatalf("expected revision greater than %d, received %d", rev, e.Revision)
+			}
+			rev = e.Revision
+			events = append(events, event{e.Type, e.Key, string(e.Value)})
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, received %v", events)
+		}
+	}
+	if diff := cmp.Diff(expected, events); diff != "" {
+		t.Errorf("client: after WatchKey differs: (-want +got)\n%s", diff)
+	}
+	if w.watches != 2 {
+		t.Fatalf("expected the watch to be resumed once, received %d watches", w.watches)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected no more events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected channel to be closed")
+	}
+}
+
+func TestWatchPrefix(t *testing.T) {
+	c := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := c.WatchPrefix(ctx, "/watchprefix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"/watchprefix/a", "/watchprefix/b"} {
+		if err := c.Set(key, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"/watchprefix/a", "/watchprefix/b"} {
+		select {
+		case e := <-ch:
+			if e.Type != client.WatchPut || e.Key != key {
+				t.Fatalf("expected %s %s, received %s %s", client.WatchPut, key, e.Type, e.Key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", key)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package cmdutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// SetFlagsFromFile sets the values of flags from a YAML or JSON configuration
+// file, where the keys are the flag names, like:
+//
+//	required-cluster-size: 3
+//	snapshot-interval: 5m
+//	bootstrap-addrs: [10.0.0.1, 10.0.0.2]
+//
+// Lists are joined with commas. Flags that were set on the command line are
+// not changed, so that they take precedence over the file, and flags that are
+// not in the file keep their defaults.
+func SetFlagsFromFile(fs *pflag.FlagSet, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read config file: %#v", path)
+	}
+	values, err := decodeConfig(data)
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse config file: %#v", path)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil {
+			return errors.Errorf("unknown option in config file %#v: %#v", path, name)
+		}
+		if f.Changed {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return errors.Wrapf(err, "invalid value for %#v in config file %#v", name, path)
+		}
+	}
+	return nil
+}
+
+// SetChangedFromEnv marks the flags that are set by an environment variable as
+// changed, so that SetFlagsFromFile does not override them and the precedence
+// is flags, then environment variables, then the file. The variable of a flag
+// is its name in upper case with dashes replaced by underscores, after the
+// provided prefix, such as E2D_DATA_DIR for --data-dir with the prefix "E2D_".
+func SetChangedFromEnv(fs *pflag.FlagSet, prefix string) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := prefix + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+		if _, ok := os.LookupEnv(name); ok {
+			f.Changed = true
+		}
+	})
+}
+
+// decodeConfig decodes the YAML or JSON configuration into the string values
+// of the flags. Numbers are kept as they were written, so that large integers
+// are not formatted as floats.
+func decodeConfig(data []byte) (map[string]string, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for k, v := range raw {
+		s, err := formatConfigValue(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for %#v", k)
+		}
+		values[k] = s
+	}
+	return values, nil
+}
+
+func formatConfigValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case json.Number, bool:
+		return fmt.Sprint(t), nil
+	case []interface{}:
+		items := make([]string, 0, len(t))
+		for _, item := range t {
+			s, err := formatConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", errors.Errorf("unsupported type: %T", v)
+	}
+}
@@ -0,0 +1,167 @@
+package cmdutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/pflag"
+)
+
+type configOptions struct {
+	DataDir             string
+	RequiredClusterSize int
+	SnapshotInterval    time.Duration
+	SnapshotCompression bool
+	BootstrapAddrs      []string
+	QuotaBackendBytes   int64
+	Name                string
+}
+
+func newConfigFlagSet(o *configOptions) *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVar(&o.DataDir, "data-dir", "", "")
+	fs.IntVar(&o.RequiredClusterSize, "required-cluster-size", 1, "")
+	fs.DurationVar(&o.SnapshotInterval, "snapshot-interval", 1*time.Minute, "")
+	fs.BoolVar(&o.SnapshotCompression, "snapshot-compression", false, "")
+	fs.StringSliceVar(&o.BootstrapAddrs, "bootstrap-addrs", nil, "")
+	fs.Int64Var(&o.QuotaBackendBytes, "quota-backend-bytes", 0, "")
+	fs.StringVar(&o.Name, "name", "default", "")
+	return fs
+}
+
+func writeConfigFile(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "e2d.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSetFlagsFromFile(t *testing.T) {
+	path := writeConfigFile(t, `
+data-dir: /var/lib/etcd
+required-cluster-size: 3
+snapshot-interval: 5m
+snapshot-compression: true
+bootstrap-addrs:
+- 10.0.0.1
+- 10.0.0.2
+quota-backend-bytes: 8589934592
+`)
+	var o configOptions
+	fs := newConfigFlagSet(&o)
+	if err := fs.Parse([]string{"--required-cluster-size=5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFlagsFromFile(fs, path); err != nil {
+		t.Fatal(err)
+	}
+	expected := configOptions{
+		DataDir: "/var/lib/etcd",
+
+		// set on the command line, which takes precedence over the file
+		RequiredClusterSize: 5,
+		SnapshotInterval:    5 * time.Minute,
+		SnapshotCompression: true,
+		BootstrapAddrs:      []string{"10.0.0.1", "10.0.0.2"},
+		QuotaBackendBytes:   8 * 1024 * 1024 * 1024,
+
+		// not in the file, so the default is kept
+		Name: "default",
+	}
+	if diff := cmp.Diff(expected, o); diff != "" {
+		t.Errorf("options: (-want +got)\n%s", diff)
+	}
+}
+
+func TestSetFlagsFromFileEnv(t *testing.T) {
+	path := writeConfigFile(t, `
+data-dir: /var/lib/etcd
+required-cluster-size: 3
+name: file
+`)
+	for k, v := range map[string]string{
+		"E2D_DATA_DIR":              "/var/lib/e2d",
+		"E2D_REQUIRED_CLUSTER_SIZE": "5",
+	} {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Unsetenv(k)
+	}
+	var o struct {
+		DataDir             string `env:"E2D_DATA_DIR"`
+		RequiredClusterSize int    `env:"E2D_REQUIRED_CLUSTER_SIZE"`
+		Name                string `env:"E2D_NAME"`
+	}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVar(&o.DataDir, "data-dir", "", "")
+	fs.IntVar(&o.RequiredClusterSize, "required-cluster-size", 1, "")
+	fs.StringVar(&o.Name, "name", "default", "")
+	if err := SetEnvs(&o); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--required-cluster-size=7"}); err != nil {
+		t.Fatal(err)
+	}
+	SetChangedFromEnv(fs, "E2D_")
+	if err := SetFlagsFromFile(fs, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment takes precedence over the file, and the command line
+	// takes precedence over both
+	if o.DataDir != "/var/lib/e2d" {
+		t.Errorf("expected data-dir from the environment, received %#v", o.DataDir)
+	}
+	if o.RequiredClusterSize != 7 {
+		t.Errorf("expected required-cluster-size from the command line, received %d", o.RequiredClusterSize)
+	}
+	if o.Name != "file" {
+		t.Errorf("expected name from the file, received %#v", o.Name)
+	}
+}
+
+func TestSetFlagsFromFileJSON(t *testing.T) {
+	path := writeConfigFile(t, `{"data-dir": "/var/lib/etcd", "bootstrap-addrs": ["10.0.0.1"]}`)
+	var o configOptions
+	fs := newConfigFlagSet(&o)
+	if err := SetFlagsFromFile(fs, path); err != nil {
+		t.Fatal(err)
+	}
+	if o.DataDir != "/var/lib/etcd" {
+		t.Fatalf("incorrect string value: %v", o.DataDir)
+	}
+	if diff := cmp.Diff([]string{"10.0.0.1"}, o.BootstrapAddrs); diff != "" {
+		t.Errorf("bootstrap-addrs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestSetFlagsFromFileErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"unknown option", "data-dri: /var/lib/etcd\n"},
+		{"invalid value", "snapshot-interval: often\n"},
+		{"unsupported type", "data-dir: {path: /var/lib/etcd}\n"},
+		{"invalid yaml", "data-dir: [\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var o configOptions
+			if err := SetFlagsFromFile(newConfigFlagSet(&o), writeConfigFile(t, tc.data)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// CachingPeerGetter wraps a PeerGetter, retrying failed lookups with backoff
+// and caching the last addresses found. This allows a node to start while the
+// cloud provider API is briefly unavailable, and Run keeps the cached
+// addresses up-to-date so that later attempts to bootstrap see peers that were
+// launched afterwards.
+type CachingPeerGetter struct {
+	PeerGetter PeerGetter
+
+	// RetryInterval is the time waited before retrying a failed lookup, which
+	// doubles after each failure up to MaxRetryInterval.
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	// RefreshInterval is how often Run refreshes the cached addresses.
+	RefreshInterval time.Duration
+
+	mu    sync.Mutex
+	addrs []string
+}
+
+func NewCachingPeerGetter(pg PeerGetter) *CachingPeerGetter {
+	return &CachingPeerGetter{
+		PeerGetter:       pg,
+		RetryInterval:    1 * time.Second,
+		MaxRetryInterval: 30 * time.Second,
+		RefreshInterval:  30 * time.Second,
+	}
+}
+
+// GetAddrs returns the cached addresses, or when nothing has been cached yet,
+// looks up the addresses, retrying with backoff until successful or the
+// context is done.
+func (p *CachingPeerGetter) GetAddrs(ctx context.Context) ([]string, error) {
+	if addrs, ok := p.cached(); ok {
+		return addrs, nil
+	}
+	interval := p.RetryInterval
+	for {
+		addrs, err := p.refresh(ctx)
+		if err == nil {
+			return addrs, nil
+		}
+		log.Debug("cannot get peer addresses, retrying ...",
+			zap.Duration("retry-interval", interval),
+			zap.Error(err),
+		)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, errors.Wrap(err, "cannot get peer addresses")
+		}
+		interval *= 2
+		if interval > p.MaxRetryInterval {
+			interval = p.MaxRetryInterval
+		}
+	}
+}
+
+// Run refreshes the cached addresses every RefreshInterval until the context
+// is done. The last addresses found are kept when a refresh fails.
+func (p *CachingPeerGetter) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := p.refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Debug("cannot refresh peer addresses", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *CachingPeerGetter) refresh(ctx context.Context) ([]string, error) {
+	addrs, err := p.PeerGetter.GetAddrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addrs = append([]string{}, addrs...)
+	return addrs, nil
+}
+
+func (p *CachingPeerGetter) cached() ([]string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.addrs == nil {
+		return nil, false
+	}
+	return append([]string{}, p.addrs...), true
+}
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// flakyPeerGetter fails the first n lookups, and then returns addrs.
+type flakyPeerGetter struct {
+	mu    sync.Mutex
+	n     int
+	calls int
+	addrs []string
+}
+
+func (p *flakyPeerGetter) GetAddrs(ctx context.Context) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls <= p.n {
+		return nil, errors.New("service unavailable")
+	}
+	return p.addrs, nil
+}
+
+func (p *flakyPeerGetter) setAddrs(addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addrs = addrs
+}
+
+func TestCachingPeerGetterRetry(t *testing.T) {
+	pg := &flakyPeerGetter{n: 3, addrs: []string{"10.0.0.11", "10.0.0.12"}}
+	p := NewCachingPeerGetter(pg)
+	p.RetryInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := p.GetAddrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"10.0.0.11", "10.0.0.12"}, addrs); diff != "" {
+		t.Errorf("addrs: (-want +got)\n%s", diff)
+	}
+	if pg.calls != 4 {
+		t.Errorf("calls = %d, want 4", pg.calls)
+	}
+
+	// the cached addresses are returned without another lookup
+	if _, err := p.GetAddrs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pg.calls != 4 {
+		t.Errorf("calls = %d, want 4", pg.calls)
+	}
+}
+
+func TestCachingPeerGetterTimeout(t *testing.T) {
+	p := NewCachingPeerGetter(&flakyPeerGetter{n: 1000})
+	p.RetryInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetAddrs(ctx); err == nil {
+		t.Fatal("expected error after the context is done")
+	}
+}
+
+func TestCachingPeerGetterRun(t *testing.T) {
+	pg := &flakyPeerGetter{addrs: []string{"10.0.0.11"}}
+	p := NewCachingPeerGetter(pg)
+	p.RefreshInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := p.GetAddrs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go p.Run(ctx)
+
+	// a newly-launched peer is seen after the next refresh
+	pg.setAddrs([]string{"10.0.0.11", "10.0.0.12"})
+	want := []string{"10.0.0.11", "10.0.0.12"}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		addrs, err := p.GetAddrs(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmp.Equal(want, addrs) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("addrs: (-want +got)\n%s", cmp.Diff(want, addrs))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
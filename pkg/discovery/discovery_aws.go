@@ -7,12 +7,19 @@ import (
 	"github.com/pkg/errors"
 )
 
+type AmazonConfig struct {
+	// static credentials used instead of the default credential chain, such
+	// as when running outside of EC2
+	AccessKey string
+	SecretKey string
+}
+
 type AmazonAutoScalingPeerGetter struct {
 	*e2daws.Client
 }
 
-func NewAmazonAutoScalingPeerGetter() (*AmazonAutoScalingPeerGetter, error) {
-	awsCfg, err := e2daws.NewConfig()
+func NewAmazonAutoScalingPeerGetter(cfg *AmazonConfig) (*AmazonAutoScalingPeerGetter, error) {
+	awsCfg, err := e2daws.NewConfigWithCredentials(cfg.AccessKey, cfg.SecretKey)
 	if err != nil {
 		return nil, err
 	}
@@ -32,11 +39,11 @@ type AmazonInstanceTagPeerGetter struct {
 	tags map[string]string
 }
 
-func NewAmazonInstanceTagPeerGetter(kvs []KeyValue) (*AmazonInstanceTagPeerGetter, error) {
+func NewAmazonInstanceTagPeerGetter(cfg *AmazonConfig, kvs []KeyValue) (*AmazonInstanceTagPeerGetter, error) {
 	if len(kvs) == 0 {
 		return nil, errors.New("must provide at least 1 tag key/value")
 	}
-	awsCfg, err := e2daws.NewConfig()
+	awsCfg, err := e2daws.NewConfigWithCredentials(cfg.AccessKey, cfg.SecretKey)
 	if err != nil {
 		return nil, err
 	}
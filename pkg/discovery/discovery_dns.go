@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/netutil"
+)
+
+// DefaultDNSSRVService is the SRV service name used by etcd for DNS discovery
+// of the peers of a cluster.
+const DefaultDNSSRVService = "etcd-server"
+
+// Resolver looks up DNS records, and is satisfied by *net.Resolver.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+type DNSSRVPeerGetter struct {
+	Resolver Resolver
+	domain   string
+	service  string
+}
+
+// NewDNSSRVPeerGetter returns a PeerGetter that finds peers from the
+// _<service>._tcp.<domain> SRV records, like etcd DNS discovery. The service
+// defaults to DefaultDNSSRVService when empty.
+func NewDNSSRVPeerGetter(domain, service string) (*DNSSRVPeerGetter, error) {
+	if domain == "" {
+		return nil, errors.New("must provide a domain")
+	}
+	if service == "" {
+		service = DefaultDNSSRVService
+	}
+	return &DNSSRVPeerGetter{
+		Resolver: net.DefaultResolver,
+		domain:   domain,
+		service:  service,
+	}, nil
+}
+
+// GetAddrs returns the IPv4 addresses of the targets of the SRV records. The
+// ports of the records are not used, since peers are joined on the gossip
+// port.
+func (p *DNSSRVPeerGetter) GetAddrs(ctx context.Context) ([]string, error) {
+	_, srvs, err := p.Resolver.LookupSRV(ctx, p.service, "tcp", p.domain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot lookup srv records: _%s._tcp.%s", p.service, p.domain)
+	}
+	seen := make(map[string]bool)
+	addrs := make([]string, 0)
+	for _, srv := range srvs {
+		target := strings.TrimSuffix(srv.Target, ".")
+		hosts, err := p.Resolver.LookupHost(ctx, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot lookup srv target: %#v", target)
+		}
+		for _, h := range hosts {
+			if !netutil.IsRoutableIPv4(h) || seen[h] {
+				continue
+			}
+			seen[h] = true
+			addrs = append(addrs, h)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type fakeResolver struct {
+	srvs  map[string][]*net.SRV
+	hosts map[string][]string
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname := "_" + service + "._" + proto + "." + name
+	srvs, ok := r.srvs[cname]
+	if !ok {
+		return "", nil, errors.Errorf("no such host: %s", cname)
+	}
+	return cname, srvs, nil
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.Errorf("no such host: %s", host)
+	}
+	return addrs, nil
+}
+
+func TestDNSSRVPeerGetter(t *testing.T) {
+	r := &fakeResolver{
+		srvs: map[string][]*net.SRV{
+			"_etcd-server._tcp.example.com": {
+				{Target: "etcd-2.example.com.", Port: 2380},
+				{Target: "etcd-1.example.com.", Port: 2380},
+				{Target: "etcd-3.example.com.", Port: 2380},
+			},
+			"_e2d._tcp.example.com": {
+				{Target: "e2d.example.com.", Port: 7980},
+			},
+		},
+		hosts: map[string][]string{
+			"etcd-1.example.com": {"10.0.0.11", "fd00::11"},
+			"etcd-2.example.com": {"10.0.0.12"},
+			"etcd-3.example.com": {"10.0.0.12", "127.0.0.1"},
+			"e2d.example.com":    {"10.0.1.1"},
+		},
+	}
+	tests := []struct {
+		name    string
+		domain  string
+		service string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "default service",
+			domain: "example.com",
+			want:   []string{"10.0.0.11", "10.0.0.12"},
+		},
+		{
+			name:    "custom service",
+			domain:  "example.com",
+			service: "e2d",
+			want:    []string{"10.0.1.1"},
+		},
+		{
+			name:    "missing records",
+			domain:  "example.org",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewDNSSRVPeerGetter(tt.domain, tt.service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Resolver = r
+			addrs, err := p.GetAddrs(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetAddrs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, addrs); diff != "" {
+				t.Errorf("addrs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package discovery
+
+import (
+	"context"
+
+	"github.com/criticalstack/e2d/pkg/provider/gcp"
+)
+
+type GCPInstanceGroupPeerGetter struct {
+	*gcp.Client
+}
+
+func NewGCPInstanceGroupPeerGetter() (*GCPInstanceGroupPeerGetter, error) {
+	client, err := gcp.NewClient(&gcp.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &GCPInstanceGroupPeerGetter{client}, nil
+}
+
+func (p *GCPInstanceGroupPeerGetter) GetAddrs(ctx context.Context) ([]string, error) {
+	return p.GetInstanceGroupAddresses(ctx)
+}
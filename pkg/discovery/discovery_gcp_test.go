@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/criticalstack/e2d/pkg/provider/gcp"
+)
+
+// newFakeGCP returns a server acting as both the metadata server and the
+// Compute Engine API for an instance e2d-1 in a managed instance group of 3
+// instances.
+func newFakeGCP(t *testing.T) *httptest.Server {
+	instances := map[string]string{
+		"e2d-1": "10.0.0.11",
+		"e2d-2": "10.0.0.12",
+		"e2d-3": "10.0.0.13",
+	}
+	mux := http.NewServeMux()
+	metadata := map[string]string{
+		"instance/name":                           "e2d-1",
+		"instance/attributes/created-by":          "projects/123/zones/us-east1-b/instanceGroupManagers/e2d",
+		"instance/service-accounts/default/token": `{"access_token":"token","expires_in":3600,"token_type":"Bearer"}`,
+	}
+	for k, v := range metadata {
+		v := v
+		mux.HandleFunc("/computeMetadata/v1/"+k, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, v)
+		})
+	}
+	mux.HandleFunc("/compute/v1/projects/123/zones/us-east1-b/instanceGroupManagers/e2d/listManagedInstances", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// the instances are split across pages to test pagination
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			fmt.Fprint(w, `{"managedInstances":[{"instance":"https://www.googleapis.com/compute/v1/projects/my-project/zones/us-east1-b/instances/e2d-1"},{"instance":"https://www.googleapis.com/compute/v1/projects/my-project/zones/us-east1-b/instances/e2d-2"}],"nextPageToken":"2"}`)
+		case "2":
+			fmt.Fprint(w, `{"managedInstances":[{"instance":"https://www.googleapis.com/compute/v1/projects/my-project/zones/us-east1-b/instances/e2d-3"}]}`)
+		}
+	})
+	mux.HandleFunc("/compute/v1/projects/my-project/zones/us-east1-b/instances/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		name := r.URL.Path[len("/compute/v1/projects/my-project/zones/us-east1-b/instances/"):]
+		ip, ok := instances[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"name":%q,"networkInterfaces":[{"networkIP":%q}]}`, name, ip)
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestGCPInstanceGroupPeerGetter(t *testing.T) {
+	s := newFakeGCP(t)
+	client, err := gcp.NewClient(&gcp.Config{
+		MetadataEndpoint: s.URL + "/computeMetadata/v1",
+		ComputeEndpoint:  s.URL + "/compute/v1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &GCPInstanceGroupPeerGetter{client}
+	addrs, err := p.GetAddrs(context.Background())
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if diff := cmp.Diff([]string{"10.0.0.12", "10.0.0.13"}, addrs); diff != "" {
+		t.Errorf("addrs: (-want +got)\n%s", diff)
+	}
+}
@@ -1 +1,67 @@
 package discovery
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/provider/kubernetes"
+)
+
+type KubernetesConfig struct {
+	// Kubeconfig is the path to a kubeconfig file. The in-cluster config of
+	// the pod's service account is used when it is empty.
+	Kubeconfig string
+
+	// Namespace the pods are listed in, which defaults to the namespace of the
+	// service account or kubeconfig context.
+	Namespace string
+
+	// Labels that must all match the labels of a pod for it to be a peer.
+	Labels []KeyValue
+}
+
+type KubernetesPeerGetter struct {
+	*kubernetes.Client
+	namespace string
+	selector  string
+}
+
+func NewKubernetesPeerGetter(cfg *KubernetesConfig) (*KubernetesPeerGetter, error) {
+	kcfg, err := kubernetes.NewConfig(cfg.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return newKubernetesPeerGetter(kcfg, cfg)
+}
+
+func newKubernetesPeerGetter(kcfg *kubernetes.Config, cfg *KubernetesConfig) (*KubernetesPeerGetter, error) {
+	if len(cfg.Labels) == 0 {
+		return nil, errors.New("must provide at least 1 label key/value")
+	}
+	client, err := kubernetes.NewClient(kcfg)
+	if err != nil {
+		return nil, err
+	}
+	selectors := make([]string, 0)
+	for _, kv := range cfg.Labels {
+		if kv.Value == "" {
+			// a label without a value matches pods that have the label
+			selectors = append(selectors, kv.Key)
+			continue
+		}
+		selectors = append(selectors, kv.Key+"="+kv.Value)
+	}
+	sort.Strings(selectors)
+	return &KubernetesPeerGetter{
+		Client:    client,
+		namespace: cfg.Namespace,
+		selector:  strings.Join(selectors, ","),
+	}, nil
+}
+
+func (p *KubernetesPeerGetter) GetAddrs(ctx context.Context) ([]string, error) {
+	return p.GetAddrsByLabels(ctx, p.namespace, p.selector)
+}
@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/criticalstack/e2d/pkg/provider/kubernetes"
+)
+
+// fakePods is the pod list returned by the fake API server.
+const fakePods = `{
+  "kind": "PodList",
+  "apiVersion": "v1",
+  "items": [
+    {"metadata": {"name": "e2d-2"}, "status": {"phase": "Running", "podIP": "10.0.0.12"}},
+    {"metadata": {"name": "e2d-1"}, "status": {"phase": "Running", "podIP": "10.0.0.11"}},
+    {"metadata": {"name": "e2d-3"}, "status": {"phase": "Pending", "podIP": ""}},
+    {"metadata": {"name": "e2d-4", "deletionTimestamp": "2020-01-01T00:00:00Z"}, "status": {"phase": "Running", "podIP": "10.0.0.14"}}
+  ]
+}`
+
+func TestKubernetesPeerGetter(t *testing.T) {
+	var gotPath, gotSelector, gotAuth string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotSelector = r.URL.Query().Get("labelSelector")
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fakePods))
+	}))
+	defer s.Close()
+
+	p, err := newKubernetesPeerGetter(&kubernetes.Config{
+		Host:        s.URL,
+		Namespace:   "default",
+		BearerToken: "token",
+	}, &KubernetesConfig{
+		Namespace: "etcd",
+		Labels: []KeyValue{
+			{Key: "tier", Value: "etcd"},
+			{Key: "app", Value: "e2d"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := p.GetAddrs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"10.0.0.11", "10.0.0.12"}, addrs); diff != "" {
+		t.Errorf("addrs: (-want +got)\n%s", diff)
+	}
+	if gotPath != "/api/v1/namespaces/etcd/pods" {
+		t.Errorf("path = %q, want %q", gotPath, "/api/v1/namespaces/etcd/pods")
+	}
+	if gotSelector != "app=e2d,tier=etcd" {
+		t.Errorf("labelSelector = %q, want %q", gotSelector, "app=e2d,tier=etcd")
+	}
+	if gotAuth != "Bearer token" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer token")
+	}
+}
+
+func TestKubernetesPeerGetterError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `pods is forbidden`, http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	p, err := newKubernetesPeerGetter(&kubernetes.Config{Host: s.URL, Namespace: "default"}, &KubernetesConfig{
+		Labels: []KeyValue{{Key: "app", Value: "e2d"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetAddrs(context.Background()); err == nil {
+		t.Fatal("expected error listing pods")
+	}
+}
+
+func TestNewKubernetesPeerGetterNoLabels(t *testing.T) {
+	if _, err := newKubernetesPeerGetter(&kubernetes.Config{Host: "https://127.0.0.1:6443"}, &KubernetesConfig{}); err == nil {
+		t.Fatal("expected error without labels")
+	}
+}
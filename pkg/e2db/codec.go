@@ -3,6 +3,11 @@ package e2db
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/go-msgpack/codec"
 
 	"github.com/criticalstack/e2d/pkg/e2db/crypto"
 )
@@ -26,22 +31,165 @@ func (*gobCodec) Decode(data []byte, iface interface{}) error {
 	return gob.NewDecoder(bytes.NewReader(data)).Decode(iface)
 }
 
-type encryptedGobCodec struct {
+// JSONCodec encodes rows as JSON, which can be read directly from etcd, for
+// example when debugging. Fields are encoded the same as encoding/json, so the
+// json struct tags of the model are used.
+type JSONCodec struct{}
+
+func (*JSONCodec) Encode(iface interface{}) ([]byte, error) {
+	return json.Marshal(iface)
+}
+
+func (*JSONCodec) Decode(data []byte, iface interface{}) error {
+	return json.Unmarshal(data, iface)
+}
+
+// MsgpackCodec encodes rows as MessagePack, which is more compact than JSON.
+type MsgpackCodec struct{}
+
+var msgpackHandle = &codec.MsgpackHandle{
+	RawToString: true,
+	WriteExt:    true,
+}
+
+func (*MsgpackCodec) Encode(iface interface{}) ([]byte, error) {
+	var b []byte
+	if err := codec.NewEncoderBytes(&b, msgpackHandle).Encode(iface); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (*MsgpackCodec) Decode(data []byte, iface interface{}) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(iface)
+}
+
+// encryptedCodec wraps a Codec and encrypts the encoded rows.
+type encryptedCodec struct {
+	Codec
 	key *[32]byte
 }
 
-func (c *encryptedGobCodec) Encode(iface interface{}) ([]byte, error) {
-	var b bytes.Buffer
-	if err := gob.NewEncoder(&b).Encode(iface); err != nil {
+func (c *encryptedCodec) Encode(iface interface{}) ([]byte, error) {
+	data, err := c.Codec.Encode(iface)
+	if err != nil {
 		return nil, err
 	}
-	return crypto.Encrypt(b.Bytes(), c.key)
+	return crypto.Encrypt(data, c.key)
 }
 
-func (c *encryptedGobCodec) Decode(ciphertext []byte, iface interface{}) error {
+func (c *encryptedCodec) Decode(ciphertext []byte, iface interface{}) error {
 	plaintext, err := crypto.Decrypt(ciphertext, c.key)
 	if err != nil {
 		return err
 	}
-	return gob.NewDecoder(bytes.NewReader(plaintext)).Decode(iface)
+	return c.Codec.Decode(plaintext, iface)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeCodec wraps a Codec and normalizes any time.Time values to UTC,
+// truncated to precision, before they are encoded. This ensures that stored
+// times compare predictably regardless of the timezone of the client that
+// wrote them. A precision of 0 does not truncate.
+type timeCodec struct {
+	Codec
+	precision time.Duration
+}
+
+func (c *timeCodec) Encode(iface interface{}) ([]byte, error) {
+	v := reflect.ValueOf(iface)
+	if !v.IsValid() || !hasTime(v.Type(), make(map[reflect.Type]bool)) {
+		return c.Codec.Encode(iface)
+	}
+	return c.Codec.Encode(normalizeTime(v, c.precision).Interface())
+}
+
+// hasTime determines if time.Time values can be reached from the provided
+// type, so that values without any can be encoded without being copied.
+func hasTime(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == timeType {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasTime(t.Elem(), seen)
+	case reflect.Interface:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			if hasTime(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeTime returns a copy of v with all exported time.Time values
+// converted to UTC and truncated to precision. The provided value is never
+// modified.
+func normalizeTime(v reflect.Value, precision time.Duration) reflect.Value {
+	if v.Type() == timeType {
+		return reflect.ValueOf(v.Interface().(time.Time).UTC().Truncate(precision))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(normalizeTime(v.Elem(), precision))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(normalizeTime(v.Elem(), precision))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(normalizeTime(v.Index(i), precision))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(normalizeTime(v.Index(i), precision))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), normalizeTime(iter.Value(), precision))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			c.Field(i).Set(normalizeTime(v.Field(i), precision))
+		}
+		return c
+	}
+	return v
 }
@@ -0,0 +1,125 @@
+package e2db
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTimeCodecNormalizesTimezone(t *testing.T) {
+	type event struct {
+		Name    string
+		Created time.Time
+		Updated *time.Time
+		History []time.Time
+	}
+
+	ts := time.Date(2020, 7, 1, 12, 30, 0, 123456789, time.UTC)
+	want := ts.Truncate(time.Millisecond)
+	c := &timeCodec{Codec: &gobCodec{}, precision: time.Millisecond}
+
+	var prev []byte
+	for _, loc := range []*time.Location{
+		time.UTC,
+		time.FixedZone("EST", -5*60*60),
+		time.FixedZone("JST", 9*60*60),
+	} {
+		local := ts.In(loc)
+		e := &event{
+			Name:    "test",
+			Created: local,
+			Updated: &local,
+			History: []time.Time{local},
+		}
+		data, err := c.Encode(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prev != nil && !bytes.Equal(prev, data) {
+			t.Fatalf("expected encoding in %s to match previous encoding", loc)
+		}
+		prev = data
+
+		// the original object should be left unchanged
+		if e.Created.Location() != loc || e.Updated.Location() != loc {
+			t.Fatalf("expected original times to remain in %s", loc)
+		}
+
+		var got *event
+		if err := c.Decode(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []time.Time{got.Created, *got.Updated, got.History[0]} {
+			if v != want {
+				t.Fatalf("expected %v, received %v", want, v)
+			}
+		}
+		if diff := cmp.Diff(&event{
+			Name:    "test",
+			Created: want,
+			Updated: &want,
+			History: []time.Time{want},
+		}, got); diff != "" {
+			t.Errorf("event: after Decode differs: (-want +got)\n%s", diff)
+		}
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	type item struct {
+		ID      int
+		Name    string
+		Tags    []string
+		Labels  map[string]string
+		Data    []byte
+		Score   float64
+		Enabled bool
+		Created time.Time
+		Parent  *item
+	}
+
+	ts := time.Date(2020, 7, 1, 12, 30, 0, 123000000, time.UTC)
+	key := [32]byte{}
+	copy(key[:], "secret")
+
+	cases := []struct {
+		name  string
+		codec Codec
+	}{
+		{"gob", &gobCodec{}},
+		{"json", &JSONCodec{}},
+		{"msgpack", &MsgpackCodec{}},
+		{"encrypted json", &encryptedCodec{Codec: &JSONCodec{}, key: &key}},
+		{"encrypted msgpack", &encryptedCodec{Codec: &MsgpackCodec{}, key: &key}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &timeCodec{Codec: tc.codec, precision: time.Millisecond}
+			want := &item{
+				ID:      1,
+				Name:    "test",
+				Tags:    []string{"a", "b"},
+				Labels:  map[string]string{"app": "e2d"},
+				Data:    []byte("data"),
+				Score:   1.5,
+				Enabled: true,
+				Created: ts.In(time.FixedZone("EST", -5*60*60)),
+				Parent:  &item{ID: 2, Name: "parent", Created: ts},
+			}
+			data, err := c.Encode(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got item
+			if err := c.Decode(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			want.Created = ts
+			if diff := cmp.Diff(want, &got); diff != "" {
+				t.Errorf("%s: after Decode differs: (-want +got)\n%s", tc.name, diff)
+			}
+		})
+	}
+}
@@ -24,6 +24,16 @@ type Config struct {
 	AutoSyncInterval time.Duration
 	SecretKey        []byte
 
+	// maximum number of rows a query may read before failing with
+	// ErrResultTooLarge, where 0 means unlimited. The limit is applied to the
+	// etcd reads themselves, so rows are counted before any filters.
+	MaxResults int
+
+	// amount of time to retry requests that fail because the cluster has no
+	// leader, so that brief leader elections are transparent to callers.
+	// Each attempt is still bounded by Timeout. Disabled when zero.
+	NoLeaderRetryTimeout time.Duration
+
 	clientURL      url.URL
 	key            *[32]byte
 	securityConfig client.SecurityConfig
@@ -62,7 +72,7 @@ func (c *Config) validate() error {
 		return err
 	}
 	if caddr.IsUnspecified() {
-		caddr.Host, err = netutil.DetectHostIPv4()
+		caddr.Host, err = netutil.DetectHostIP(caddr.Host)
 		if err != nil {
 			return err
 		}
@@ -78,3 +88,9 @@ func (c *Config) validate() error {
 	}
 	return nil
 }
+
+// requestTimeout is the total amount of time allowed for a request, including
+// any retries while the cluster has no leader.
+func (c *Config) requestTimeout() time.Duration {
+	return c.Timeout + c.NoLeaderRetryTimeout
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"crypto/sha512"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/clientv3/namespace"
 
 	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/e2db/key"
 )
 
 type DB struct {
@@ -22,9 +26,11 @@ func New(ctx context.Context, cfg *Config) (*DB, error) {
 		return nil, err
 	}
 	c, err := client.New(&client.Config{
-		ClientURLs:       []string{cfg.clientURL.String()},
-		SecurityConfig:   cfg.securityConfig,
-		AutoSyncInterval: cfg.AutoSyncInterval,
+		ClientURLs:           []string{cfg.clientURL.String()},
+		SecurityConfig:       cfg.securityConfig,
+		Timeout:              cfg.Timeout,
+		AutoSyncInterval:     cfg.AutoSyncInterval,
+		NoLeaderRetryTimeout: cfg.NoLeaderRetryTimeout,
 	})
 	if err != nil {
 		return nil, err
@@ -54,17 +60,109 @@ func (db *DB) Close() {
 	db.client.Close()
 }
 
+// Tables returns the definitions of all tables stored in the namespace, sorted
+// by name.
+func (db *DB) Tables() ([]*ModelDef, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), db.cfg.requestTimeout())
+	defer cancel()
+
+	// Only the first key of each table is read, skipping past the rest of the
+	// table's rows and indexes, so that listing tables does not depend upon
+	// the number of rows.
+	tables := make([]*ModelDef, 0)
+	tc := &gobCodec{}
+	from, end := "/", clientv3.GetPrefixRangeEnd("/")
+	for {
+		resp, err := db.client.Client.Get(ctx, from, clientv3.WithRange(end), clientv3.WithKeysOnly(), clientv3.WithLimit(1))
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Kvs) == 0 {
+			break
+		}
+		name := strings.SplitN(strings.TrimPrefix(string(resp.Kvs[0].Key), "/"), "/", 2)[0]
+		from = clientv3.GetPrefixRangeEnd(key.Table(name))
+
+		v, err := db.client.Client.Get(ctx, key.TableDef(name))
+		if err != nil {
+			return nil, err
+		}
+		if len(v.Kvs) == 0 {
+			// not a table, or the table was dropped
+			continue
+		}
+		var m *ModelDef
+		if err := tc.Decode(v.Kvs[0].Value, &m); err != nil {
+			return nil, errors.Wrapf(err, "cannot decode table definition: %#v", name)
+		}
+		tables = append(tables, m)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables, nil
+}
+
+// RowCount returns the number of rows stored in the named table.
+func (db *DB) RowCount(table string) (int64, error) {
+	n, err := db.client.Count(key.Table(table))
+	if err != nil {
+		return 0, err
+	}
+
+	// table definitions and indexes are stored under the table prefix
+	hidden, err := db.client.Count(key.Hidden(table))
+	if err != nil {
+		return 0, err
+	}
+	return n - hidden, nil
+}
+
+// DropNamespace deletes every table stored in the namespace, including their
+// rows and indexes, in a single request. It is safe to call when the namespace
+// has no tables. The DB must be configured with a Namespace, to prevent
+// deleting every key in etcd.
+func (db *DB) DropNamespace() error {
+	if db.cfg.Namespace == "" {
+		return errors.New("cannot drop namespace: Namespace is not set")
+	}
+	// all keys are namespaced by the KV, and start with the table name
+	// prefixed with "/"
+	_, err := db.client.DeletePrefix("/")
+	return err
+}
+
 func (db *DB) Lock(name string, timeout time.Duration) (context.CancelFunc, error) {
 	return db.client.Lock(name, timeout)
 }
 
 type TableOption func(*Table)
 
+// WithEncryption encrypts the rows of the table, after they are encoded by the
+// table's codec.
 func WithEncryption(secretKey []byte) TableOption {
 	return func(t *Table) {
 		key := [32]byte{}
 		copy(key[:], sha512.New512_256().Sum(secretKey))
-		t.c = &encryptedGobCodec{key: &key}
+		t.key = &key
+	}
+}
+
+// WithCodec sets the codec used to encode the rows of the table, such as
+// JSONCodec or MsgpackCodec. By default, rows are encoded with encoding/gob.
+// The table definition is always encoded with encoding/gob.
+func WithCodec(c Codec) TableOption {
+	return func(t *Table) {
+		t.c = c
+	}
+}
+
+// WithTimePrecision sets the precision that time.Time values are truncated to
+// when objects are stored in the table. All time.Time values are stored in
+// UTC, and by default are not truncated.
+func WithTimePrecision(d time.Duration) TableOption {
+	return func(t *Table) {
+		t.timePrecision = d
 	}
 }
 
@@ -78,5 +176,9 @@ func (db *DB) Table(iface interface{}, options ...TableOption) *Table {
 	for _, opt := range options {
 		opt(t)
 	}
+	if t.key != nil {
+		t.c = &encryptedCodec{Codec: t.c, key: t.key}
+	}
+	t.c = &timeCodec{Codec: t.c, precision: t.timePrecision}
 	return t
 }
@@ -3,8 +3,11 @@ package e2db_test
 import (
 	"bytes"
 	"context"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -98,6 +101,45 @@ func TestFindOnePrimaryIndex(t *testing.T) {
 	}
 }
 
+func TestGet(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+	var r Role
+	if err := roles.Get(2, &r); err != nil {
+		t.Fatal(err)
+	}
+	expected := &Role{ID: 2, Name: "admin", Description: "administrator"}
+	if diff := cmp.Diff(expected, &r); diff != "" {
+		t.Errorf("e2db: after Get differs: (-want +got)\n%s", diff)
+	}
+	if err := roles.Get(10, &r); errors.Cause(err) != e2db.ErrNoRows {
+		t.Fatalf("expected ErrNoRows, received %v", err)
+	}
+
+	gadgets := db.Table(&Gadget{})
+	if err := gadgets.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	if err := gadgets.Insert(&Gadget{Serial: "g-100", Name: "sprocket"}); err != nil {
+		t.Fatal(err)
+	}
+	var g Gadget
+	if err := gadgets.Get("g-100", &g); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&Gadget{Serial: "g-100", Name: "sprocket"}, &g); diff != "" {
+		t.Errorf("e2db: after Get differs: (-want +got)\n%s", diff)
+	}
+	if err := gadgets.Get("g-200", &g); errors.Cause(err) != e2db.ErrNoRows {
+		t.Fatalf("expected ErrNoRows, received %v", err)
+	}
+}
+
+type Gadget struct {
+	Serial string `e2db:"id"`
+	Name   string
+}
+
 func TestFindOneUnique(t *testing.T) {
 	resetTable(t)
 	roles := db.Table(&Role{})
@@ -159,6 +201,69 @@ func TestFindAll(t *testing.T) {
 	}
 }
 
+func TestFindLimitSkip(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+	tests := []struct {
+		name     string
+		query    e2db.Query
+		expected []int
+	}{
+		{name: "limit", query: roles.Limit(2), expected: []int{2, 3}},
+		{name: "skip", query: roles.Skip(1), expected: []int{3, 4}},
+		{name: "skip and limit", query: roles.Skip(1).Limit(1), expected: []int{3}},
+		{name: "limit larger than results", query: roles.Limit(10), expected: []int{2, 3, 4}},
+		{name: "skip past results", query: roles.Skip(3), expected: []int{}},
+		{name: "sorted", query: roles.OrderBy("Name").Limit(2), expected: []int{2, 4}},
+		{name: "sorted and reversed", query: roles.OrderBy("Name").Reverse().Skip(1).Limit(2), expected: []int{4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r []*Role
+			if err := tt.query.Find("Description", "administrator", &r); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int, 0)
+			for _, role := range r {
+				ids = append(ids, role.ID)
+			}
+			if diff := cmp.Diff(tt.expected, ids); diff != "" {
+				t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAllLimitSkip(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+	tests := []struct {
+		name     string
+		query    e2db.Query
+		expected []int
+	}{
+		{name: "limit", query: roles.Limit(2), expected: []int{1, 2}},
+		{name: "skip", query: roles.Skip(2), expected: []int{3, 4}},
+		{name: "skip and limit", query: roles.Skip(1).Limit(2), expected: []int{2, 3}},
+		{name: "sorted", query: roles.OrderBy("Name").Skip(1).Limit(2), expected: []int{4, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r []*Role
+			if err := tt.query.All(&r); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int, 0)
+			for _, role := range r {
+				ids = append(ids, role.ID)
+			}
+			if diff := cmp.Diff(tt.expected, ids); diff != "" {
+				t.Errorf("e2db: after All differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestInsertRequired(t *testing.T) {
 	resetTable(t)
 	roles := db.Table(&Role{})
@@ -180,6 +285,56 @@ func TestCount(t *testing.T) {
 	}
 }
 
+func TestDistinct(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+	values, err := roles.Distinct("Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"administrator", "user"}, values); diff != "" {
+		t.Errorf("e2db: after Distinct differs: (-want +got)\n%s", diff)
+	}
+	n, err := roles.CountDistinct("Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected count 2, received %d", n)
+	}
+
+	// every value of a unique index is distinct
+	n, err = roles.CountDistinct("Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(newRoles)) {
+		t.Errorf("expected count %d, received %d", len(newRoles), n)
+	}
+
+	// a value is no longer distinct once the last row with it is deleted
+	if _, err := roles.Delete("Name", "user"); err != nil {
+		t.Fatal(err)
+	}
+	values, err = roles.Distinct("Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"administrator"}, values); diff != "" {
+		t.Errorf("e2db: after Distinct differs: (-want +got)\n%s", diff)
+	}
+
+	if _, err := roles.Distinct("NotIndexed"); errors.Cause(err) != e2db.ErrNotIndexed {
+		t.Fatalf("expected ErrNotIndexed, received %v", err)
+	}
+	if _, err := roles.CountDistinct("NotIndexed"); errors.Cause(err) != e2db.ErrNotIndexed {
+		t.Fatalf("expected ErrNotIndexed, received %v", err)
+	}
+	if _, err := roles.Distinct("Missing"); errors.Cause(err) != e2db.ErrInvalidField {
+		t.Fatalf("expected ErrInvalidField, received %v", err)
+	}
+}
+
 func TestCountPrimaryIndex(t *testing.T) {
 	resetTable(t)
 	roles := db.Table(&Role{})
@@ -358,6 +513,41 @@ func TestEncryptedTable(t *testing.T) {
 	time.Sleep(1 * time.Second)
 }
 
+func TestTableCodec(t *testing.T) {
+	db, err := e2db.New(context.Background(), &e2db.Config{
+		ClientAddr: ":2479",
+		Namespace:  "codec",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, c := range []e2db.Codec{&e2db.JSONCodec{}, &e2db.MsgpackCodec{}} {
+		for _, opts := range [][]e2db.TableOption{
+			{e2db.WithCodec(c)},
+			{e2db.WithCodec(c), e2db.WithEncryption([]byte("secret"))},
+			{e2db.WithEncryption([]byte("secret")), e2db.WithCodec(c)},
+		} {
+			roles := db.Table(&Role{}, opts...)
+			r := &Role{Name: "user", Description: "user"}
+			if err := roles.Insert(r); err != nil {
+				t.Fatal(err)
+			}
+			var got Role
+			if err := roles.Find("Name", "user", &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(r, &got); diff != "" {
+				t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+			}
+			if err := roles.Drop(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
 type Cert struct {
 	Path        string `e2db:"id"`
 	Description string `e2db:"index"`
@@ -416,3 +606,696 @@ func TestEncryptedField(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type Widget struct {
+	ID   int    `e2db:"increment"`
+	Name string `e2db:"unique"`
+}
+
+func TestTables(t *testing.T) {
+	tdb, err := e2db.New(context.Background(), &e2db.Config{
+		ClientAddr: ":2479",
+		Namespace:  "tables",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tdb.Close()
+
+	roles := tdb.Table(&Role{})
+	widgets := tdb.Table(&Widget{})
+	for _, table := range []*e2db.Table{roles, widgets} {
+		if err := table.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range newRoles {
+		if err := roles.Insert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := widgets.Insert(&Widget{Name: "sprocket"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := tdb.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	if diff := cmp.Diff([]string{"Role", "Widget"}, names); diff != "" {
+		t.Errorf("e2db: after Tables differs: (-want +got)\n%s", diff)
+	}
+	if _, ok := tables[0].Fields["Description"]; !ok {
+		t.Fatalf("expected Role table to have field Description, received %v", tables[0].Fields)
+	}
+
+	for name, expected := range map[string]int64{"Role": 4, "Widget": 1} {
+		n, err := tdb.RowCount(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != expected {
+			t.Fatalf("expected %d rows in %s, received %d", expected, name, n)
+		}
+	}
+}
+
+func TestSchemaMismatch(t *testing.T) {
+	sdb, err := e2db.New(context.Background(), &e2db.Config{
+		ClientAddr: ":2479",
+		Namespace:  "schema",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdb.Close()
+
+	roles := sdb.Table(&Role{})
+	if err := roles.Insert(&Role{Name: "user", Description: "user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// querying into a renamed struct
+	type RenamedRole Role
+	var r RenamedRole
+	if err := roles.Find("Name", "user", &r); errors.Cause(err) != e2db.ErrSchemaMismatch {
+		t.Fatalf("expected %v, received %v", e2db.ErrSchemaMismatch, err)
+	}
+
+	// a struct with the same name whose fields have changed
+	{
+		type Role struct {
+			ID          int    `e2db:"increment"`
+			DisplayName string `e2db:"unique"`
+			Description string `e2db:"index,required"`
+		}
+		changed := sdb.Table(&Role{})
+		var r Role
+		err := changed.Find("ID", 1, &r)
+		if errors.Cause(err) != e2db.ErrSchemaMismatch {
+			t.Fatalf("expected %v, received %v", e2db.ErrSchemaMismatch, err)
+		}
+		for _, s := range []string{"[Description ID LimitRange Name NotIndexed ResourceQuota SuperAdminOnly]", "[Description DisplayName ID]"} {
+			if !strings.Contains(err.Error(), s) {
+				t.Fatalf("expected error to contain the fields %s, received %v", s, err)
+			}
+		}
+		if err := changed.Insert(&Role{DisplayName: "user", Description: "user"}); errors.Cause(err) != e2db.ErrSchemaMismatch {
+			t.Fatalf("expected %v, received %v", e2db.ErrSchemaMismatch, err)
+		}
+
+		// the changed table can still be dropped and replaced
+		if err := changed.Drop(); err != nil {
+			t.Fatal(err)
+		}
+		if err := changed.Insert(&Role{DisplayName: "user", Description: "user"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := changed.Drop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDropNamespace(t *testing.T) {
+	newDB := func(ns string) *e2db.DB {
+		db, err := e2db.New(context.Background(), &e2db.Config{
+			ClientAddr: ":2479",
+			Namespace:  ns,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	db := newDB("drop")
+	defer db.Close()
+
+	// a namespace sharing the same prefix must not be dropped
+	other := newDB("dropother")
+	defer other.Close()
+
+	// dropping an empty namespace is not an error
+	if err := db.DropNamespace(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []*e2db.DB{db, other} {
+		if err := d.Table(&Role{}).Insert(&Role{Name: "user", Description: "user"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Table(&Widget{}).Insert(&Widget{Name: "sprocket"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.DropNamespace(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Role", "Widget"} {
+		n, err := db.RowCount(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Fatalf("expected 0 rows in %s, received %d", name, n)
+		}
+	}
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 0 {
+		t.Fatalf("expected no tables, received %d", len(tables))
+	}
+	if n, err := db.Table(&Role{}).Count("Name", "user"); err != nil || n != 0 {
+		t.Fatalf("expected 0 rows matching Name, received %d: %v", n, err)
+	}
+
+	for name, expected := range map[string]int64{"Role": 1, "Widget": 1} {
+		n, err := other.RowCount(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != expected {
+			t.Fatalf("expected %d rows in %s, received %d", expected, name, n)
+		}
+	}
+	if err := other.DropNamespace(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type Event struct {
+	ID      int       `e2db:"increment"`
+	Name    string    `e2db:"unique"`
+	Created time.Time `e2db:"index"`
+}
+
+func TestIndexedTimeAcrossTimezones(t *testing.T) {
+	events := db.Table(&Event{}, e2db.WithTimePrecision(time.Millisecond))
+	if err := events.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2020, 7, 1, 12, 30, 0, 123456789, time.UTC)
+	est := ts.In(time.FixedZone("EST", -5*60*60))
+	if err := events.Insert(&Event{Name: "a", Created: est}); err != nil {
+		t.Fatal(err)
+	}
+	if err := events.Insert(&Event{Name: "b", Created: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same instant written from different timezones shares an index key
+	for _, q := range []time.Time{ts, est, ts.In(time.FixedZone("JST", 9*60*60))} {
+		var e []*Event
+		if err := events.Find("Created", q, &e); err != nil {
+			t.Fatal(err)
+		}
+		if len(e) != 2 {
+			t.Fatalf("expected 2 events for %v, received %d", q, len(e))
+		}
+	}
+
+	// updating to the same instant in another timezone leaves the index alone
+	if err := events.Update(&Event{ID: 1, Name: "a", Created: ts}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := events.Count("Created", est)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 indexed events, received %d", n)
+	}
+}
+
+type Deployment struct {
+	ID          int               `e2db:"increment"`
+	Name        string            `e2db:"unique"`
+	Labels      []string          `e2db:"index"`
+	Annotations map[string]string `e2db:"index"`
+}
+
+func TestIndexedSliceField(t *testing.T) {
+	deployments := db.Table(&Deployment{})
+	if err := deployments.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	for _, d := range []*Deployment{
+		{Name: "api", Labels: []string{"prod", "web", "prod"}, Annotations: map[string]string{"team": "core"}},
+		{Name: "worker", Labels: []string{"prod"}, Annotations: map[string]string{"team": "data"}},
+		{Name: "canary", Labels: []string{"staging", "web"}},
+	} {
+		if err := deployments.Insert(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(field string, value interface{}) []string {
+		var d []*Deployment
+		if err := deployments.Find(field, value, &d); err != nil && errors.Cause(err) != e2db.ErrNoRows {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, v := range d {
+			names = append(names, v.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	cases := []struct {
+		field    string
+		value    interface{}
+		expected []string
+	}{
+		{"Labels", "prod", []string{"api", "worker"}},
+		{"Labels", "web", []string{"api", "canary"}},
+		{"Labels", "dev", []string{}},
+		{"Annotations", "team=core", []string{"api"}},
+	}
+	for _, tc := range cases {
+		if diff := cmp.Diff(tc.expected, names(tc.field, tc.value)); diff != "" {
+			t.Errorf("%s=%v: after Find differs: (-want +got)\n%s", tc.field, tc.value, diff)
+		}
+	}
+
+	// updating replaces only the index entries that changed
+	if err := deployments.Update(&Deployment{ID: 1, Name: "api", Labels: []string{"web", "canary"}, Annotations: map[string]string{"team": "data"}}); err != nil {
+		t.Fatal(err)
+	}
+	cases = []struct {
+		field    string
+		value    interface{}
+		expected []string
+	}{
+		{"Labels", "prod", []string{"worker"}},
+		{"Labels", "web", []string{"api", "canary"}},
+		{"Labels", "canary", []string{"api"}},
+		{"Annotations", "team=core", []string{}},
+		{"Annotations", "team=data", []string{"api", "worker"}},
+	}
+	for _, tc := range cases {
+		if diff := cmp.Diff(tc.expected, names(tc.field, tc.value)); diff != "" {
+			t.Errorf("%s=%v: after Update differs: (-want +got)\n%s", tc.field, tc.value, diff)
+		}
+	}
+
+	// deleting removes the index entries for every element
+	n, err := deployments.Delete("Labels", "canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 deleted, received %d", n)
+	}
+	if diff := cmp.Diff([]string{"canary"}, names("Labels", "web")); diff != "" {
+		t.Errorf("after Delete differs: (-want +got)\n%s", diff)
+	}
+}
+
+type UniqueTags struct {
+	ID   int      `e2db:"increment"`
+	Tags []string `e2db:"unique"`
+}
+
+func TestUniqueSliceField(t *testing.T) {
+	tags := db.Table(&UniqueTags{})
+	if err := tags.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	if err := tags.Insert(&UniqueTags{Tags: []string{"a"}}); err == nil {
+		t.Fatal("expected error for unique slice field")
+	}
+}
+
+type Service struct {
+	ID        int    `e2db:"increment"`
+	Namespace string `e2db:"index,index:namespacedName"`
+	Name      string `e2db:"index:namespacedName"`
+	Port      int
+}
+
+func TestCompoundIndex(t *testing.T) {
+	services := db.Table(&Service{})
+	if err := services.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	for _, s := range []*Service{
+		{Namespace: "default", Name: "web", Port: 80},
+		{Namespace: "default", Name: "api", Port: 8080},
+		{Namespace: "kube-system", Name: "web", Port: 8000},
+		{Namespace: "kube-system", Name: "dns", Port: 53},
+	} {
+		if err := services.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// found by the ordered values, or by a struct with the values
+	var s Service
+	if err := services.Find("namespacedName", []string{"kube-system", "web"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	expected := &Service{ID: 3, Namespace: "kube-system", Name: "web", Port: 8000}
+	if diff := cmp.Diff(expected, &s); diff != "" {
+		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+	}
+	var ss []*Service
+	if err := services.Find("namespacedName", Service{Namespace: "default", Name: "api"}, &ss); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*Service{{ID: 2, Namespace: "default", Name: "api", Port: 8080}}, ss); diff != "" {
+		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+	}
+	if err := services.Find("namespacedName", []string{"web", "kube-system"}, &s); errors.Cause(err) != e2db.ErrNoRows {
+		t.Fatalf("expected ErrNoRows, received %v", err)
+	}
+	if err := services.Find("namespacedName", []string{"default"}, &s); err == nil {
+		t.Fatal("expected error for missing compound index value")
+	}
+
+	// fields only in a compound index are not indexed on their own
+	if err := services.Find("Name", "web", &ss); errors.Cause(err) != e2db.ErrNotIndexed {
+		t.Fatalf("expected ErrNotIndexed, received %v", err)
+	}
+	n, err := services.Count("Namespace", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 services in namespace default, received %d", n)
+	}
+
+	count := func(namespace, name string) int64 {
+		n, err := services.Count("namespacedName", []string{namespace, name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// updating replaces the compound index entry
+	if err := services.Update(&Service{ID: 1, Namespace: "default", Name: "www", Port: 80}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("default", "web"); n != 0 {
+		t.Fatalf("expected old compound index entry to be removed, received %d", n)
+	}
+	if n := count("default", "www"); n != 1 {
+		t.Fatalf("expected new compound index entry, received %d", n)
+	}
+
+	// updating other fields keeps the compound index entry
+	if err := services.Update(&Service{ID: 1, Namespace: "default", Name: "www", Port: 443}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("default", "www"); n != 1 {
+		t.Fatalf("expected compound index entry to be kept, received %d", n)
+	}
+
+	// deleting by compound index removes all of the row's index entries
+	n, err = services.Delete("namespacedName", []string{"kube-system", "dns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 deleted, received %d", n)
+	}
+	if n := count("kube-system", "dns"); n != 0 {
+		t.Fatalf("expected compound index entry to be removed, received %d", n)
+	}
+	n, err = services.Count("Namespace", "kube-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 service in namespace kube-system, received %d", n)
+	}
+}
+
+type Phase string
+
+type Job struct {
+	ID      int       `e2db:"increment"`
+	Phase   Phase     `e2db:"index"`
+	Host    net.IP    `e2db:"index"`
+	Done    bool      `e2db:"index"`
+	Score   float64   `e2db:"index"`
+	Started time.Time `e2db:"index"`
+}
+
+func TestIndexedCustomTypes(t *testing.T) {
+	jobs := db.Table(&Job{})
+	if err := jobs.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	ts := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	for _, j := range []*Job{
+		{Phase: "running", Host: net.ParseIP("10.0.0.1"), Score: 0.5, Started: ts},
+		{Phase: "done", Host: net.ParseIP("10.0.0.2"), Done: true, Score: -1.25, Started: ts.Add(-time.Hour)},
+		{Phase: "running", Host: net.ParseIP("10.0.0.2"), Score: 10, Started: ts.Add(time.Hour)},
+	} {
+		if err := jobs.Insert(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(field string, value interface{}) []int {
+		var j []*Job
+		if err := jobs.Find(field, value, &j); err != nil && errors.Cause(err) != e2db.ErrNoRows {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0)
+		for _, job := range j {
+			ids = append(ids, job.ID)
+		}
+		sort.Ints(ids)
+		return ids
+	}
+	cases := []struct {
+		field    string
+		value    interface{}
+		expected []int
+	}{
+		{"Phase", Phase("running"), []int{1, 3}},
+		{"Phase", "done", []int{2}},
+		{"Host", net.ParseIP("10.0.0.2"), []int{2, 3}},
+		{"Done", true, []int{2}},
+		{"Score", -1.25, []int{2}},
+		{"Started", ts.Add(time.Hour), []int{3}},
+		{"Started", ts.In(time.FixedZone("EST", -5*60*60)), []int{1}},
+	}
+	for _, tc := range cases {
+		if diff := cmp.Diff(tc.expected, ids(tc.field, tc.value)); diff != "" {
+			t.Errorf("%s=%v: after Find differs: (-want +got)\n%s", tc.field, tc.value, diff)
+		}
+	}
+
+	// ordered types are sorted by value rather than by their string form
+	sorted := func(query e2db.Query) []int {
+		var j []*Job
+		if err := query.All(&j); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0)
+		for _, job := range j {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	if diff := cmp.Diff([]int{2, 1, 3}, sorted(jobs.OrderBy("Score"))); diff != "" {
+		t.Errorf("after OrderBy differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{2, 1, 3}, sorted(jobs.OrderBy("Started"))); diff != "" {
+		t.Errorf("after OrderBy differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 3}, sorted(jobs.Where("Score").GreaterThan(-1.0))); diff != "" {
+		t.Errorf("after Where differs: (-want +got)\n%s", diff)
+	}
+}
+
+type Account struct {
+	ID        int       `e2db:"increment"`
+	Name      string    `e2db:"unique"`
+	Team      string    `e2db:"index"`
+	DeletedAt time.Time `e2db:"softdelete"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	accounts := db.Table(&Account{})
+	if err := accounts.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	for _, a := range []*Account{
+		{Name: "alice", Team: "red"},
+		{Name: "bob", Team: "red"},
+		{Name: "carol", Team: "blue"},
+	} {
+		if err := accounts.Insert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	n, err := accounts.Delete("Name", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 row deleted, received %d", n)
+	}
+
+	names := func(accts []*Account) []string {
+		names := make([]string, 0)
+		for _, a := range accts {
+			names = append(names, a.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// soft-deleted rows are excluded by default
+	var all []*Account
+	if err := accounts.All(&all); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"bob", "carol"}, names(all)); diff != "" {
+		t.Errorf("e2db: after All differs: (-want +got)\n%s", diff)
+	}
+	var red []*Account
+	if err := accounts.Find("Team", "red", &red); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"bob"}, names(red)); diff != "" {
+		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+	}
+	var a Account
+	if err := accounts.Find("ID", 1, &a); errors.Cause(err) != e2db.ErrNoRows {
+		t.Fatalf("expected ErrNoRows, received %v", err)
+	}
+	if err := accounts.Find("Name", "alice", &a); errors.Cause(err) != e2db.ErrNoRows {
+		t.Fatalf("expected ErrNoRows, received %v", err)
+	}
+	var page []*Account
+	if _, err := accounts.Page(10, "", &page); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"bob", "carol"}, names(page)); diff != "" {
+		t.Errorf("e2db: after Page differs: (-want +got)\n%s", diff)
+	}
+
+	// soft-deleted rows are kept, and can be included explicitly
+	all = nil
+	if err := accounts.IncludeDeleted().All(&all); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"alice", "bob", "carol"}, names(all)); diff != "" {
+		t.Errorf("e2db: after All differs: (-want +got)\n%s", diff)
+	}
+	if err := accounts.IncludeDeleted().Find("ID", 1, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "alice" || a.DeletedAt.Before(start.Truncate(time.Second)) {
+		t.Fatalf("expected alice to be deleted after %v, received %#v", start, a)
+	}
+	if n, err := db.RowCount("Account"); err != nil || n != 3 {
+		t.Fatalf("expected 3 rows, received %d: %v", n, err)
+	}
+
+	// a soft-deleted row is not deleted again, and its unique value can be
+	// reused
+	n, err = accounts.Delete("Name", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected 0 rows deleted, received %d", n)
+	}
+	if err := accounts.Insert(&Account{Name: "alice", Team: "blue"}); err != nil {
+		t.Fatal(err)
+	}
+	var blue []*Account
+	if err := accounts.Find("Team", "blue", &blue); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"alice", "carol"}, names(blue)); diff != "" {
+		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+	}
+}
+
+type VersionedAccount struct {
+	ID        int       `e2db:"increment"`
+	Name      string    `e2db:"unique"`
+	Team      string    `e2db:"index"`
+	Version   int       `e2db:"version"`
+	DeletedAt time.Time `e2db:"softdelete"`
+}
+
+func TestSoftDeleteUpdate(t *testing.T) {
+	accounts := db.Table(&VersionedAccount{})
+	if err := accounts.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	for _, a := range []*VersionedAccount{
+		{Name: "alice", Team: "red"},
+		{Name: "bob", Team: "red"},
+	} {
+		if err := accounts.Insert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(accts []*VersionedAccount) []string {
+		names := make([]string, 0)
+		for _, a := range accts {
+			names = append(names, a.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// soft-deleting a row with Update removes its indexes, the same as Delete
+	var alice VersionedAccount
+	if err := accounts.Find("Name", "alice", &alice); err != nil {
+		t.Fatal(err)
+	}
+	alice.DeletedAt = time.Now().UTC()
+	if err := accounts.Update(&alice); err != nil {
+		t.Fatal(err)
+	}
+	var red []*VersionedAccount
+	if err := accounts.IncludeDeleted().Find("Team", "red", &red); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"bob"}, names(red)); diff != "" {
+		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+	}
+	if err := accounts.Insert(&VersionedAccount{Name: "alice", Team: "blue"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a soft-deleted row is written again by Update, starting again at
+	// version 1
+	restored := &VersionedAccount{ID: alice.ID, Name: "alicia", Team: "red"}
+	if err := accounts.Update(restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version != 1 {
+		t.Fatalf("expected version 1, received %d", restored.Version)
+	}
+	red = nil
+	if err := accounts.Find("Team", "red", &red); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"alicia", "bob"}, names(red)); diff != "" {
+		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+	}
+
+	// once written again, the row is no longer deleted
+	if err := accounts.Update(&VersionedAccount{ID: alice.ID, Name: "alicia", Team: "blue"}); errors.Cause(err) != e2db.ErrConflict {
+		t.Fatalf("expected ErrConflict, received %v", err)
+	}
+}
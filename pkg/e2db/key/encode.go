@@ -0,0 +1,130 @@
+package key
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Encode returns the canonical string form of v, which is used for primary
+// keys and index values. Times are formatted in UTC without the monotonic clock
+// reading, so that the same instant always produces the same string. Types
+// with a String or MarshalText method use it, and other types are encoded by
+// their underlying kind, so that custom types like `type Phase string` can be
+// indexed. Nil values, including nil pointers, are encoded as an empty string.
+func Encode(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return t.String()
+	case error:
+		return t.Error()
+	case encoding.TextMarshaler:
+		if b, err := t.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return ""
+		}
+		return Encode(rv.Elem().Interface())
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f == 0 {
+			// negative zero is equal to zero, so it must be encoded the same
+			f = 0
+		}
+		return strconv.FormatFloat(f, 'f', -1, rv.Type().Bits())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
+		}
+	}
+	panic(fmt.Sprintf("unknown type: %T", v))
+}
+
+// Sortable returns a string form of v that sorts in the same order as v, for
+// integers, floats, time.Time, bools, strings and byte slices (including custom
+// types of those kinds). It returns false for other types.
+func Sortable(v interface{}) (string, bool) {
+	if t, ok := v.(time.Time); ok {
+		return Time(t), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Bool:
+		if rv.Bool() {
+			return "1", true
+		}
+		return "0", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Uint(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return Float(rv.Float()), true
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes()), true
+		}
+	}
+	return "", false
+}
+
+// Int encodes i so that the encoded values sort in the same order as the
+// integers themselves. The sign bit is flipped so that negative values sort
+// before positive values.
+func Int(i int64) string {
+	return fmt.Sprintf("%016x", uint64(i)^(1<<63))
+}
+
+// Uint encodes u so that the encoded values sort in the same order as the
+// integers themselves.
+func Uint(u uint64) string {
+	return fmt.Sprintf("%016x", u)
+}
+
+// Float encodes f so that the encoded values sort in the same order as the
+// floats themselves. Positive values have the sign bit set, and negative values
+// have every bit flipped, so that larger magnitudes sort first.
+func Float(f float64) string {
+	if f == 0 {
+		f = 0
+	}
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("%016x", bits)
+}
+
+// Time encodes t so that the encoded values sort in the same order as the
+// instants themselves, regardless of timezone.
+func Time(t time.Time) string {
+	return fmt.Sprintf("%s%08x", Int(t.Unix()), t.Nanosecond())
+}
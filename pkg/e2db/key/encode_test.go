@@ -0,0 +1,96 @@
+package key
+
+import (
+	"math"
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+type phase string
+
+type priority int
+
+func TestEncode(t *testing.T) {
+	ts := time.Date(2020, 7, 1, 12, 30, 0, 500, time.UTC)
+	var nilPtr *int
+	n := 5
+	cases := []struct {
+		name     string
+		v        interface{}
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"string", "value", "value"},
+		{"bytes", []byte("value"), "value"},
+		{"bool", true, "true"},
+		{"int", -10, "-10"},
+		{"uint64", uint64(math.MaxUint64), "18446744073709551615"},
+		{"float", 1.5, "1.5"},
+		{"float32", float32(0.1), "0.1"},
+		{"negative zero", math.Copysign(0, -1), "0"},
+		{"time", ts, "2020-07-01T12:30:00.0000005Z"},
+		{"time in timezone", ts.In(time.FixedZone("EST", -5*60*60)), "2020-07-01T12:30:00.0000005Z"},
+		{"stringer", net.ParseIP("10.0.0.1"), "10.0.0.1"},
+		{"custom string", phase("running"), "running"},
+		{"custom int", priority(3), "3"},
+		{"pointer", &n, "5"},
+		{"nil pointer", nilPtr, ""},
+	}
+	for _, tc := range cases {
+		if got := Encode(tc.v); got != tc.expected {
+			t.Errorf("%s: expected %q, received %q", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestEncodeUnknownType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Encode to panic")
+		}
+	}()
+	Encode(struct{}{})
+}
+
+func assertSorted(t *testing.T, values ...interface{}) {
+	t.Helper()
+	keys := make([]string, 0)
+	for _, v := range values {
+		s, ok := Sortable(v)
+		if !ok {
+			t.Fatalf("expected %T to be sortable", v)
+		}
+		keys = append(keys, s)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("expected encoded values to be sorted: %v", keys)
+	}
+}
+
+func TestSortable(t *testing.T) {
+	assertSorted(t, int64(math.MinInt64), int64(-1<<40), int64(-256), int64(-2), int64(-1), int64(0), int64(1), int64(2), int64(255), int64(256), int64(1<<40), int64(math.MaxInt64))
+	assertSorted(t, uint64(0), uint64(1), uint64(255), uint64(256), uint64(1<<40), uint64(math.MaxUint64))
+	assertSorted(t, priority(-1), priority(0), priority(10))
+	assertSorted(t, math.Inf(-1), -math.MaxFloat64, -1.5, -1.0, -math.SmallestNonzeroFloat64, 0.0, math.SmallestNonzeroFloat64, 0.5, 1.0, 100.25, math.MaxFloat64, math.Inf(1))
+	assertSorted(t, false, true)
+
+	ts := time.Date(2020, 7, 1, 12, 30, 0, 0, time.UTC)
+	assertSorted(t,
+		time.Date(1500, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Unix(-1, 999999999),
+		time.Unix(0, 0),
+		ts,
+		ts.Add(1).In(time.FixedZone("JST", 9*60*60)),
+		ts.Add(time.Second).In(time.FixedZone("EST", -5*60*60)),
+		time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC),
+	)
+
+	if a, _ := Sortable(math.Copysign(0, -1)); a != Float(0) {
+		t.Fatalf("expected negative zero to be encoded as zero, received %q", a)
+	}
+	if _, ok := Sortable(struct{}{}); ok {
+		t.Fatal("expected struct to not be sortable")
+	}
+}
@@ -2,6 +2,7 @@ package key
 
 import (
 	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 const (
 	indexPrefix = "_index"
+	rangePrefix = "_range"
 	tablePrefix = "_table"
 )
 
@@ -25,6 +27,22 @@ func Hash(s string) string {
 	return fmt.Sprintf("%x", h.Sum([]byte(s)))
 }
 
+// Unhash returns the value that was passed to Hash. Hash appends the digest
+// of nothing to the value, rather than digesting the value, so the value is
+// the decoded hash without that digest. False is returned when h was not
+// returned by Hash.
+func Unhash(h string) (string, bool) {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return "", false
+	}
+	suffix := sha512.New().Sum(nil)
+	if len(b) < len(suffix) || string(b[len(b)-len(suffix):]) != string(suffix) {
+		return "", false
+	}
+	return string(b[:len(b)-len(suffix)]), true
+}
+
 func Hidden(model string) string {
 	return join(model, "_")
 }
@@ -57,6 +75,50 @@ func Indexes(model, field, value string) string {
 	return join(model, indexPrefix, field, Hash(value))
 }
 
+// AllIndexes is the prefix of every index entry of a table, including the
+// unique, range and compound indexes.
+func AllIndexes(model string) string {
+	return join(model, indexPrefix) + "/"
+}
+
+// FieldIndexes is the prefix of every index entry for a field, including its
+// range index entries.
+func FieldIndexes(model, field string) string {
+	return join(model, indexPrefix, field) + "/"
+}
+
+// IndexValue returns the value of an index or unique index entry of the field
+// that starts with FieldIndexes. False is returned for other keys, like the
+// range index entries of the field.
+func IndexValue(model, field, k string) (string, bool) {
+	prefix := FieldIndexes(model, field)
+	if !strings.HasPrefix(k, prefix) {
+		return "", false
+	}
+	h := strings.SplitN(strings.TrimPrefix(k, prefix), "/", 2)[0]
+	if h == rangePrefix {
+		return "", false
+	}
+	return Unhash(h)
+}
+
 func Unique(model, field, value string) string {
 	return join(model, indexPrefix, field, Hash(value))
 }
+
+// Range is the key of a range index entry, where value is sortable (see
+// Sortable), so that rows can be read in order of the field value.
+func Range(model, field, value, id string) string {
+	return join(model, indexPrefix, field, rangePrefix, value, id)
+}
+
+// Ranges is the prefix of all range index entries for a field.
+func Ranges(model, field string) string {
+	return join(model, indexPrefix, field, rangePrefix) + "/"
+}
+
+// Compound joins the values of the fields in a compound index into the single
+// value that is used with Index and Indexes.
+func Compound(values ...string) string {
+	return strings.Join(values, "\x00")
+}
@@ -0,0 +1,29 @@
+package key
+
+import "testing"
+
+func TestIndexValue(t *testing.T) {
+	cases := []struct {
+		name  string
+		field string
+		key   string
+		value string
+		ok    bool
+	}{
+		{"index", "Description", Index("Role", "Description", "administrator", "2"), "administrator", true},
+		{"unique", "Name", Unique("Role", "Name", "admin"), "admin", true},
+		{"empty value", "Description", Index("Role", "Description", "", "2"), "", true},
+		{"compound", "NameDescription", Index("Role", "NameDescription", Compound("admin", "administrator"), "2"), Compound("admin", "administrator"), true},
+		{"range", "Description", Range("Role", "Description", "administrator", "2"), "", false},
+		{"other field", "Description", Index("Role", "Name", "admin", "2"), "", false},
+		{"not hashed", "Description", FieldIndexes("Role", "Description") + "administrator/2", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			value, ok := IndexValue("Role", c.field, c.key)
+			if value != c.value || ok != c.ok {
+				t.Fatalf("expected %#v %v, received %#v %v", c.value, c.ok, value, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package e2db
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+
+	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/e2db/key"
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// Migrate converts the rows of a table stored with the model from into the
+// table's model. The model from must be created with NewModelDef from the
+// previous struct type, which must have the same name. Each row is decoded
+// into the previous struct type, and fn is called to set the fields of the new
+// struct from the old struct, both of which are addressable struct values.
+// The rows are then rewritten along with all of their indexes, and the stored
+// table definition is replaced.
+//
+// Migrate returns ErrSchemaMismatch if the stored table definition does not
+// match from, and does nothing if the table has already been migrated. Rows
+// are rewritten one at a time, so when Migrate fails part way through, it can
+// be called again to finish migrating the remaining rows. Rows that have a
+// version field are rewritten with version 1, the same as when inserted.
+func (t *Table) Migrate(from *ModelDef, fn func(old, new reflect.Value) error) error {
+	if from.t == nil {
+		return errors.New("cannot migrate table: previous model must be created with NewModelDef")
+	}
+	if from.Name != t.meta.Name {
+		return errors.Errorf("cannot migrate table %#v from a model with a different name: %#v", t.meta.Name, from.Name)
+	}
+
+	unlock, err := t.db.client.Lock(key.TableLock(t.meta.Name), t.db.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v, err := t.db.client.Get(key.TableDef(t.meta.Name))
+	if err != nil {
+		if errors.Cause(err) == client.ErrKeyNotFound {
+			return errors.Wrap(ErrTableNotFound, t.meta.Name)
+		}
+		return err
+	}
+	var stored *ModelDef
+	if err := t.tc.Decode(v, &stored); err != nil {
+		return err
+	}
+	if t.validateModel(stored) == nil {
+		log.Debugf("table %s has already been migrated", t.meta.Name)
+		return nil
+	}
+	prev := &Table{db: t.db, c: t.c, tc: t.tc, meta: from}
+	if err := prev.validateModel(stored); err != nil {
+		return err
+	}
+
+	kvs, err := t.db.client.Prefix(key.Table(t.meta.Name))
+	if err != nil {
+		return err
+	}
+
+	// The indexes are removed before the rows are rewritten, so that the
+	// unique indexes of the rewritten rows do not conflict with the unique
+	// indexes of the rows being replaced.
+	if _, err := t.db.client.DeletePrefix(key.AllIndexes(t.meta.Name)); err != nil {
+		return err
+	}
+
+	tx := &Tx{t}
+	hidden := key.Hidden(t.meta.Name)
+	var n int
+	for _, kv := range kvs {
+		k := string(kv.Key)
+		if strings.HasPrefix(k, hidden) {
+			continue
+		}
+		old := reflect.New(from.t)
+		if err := t.c.Decode(kv.Value, old.Interface()); err != nil {
+			return errors.Wrapf(err, "cannot decode row %#v", k)
+		}
+		if err := newQuery(prev).handleItemTags(old.Elem()); err != nil {
+			return err
+		}
+		v := reflect.New(t.meta.t)
+		if err := fn(old.Elem(), v.Elem()); err != nil {
+			return errors.Wrapf(err, "cannot migrate row %#v", k)
+		}
+		cmps, ops, err := tx.insertOps(v.Interface(), 0)
+		if err != nil {
+			return errors.Wrapf(err, "cannot migrate row %#v", k)
+		}
+
+		// the existing row is replaced, rather than required to not exist
+		uniqueCmps := make([]clientv3.Cmp, 0)
+		for _, c := range cmps {
+			if c.Target != etcdserverpb.Compare_MOD {
+				uniqueCmps = append(uniqueCmps, c)
+			}
+		}
+		pk, err := NewModelItem(v).getPrimaryKey()
+		if err != nil {
+			return err
+		}
+		if id := key.ID(t.meta.Name, toString(pk.value.Interface())); id != k {
+			ops = append(ops, clientv3.OpDelete(k))
+		}
+		if _, err := tx.batchOps(uniqueCmps, ops...); err != nil {
+			return errors.Wrapf(err, "cannot migrate row %#v", k)
+		}
+		n++
+	}
+
+	data, err := t.tc.Encode(t.meta)
+	if err != nil {
+		return err
+	}
+	if err := t.db.client.Set(key.TableDef(t.meta.Name), string(data)); err != nil {
+		return err
+	}
+	log.Debugf("migrated table %s, %d rows rewritten", t.meta.Name, n)
+	return nil
+}
+
+// Reindex writes the compound and range index keys of every row in the table.
+// These keys are maintained for the row as a whole, so rows written before
+// they were added to e2db do not have them, and are not found by range
+// queries until Reindex is called (or the row is updated). Soft-deleted rows
+// are skipped, since they are stored without indexes. Reindex can be called
+// again safely, as it only rewrites the keys of each row.
+func (t *Table) Reindex() error {
+	unlock, err := t.db.client.Lock(key.TableLock(t.meta.Name), t.db.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v, err := t.db.client.Get(key.TableDef(t.meta.Name))
+	if err != nil {
+		if errors.Cause(err) == client.ErrKeyNotFound {
+			return errors.Wrap(ErrTableNotFound, t.meta.Name)
+		}
+		return err
+	}
+	var stored *ModelDef
+	if err := t.tc.Decode(v, &stored); err != nil {
+		return err
+	}
+	if err := t.validateModel(stored); err != nil {
+		return err
+	}
+	kvs, err := t.db.client.Prefix(key.Table(t.meta.Name))
+	if err != nil {
+		return err
+	}
+	tx := &Tx{t}
+	hidden := key.Hidden(t.meta.Name)
+	var n int
+	for _, kv := range kvs {
+		k := string(kv.Key)
+		if strings.HasPrefix(k, hidden) {
+			continue
+		}
+		row := reflect.New(t.meta.t)
+		if err := t.c.Decode(kv.Value, row.Interface()); err != nil {
+			return errors.Wrapf(err, "cannot decode row %#v", k)
+		}
+		if t.isDeleted(row) {
+			continue
+		}
+		pk, err := NewModelItem(row).getPrimaryKey()
+		if err != nil {
+			return err
+		}
+		keys, err := t.rowIndexKeys(row.Elem(), toString(pk.value.Interface()))
+		if err != nil {
+			return errors.Wrapf(err, "cannot reindex row %#v", k)
+		}
+		ops := make([]clientv3.Op, 0)
+		for _, idx := range keys {
+			ops = append(ops, clientv3.OpPut(idx, k))
+		}
+
+		// a row written since it was read already has its keys
+		if _, err := tx.batchOps([]clientv3.Cmp{revisionCmp(k, kv.ModRevision)}, ops...); err != nil {
+			if errors.Cause(err) == ErrConflict {
+				continue
+			}
+			return errors.Wrapf(err, "cannot reindex row %#v", k)
+		}
+		n++
+	}
+	log.Debugf("reindexed table %s, %d rows", t.meta.Name, n)
+	return nil
+}
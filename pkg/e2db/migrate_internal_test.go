@@ -0,0 +1,77 @@
+package e2db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/e2db/key"
+)
+
+type measurement struct {
+	ID        int       `e2db:"increment"`
+	Value     int64     `e2db:"index"`
+	DeletedAt time.Time `e2db:"softdelete"`
+}
+
+func TestReindex(t *testing.T) {
+	// the server is started by the init of the external tests
+	db, err := New(context.Background(), &Config{
+		ClientAddr: ":2479",
+		Namespace:  "reindex",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	measurements := db.Table(&measurement{})
+	if err := measurements.Drop(); err != nil && errors.Cause(err) != ErrTableNotFound {
+		t.Fatal(err)
+	}
+	for _, v := range []int64{30, 10, 20} {
+		if err := measurements.Insert(&measurement{Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := measurements.Insert(&measurement{Value: 15, DeletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows written before range indexes existed have no range index keys
+	if _, err := db.client.DeletePrefix(key.Ranges(measurements.meta.Name, "Value")); err != nil {
+		t.Fatal(err)
+	}
+	values := func() []int64 {
+		var m []*measurement
+		if err := measurements.Where("Value").GreaterThan(0).All(&m); err != nil && errors.Cause(err) != ErrNoRows {
+			t.Fatal(err)
+		}
+		values := make([]int64, 0)
+		for _, v := range m {
+			values = append(values, v.Value)
+		}
+		return values
+	}
+	if diff := cmp.Diff([]int64{}, values()); diff != "" {
+		t.Fatalf("before Reindex differs: (-want +got)\n%s", diff)
+	}
+	for i := 0; i < 2; i++ {
+		if err := measurements.Reindex(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]int64{10, 20, 30}, values()); diff != "" {
+			t.Fatalf("after Reindex differs: (-want +got)\n%s", diff)
+		}
+	}
+	n, err := db.client.Count(key.Ranges(measurements.meta.Name, "Value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 range index keys, received %d", n)
+	}
+}
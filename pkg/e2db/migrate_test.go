@@ -0,0 +1,111 @@
+package e2db_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/e2db"
+)
+
+// Profile is the previous version of the model in TestMigrate.
+type Profile struct {
+	ID    int    `e2db:"increment"`
+	Name  string `e2db:"unique"`
+	Email string
+}
+
+func TestMigrate(t *testing.T) {
+	mdb, err := e2db.New(context.Background(), &e2db.Config{
+		ClientAddr: ":2479",
+		Namespace:  "migrate",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+
+	from := e2db.NewModelDef(reflect.TypeOf(Profile{}))
+	profiles := mdb.Table(&Profile{})
+	for _, p := range []*Profile{
+		{Name: "smoot", Email: "smoot@example.com"},
+		{Name: "chris", Email: "chris@criticalstack.com"},
+		{Name: "alice", Email: "alice@criticalstack.com"},
+	} {
+		if err := profiles.Insert(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the new version of the model adds an indexed field
+	type Profile struct {
+		ID     int    `e2db:"increment"`
+		Name   string `e2db:"unique"`
+		Email  string
+		Domain string `e2db:"index"`
+	}
+	profiles = mdb.Table(&Profile{})
+	var p Profile
+	if err := profiles.Find("ID", 1, &p); errors.Cause(err) != e2db.ErrSchemaMismatch {
+		t.Fatalf("expected %v, received %v", e2db.ErrSchemaMismatch, err)
+	}
+
+	migrate := func(old, new reflect.Value) error {
+		new.FieldByName("ID").Set(old.FieldByName("ID"))
+		new.FieldByName("Name").Set(old.FieldByName("Name"))
+		email := old.FieldByName("Email").String()
+		new.FieldByName("Email").SetString(email)
+		new.FieldByName("Domain").SetString(email[len("smoot@"):])
+		return nil
+	}
+	if err := profiles.Migrate(e2db.NewModelDef(reflect.TypeOf(Role{})), migrate); err == nil {
+		t.Fatal("expected error migrating from a model with a different name")
+	}
+	if err := profiles.Migrate(from, migrate); err != nil {
+		t.Fatal(err)
+	}
+
+	// migrating again does nothing
+	if err := profiles.Migrate(from, func(old, new reflect.Value) error {
+		return errors.New("expected migration to not run")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*Profile
+	if err := profiles.Find("Domain", "criticalstack.com", &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Profile{
+		{ID: 2, Name: "chris", Email: "chris@criticalstack.com", Domain: "criticalstack.com"},
+		{ID: 3, Name: "alice", Email: "alice@criticalstack.com", Domain: "criticalstack.com"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("e2db: after Migrate differs: (-want +got)\n%s", diff)
+	}
+
+	// the increment is kept, and the unique index is rebuilt
+	p = Profile{Name: "bob", Email: "bob@example.com", Domain: "example.com"}
+	if err := profiles.Insert(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != 4 {
+		t.Fatalf("expected ID 4, received %d", p.ID)
+	}
+	if err := profiles.Insert(&Profile{Name: "smoot"}); errors.Cause(err) != e2db.ErrUniqueConstraint {
+		t.Fatalf("expected %v, received %v", e2db.ErrUniqueConstraint, err)
+	}
+	n, err := profiles.Count("Domain", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows, received %d", n)
+	}
+	if err := profiles.Drop(); err != nil {
+		t.Fatal(err)
+	}
+}
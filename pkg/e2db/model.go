@@ -3,7 +3,9 @@ package e2db
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -18,6 +20,12 @@ type Tag struct {
 	Name, Value string
 }
 
+// isCompoundIndex returns true for a tag like `e2db:"index:name"` that adds the
+// field to the compound index called name.
+func (t *Tag) isCompoundIndex() bool {
+	return t.Name == "index" && t.Value != ""
+}
+
 type FieldDef struct {
 	Name string
 	Tags []*Tag
@@ -27,8 +35,14 @@ func (f *FieldDef) isIndex() bool {
 	return f.isPrimaryKey() || f.hasTag("index", "unique")
 }
 
+// hasTag returns true if the field has any of the provided tags. A field that
+// is only part of a compound index does not have the index tag, since it is
+// not indexed on its own.
 func (f *FieldDef) hasTag(tags ...string) bool {
 	for _, t := range f.Tags {
+		if t.isCompoundIndex() {
+			continue
+		}
 		for _, tag := range tags {
 			if t.Name == tag {
 				return true
@@ -119,9 +133,8 @@ func readStructFields(t reflect.Type) map[string]*FieldDef {
 		tags := make([]*Tag, 0)
 		if tagValue, ok := f.Tag.Lookup("e2db"); ok {
 			for _, t := range strings.Split(tagValue, ",") {
-				parts := strings.SplitN(t, "=", 2)
-				if len(parts) == 2 {
-					tags = append(tags, &Tag{parts[0], parts[1]})
+				if i := strings.IndexAny(t, "=:"); i >= 0 {
+					tags = append(tags, &Tag{t[:i], t[i+1:]})
 				} else {
 					tags = append(tags, &Tag{Name: t})
 				}
@@ -167,9 +180,69 @@ type ModelDef struct {
 	Name   string
 	Fields map[string]*FieldDef
 
+	// Indexes are the compound indexes of the model by name, with the names of
+	// the fields in each index in the order they are declared.
+	Indexes map[string][]string
+
 	t reflect.Type
 }
 
+// readCompoundIndexes returns the compound indexes for the provided struct
+// fields. A compound index cannot share a name with a field, since queries
+// reference both by name.
+func readCompoundIndexes(t reflect.Type, fields map[string]*FieldDef) map[string][]string {
+	var indexes map[string][]string
+	for _, f := range fields {
+		for _, tag := range f.Tags {
+			if !tag.isCompoundIndex() {
+				continue
+			}
+			if _, ok := fields[tag.Value]; ok {
+				panic(fmt.Sprintf("compound index in type %v has the same name as a field: %q", t, tag.Value))
+			}
+			if indexes == nil {
+				indexes = make(map[string][]string)
+			}
+			indexes[tag.Value] = append(indexes[tag.Value], f.Name)
+		}
+	}
+	for _, names := range indexes {
+		sort.Slice(names, func(i, j int) bool {
+			fi, _ := t.FieldByName(names[i])
+			fj, _ := t.FieldByName(names[j])
+			for n := 0; n < len(fi.Index) && n < len(fj.Index); n++ {
+				if fi.Index[n] != fj.Index[n] {
+					return fi.Index[n] < fj.Index[n]
+				}
+			}
+			return len(fi.Index) < len(fj.Index)
+		})
+	}
+	return indexes
+}
+
+// field returns the definition of the named field or compound index. A
+// compound index is queried the same way as a secondary index.
+func (m *ModelDef) field(name string) (*FieldDef, bool) {
+	if f, ok := m.Fields[name]; ok {
+		return f, true
+	}
+	if _, ok := m.Indexes[name]; ok {
+		return &FieldDef{Name: name, Tags: []*Tag{{Name: "index"}}}, true
+	}
+	return nil, false
+}
+
+// fieldNames returns the sorted names of the fields of the model.
+func (m *ModelDef) fieldNames() []string {
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func NewModelDef(t reflect.Type) *ModelDef {
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -180,14 +253,33 @@ func NewModelDef(t reflect.Type) *ModelDef {
 	if t.NumField() == 0 {
 		panic("must have at least 1 struct field")
 	}
+	fields := readStructFields(t)
 	m := &ModelDef{
-		Name:   t.Name(),
-		Fields: readStructFields(t),
-		t:      t,
+		Name:    t.Name(),
+		Fields:  fields,
+		Indexes: readCompoundIndexes(t, fields),
+		t:       t,
+	}
+	if name := m.softDeleteField(); name != "" {
+		if sf, _ := t.FieldByName(name); sf.Type != reflect.TypeOf(time.Time{}) {
+			panic(fmt.Sprintf("softdelete field in type %v must be a time.Time: %q", t, name))
+		}
 	}
 	return m
 }
 
+// softDeleteField returns the name of the field with the softdelete tag, which
+// is set to the time a row is deleted rather than the row being removed, or an
+// empty string if the rows of the model are removed when deleted.
+func (m *ModelDef) softDeleteField() string {
+	for _, name := range m.fieldNames() {
+		if m.Fields[name].hasTag("softdelete") {
+			return name
+		}
+	}
+	return ""
+}
+
 func (m *ModelDef) New() *reflect.Value {
 	if m.t == nil {
 		return nil
@@ -236,3 +328,23 @@ func (m *ModelItem) getPrimaryKey() (*Field, error) {
 	}
 	return nil, ErrNoPrimaryKey
 }
+
+// getVersion returns the version field of the model, which is incremented on
+// every write, or nil if the model is not versioned.
+func (m *ModelItem) getVersion() (*Field, error) {
+	for _, f := range m.Fields {
+		if !f.hasTag("version") {
+			continue
+		}
+		switch f.value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !f.value.CanSet() {
+				return nil, errors.Errorf("must provide a pointer to write versioned type %s", m.Name)
+			}
+			return f, nil
+		default:
+			return nil, errors.Errorf("version field %#v must be an integer, received %s", f.Name, f.value.Type())
+		}
+	}
+	return nil, nil
+}
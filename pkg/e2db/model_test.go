@@ -285,6 +285,60 @@ func TestNewModelDef(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "compound index",
+			model: func() reflect.Type {
+				type nest struct {
+					Namespace string `e2db:"index:namespacedName"`
+				}
+				type testModel struct {
+					ID   string `e2db:"id"`
+					Name string `e2db:"unique,index:namespacedName"`
+					nest
+				}
+				return reflect.TypeOf(new(testModel))
+			},
+			expected: &e2db.ModelDef{
+				Name: "testModel",
+				Fields: fieldMap(
+					field("ID", "id"),
+					&e2db.FieldDef{
+						Name: "Name",
+						Tags: []*e2db.Tag{{Name: "unique"}, {Name: "index", Value: "namespacedName"}},
+					},
+					&e2db.FieldDef{
+						Name: "Namespace",
+						Tags: []*e2db.Tag{{Name: "index", Value: "namespacedName"}},
+					},
+				),
+				Indexes: map[string][]string{
+					"namespacedName": {"Name", "Namespace"},
+				},
+			},
+		},
+		{
+			name: "compound index same name as field",
+			model: func() reflect.Type {
+				type testModel struct {
+					ID        string `e2db:"id"`
+					Name      string `e2db:"index:Namespace"`
+					Namespace string `e2db:"index:Namespace"`
+				}
+				return reflect.TypeOf(new(testModel))
+			},
+			expectPanic: true,
+		},
+		{
+			name: "softdelete field not a time",
+			model: func() reflect.Type {
+				type testModel struct {
+					ID      string `e2db:"id"`
+					Deleted bool   `e2db:"softdelete"`
+				}
+				return reflect.TypeOf(new(testModel))
+			},
+			expectPanic: true,
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,113 @@
+package e2db
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+
+	"github.com/criticalstack/e2d/pkg/e2db/key"
+)
+
+// MultiTx is a transaction across multiple tables, created by DB.Tx. Writes
+// are not made immediately, but are committed together in a single etcd
+// transaction after the function passed to DB.Tx returns, so reads made
+// during the transaction do not see them.
+type MultiTx struct {
+	txs  map[string]*Tx
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+// Tx runs fn in a transaction across the provided tables. The tables are
+// locked in order of their names, so that transactions locking the same tables
+// cannot deadlock. The writes made with the MultiTx are committed atomically
+// if fn returns nil, and discarded otherwise.
+func (db *DB) Tx(tables []*Table, fn func(*MultiTx) error) error {
+	mtx := &MultiTx{txs: make(map[string]*Tx)}
+	names := make([]string, 0)
+	for _, t := range tables {
+		if _, ok := mtx.txs[t.meta.Name]; ok {
+			continue
+		}
+		if err := t.tableMustExist(); err != nil {
+			return err
+		}
+		mtx.txs[t.meta.Name] = &Tx{t}
+		names = append(names, t.meta.Name)
+	}
+	if len(names) == 0 {
+		return errors.New("must provide at least one table")
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		unlock, err := db.client.Lock(key.TableLock(name), db.cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	if err := fn(mtx); err != nil {
+		return err
+	}
+	if len(mtx.ops) == 0 {
+		return nil
+	}
+	_, err := mtx.txs[names[0]].batchOps(mtx.cmps, mtx.ops...)
+	return err
+}
+
+func (mtx *MultiTx) tx(t *Table) (*Tx, error) {
+	tx, ok := mtx.txs[t.meta.Name]
+	if !ok {
+		return nil, errors.Errorf("table %#v is not part of the transaction", t.meta.Name)
+	}
+	return tx, nil
+}
+
+// Insert adds the insert of the provided value into table t to the
+// transaction.
+func (mtx *MultiTx) Insert(t *Table, iface interface{}) error {
+	tx, err := mtx.tx(t)
+	if err != nil {
+		return err
+	}
+	cmps, ops, err := tx.insertOps(iface, 0)
+	if err != nil {
+		return err
+	}
+	mtx.cmps = append(mtx.cmps, cmps...)
+	mtx.ops = append(mtx.ops, ops...)
+	return nil
+}
+
+// Update adds the update of the provided value in table t to the transaction.
+func (mtx *MultiTx) Update(t *Table, iface interface{}) error {
+	tx, err := mtx.tx(t)
+	if err != nil {
+		return err
+	}
+	cmps, ops, err := tx.updateOps(iface)
+	if err != nil {
+		return err
+	}
+	mtx.cmps = append(mtx.cmps, cmps...)
+	mtx.ops = append(mtx.ops, ops...)
+	return nil
+}
+
+// Delete adds the delete of the rows in table t matching the provided field
+// value to the transaction, returning the number of rows that will be
+// deleted.
+func (mtx *MultiTx) Delete(t *Table, fieldName string, data interface{}) (int64, error) {
+	tx, err := mtx.tx(t)
+	if err != nil {
+		return 0, err
+	}
+	n, ops, err := tx.deleteRowsOps(fieldName, data)
+	if err != nil {
+		return 0, err
+	}
+	mtx.ops = append(mtx.ops, ops...)
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package e2db_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+
+	"github.com/criticalstack/e2d/pkg/e2db"
+)
+
+type Team struct {
+	ID   int    `e2db:"increment"`
+	Name string `e2db:"unique"`
+}
+
+type Member struct {
+	ID     int    `e2db:"increment"`
+	Name   string `e2db:"unique"`
+	TeamID int    `e2db:"index"`
+}
+
+func TestMultiTx(t *testing.T) {
+	teams := db.Table(&Team{})
+	members := db.Table(&Member{})
+	for _, table := range []*e2db.Table{teams, members} {
+		if err := table.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+			t.Fatal(err)
+		}
+	}
+
+	team := &Team{Name: "core"}
+	member := &Member{Name: "smoot"}
+	err := db.Tx([]*e2db.Table{members, teams}, func(tx *e2db.MultiTx) error {
+		if err := tx.Insert(teams, team); err != nil {
+			return err
+		}
+		member.TeamID = team.ID
+		return tx.Insert(members, member)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both rows are written by the same etcd transaction, so they share a
+	// revision
+	c, err := clientv3.New(clientv3.Config{Endpoints: []string{"127.0.0.1:2479"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	modRevision := func(k string) int64 {
+		resp, err := c.Get(context.Background(), "/criticalstack"+k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Kvs) == 0 {
+			t.Fatalf("expected key %#v to exist", k)
+		}
+		return resp.Kvs[0].ModRevision
+	}
+	teamRev := modRevision("/Team/" + strconv.Itoa(team.ID))
+	memberRev := modRevision("/Member/" + strconv.Itoa(member.ID))
+	if teamRev != memberRev {
+		t.Fatalf("expected a single commit, received revisions %d and %d", teamRev, memberRev)
+	}
+
+	// nothing is written when the transaction fails, even if it fails after
+	// writing to another table
+	err = db.Tx([]*e2db.Table{teams, members}, func(tx *e2db.MultiTx) error {
+		if err := tx.Insert(teams, &Team{Name: "data"}); err != nil {
+			return err
+		}
+		return tx.Insert(members, &Member{Name: "smoot"})
+	})
+	if errors.Cause(err) != e2db.ErrUniqueConstraint {
+		t.Fatalf("expected ErrUniqueConstraint, received %v", err)
+	}
+	n, err := teams.Count("Name", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected team to not be written, received %d", n)
+	}
+
+	// deletes and updates across tables are also committed together
+	err = db.Tx([]*e2db.Table{teams, members}, func(tx *e2db.MultiTx) error {
+		n, err := tx.Delete(members, "TeamID", team.ID)
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return errors.Errorf("expected 1 member to be deleted, received %d", n)
+		}
+		return tx.Update(teams, &Team{ID: team.ID, Name: "platform"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m []*Member
+	if err := members.Find("TeamID", team.ID, &m); errors.Cause(err) != e2db.ErrNoRows {
+		t.Fatalf("expected ErrNoRows, received %v", err)
+	}
+	var tm Team
+	if err := teams.Find("Name", "platform", &tm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Tx([]*e2db.Table{teams}, func(tx *e2db.MultiTx) error {
+		return tx.Insert(members, &Member{Name: "outside"})
+	}); err == nil {
+		t.Fatal("expected error for table not part of the transaction")
+	}
+}
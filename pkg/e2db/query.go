@@ -1,6 +1,7 @@
 package e2db
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
@@ -8,6 +9,8 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/mvcc/mvccpb"
 	"go.uber.org/zap"
 
 	"github.com/criticalstack/e2d/pkg/client"
@@ -21,6 +24,10 @@ var (
 	ErrNotIndexed   = errors.New("field is not indexed")
 	ErrInvalidField = errors.New("invalid field name")
 	ErrNoRows       = errors.New("no rows found")
+
+	// ErrResultTooLarge is returned when a query would read more rows than
+	// Config.MaxResults.
+	ErrResultTooLarge = errors.New("query result too large")
 )
 
 type Query interface {
@@ -29,9 +36,62 @@ type Query interface {
 	Filter(...q.Matcher) Query
 	Limit(int) Query
 	Skip(int) Query
+	Where(string) Range
+	IncludeDeleted() Query
 	All(interface{}) error
 	Count(string, interface{}) (int64, error)
 	Find(string, interface{}, interface{}) error
+	MustFind(string, interface{}, interface{}) error
+}
+
+// Range restricts the rows returned by All to those where an indexed number
+// or time.Time field is within a range of values. The rows are returned in
+// order of the field value, unless they are sorted by OrderBy.
+type Range interface {
+	// Between includes the rows where the field is from min to max,
+	// inclusive.
+	Between(min, max interface{}) Query
+	GreaterThan(interface{}) Query
+	GreaterThanOrEqual(interface{}) Query
+	LessThan(interface{}) Query
+	LessThanOrEqual(interface{}) Query
+}
+
+type bound struct {
+	value     interface{}
+	inclusive bool
+}
+
+type rangeQuery struct {
+	q        *query
+	field    string
+	min, max *bound
+}
+
+func (r *rangeQuery) Between(min, max interface{}) Query {
+	r.min = &bound{min, true}
+	r.max = &bound{max, true}
+	return r.q
+}
+
+func (r *rangeQuery) GreaterThan(v interface{}) Query {
+	r.min = &bound{v, false}
+	return r.q
+}
+
+func (r *rangeQuery) GreaterThanOrEqual(v interface{}) Query {
+	r.min = &bound{v, true}
+	return r.q
+}
+
+func (r *rangeQuery) LessThan(v interface{}) Query {
+	r.max = &bound{v, false}
+	return r.q
+}
+
+func (r *rangeQuery) LessThanOrEqual(v interface{}) Query {
+	r.max = &bound{v, true}
+	return r.q
 }
 
 type query struct {
@@ -41,6 +101,10 @@ type query struct {
 	skip     int
 	sort     string
 	reverse  bool
+	rng      *rangeQuery
+
+	// includeDeleted includes soft-deleted rows in the results
+	includeDeleted bool
 }
 
 func newQuery(t *Table, matchers ...q.Matcher) *query {
@@ -66,18 +130,44 @@ func (q *query) Filter(matchers ...q.Matcher) Query {
 	return q
 }
 
-// TODO(chris): doesn't work
+// Limit sets the maximum number of rows returned by a query. It is applied
+// after the rows are filtered, sorted and skipped.
 func (q *query) Limit(i int) Query {
 	q.limit = i
 	return q
 }
 
-// TODO(chris): doesn't work
+// Skip sets the number of rows skipped at the start of the results of a query.
+// It is applied after the rows are filtered and sorted, but before Limit.
 func (q *query) Skip(i int) Query {
 	q.skip = i
 	return q
 }
 
+// Where starts a range query on the provided field. Calling Where again with
+// the same field keeps the bounds that are already set, so that they can be
+// set separately.
+func (q *query) Where(field string) Range {
+	if q.rng == nil || q.rng.field != field {
+		q.rng = &rangeQuery{q: q, field: field}
+	}
+	return q.rng
+}
+
+// IncludeDeleted includes the rows that were soft-deleted in the results of a
+// query, which are otherwise excluded. Soft-deleted rows have no indexes, so
+// are only found by All or by their primary key.
+func (q *query) IncludeDeleted() Query {
+	q.includeDeleted = true
+	return q
+}
+
+// isExcluded returns true for a soft-deleted row, unless the query includes
+// them.
+func (q *query) isExcluded(v reflect.Value) bool {
+	return !q.includeDeleted && q.t.isDeleted(v)
+}
+
 func (q *query) handleItemTags(v reflect.Value) error {
 	m := NewModelItem(v)
 	for _, f := range m.Fields {
@@ -102,23 +192,88 @@ func (q *query) handleItemTags(v reflect.Value) error {
 	return nil
 }
 
-func (q *query) findOneByPrimaryKey(key string, v reflect.Value) error {
-	value, err := q.t.db.client.Get(key)
+// readRange reads the keys in the range [start, end). When MaxResults is set,
+// the read itself is limited so that a range holding more than MaxResults keys
+// (including the n keys already read by the query) fails with
+// ErrResultTooLarge without being loaded into memory.
+func (q *query) readRange(start, end string, n int) ([]*mvccpb.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.t.db.cfg.requestTimeout())
+	defer cancel()
+
+	opts := []clientv3.OpOption{clientv3.WithRange(end)}
+	max := q.t.db.cfg.MaxResults
+	if max > 0 {
+		opts = append(opts, clientv3.WithLimit(int64(max-n+1)))
+	}
+	resp, err := q.t.db.client.Client.Get(ctx, start, opts...)
 	if err != nil {
-		if errors.Cause(err) == client.ErrKeyNotFound {
-			return errors.Wrapf(ErrNoRows, "findOneByPrimaryKey: %#v", key)
+		return nil, err
+	}
+	if max > 0 && n+len(resp.Kvs) > max {
+		return nil, errors.Wrapf(ErrResultTooLarge, "exceeded maximum of %d results", max)
+	}
+	return resp.Kvs, nil
+}
+
+// sortAndPaginate sorts the results of a query, and then applies Skip and
+// Limit. Without OrderBy, the results are in key order, so the pages of a
+// query are still deterministic.
+func (q *query) sortAndPaginate(v reflect.Value) error {
+	if q.sort != "" {
+		s, err := newSorter(v, q.sort, q.reverse)
+		if err != nil {
+			return err
 		}
-		return err
+		sort.Sort(s)
 	}
-	if err := q.t.c.Decode(value, v.Addr().Interface()); err != nil {
-		return err
+	start, end := q.skip, v.Len()
+	if start < 0 {
+		start = 0
 	}
-	if err := q.handleItemTags(v); err != nil {
+	if start > end {
+		start = end
+	}
+	if q.limit > 0 && start+q.limit < end {
+		end = start + q.limit
+	}
+	v.Set(v.Slice(start, end))
+	return nil
+}
+
+func (q *query) findOneByPrimaryKey(key string, v reflect.Value) error {
+	item := reflect.New(v.Type()).Elem()
+	if _, err := q.getRow(key, item); err != nil {
 		return err
 	}
+	if q.isExcluded(item) {
+		return errors.Wrapf(ErrNoRows, "findOneByPrimaryKey: %#v", key)
+	}
+	v.Set(item)
 	return nil
 }
 
+// getRow reads the row stored at key into v, returning the mod revision of the
+// row.
+func (q *query) getRow(key string, v reflect.Value) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.t.db.cfg.requestTimeout())
+	defer cancel()
+
+	resp, err := q.t.db.client.Client.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, errors.Wrapf(ErrNoRows, "findOneByPrimaryKey: %#v", key)
+	}
+	if err := q.t.c.Decode(resp.Kvs[0].Value, v.Addr().Interface()); err != nil {
+		return 0, err
+	}
+	if err := q.handleItemTags(v); err != nil {
+		return 0, err
+	}
+	return resp.Kvs[0].ModRevision, nil
+}
+
 func (q *query) findOneByUniqueIndex(key string, v reflect.Value) error {
 	pk, err := q.t.db.client.Get(key)
 	if err != nil {
@@ -138,30 +293,51 @@ func (q *query) findOneBySecondaryIndex(key string, v reflect.Value) error {
 		}
 		return err
 	}
+	if len(kvs) == 0 {
+		return errors.Wrapf(ErrNoRows, "findOneBySecondaryIndex: %#v", key)
+	}
 	return q.findOneByPrimaryKey(string(kvs[0].Value), v)
 }
 
 func (q *query) findManyByIndex(key string, v reflect.Value) error {
-	kvs, err := q.t.db.client.Prefix(key)
+	kvs, err := q.readRange(key, clientv3.GetPrefixRangeEnd(key), 0)
 	if err != nil {
-		if errors.Cause(err) == client.ErrKeyNotFound {
-			return errors.Wrapf(ErrNoRows, "findManyByIndex: %#v", key)
-		}
 		return err
 	}
-	for i, kv := range kvs {
-		if q.limit != 0 && q.limit <= i {
-			fmt.Println("reached limit")
-			break
+	if len(kvs) == 0 {
+		return errors.Wrapf(ErrNoRows, "findManyByIndex: %#v", key)
+	}
+	return q.findManyByKeys(kvs, v)
+}
+
+// findManyByKeys appends the rows referenced by the provided index entries to
+// v. The rows are read together with GetMany rather than one request per row,
+// and are appended in the order of the index entries.
+func (q *query) findManyByKeys(kvs []*mvccpb.KeyValue, v reflect.Value) error {
+	pks := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		pks = append(pks, string(kv.Value))
+	}
+	rows, err := q.t.db.client.GetMany(pks)
+	if err != nil {
+		return err
+	}
+	for _, pk := range pks {
+		data, ok := rows[pk]
+		if !ok {
+			return errors.Wrapf(ErrNoRows, "findOneByPrimaryKey: %#v", pk)
 		}
 		item := reflect.New(v.Type().Elem())
-		if err := q.findOneByPrimaryKey(string(kv.Value), reflect.Indirect(item)); err != nil {
+		if err := q.t.c.Decode(data, item.Interface()); err != nil {
 			return err
 		}
 		el := item.Elem()
 		if err := q.handleItemTags(el); err != nil {
 			return err
 		}
+		if q.isExcluded(el) {
+			continue
+		}
 		if len(q.matchers) == 0 {
 			v.Set(reflect.Append(v, el))
 			continue
@@ -176,29 +352,80 @@ func (q *query) findManyByIndex(key string, v reflect.Value) error {
 			}
 		}
 	}
-	if q.sort == "" {
-		return nil
+	return q.sortAndPaginate(v)
+}
+
+// findRange finds the rows within the range of values of the query's range
+// field, by reading the field's range index.
+func (q *query) findRange(v reflect.Value) error {
+	f, ok := q.t.meta.Fields[q.rng.field]
+	if !ok {
+		return errors.Wrap(ErrInvalidField, q.rng.field)
+	}
+	if !f.isIndex() {
+		return errors.Wrap(ErrNotIndexed, q.rng.field)
+	}
+	sf, _ := q.t.meta.t.FieldByName(f.Name)
+	if !isOrdered(sf.Type) {
+		return errors.Errorf("field %#v of type %s cannot be used in a range query", f.Name, sf.Type)
 	}
-	s, err := newSorter(v, q.sort, q.reverse)
+	prefix := key.Ranges(q.t.meta.Name, f.Name)
+	start, end := prefix, clientv3.GetPrefixRangeEnd(prefix)
+	if b := q.rng.min; b != nil {
+		value, err := q.t.rangeValue(sf.Type, b.value)
+		if err != nil {
+			return errors.Wrap(err, f.Name)
+		}
+		start = prefix + value
+		if !b.inclusive {
+			start = clientv3.GetPrefixRangeEnd(prefix + value + "/")
+		}
+	}
+	if b := q.rng.max; b != nil {
+		value, err := q.t.rangeValue(sf.Type, b.value)
+		if err != nil {
+			return errors.Wrap(err, f.Name)
+		}
+		end = clientv3.GetPrefixRangeEnd(prefix + value + "/")
+		if !b.inclusive {
+			end = prefix + value
+		}
+	}
+	if start >= end {
+		return errors.Wrapf(ErrNoRows, "findRange: %#v", f.Name)
+	}
+	kvs, err := q.readRange(start, end, 0)
 	if err != nil {
 		return err
 	}
-	sort.Sort(s)
-	return nil
+	if len(kvs) == 0 {
+		return errors.Wrapf(ErrNoRows, "findRange: %#v", f.Name)
+	}
+	if q.reverse && q.sort == "" {
+		for i, j := 0, len(kvs)-1; i < j; i, j = i+1, j-1 {
+			kvs[i], kvs[j] = kvs[j], kvs[i]
+		}
+	}
+	return q.findManyByKeys(kvs, v)
 }
 
 func (q *query) findAll(table string, v reflect.Value) error {
-	kvs, err := q.t.db.client.Prefix(key.Table(table))
+	// Rows are read on either side of the hidden keys (table definition and
+	// indexes) so that those keys do not count towards MaxResults.
+	prefix, hidden := key.Table(table), key.Hidden(table)
+	kvs, err := q.readRange(prefix, hidden, 0)
 	if err != nil {
-		if errors.Cause(err) == client.ErrKeyNotFound {
-			return ErrNoRows
-		}
 		return err
 	}
+	rest, err := q.readRange(clientv3.GetPrefixRangeEnd(hidden), clientv3.GetPrefixRangeEnd(prefix), len(kvs))
+	if err != nil {
+		return err
+	}
+	kvs = append(kvs, rest...)
+	if len(kvs) == 0 {
+		return ErrNoRows
+	}
 	for _, kv := range kvs {
-		if strings.Contains(string(kv.Key), key.Hidden(table)) {
-			continue
-		}
 		item := reflect.New(v.Type().Elem())
 		if err := q.t.c.Decode(kv.Value, item.Interface()); err != nil {
 			return err
@@ -207,6 +434,9 @@ func (q *query) findAll(table string, v reflect.Value) error {
 		if err := q.handleItemTags(el); err != nil {
 			return err
 		}
+		if q.isExcluded(el) {
+			continue
+		}
 		if len(q.matchers) == 0 {
 			v.Set(reflect.Append(v, el))
 			continue
@@ -220,20 +450,69 @@ func (q *query) findAll(table string, v reflect.Value) error {
 				v.Set(reflect.Append(v, el))
 			}
 		}
-		v.Set(reflect.Append(v, el))
 	}
 	if v.Len() == 0 {
 		return ErrNoRows
 	}
-	if q.sort == "" {
-		return nil
+	return q.sortAndPaginate(v)
+}
+
+// page reads up to limit rows of the table, in key order, starting after the
+// row with the primary key token. The primary key of the last row is returned
+// as the token for the next page, or an empty token if there are no more rows.
+func (q *query) page(limit int, token string, v reflect.Value) (string, error) {
+	if limit <= 0 {
+		return "", errors.Errorf("page limit must be greater than 0, received %d", limit)
 	}
-	s, err := newSorter(v, q.sort, q.reverse)
-	if err != nil {
-		return err
+	ctx, cancel := context.WithTimeout(context.Background(), q.t.db.cfg.requestTimeout())
+	defer cancel()
+
+	// Rows are read on either side of the hidden keys (table definition and
+	// indexes), and one more row than the limit is read to know whether there
+	// is another page.
+	prefix, hidden := key.Table(q.t.meta.Name), key.Hidden(q.t.meta.Name)
+	from := prefix
+	if token != "" {
+		from = key.ID(q.t.meta.Name, token) + "\x00"
 	}
-	sort.Sort(s)
-	return nil
+	kvs := make([]*mvccpb.KeyValue, 0)
+	for _, r := range [][2]string{
+		{prefix, hidden},
+		{clientv3.GetPrefixRangeEnd(hidden), clientv3.GetPrefixRangeEnd(prefix)},
+	} {
+		start, end := r[0], r[1]
+		if from > start {
+			start = from
+		}
+		if start >= end || len(kvs) > limit {
+			continue
+		}
+		resp, err := q.t.db.client.Client.Get(ctx, start, clientv3.WithRange(end), clientv3.WithLimit(int64(limit+1-len(kvs))))
+		if err != nil {
+			return "", err
+		}
+		kvs = append(kvs, resp.Kvs...)
+	}
+	next := ""
+	if len(kvs) > limit {
+		kvs = kvs[:limit]
+		next = strings.TrimPrefix(string(kvs[limit-1].Key), prefix)
+	}
+	for _, kv := range kvs {
+		item := reflect.New(v.Type().Elem())
+		if err := q.t.c.Decode(kv.Value, item.Interface()); err != nil {
+			return "", err
+		}
+		el := item.Elem()
+		if err := q.handleItemTags(el); err != nil {
+			return "", err
+		}
+		if q.isExcluded(el) {
+			continue
+		}
+		v.Set(reflect.Append(v, el))
+	}
+	return next, nil
 }
 
 func (q *query) All(to interface{}) error {
@@ -251,6 +530,9 @@ func (q *query) All(to interface{}) error {
 	if err := q.t.validateModel(NewModelDef(vt)); err != nil {
 		return err
 	}
+	if q.rng != nil {
+		return q.findRange(v)
+	}
 	return q.findAll(q.t.meta.Name, v)
 }
 
@@ -258,11 +540,15 @@ func (q *query) Count(fieldName string, data interface{}) (int64, error) {
 	if err := q.t.tableMustExist(); err != nil {
 		return 0, err
 	}
-	f, ok := q.t.meta.Fields[fieldName]
+	f, ok := q.t.meta.field(fieldName)
 	if !ok {
 		return 0, errors.Wrap(ErrInvalidField, fieldName)
 	}
-	k, err := f.indexKey(q.t.meta.Name, toString(data))
+	value, err := q.t.queryValue(fieldName, data)
+	if err != nil {
+		return 0, err
+	}
+	k, err := f.indexKey(q.t.meta.Name, value)
 	if err != nil {
 		return 0, err
 	}
@@ -274,7 +560,7 @@ func (q *query) Find(fieldName string, data interface{}, to interface{}) error {
 	defer func() {
 		log.Debug("query.Find",
 			zap.String("key", fmt.Sprintf("%s/%v", q.t.meta.Name, fieldName)),
-			zap.String("q", toString(data)),
+			zap.String("q", fmt.Sprint(data)),
 			zap.Duration("elapsed", time.Since(st)),
 		)
 	}()
@@ -285,14 +571,17 @@ func (q *query) Find(fieldName string, data interface{}, to interface{}) error {
 	if err := q.t.validateSchema(v.Type()); err != nil {
 		return err
 	}
-	f, ok := q.t.meta.Fields[fieldName]
+	f, ok := q.t.meta.field(fieldName)
 	if !ok {
 		return errors.Wrap(ErrInvalidField, fieldName)
 	}
 	if !f.isIndex() {
 		return errors.Wrap(ErrNotIndexed, fieldName)
 	}
-	k := toString(data)
+	k, err := q.t.queryValue(fieldName, data)
+	if err != nil {
+		return err
+	}
 	if v.Type().Kind() == reflect.Slice {
 		if f.isPrimaryKey() {
 			item := reflect.New(v.Type().Elem())
@@ -316,6 +605,20 @@ func (q *query) Find(fieldName string, data interface{}, to interface{}) error {
 	}
 }
 
+// MustFind is like Find, but also returns ErrNoRows when finding into a slice
+// does not add any rows, such as when every row in the index is excluded by a
+// Filter.
 func (q *query) MustFind(fieldName string, data interface{}, to interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(to))
+	n := 0
+	if v.Kind() == reflect.Slice {
+		n = v.Len()
+	}
+	if err := q.Find(fieldName, data, to); err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Slice && v.Len() <= n {
+		return errors.Wrapf(ErrNoRows, "MustFind: %s/%s: %v", q.t.meta.Name, fieldName, data)
+	}
 	return nil
 }
@@ -0,0 +1,163 @@
+package e2db
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+
+	"github.com/criticalstack/e2d/pkg/e2db/key"
+	"github.com/criticalstack/e2d/pkg/e2db/q"
+)
+
+type widget struct {
+	ID    int    `e2db:"increment"`
+	Name  string `e2db:"unique"`
+	Group string `e2db:"index"`
+	Size  int
+}
+
+// more rows than fit in one GetMany transaction are in group "a"
+const numWidgets = 300
+
+func newWidgetTable(tb testing.TB, namespace string) (*DB, *Table) {
+	// the server is started by the init of the external tests
+	db, err := New(context.Background(), &Config{
+		ClientAddr: ":2479",
+		Namespace:  namespace,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	widgets := db.Table(&widget{})
+	if err := widgets.Drop(); err != nil && errors.Cause(err) != ErrTableNotFound {
+		tb.Fatal(err)
+	}
+	for i := 0; i < numWidgets; i++ {
+		group := "a"
+		if i%5 == 0 {
+			group = "b"
+		}
+		w := &widget{Name: "widget" + strconv.Itoa(i), Group: group, Size: (i * 7) % 31}
+		if err := widgets.Insert(w); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return db, widgets
+}
+
+// findManyByKeysSerial is the implementation of findManyByKeys before the rows
+// were read with GetMany, reading each row with a separate request.
+func (q *query) findManyByKeysSerial(kvs []*mvccpb.KeyValue, v reflect.Value) error {
+	for _, kv := range kvs {
+		item := reflect.New(v.Type().Elem())
+		if err := q.findOneByPrimaryKey(string(kv.Value), reflect.Indirect(item)); err != nil {
+			return err
+		}
+		el := item.Elem()
+		if len(q.matchers) == 0 {
+			v.Set(reflect.Append(v, el))
+			continue
+		}
+		for _, m := range q.matchers {
+			ok, err := m.Match(el)
+			if err != nil {
+				return err
+			}
+			if ok {
+				v.Set(reflect.Append(v, el))
+			}
+		}
+	}
+	return q.sortAndPaginate(v)
+}
+
+func (q *query) findManyByIndexSerial(key string, v reflect.Value) error {
+	kvs, err := q.readRange(key, clientv3.GetPrefixRangeEnd(key), 0)
+	if err != nil {
+		return err
+	}
+	if len(kvs) == 0 {
+		return errors.Wrapf(ErrNoRows, "findManyByIndex: %#v", key)
+	}
+	return q.findManyByKeysSerial(kvs, v)
+}
+
+func TestFindManyByKeysBatched(t *testing.T) {
+	db, widgets := newWidgetTable(t, "find-many-batched")
+	defer db.Close()
+
+	cases := []struct {
+		name  string
+		group string
+		query func() Query
+	}{
+		{"all", "a", func() Query { return newQuery(widgets) }},
+		{"small group", "b", func() Query { return newQuery(widgets) }},
+		{"sort", "a", func() Query { return widgets.OrderBy("Size") }},
+		{"sort reverse", "a", func() Query { return widgets.OrderBy("Size").Reverse() }},
+		{"limit", "a", func() Query { return widgets.Limit(10) }},
+		{"skip", "a", func() Query { return widgets.Skip(150) }},
+		{"sort skip limit", "a", func() Query { return widgets.OrderBy("Name").Skip(5).Limit(20) }},
+		{"filter", "a", func() Query { return widgets.Filter(q.Eq("Size", 3)) }},
+		{"filters", "a", func() Query { return widgets.Filter(q.Eq("Size", 3), q.Or(q.Eq("Size", 3), q.Eq("Size", 5))) }},
+		{"filter sort limit", "a", func() Query { return widgets.Filter(q.Not(q.Eq("Size", 3))).OrderBy("Size").Limit(15) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var want []*widget
+			k := key.Indexes(widgets.meta.Name, "Group", c.group)
+			if err := c.query().(*query).findManyByIndexSerial(k, reflect.ValueOf(&want).Elem()); err != nil {
+				t.Fatal(err)
+			}
+			var got []*widget
+			if err := c.query().Find("Group", c.group, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) == 0 {
+				t.Fatal("expected rows to be found")
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+
+	// an index entry referencing a missing row fails the query, as when the
+	// rows were read one at a time
+	if err := db.client.Delete(key.ID(widgets.meta.Name, "2")); err != nil {
+		t.Fatal(err)
+	}
+	var got []*widget
+	if err := widgets.Find("Group", "a", &got); errors.Cause(err) != ErrNoRows {
+		t.Fatalf("expected %v, received %v", ErrNoRows, err)
+	}
+}
+
+func BenchmarkFindManyByIndex(b *testing.B) {
+	db, widgets := newWidgetTable(b, "find-many-bench")
+	defer db.Close()
+
+	k := key.Indexes(widgets.meta.Name, "Group", "a")
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var ws []*widget
+			if err := newQuery(widgets).findManyByIndex(k, reflect.ValueOf(&ws).Elem()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var ws []*widget
+			if err := newQuery(widgets).findManyByIndexSerial(k, reflect.ValueOf(&ws).Elem()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,10 +1,14 @@
 package e2db_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 
+	"github.com/criticalstack/e2d/pkg/e2db"
 	"github.com/criticalstack/e2d/pkg/e2db/q"
 )
 
@@ -106,3 +110,360 @@ func TestFindManyFilterNot(t *testing.T) {
 		t.Errorf("e2db: after Find differs: (-want +got)\n%s", diff)
 	}
 }
+
+func TestFindManyMaxResults(t *testing.T) {
+	resetTable(t)
+	limited, err := e2db.New(context.Background(), &e2db.Config{
+		ClientAddr: ":2479",
+		Namespace:  "criticalstack",
+		MaxResults: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer limited.Close()
+
+	roles := limited.Table(&Role{})
+	var r []*Role
+	if err := roles.Find("Description", "administrator", &r); errors.Cause(err) != e2db.ErrResultTooLarge {
+		t.Fatalf("expected ErrResultTooLarge, received %v", err)
+	}
+	r = nil
+	if err := roles.Find("Description", "user", &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 {
+		t.Fatalf("expected 1 result, received %d", len(r))
+	}
+	r = nil
+	if err := roles.All(&r); errors.Cause(err) != e2db.ErrResultTooLarge {
+		t.Fatalf("expected ErrResultTooLarge, received %v", err)
+	}
+
+	// hidden table keys and indexes do not count towards the limit, and each
+	// row is only counted once
+	if _, err := roles.Delete("Description", "administrator"); err != nil {
+		t.Fatal(err)
+	}
+	if err := roles.Insert(&Role{ID: 10, Name: "admin", Description: "administrator"}); err != nil {
+		t.Fatal(err)
+	}
+	r = nil
+	if err := roles.All(&r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 {
+		t.Fatalf("expected 2 results, received %d", len(r))
+	}
+}
+
+func TestMustFind(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+
+	cases := []struct {
+		name     string
+		field    string
+		value    interface{}
+		expected *Role
+	}{
+		{"primary", "ID", 3, &Role{ID: 3, Name: "superadmin", Description: "administrator"}},
+		{"primary not found", "ID", 10, nil},
+		{"unique", "Name", "user", &Role{ID: 1, Name: "user", Description: "user"}},
+		{"unique not found", "Name", "nobody", nil},
+		{"secondary", "Description", "user", &Role{ID: 1, Name: "user", Description: "user"}},
+		{"secondary not found", "Description", "nobody", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r Role
+			err := roles.MustFind(tc.field, tc.value, &r)
+			if tc.expected == nil {
+				if errors.Cause(err) != e2db.ErrNoRows {
+					t.Fatalf("expected ErrNoRows, received %v", err)
+				}
+				if diff := cmp.Diff(&Role{}, &r); diff != "" {
+					t.Errorf("e2db: after MustFind differs: (-want +got)\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expected, &r); diff != "" {
+				t.Errorf("e2db: after MustFind differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMustFindMany(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+
+	cases := []struct {
+		name     string
+		query    e2db.Query
+		field    string
+		value    interface{}
+		expected []*Role
+	}{
+		{
+			name:  "primary",
+			query: roles.Filter(),
+			field: "ID",
+			value: 2,
+			expected: []*Role{
+				{ID: 2, Name: "admin", Description: "administrator"},
+			},
+		},
+		{
+			name:  "primary not found",
+			query: roles.Filter(),
+			field: "ID",
+			value: 10,
+		},
+		{
+			name:  "unique",
+			query: roles.Filter(),
+			field: "Name",
+			value: "smoot",
+			expected: []*Role{
+				{ID: 4, Name: "smoot", Description: "administrator"},
+			},
+		},
+		{
+			name:  "unique not found",
+			query: roles.Filter(),
+			field: "Name",
+			value: "nobody",
+		},
+		{
+			name:  "secondary",
+			query: roles.Filter(q.Eq("Name", "superadmin")),
+			field: "Description",
+			value: "administrator",
+			expected: []*Role{
+				{ID: 3, Name: "superadmin", Description: "administrator"},
+			},
+		},
+		{
+			name:  "secondary not found",
+			query: roles.Filter(),
+			field: "Description",
+			value: "nobody",
+		},
+		{
+			name:  "secondary filtered",
+			query: roles.Filter(q.Eq("Name", "nobody")),
+			field: "Description",
+			value: "administrator",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r []*Role
+			err := tc.query.MustFind(tc.field, tc.value, &r)
+			if tc.expected == nil {
+				if errors.Cause(err) != e2db.ErrNoRows {
+					t.Fatalf("expected ErrNoRows, received %v", err)
+				}
+				if len(r) != 0 {
+					t.Errorf("expected no rows, received %d", len(r))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expected, r); diff != "" {
+				t.Errorf("e2db: after MustFind differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRangeQuery(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+
+	ids := func(query e2db.Query) []int {
+		var r []*Role
+		if err := query.All(&r); err != nil && errors.Cause(err) != e2db.ErrNoRows {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0)
+		for _, role := range r {
+			ids = append(ids, role.ID)
+		}
+		return ids
+	}
+	cases := []struct {
+		name     string
+		query    e2db.Query
+		expected []int
+	}{
+		{"between", roles.Where("ID").Between(2, 4), []int{2, 3, 4}},
+		{"between same", roles.Where("ID").Between(3, 3), []int{3}},
+		{"between empty", roles.Where("ID").Between(4, 2), []int{}},
+		{"greater than", roles.Where("ID").GreaterThan(2), []int{3, 4}},
+		{"greater than or equal", roles.Where("ID").GreaterThanOrEqual(2), []int{2, 3, 4}},
+		{"less than", roles.Where("ID").LessThan(2), []int{1}},
+		{"less than or equal", roles.Where("ID").LessThanOrEqual(2), []int{1, 2}},
+		{"less than min", roles.Where("ID").LessThan(1), []int{}},
+		{"both bounds", roles.Where("ID").GreaterThan(1).Where("ID").LessThan(4), []int{2, 3}},
+		{"reverse", roles.Reverse().Where("ID").GreaterThan(1), []int{4, 3, 2}},
+		{"order by", roles.OrderBy("Name").Where("ID").GreaterThan(1), []int{2, 4, 3}},
+		{"filter", roles.Filter(q.Eq("Description", "administrator")).Where("ID").LessThan(4), []int{2, 3}},
+		{"limit", roles.Where("ID").GreaterThan(1).Skip(1).Limit(1), []int{3}},
+	}
+	for _, tc := range cases {
+		if diff := cmp.Diff(tc.expected, ids(tc.query)); diff != "" {
+			t.Errorf("%s: after All differs: (-want +got)\n%s", tc.name, diff)
+		}
+	}
+
+	// the range index is kept up to date when rows are deleted
+	if _, err := roles.Delete("ID", 3); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int{2, 4}, ids(roles.Where("ID").Between(2, 4))); diff != "" {
+		t.Errorf("after Delete differs: (-want +got)\n%s", diff)
+	}
+
+	var r []*Role
+	if err := roles.Where("Name").GreaterThan(1).All(&r); err == nil {
+		t.Fatal("expected error for range query on string field")
+	}
+	if err := roles.Where("ID").GreaterThan("1").All(&r); err == nil {
+		t.Fatal("expected error for range query with string value")
+	}
+}
+
+type Reading struct {
+	ID    int       `e2db:"increment"`
+	Value int64     `e2db:"index"`
+	Taken time.Time `e2db:"index"`
+}
+
+func TestRangeQueryOrdering(t *testing.T) {
+	readings := db.Table(&Reading{})
+	if err := readings.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	ts := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	for _, r := range []*Reading{
+		{Value: 10, Taken: ts.Add(2 * time.Hour)},
+		{Value: -300, Taken: ts.Add(-1 * time.Hour)},
+		{Value: 2, Taken: ts},
+		{Value: -2, Taken: ts.Add(3 * time.Hour)},
+		{Value: 100, Taken: ts.Add(1 * time.Hour)},
+	} {
+		if err := readings.Insert(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values := func(query e2db.Query) []int64 {
+		var r []*Reading
+		if err := query.All(&r); err != nil {
+			t.Fatal(err)
+		}
+		values := make([]int64, 0)
+		for _, reading := range r {
+			values = append(values, reading.Value)
+		}
+		return values
+	}
+	if diff := cmp.Diff([]int64{-300, -2, 2, 10, 100}, values(readings.Where("Value").GreaterThan(-1000))); diff != "" {
+		t.Errorf("after All differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]int64{-2, 2, 10}, values(readings.Where("Value").Between(-2, 10))); diff != "" {
+		t.Errorf("after All differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]int64{2, 100, 10}, values(readings.Where("Taken").Between(ts, ts.Add(2*time.Hour)))); diff != "" {
+		t.Errorf("after All differs: (-want +got)\n%s", diff)
+	}
+	est := time.FixedZone("EST", -5*60*60)
+	if diff := cmp.Diff([]int64{-300, 2}, values(readings.Where("Taken").LessThan(ts.Add(1*time.Hour).In(est)))); diff != "" {
+		t.Errorf("after All differs: (-want +got)\n%s", diff)
+	}
+
+	// updating a value moves it within the range index
+	if err := readings.Update(&Reading{ID: 2, Value: 1000, Taken: ts.Add(-1 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int64{-2, 2, 10, 100, 1000}, values(readings.Where("Value").GreaterThan(-1000))); diff != "" {
+		t.Errorf("after Update differs: (-want +got)\n%s", diff)
+	}
+}
+
+type Item struct {
+	ID   int    `e2db:"increment"`
+	Name string `e2db:"index"`
+}
+
+func TestPage(t *testing.T) {
+	items := db.Table(&Item{})
+	if err := items.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	err := items.Tx(func(tx *e2db.Tx) error {
+		for i := 0; i < 100; i++ {
+			if err := tx.Insert(&Item{Name: "item"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	pages := 0
+	token := ""
+	for {
+		var page []*Item
+		token, err = items.Page(10, token, &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		if len(page) != 10 {
+			t.Fatalf("expected 10 items in page %d, received %d", pages, len(page))
+		}
+		for _, item := range page {
+			if seen[item.ID] {
+				t.Fatalf("item %d found in more than one page", item.ID)
+			}
+			seen[item.ID] = true
+		}
+		if token == "" {
+			break
+		}
+		if pages > 10 {
+			t.Fatal("expected pagination to end")
+		}
+	}
+	if pages != 10 || len(seen) != 100 {
+		t.Fatalf("expected 100 items in 10 pages, received %d items in %d pages", len(seen), pages)
+	}
+
+	// a page that is not filled is the last page
+	var page []*Item
+	token, err = items.Page(30, "", &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for token != "" {
+		page = page[:0]
+		token, err = items.Page(30, token, &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(page) != 10 {
+		t.Fatalf("expected 10 items in the last page, received %d", len(page))
+	}
+}
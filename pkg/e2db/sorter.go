@@ -4,6 +4,8 @@ import (
 	"reflect"
 
 	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/e2db/key"
 )
 
 type sorter struct {
@@ -31,9 +33,18 @@ func (s *sorter) Len() int {
 	return s.v.Len()
 }
 
+// sortValue returns the string that v is sorted by. Ordered types, like
+// numbers and times, use a form that sorts in the same order as the values.
+func sortValue(v reflect.Value) string {
+	if s, ok := key.Sortable(v.Interface()); ok {
+		return s
+	}
+	return toString(v.Interface())
+}
+
 func (s *sorter) Less(i, j int) bool {
-	a := toString(s.fields[i].Interface())
-	b := toString(s.fields[j].Interface())
+	a := sortValue(s.fields[i])
+	b := sortValue(s.fields[j])
 	if s.reverse {
 		return a > b
 	}
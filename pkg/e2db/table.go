@@ -1,9 +1,13 @@
 package e2db
 
 import (
+	"context"
 	"reflect"
+	"sort"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
 
 	"github.com/criticalstack/e2d/pkg/client"
 	"github.com/criticalstack/e2d/pkg/e2db/key"
@@ -12,18 +16,212 @@ import (
 
 var _ Query = (*Table)(nil)
 
-// TODO(chris): could probably do something cool like automatic schema
-// migrations
 type Table struct {
 	db   *DB
 	c    Codec
 	tc   Codec
 	meta *ModelDef
+
+	timePrecision time.Duration
+
+	// key encrypts the rows of the table, when set by WithEncryption
+	key *[32]byte
+}
+
+// indexValue returns the string used to represent data in index keys. Times
+// are normalized the same way they are when stored, so an instant produces the
+// same index key regardless of the timezone of the client that wrote it.
+func (t *Table) indexValue(data interface{}) string {
+	if v, ok := data.(time.Time); ok {
+		data = v.UTC().Truncate(t.timePrecision)
+	}
+	return toString(data)
+}
+
+// isMultiValue returns true for fields that produce an index entry for each
+// of their values, rather than a single index entry.
+func isMultiValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.Type().Elem().Kind() != reflect.Uint8
+	case reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// indexValues returns the strings used to represent data in index keys. Each
+// element of a slice or array, and each entry of a map (as "key=value"),
+// produces its own index entry so that a row can be found by any one of them.
+// Duplicates only produce a single entry.
+func (t *Table) indexValues(data interface{}) []string {
+	v := reflect.ValueOf(data)
+	if !isMultiValue(v) {
+		return []string{t.indexValue(data)}
+	}
+	values := make([]string, 0)
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			values = append(values, t.indexValue(k.Interface())+"="+t.indexValue(v.MapIndex(k).Interface()))
+		}
+	default:
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, t.indexValue(v.Index(i).Interface()))
+		}
+	}
+	sort.Strings(values)
+	unique := values[:0]
+	for i, s := range values {
+		if i > 0 && s == values[i-1] {
+			continue
+		}
+		unique = append(unique, s)
+	}
+	return unique
+}
+
+// queryValue returns the index value used to look up data by the named field
+// or compound index. A compound index is looked up by a struct with the values
+// of the fields in the index, or by a slice of the values in the order that
+// the fields are declared.
+func (t *Table) queryValue(name string, data interface{}) (string, error) {
+	fields, ok := t.meta.Indexes[name]
+	if !ok {
+		return t.indexValue(data), nil
+	}
+	values := make([]string, len(fields))
+	v := reflect.Indirect(reflect.ValueOf(data))
+	switch v.Kind() {
+	case reflect.Struct:
+		for i, f := range fields {
+			fv := v.FieldByName(f)
+			if !fv.IsValid() {
+				return "", errors.Errorf("compound index %#v: %T does not have field %#v", name, data, f)
+			}
+			values[i] = t.indexValue(fv.Interface())
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() != len(fields) {
+			return "", errors.Errorf("compound index %#v: expected %d values, received %d", name, len(fields), v.Len())
+		}
+		for i := range fields {
+			values[i] = t.indexValue(v.Index(i).Interface())
+		}
+	default:
+		return "", errors.Errorf("compound index %#v: must provide struct or slice of values, received %T", name, data)
+	}
+	return key.Compound(values...), nil
+}
+
+// compoundIndexKeys returns the index keys of the compound indexes for the
+// provided row.
+func (t *Table) compoundIndexKeys(v reflect.Value, id string) ([]string, error) {
+	keys := make([]string, 0)
+	for name, fields := range t.meta.Indexes {
+		values := make([]string, len(fields))
+		for i, f := range fields {
+			fv := v.FieldByName(f)
+			if isMultiValue(fv) {
+				return nil, errors.Errorf("compound index %#v is not supported for field %#v of type %s", name, f, fv.Type())
+			}
+			values[i] = t.indexValue(fv.Interface())
+		}
+		keys = append(keys, key.Index(t.meta.Name, name, key.Compound(values...), id))
+	}
+	return keys, nil
+}
+
+// isOrdered returns true for the field types that have range indexes.
+func isOrdered(typ reflect.Type) bool {
+	if typ == timeType {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// rangeValue returns the sortable string used to represent data in the range
+// index of a field of type typ.
+func (t *Table) rangeValue(typ reflect.Type, data interface{}) (string, error) {
+	if typ == timeType {
+		v, ok := data.(time.Time)
+		if !ok {
+			return "", errors.Errorf("expected time.Time, received %T", data)
+		}
+		return key.Time(v.Truncate(t.timePrecision)), nil
+	}
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return "", errors.Errorf("expected number, received %T", data)
+	}
+
+	// a float is not converted to an integer field type, since the bound
+	// would be truncated
+	isFloat := func(k reflect.Kind) bool { return k == reflect.Float32 || k == reflect.Float64 }
+	if isFloat(v.Kind()) && !isFloat(typ.Kind()) {
+		return "", errors.Errorf("expected integer, received %T", data)
+	}
+	value, ok := key.Sortable(v.Convert(typ).Interface())
+	if !ok {
+		return "", errors.Errorf("type %s cannot be used in a range index", typ)
+	}
+	return value, nil
 }
 
+// rangeIndexKeys returns the range index keys for the provided row. Every
+// indexed number and time.Time field has a range index, alongside its regular
+// index, so that it can be used in range queries.
+func (t *Table) rangeIndexKeys(v reflect.Value, id string) ([]string, error) {
+	keys := make([]string, 0)
+	for name, f := range t.meta.Fields {
+		if !f.isIndex() {
+			continue
+		}
+		fv := v.FieldByName(name)
+		if !isOrdered(fv.Type()) {
+			continue
+		}
+		value, err := t.rangeValue(fv.Type(), fv.Interface())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.Range(t.meta.Name, name, value, id))
+	}
+	return keys, nil
+}
+
+// rowIndexKeys returns the compound and range index keys for the provided
+// row, which are maintained for the row as a whole rather than by the tags of
+// each field.
+func (t *Table) rowIndexKeys(v reflect.Value, id string) ([]string, error) {
+	compound, err := t.compoundIndexKeys(v, id)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := t.rangeIndexKeys(v, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(compound, ranges...), nil
+}
+
+// validateModel returns ErrSchemaMismatch if the name or the fields of the
+// table's model do not match the remote model, such as the table definition
+// stored in the database.
 func (t *Table) validateModel(remote *ModelDef) error {
-	if t.meta.Name != remote.Name {
-		return errors.Errorf("type name mismatch, expected %#v, received %#v", remote.Name, t.meta.Name)
+	expected, actual := remote.fieldNames(), t.meta.fieldNames()
+	if t.meta.Name != remote.Name || !reflect.DeepEqual(expected, actual) {
+		return errors.Wrapf(ErrSchemaMismatch, "expected type %#v with fields %v, received type %#v with fields %v", remote.Name, expected, t.meta.Name, actual)
 	}
 	return nil
 }
@@ -35,6 +233,16 @@ func (t *Table) validateSchema(typ reflect.Type) error {
 	return t.validateModel(NewModelDef(typ))
 }
 
+// isDeleted returns true if the row has been soft-deleted, which is when the
+// softdelete field of the model is set.
+func (t *Table) isDeleted(v reflect.Value) bool {
+	name := t.meta.softDeleteField()
+	if name == "" {
+		return false
+	}
+	return !reflect.Indirect(v).FieldByName(name).Interface().(time.Time).IsZero()
+}
+
 func (t *Table) tableMustExist() error {
 	v, err := t.db.client.Get(key.TableDef(t.meta.Name))
 	if err != nil && errors.Cause(err) != client.ErrKeyNotFound {
@@ -62,14 +270,101 @@ func (t *Table) All(to interface{}) error {
 	return newQuery(t).All(to)
 }
 
+// Page finds up to limit rows of the table, in key order (the lexical order of
+// the primary keys), so that a large table can be iterated without loading all
+// of it into memory. The first
+// page is found with an empty token, and each page returns the token for the
+// next page, which is empty after the last page. Soft-deleted rows are
+// skipped, so a page before the last can have fewer than limit rows.
+func (t *Table) Page(limit int, token string, to interface{}) (string, error) {
+	if err := t.tableMustExist(); err != nil {
+		return "", err
+	}
+	v := reflect.Indirect(reflect.ValueOf(to))
+	if v.Type().Kind() != reflect.Slice {
+		return "", errors.New("results value must be a slice")
+	}
+	if err := t.validateSchema(v.Type()); err != nil {
+		return "", err
+	}
+	return newQuery(t).page(limit, token, v)
+}
+
 func (t *Table) Count(fieldName string, data interface{}) (int64, error) {
 	return newQuery(t).Count(fieldName, data)
 }
 
+// Distinct returns the sorted distinct values of an index or unique index,
+// which are read from the index entries without reading the rows. The values
+// are in the form they are indexed, so a multi-value field has each of its
+// values, and a compound index has its values joined by key.Compound.
+func (t *Table) Distinct(fieldName string) ([]string, error) {
+	if err := t.tableMustExist(); err != nil {
+		return nil, err
+	}
+	f, ok := t.meta.field(fieldName)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidField, fieldName)
+	}
+	switch f.Type() {
+	case SecondaryIndex, UniqueIndex:
+	default:
+		return nil, errors.Wrap(ErrNotIndexed, fieldName)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.db.cfg.requestTimeout())
+	defer cancel()
+
+	resp, err := t.db.client.Client.Get(ctx, key.FieldIndexes(t.meta.Name, f.Name), clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	values := make([]string, 0)
+	for _, kv := range resp.Kvs {
+		v, ok := key.IndexValue(t.meta.Name, f.Name, string(kv.Key))
+		if !ok || seen[v] {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// CountDistinct returns the number of distinct values of an index or unique
+// index (see Distinct).
+func (t *Table) CountDistinct(fieldName string) (int64, error) {
+	values, err := t.Distinct(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(values)), nil
+}
+
 func (t *Table) Find(fieldName string, data interface{}, to interface{}) error {
 	return newQuery(t).Find(fieldName, data, to)
 }
 
+// Get finds the row with the provided primary key value, returning ErrNoRows
+// if it does not exist.
+func (t *Table) Get(pk interface{}, to interface{}) error {
+	for name, f := range t.meta.Fields {
+		if f.isPrimaryKey() {
+			return newQuery(t).Find(name, pk, to)
+		}
+	}
+	return ErrNoPrimaryKey
+}
+
+func (t *Table) MustFind(fieldName string, data interface{}, to interface{}) error {
+	return newQuery(t).MustFind(fieldName, data, to)
+}
+
+func (t *Table) Where(field string) Range {
+	return newQuery(t).Where(field)
+}
+
 func (t *Table) OrderBy(field string) Query {
 	q := newQuery(t)
 	q.sort = field
@@ -82,6 +377,12 @@ func (t *Table) Reverse() Query {
 	return q
 }
 
+func (t *Table) IncludeDeleted() Query {
+	q := newQuery(t)
+	q.includeDeleted = true
+	return q
+}
+
 func (t *Table) Filter(matchers ...q.Matcher) Query {
 	q := newQuery(t, matchers...)
 	return q
@@ -114,10 +415,17 @@ func (t *Table) DeleteAll() error {
 	})
 }
 
+// Drop deletes the table, including its rows and indexes. Unlike other
+// operations, Drop does not require the model of the table to match the stored
+// table definition, so it can be used to replace a table whose model changed.
 func (t *Table) Drop() error {
-	return t.Tx(func(tx *Tx) error {
-		return tx.Drop()
-	})
+	unlock, err := t.db.client.Lock(key.TableLock(t.meta.Name), t.db.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return (&Tx{t}).Drop()
 }
 
 func (t *Table) Insert(iface interface{}) error {
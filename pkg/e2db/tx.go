@@ -20,6 +20,16 @@ import (
 )
 
 var (
+	// ErrConflict is returned when writing a versioned row that has been
+	// changed since it was read.
+	ErrConflict = errors.New("row has been modified")
+
+	// ErrSchemaMismatch is returned when the model of a table does not match
+	// the table definition stored in the database, such as after the fields
+	// of the model's struct are changed. The table must be migrated or
+	// dropped before it can be used with the new model.
+	ErrSchemaMismatch = errors.New("schema mismatch")
+
 	ErrFieldRequired     = errors.New("must provide field")
 	ErrInvalidPrimaryKey = errors.New("invalid primary key")
 	ErrTableNotFound     = errors.New("table not found")
@@ -49,11 +59,47 @@ type batchResponse struct {
 	Deleted int64
 }
 
-func (tx *Tx) batchOps(ops ...clientv3.Op) (*batchResponse, error) {
-	resp, err := tx.db.client.Txn(context.TODO()).Then(ops...).Commit()
+// revisionCmp is the condition that a row has not been written since it was
+// read at the provided mod revision, where 0 means the row must not exist.
+func revisionCmp(k string, rev int64) clientv3.Cmp {
+	return clientv3.Compare(clientv3.ModRevision(k), "=", rev)
+}
+
+// uniqueCmp is the condition that a unique index key does not already exist.
+// The unique constraint is checked before writing for a descriptive error,
+// but is also enforced by the transaction itself, so that it does not rely
+// solely on the table lock (which may expire).
+func uniqueCmp(k string) clientv3.Cmp {
+	return clientv3.Compare(clientv3.CreateRevision(k), "=", 0)
+}
+
+func (tx *Tx) batchOps(cmps []clientv3.Cmp, ops ...clientv3.Op) (*batchResponse, error) {
+	// The rows with revision conditions are read when the transaction fails,
+	// to tell a conflicting write apart from a unique constraint violation.
+	revs := make([]int64, 0)
+	elseOps := make([]clientv3.Op, 0)
+	for _, c := range cmps {
+		if c.Target == etcdserverpb.Compare_MOD {
+			revs = append(revs, c.TargetUnion.(*etcdserverpb.Compare_ModRevision).ModRevision)
+			elseOps = append(elseOps, clientv3.OpGet(string(c.Key)))
+		}
+	}
+	resp, err := tx.db.client.Txn(context.TODO()).If(cmps...).Then(ops...).Else(elseOps...).Commit()
 	if err != nil {
 		return nil, err
 	}
+	if !resp.Succeeded {
+		for i, r := range resp.Responses {
+			var rev int64
+			if kvs := r.GetResponseRange().Kvs; len(kvs) > 0 {
+				rev = kvs[0].ModRevision
+			}
+			if rev != revs[i] {
+				return nil, errors.Wrap(ErrConflict, "row was written concurrently")
+			}
+		}
+		return nil, errors.Wrap(ErrUniqueConstraint, "unique value was written concurrently")
+	}
 	br := &batchResponse{}
 	for _, r := range resp.Responses {
 		switch t := r.Response.(type) {
@@ -68,19 +114,32 @@ func (tx *Tx) batchOps(ops ...clientv3.Op) (*batchResponse, error) {
 }
 
 func (tx *Tx) Insert(iface interface{}) error {
+	cmps, ops, err := tx.insertOps(iface, 0)
+	if err != nil {
+		return err
+	}
+	_, err = tx.batchOps(cmps, ops...)
+	return err
+}
+
+// insertOps returns the operations needed to insert the provided value, along
+// with the conditions that must hold for the unique indexes. A versioned row
+// is only inserted if it is still at the provided mod revision, where 0 means
+// the row must not exist.
+func (tx *Tx) insertOps(iface interface{}, rev int64) ([]clientv3.Cmp, []clientv3.Op, error) {
 	m := NewModelItem(reflect.ValueOf(iface))
 	if err := tx.validateModel(m.ModelDef); err != nil {
-		return err
+		return nil, nil, err
 	}
 	pk, err := m.getPrimaryKey()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if pk.isZero() {
 		if pk.hasTag("increment") {
 			id, err := tx.db.client.Incr(key.Increment(m.Name, pk.Name), 5*time.Second)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 			switch pk.value.Kind() {
 			case reflect.Int:
@@ -92,36 +151,66 @@ func (tx *Tx) Insert(iface interface{}) error {
 	}
 	id := toString(pk.value.Interface())
 	if id == "" {
-		return errors.Wrapf(ErrInvalidPrimaryKey, "cannot be empty: %#v", pk.Name)
+		return nil, nil, errors.Wrapf(ErrInvalidPrimaryKey, "cannot be empty: %#v", pk.Name)
 	}
 	indexes := make([]string, 0)
+	cmps := make([]clientv3.Cmp, 0)
+
+	// a versioned row starts at version 1, and is only inserted if it has
+	// not been written since it was read
+	version, err := m.getVersion()
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != nil {
+		version.value.SetInt(1)
+		cmps = append(cmps, revisionCmp(key.ID(m.Name, id), rev))
+	}
+
+	// a soft-deleted row is stored without its indexes
+	deleted := tx.isDeleted(reflect.ValueOf(iface))
 	for _, f := range m.Fields {
 		for _, tag := range f.Tags {
 			switch tag.Name {
 			case "index":
-				indexes = append(indexes, key.Index(m.Name, f.Name, toString(f.value.Interface()), id))
+				if tag.isCompoundIndex() {
+					continue
+				}
+				if deleted {
+					break
+				}
+				for _, v := range tx.indexValues(f.value.Interface()) {
+					indexes = append(indexes, key.Index(m.Name, f.Name, v, id))
+				}
 			case "required":
 				if f.isZero() {
-					return errors.Wrap(ErrFieldRequired, f.Name)
+					return nil, nil, errors.Wrap(ErrFieldRequired, f.Name)
 				}
 			case "unique":
-				k := key.Unique(m.Name, f.Name, toString(f.value.Interface()))
+				if isMultiValue(f.value) {
+					return nil, nil, errors.Errorf("unique index is not supported for field %#v of type %s", f.Name, f.value.Type())
+				}
+				if deleted {
+					break
+				}
+				k := key.Unique(m.Name, f.Name, tx.indexValue(f.value.Interface()))
 				ok, err := tx.db.client.Exists(k)
 				if err != nil {
-					return err
+					return nil, nil, err
 				}
 				if ok {
-					return errors.Wrapf(ErrUniqueConstraint, "%#v: %#v", f.Name, f.value.String())
+					return nil, nil, errors.Wrapf(ErrUniqueConstraint, "%#v: %#v", f.Name, f.value.String())
 				}
 				indexes = append(indexes, k)
+				cmps = append(cmps, uniqueCmp(k))
 			}
 			if f.hasTag("encrypted") {
 				if tx.db.cfg.key == nil {
-					return errors.New("encryption key is not set")
+					return nil, nil, errors.New("encryption key is not set")
 				}
 				enc, err := crypto.Encrypt([]byte(toString(f.value.Interface())), tx.db.cfg.key)
 				if err != nil {
-					return err
+					return nil, nil, err
 				}
 				switch f.value.Interface().(type) {
 				case string:
@@ -135,43 +224,104 @@ func (tx *Tx) Insert(iface interface{}) error {
 			}
 		}
 	}
+	if !deleted {
+		rowKeys, err := tx.rowIndexKeys(reflect.Indirect(reflect.ValueOf(iface)), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		indexes = append(indexes, rowKeys...)
+	}
 	data, err := tx.c.Encode(iface)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	ops := make([]clientv3.Op, 0)
 	ops = append(ops, clientv3.OpPut(key.ID(m.Name, id), string(data)))
 	for _, idx := range indexes {
 		ops = append(ops, clientv3.OpPut(idx, key.ID(m.Name, id)))
 	}
-	_, err = tx.batchOps(ops...)
-	return err
+	return cmps, ops, nil
 }
 
 func (tx *Tx) Update(iface interface{}) error {
+	cmps, ops, err := tx.updateOps(iface)
+	if err != nil {
+		return err
+	}
+	_, err = tx.batchOps(cmps, ops...)
+	return err
+}
+
+// updateOps returns the operations needed to update the provided value, along
+// with the conditions that must hold for the unique indexes. The value is
+// inserted if it does not already exist.
+func (tx *Tx) updateOps(iface interface{}) ([]clientv3.Cmp, []clientv3.Op, error) {
 	v := reflect.Indirect(reflect.ValueOf(iface))
 	m := NewModelItem(v)
 	if err := tx.validateModel(m.ModelDef); err != nil {
-		return err
+		return nil, nil, err
 	}
 	pk, err := m.getPrimaryKey()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	id := toString(pk.value.Interface())
 	if id == "" {
-		return errors.Wrapf(ErrInvalidPrimaryKey, "cannot be empty: %#v", pk.Name)
+		return nil, nil, errors.Wrapf(ErrInvalidPrimaryKey, "cannot be empty: %#v", pk.Name)
+	}
+	version, err := m.getVersion()
+	if err != nil {
+		return nil, nil, err
 	}
 	dbValue := reflect.Indirect(reflect.New(v.Type()))
-	if err := newQuery(tx.Table).findOneByPrimaryKey(key.ID(m.Name, id), dbValue); err != nil {
-		if errors.Cause(err) == ErrNoRows {
-			return tx.Insert(iface)
+	rev, err := newQuery(tx.Table).getRow(key.ID(m.Name, id), dbValue)
+	if errors.Cause(err) == ErrNoRows {
+		rev = 0
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// a soft-deleted row has no indexes, so it is written again as if it did
+	// not exist, as long as it is not written again before then
+	if rev == 0 || tx.isDeleted(dbValue) {
+		if version != nil && version.value.Int() != 0 {
+			return nil, nil, errors.Wrapf(ErrConflict, "%s %#v was deleted", m.Name, id)
 		}
-		return err
+		return tx.insertOps(iface, rev)
+	}
+	oldRowKeys, err := tx.rowIndexKeys(dbValue, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a row that is soft-deleted by the update is stored without its
+	// indexes, the same as when deleted by Delete
+	deleted := tx.isDeleted(v)
+	var oldKeys []string
+	if deleted {
+		oldKeys, err = tx.indexKeys(dbValue, id)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	removed := make([]string, 0)
+	added := make([]string, 0)
+	cmps := make([]clientv3.Cmp, 0)
+
+	// a versioned row is only updated if the provided version is the stored
+	// version, and the row is not written again before the update is
+	// committed
+	if version != nil {
+		dbVersion := dbValue.FieldByName(version.Name)
+		if version.value.Int() != dbVersion.Int() {
+			return nil, nil, errors.Wrapf(ErrConflict, "%s %#v is at version %d, received %d", m.Name, id, dbVersion.Int(), version.value.Int())
+		}
+		version.value.SetInt(dbVersion.Int() + 1)
+		dbVersion.SetInt(dbVersion.Int() + 1)
+		cmps = append(cmps, revisionCmp(key.ID(m.Name, id), rev))
 	}
-	indexes := make(map[string]string)
 	for _, f := range m.Fields {
-		if f.Name == pk.Name {
+		if f.Name == pk.Name || f == version {
 			continue
 		}
 		dbFieldValue := dbValue.FieldByName(f.Name)
@@ -186,11 +336,11 @@ func (tx *Tx) Update(iface interface{}) error {
 		}
 		if f.hasTag("encrypted") {
 			if tx.db.cfg.key == nil {
-				return errors.New("encryption key is not set")
+				return nil, nil, errors.New("encryption key is not set")
 			}
 			enc, err := crypto.Encrypt([]byte(toString(f.value.Interface())), tx.db.cfg.key)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 			switch f.value.Interface().(type) {
 			case string:
@@ -205,36 +355,82 @@ func (tx *Tx) Update(iface interface{}) error {
 		for _, tag := range f.Tags {
 			switch tag.Name {
 			case "index":
-				oldIdx := key.Index(m.Name, f.Name, toString(dbFieldValue.Interface()), id)
-				newIdx := key.Index(m.Name, f.Name, toString(f.value.Interface()), id)
-				indexes[oldIdx] = newIdx
+				if tag.isCompoundIndex() || deleted {
+					continue
+				}
+				oldIdx := make(map[string]bool)
+				for _, v := range tx.indexValues(dbFieldValue.Interface()) {
+					oldIdx[key.Index(m.Name, f.Name, v, id)] = true
+				}
+				for _, v := range tx.indexValues(f.value.Interface()) {
+					newIdx := key.Index(m.Name, f.Name, v, id)
+					if oldIdx[newIdx] {
+						delete(oldIdx, newIdx)
+						continue
+					}
+					added = append(added, newIdx)
+				}
+				for k := range oldIdx {
+					removed = append(removed, k)
+				}
 			case "unique":
-				oldIdx := key.Unique(m.Name, f.Name, toString(dbFieldValue.Interface()))
-				newIdx := key.Unique(m.Name, f.Name, toString(f.value.Interface()))
+				if deleted {
+					continue
+				}
+				oldIdx := key.Unique(m.Name, f.Name, tx.indexValue(dbFieldValue.Interface()))
+				newIdx := key.Unique(m.Name, f.Name, tx.indexValue(f.value.Interface()))
+				if oldIdx == newIdx {
+					continue
+				}
 				ok, err := tx.db.client.Exists(newIdx)
 				if err != nil {
-					return err
+					return nil, nil, err
 				}
 				if ok {
-					return errors.Wrapf(ErrUniqueConstraint, "%#v: %#v", f.Name, f.value.String())
+					return nil, nil, errors.Wrapf(ErrUniqueConstraint, "%#v: %#v", f.Name, f.value.String())
 				}
-				indexes[oldIdx] = newIdx
+				removed = append(removed, oldIdx)
+				added = append(added, newIdx)
+				cmps = append(cmps, uniqueCmp(newIdx))
 			}
 		}
 		dbFieldValue.Set(f.value)
 	}
+	if deleted {
+		removed = append(removed, oldKeys...)
+	} else {
+		newRowKeys, err := tx.rowIndexKeys(dbValue, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the row index keys are always written, so that updating a row adds
+		// any that are missing from rows written before they existed
+		oldIdx := make(map[string]bool)
+		for _, k := range oldRowKeys {
+			oldIdx[k] = true
+		}
+		for _, k := range newRowKeys {
+			delete(oldIdx, k)
+			added = append(added, k)
+		}
+		for k := range oldIdx {
+			removed = append(removed, k)
+		}
+	}
 	data, err := tx.c.Encode(dbValue.Interface())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	ops := make([]clientv3.Op, 0)
 	ops = append(ops, clientv3.OpPut(key.ID(m.Name, id), string(data)))
-	for oldIdx, newIdx := range indexes {
-		ops = append(ops, clientv3.OpDelete(oldIdx))
-		ops = append(ops, clientv3.OpPut(newIdx, key.ID(m.Name, id)))
+	for _, k := range removed {
+		ops = append(ops, clientv3.OpDelete(k))
 	}
-	_, err = tx.batchOps(ops...)
-	return err
+	for _, k := range added {
+		ops = append(ops, clientv3.OpPut(k, key.ID(m.Name, id)))
+	}
+	return cmps, ops, nil
 }
 
 // getIndexesByPrimaryKey returns all index keys for the provided primary key
@@ -251,17 +447,32 @@ func (tx *Tx) getIndexesByPrimaryKey(pk string) ([]string, error) {
 		}
 		return nil, err
 	}
-	keys := []string{pk}
 	_, id := filepath.Split(pk)
+	keys, err := tx.indexKeys(v, id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{pk}, keys...), nil
+}
+
+// indexKeys returns all index keys of the provided row.
+func (tx *Tx) indexKeys(v reflect.Value, id string) ([]string, error) {
+	keys := make([]string, 0)
 	for n, f := range tx.meta.Fields {
 		switch f.Type() {
 		case UniqueIndex:
-			keys = append(keys, key.Unique(tx.meta.Name, n, toString(v.FieldByName(n).Interface())))
+			keys = append(keys, key.Unique(tx.meta.Name, n, tx.indexValue(v.FieldByName(n).Interface())))
 		case SecondaryIndex:
-			keys = append(keys, key.Index(tx.meta.Name, n, toString(v.FieldByName(n).Interface()), id))
+			for _, value := range tx.indexValues(v.FieldByName(n).Interface()) {
+				keys = append(keys, key.Index(tx.meta.Name, n, value, id))
+			}
 		}
 	}
-	return keys, nil
+	rowKeys, err := tx.rowIndexKeys(v, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, rowKeys...), nil
 }
 
 func deleteOps(keys []string) (ops []clientv3.Op) {
@@ -277,16 +488,32 @@ func (tx *Tx) Delete(fieldName string, data interface{}) (int64, error) {
 	defer func() {
 		log.Debug("tx.Delete",
 			zap.String("key", fmt.Sprintf("%s/%v", tx.meta.Name, fieldName)),
-			zap.String("q", toString(data)),
+			zap.String("q", fmt.Sprint(data)),
 			zap.Int64("n", n),
 			zap.Duration("elapsed", time.Since(st)),
 		)
 	}()
-	f, ok := tx.meta.Fields[fieldName]
+	n, ops, err := tx.deleteRowsOps(fieldName, data)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.batchOps(nil, ops...); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// deleteRowsOps returns the operations needed to delete the rows matching the
+// provided field value, along with the number of rows being deleted.
+func (tx *Tx) deleteRowsOps(fieldName string, data interface{}) (int64, []clientv3.Op, error) {
+	f, ok := tx.meta.field(fieldName)
 	if !ok {
-		return 0, errors.Errorf("invalid field name: %#v", fieldName)
+		return 0, nil, errors.Errorf("invalid field name: %#v", fieldName)
+	}
+	k, err := tx.queryValue(fieldName, data)
+	if err != nil {
+		return 0, nil, err
 	}
-	k := toString(data)
 	pks := make([]string, 0)
 
 	// get the primary key of the item(s) being deleted
@@ -297,9 +524,9 @@ func (tx *Tx) Delete(fieldName string, data interface{}) (int64, error) {
 		b, err := tx.db.client.Get(key.Unique(tx.meta.Name, fieldName, k))
 		if err != nil {
 			if errors.Cause(err) == client.ErrKeyNotFound {
-				return 0, nil
+				return 0, nil, nil
 			}
-			return 0, err
+			return 0, nil, err
 		}
 		pks = append(pks, string(b))
 	case SecondaryIndex:
@@ -307,32 +534,67 @@ func (tx *Tx) Delete(fieldName string, data interface{}) (int64, error) {
 		kvs, err := tx.db.client.Prefix(x)
 		if err != nil {
 			if errors.Cause(err) == client.ErrKeyNotFound {
-				return 0, nil
+				return 0, nil, nil
 			}
-			return 0, err
+			return 0, nil, err
 		}
 		for _, kv := range kvs {
 			pks = append(pks, string(kv.Value))
 		}
 	default:
-		return 0, errors.Wrapf(ErrNotIndexed, "cannot delete %#v", fieldName)
+		return 0, nil, errors.Wrapf(ErrNotIndexed, "cannot delete %#v", fieldName)
 	}
 
+	var n int64
 	ops := make([]clientv3.Op, 0)
 	for _, pk := range pks {
 		keys, err := tx.getIndexesByPrimaryKey(pk)
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
-		if len(keys) > 0 {
-			n++
+		if len(keys) == 0 {
+			continue
+		}
+		n++
+		if tx.meta.softDeleteField() == "" {
+			ops = append(ops, deleteOps(keys)...)
+			continue
+		}
+
+		// a soft-deleted row is kept, and only its indexes are removed
+		op, err := tx.softDeleteOp(pk)
+		if err != nil {
+			return 0, nil, err
+		}
+		ops = append(ops, op)
+		for _, k := range keys {
+			if k != pk {
+				ops = append(ops, clientv3.OpDelete(k))
+			}
 		}
-		ops = append(ops, deleteOps(keys)...)
 	}
-	if _, err := tx.batchOps(ops...); err != nil {
-		return 0, err
+	return n, ops, nil
+}
+
+// softDeleteOp returns the operation that marks the row with the primary key
+// as deleted, by setting its softdelete field to the current time. The stored
+// row is decoded without decrypting its encrypted fields, so that they are
+// written back unchanged.
+func (tx *Tx) softDeleteOp(pk string) (clientv3.Op, error) {
+	data, err := tx.db.client.Get(pk)
+	if err != nil {
+		return clientv3.Op{}, err
 	}
-	return n, nil
+	v := reflect.New(tx.meta.t)
+	if err := tx.c.Decode(data, v.Interface()); err != nil {
+		return clientv3.Op{}, err
+	}
+	v.Elem().FieldByName(tx.meta.softDeleteField()).Set(reflect.ValueOf(time.Now().UTC()))
+	data, err = tx.c.Encode(v.Interface())
+	if err != nil {
+		return clientv3.Op{}, err
+	}
+	return clientv3.OpPut(pk, string(data)), nil
 }
 
 func (tx *Tx) DeleteAll() error {
@@ -347,26 +609,24 @@ func (tx *Tx) DeleteAll() error {
 		}
 		ops = append(ops, clientv3.OpDelete(string(kv.Key)))
 	}
-	_, err = tx.batchOps(ops...)
+	_, err = tx.batchOps(nil, ops...)
 	return err
 }
 
+// Drop deletes the table, including its rows and indexes. The stored table
+// definition is not validated, so that a table can be dropped after its model
+// has changed.
 func (tx *Tx) Drop() error {
-	v, err := tx.db.client.Get(key.TableDef(tx.meta.Name))
-	if err != nil {
-		if errors.Cause(err) != client.ErrKeyNotFound {
+	if _, err := tx.db.client.Get(key.TableDef(tx.meta.Name)); err != nil {
+		if errors.Cause(err) == client.ErrKeyNotFound {
 			return errors.Wrap(ErrTableNotFound, tx.meta.Name)
 		}
 		return err
 	}
-	var m *ModelDef
-	if err := tx.tc.Decode(v, &m); err != nil {
-		return err
-	}
-	if err := tx.validateModel(m); err != nil {
+	n, err := tx.db.client.DeletePrefix(key.Table(tx.meta.Name))
+	if err != nil {
 		return err
 	}
-	resp, err := tx.db.client.Delete(context.TODO(), key.Table(tx.meta.Name), clientv3.WithPrefix())
-	log.Debugf("dropped table %s, %d rows deleted", tx.meta.Name, resp.Deleted)
-	return err
+	log.Debugf("dropped table %s, %d rows deleted", tx.meta.Name, n)
+	return nil
 }
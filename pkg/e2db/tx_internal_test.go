@@ -0,0 +1,109 @@
+package e2db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/e2db/key"
+)
+
+type account struct {
+	ID    int    `e2db:"increment"`
+	Email string `e2db:"unique"`
+}
+
+func TestTxInsertUniqueRace(t *testing.T) {
+	// the server is started by the init of the external tests
+	db, err := New(context.Background(), &Config{
+		ClientAddr: ":2479",
+		Namespace:  "unique-race",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	accounts := db.Table(&account{})
+	if err := accounts.Drop(); err != nil && errors.Cause(err) != ErrTableNotFound {
+		t.Fatal(err)
+	}
+	err = accounts.Tx(func(tx *Tx) error {
+		cmps, ops, err := tx.insertOps(&account{Email: "smoot@example.com"}, 0)
+		if err != nil {
+			return err
+		}
+
+		// a writer whose table lock expired inserts the same unique value
+		// after the unique check, but before this insert is committed
+		k := key.Unique(tx.meta.Name, "Email", "smoot@example.com")
+		if err := tx.db.client.Set(k, key.ID(tx.meta.Name, "100")); err != nil {
+			return err
+		}
+		_, err = tx.batchOps(cmps, ops...)
+		return err
+	})
+	if errors.Cause(err) != ErrUniqueConstraint {
+		t.Fatalf("expected %v, received %v", ErrUniqueConstraint, err)
+	}
+	n, err := accounts.Count("ID", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected the racing insert to not be written, received %d rows", n)
+	}
+}
+
+type versionedAccount struct {
+	ID      int    `e2db:"increment"`
+	Email   string `e2db:"unique"`
+	Version int    `e2db:"version"`
+}
+
+func TestTxUpdateVersionRace(t *testing.T) {
+	// the server is started by the init of the external tests
+	db, err := New(context.Background(), &Config{
+		ClientAddr: ":2479",
+		Namespace:  "version-race",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	accounts := db.Table(&versionedAccount{})
+	if err := accounts.Drop(); err != nil && errors.Cause(err) != ErrTableNotFound {
+		t.Fatal(err)
+	}
+	a := &versionedAccount{Email: "smoot@example.com"}
+	if err := accounts.Insert(a); err != nil {
+		t.Fatal(err)
+	}
+	err = accounts.Tx(func(tx *Tx) error {
+		cmps, ops, err := tx.updateOps(&versionedAccount{ID: a.ID, Email: "smoot@example.org", Version: 1})
+		if err != nil {
+			return err
+		}
+
+		// a writer whose table lock expired writes the row after it was
+		// read, but before this update is committed
+		if err := tx.db.client.Set(key.ID(tx.meta.Name, strconv.Itoa(a.ID)), "x"); err != nil {
+			return err
+		}
+		_, err = tx.batchOps(cmps, ops...)
+		return err
+	})
+	if errors.Cause(err) != ErrConflict {
+		t.Fatalf("expected %v, received %v", ErrConflict, err)
+	}
+	n, err := accounts.Count("Email", "smoot@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected the stale update to not be written, received %d rows", n)
+	}
+}
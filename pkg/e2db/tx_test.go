@@ -1,6 +1,8 @@
 package e2db_test
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -120,3 +122,75 @@ func TestTxDelete(t *testing.T) {
 		})
 	}
 }
+
+type Document struct {
+	ID      string `e2db:"id"`
+	Body    string
+	Version int `e2db:"version"`
+}
+
+func TestUpdateVersionRace(t *testing.T) {
+	docs := db.Table(&Document{})
+	if err := docs.Drop(); err != nil && errors.Cause(err) != e2db.ErrTableNotFound {
+		t.Fatal(err)
+	}
+	d := &Document{ID: "readme", Body: "hello"}
+	if err := docs.Insert(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Version != 1 {
+		t.Fatalf("expected version 1 after Insert, received %d", d.Version)
+	}
+	if err := docs.Insert(&Document{ID: "readme"}); errors.Cause(err) != e2db.ErrConflict {
+		t.Fatalf("expected ErrConflict inserting existing row, received %v", err)
+	}
+
+	// both writers read version 1, so only one of them can update it
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var d Document
+			if err := docs.Find("ID", "readme", &d); err != nil {
+				errs[i] = err
+				return
+			}
+			d.Body = fmt.Sprintf("writer %d", i)
+			errs[i] = docs.Update(&d)
+		}(i)
+	}
+	wg.Wait()
+	var succeeded, conflicted int
+	for _, err := range errs {
+		switch errors.Cause(err) {
+		case nil:
+			succeeded++
+		case e2db.ErrConflict:
+			conflicted++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if succeeded != 1 || conflicted != 1 {
+		t.Fatalf("expected exactly one update to succeed, received %d succeeded, %d conflicted", succeeded, conflicted)
+	}
+
+	var r Document
+	if err := docs.Find("ID", "readme", &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Version != 2 {
+		t.Fatalf("expected version 2 after Update, received %d", r.Version)
+	}
+
+	// updating with the current version succeeds
+	r.Body = "goodbye"
+	if err := docs.Update(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Version != 3 {
+		t.Fatalf("expected version 3 after Update, received %d", r.Version)
+	}
+}
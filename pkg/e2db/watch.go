@@ -0,0 +1,122 @@
+package e2db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/e2db/key"
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// EventType is the type of change to a row in a table.
+type EventType int
+
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "PUT"
+	case EventDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Event is a change to a row in a table, received from Table.Watch.
+type Event struct {
+	Type EventType
+
+	// PrimaryKey is the primary key value of the changed row.
+	PrimaryKey string
+
+	// Value is a pointer to the row, decoded into the table's model type. For
+	// EventDelete, it is the row as it was before being deleted, and is nil if
+	// the previous value is no longer available.
+	Value interface{}
+}
+
+// Watch streams the changes to the rows of the table, starting from the
+// current revision, until the context is cancelled. The channel is closed when
+// the watch ends, including when it fails (such as when the revision being
+// watched has been compacted), which is logged.
+func (t *Table) Watch(ctx context.Context) (<-chan Event, error) {
+	if err := t.tableMustExist(); err != nil {
+		return nil, err
+	}
+
+	// The table definition and indexes are stored under the hidden prefix,
+	// which is skipped so that only changes to rows are sent.
+	prefix, hidden := key.Table(t.meta.Name), key.Hidden(t.meta.Name)
+	wch := t.db.client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				if ctx.Err() == nil {
+					log.Error("table watch failed", zap.String("table", t.meta.Name), zap.Error(err))
+				}
+				return
+			}
+			for _, ev := range resp.Events {
+				k := string(ev.Kv.Key)
+				if strings.HasPrefix(k, hidden) {
+					continue
+				}
+				e := Event{PrimaryKey: strings.TrimPrefix(k, prefix)}
+				var data []byte
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					e.Type = EventPut
+					data = ev.Kv.Value
+				case clientv3.EventTypeDelete:
+					e.Type = EventDelete
+					if ev.PrevKv != nil {
+						data = ev.PrevKv.Value
+					}
+				}
+				if data != nil {
+					v, err := t.decodeRow(data)
+					if err != nil {
+						log.Error("cannot decode table watch event",
+							zap.String("table", t.meta.Name),
+							zap.String("key", k),
+							zap.Error(err),
+						)
+						continue
+					}
+					e.Value = v
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// decodeRow decodes a stored row into a new value of the table's model type,
+// returning a pointer to it.
+func (t *Table) decodeRow(data []byte) (interface{}, error) {
+	v := reflect.New(t.meta.t)
+	if err := t.c.Decode(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	if err := newQuery(t).handleItemTags(v.Elem()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,86 @@
+package e2db_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/criticalstack/e2d/pkg/e2db"
+)
+
+func TestWatch(t *testing.T) {
+	resetTable(t)
+	roles := db.Table(&Role{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := roles.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() e2db.Event {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatal("watch channel closed")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for watch event")
+		}
+		return e2db.Event{}
+	}
+
+	// index keys written with the row are not sent
+	r := &Role{Name: "auditor", Description: "auditor"}
+	if err := roles.Insert(r); err != nil {
+		t.Fatal(err)
+	}
+	pk := strconv.Itoa(r.ID)
+	expected := e2db.Event{
+		Type:       e2db.EventPut,
+		PrimaryKey: pk,
+		Value:      &Role{ID: r.ID, Name: "auditor", Description: "auditor"},
+	}
+	if diff := cmp.Diff(expected, next()); diff != "" {
+		t.Errorf("e2db: after Insert differs: (-want +got)\n%s", diff)
+	}
+
+	if err := roles.Update(&Role{ID: r.ID, Name: "auditor", Description: "read-only"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = e2db.Event{
+		Type:       e2db.EventPut,
+		PrimaryKey: pk,
+		Value:      &Role{ID: r.ID, Name: "auditor", Description: "read-only"},
+	}
+	if diff := cmp.Diff(expected, next()); diff != "" {
+		t.Errorf("e2db: after Update differs: (-want +got)\n%s", diff)
+	}
+
+	if _, err := roles.Delete("ID", r.ID); err != nil {
+		t.Fatal(err)
+	}
+	expected = e2db.Event{
+		Type:       e2db.EventDelete,
+		PrimaryKey: pk,
+		Value:      &Role{ID: r.ID, Name: "auditor", Description: "read-only"},
+	}
+	if diff := cmp.Diff(expected, next()); diff != "" {
+		t.Errorf("e2db: after Delete differs: (-want +got)\n%s", diff)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected watch channel to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch channel to close")
+	}
+}
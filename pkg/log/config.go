@@ -54,3 +54,22 @@ func NewDefaultEncoderConfig() zapcore.EncoderConfig {
 		EncodeName:     CapitalColorFullNameEncoder,
 	}
 }
+
+// NewJSONEncoderConfig returns the encoder config used for JSON output, which
+// uses the conventional keys expected by log aggregators and no colors.
+func NewJSONEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+		EncodeName:     zapcore.FullNameEncoder,
+	}
+}
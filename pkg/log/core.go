@@ -0,0 +1,82 @@
+package log
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// core is a zapcore.Core that encodes each entry with the encoder selected by
+// SetEncoding at the time it is written. This allows loggers created before
+// the encoding is set, such as those created during init, to honor it. Both
+// encoders are built when the core is created, so that selecting one only
+// costs loading the encoding.
+type core struct {
+	zapcore.LevelEnabler
+	ns      string
+	out     zapcore.WriteSyncer
+	console zapcore.Encoder
+	json    zapcore.Encoder
+}
+
+func newCore(ns string, out zapcore.WriteSyncer, enab zapcore.LevelEnabler) zapcore.Core {
+	c := &core{
+		LevelEnabler: enab,
+		ns:           ns,
+		out:          out,
+		console:      NewEncoder(NewDefaultEncoderConfig()),
+		json:         zapcore.NewJSONEncoder(NewJSONEncoderConfig()),
+	}
+
+	// the namespace is the logger name of JSON entries, rather than a
+	// namespace for their fields
+	if ns != "" {
+		c.console.OpenNamespace(ns)
+	}
+	return c
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.console = c.console.Clone()
+	clone.json = c.json.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.console)
+		f.AddTo(clone.json)
+	}
+	return &clone
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := c.console
+	if getEncoding() == EncodingJSON {
+		enc = c.json
+		if c.ns != "" {
+			ent.LoggerName = c.ns
+		}
+	}
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	_, err = c.out.Write(buf.Bytes())
+	buf.Free()
+	if err != nil {
+		return err
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		// the process may exit after a panic or fatal entry, so the output
+		// is flushed first
+		return c.Sync()
+	}
+	return nil
+}
+
+func (c *core) Sync() error {
+	return c.out.Sync()
+}
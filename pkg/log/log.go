@@ -2,32 +2,54 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sync/atomic"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	// EncodingConsole is the default logfmt output, colored for terminals.
+	EncodingConsole = "console"
+
+	// EncodingJSON outputs a JSON object per line, for log aggregators.
+	EncodingJSON = "json"
+)
+
 var (
 	level = zap.NewAtomicLevel()
 
-	log = zap.New(zapcore.NewCore(
-		NewEncoder(NewDefaultEncoderConfig()),
-		zapcore.AddSync(os.Stderr),
-		level,
-	), zap.AddCaller(), zap.AddCallerSkip(1))
+	encoding atomic.Value
+
+	log = zap.New(newCore("", zapcore.AddSync(os.Stderr), level), zap.AddCaller(), zap.AddCallerSkip(1))
 )
 
+// SetEncoding sets the encoding {console,json} used by all loggers, including
+// the child loggers that were already created.
+func SetEncoding(enc string) error {
+	switch enc {
+	case EncodingConsole, EncodingJSON:
+	default:
+		return errors.Errorf("unknown log encoding %#v, must be console or json", enc)
+	}
+	encoding.Store(enc)
+	return nil
+}
+
+func getEncoding() string {
+	if enc, ok := encoding.Load().(string); ok {
+		return enc
+	}
+	return EncodingConsole
+}
+
 // NewLogger creates a new child logger with the provided namespace.
 func NewLogger(ns string) *zap.Logger {
-	encoder := NewEncoder(NewDefaultEncoderConfig())
-	encoder.OpenNamespace(ns)
 	return log.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-		return zapcore.NewCore(
-			encoder,
-			zapcore.AddSync(os.Stderr),
-			level,
-		)
+		return newCore(ns, zapcore.AddSync(os.Stderr), level)
 	}), zap.AddCaller())
 }
 
@@ -35,14 +57,14 @@ func NewLogger(ns string) *zap.Logger {
 // Since this specifies a level, it overrides the global package level for this
 // child logger only.
 func NewLoggerWithLevel(ns string, lvl zapcore.Level) *zap.Logger {
-	encoder := NewEncoder(NewDefaultEncoderConfig())
-	encoder.OpenNamespace(ns)
+	return NewLoggerWithOutput(ns, lvl, os.Stderr)
+}
+
+// NewLoggerWithOutput creates a new child logger with the provided namespace
+// and level that writes to w instead of stderr.
+func NewLoggerWithOutput(ns string, lvl zapcore.Level, w io.Writer) *zap.Logger {
 	return log.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-		return zapcore.NewCore(
-			encoder,
-			zapcore.AddSync(os.Stderr),
-			lvl,
-		)
+		return newCore(ns, zapcore.AddSync(w), lvl)
 	}))
 }
 
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetEncodingJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLoggerWithOutput("etcd", zapcore.DebugLevel, &buf)
+	t.Cleanup(func() {
+		if err := SetEncoding(EncodingConsole); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// the encoding is honored by loggers created before it was set
+	if err := SetEncoding(EncodingJSON); err != nil {
+		t.Fatal(err)
+	}
+	l.With(zap.String("member", "node1")).Info("starting etcd", zap.Int("port", 2379))
+	l.Debug("quoted \"message\"\nwith newline")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, received %d:\n%s", len(lines), buf.String())
+	}
+	entries := make([]map[string]interface{}, 0)
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		delete(entry, "ts")
+		delete(entry, "caller")
+		entries = append(entries, entry)
+	}
+	expected := []map[string]interface{}{
+		{
+			"level":  "info",
+			"logger": "etcd",
+			"msg":    "starting etcd",
+			"member": "node1",
+			"port":   float64(2379),
+		},
+		{
+			"level":  "debug",
+			"logger": "etcd",
+			"msg":    "quoted \"message\"\nwith newline",
+		},
+	}
+	if diff := cmp.Diff(expected, entries); diff != "" {
+		t.Errorf("entries: (-want +got)\n%s", diff)
+	}
+}
+
+func TestSetEncodingConsole(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLoggerWithOutput("etcd", zapcore.DebugLevel, &buf)
+	if err := SetEncoding(EncodingConsole); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("starting etcd", zap.Int("port", 2379))
+	if out := buf.String(); !strings.Contains(out, "M=\"starting etcd\"") || !strings.Contains(out, "port=2379") {
+		t.Fatalf("expected logfmt output, received %q", out)
+	}
+	if json.Valid(buf.Bytes()) {
+		t.Fatalf("expected logfmt output, received JSON %q", buf.String())
+	}
+}
+
+func TestSetEncodingWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLoggerWithOutput("etcd", zapcore.DebugLevel, &buf)
+	t.Cleanup(func() {
+		if err := SetEncoding(EncodingConsole); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// fields added before the encoding is set are kept by both encodings
+	l = l.With(zap.String("member", "node1"))
+	if err := SetEncoding(EncodingJSON); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("starting etcd")
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+	if entry["member"] != "node1" || entry["logger"] != "etcd" {
+		t.Fatalf("expected member and logger fields, received %v", entry)
+	}
+
+	buf.Reset()
+	if err := SetEncoding(EncodingConsole); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("starting etcd")
+	if out := buf.String(); !strings.Contains(out, "member=node1") {
+		t.Fatalf("expected member field, received %q", out)
+	}
+}
+
+func TestSetEncodingUnknown(t *testing.T) {
+	if err := SetEncoding("xml"); err == nil {
+		t.Fatal("expected error for unknown encoding")
+	}
+	if enc := getEncoding(); enc != EncodingConsole {
+		t.Fatalf("expected encoding %#v to be unchanged, received %#v", EncodingConsole, enc)
+	}
+}
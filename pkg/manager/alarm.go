@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/pkg/types"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// alarmClient is the subset of the etcd maintenance API used to list and
+// disarm alarms.
+type alarmClient interface {
+	AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error)
+	AlarmDisarm(ctx context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error)
+}
+
+// ListAlarms returns the alarms currently raised by members of the etcd
+// cluster, such as the NOSPACE alarm raised when the backend quota is
+// exceeded.
+func (m *Manager) ListAlarms(ctx context.Context) ([]*etcdserverpb.AlarmMember, error) {
+	c, err := m.newMemberClient(m.cfg.ClientURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	resp, err := c.AlarmList(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list alarms")
+	}
+	return resp.Alarms, nil
+}
+
+// DisarmAlarm disarms all alarms raised by the member with the provided ID.
+// An alarm raised again by the member, because the cause has not been
+// resolved, is not affected.
+func (m *Manager) DisarmAlarm(ctx context.Context, memberID uint64) error {
+	c, err := m.newMemberClient(m.cfg.ClientURL.String())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = disarmAlarms(ctx, c, func(a *etcdserverpb.AlarmMember) bool {
+		return a.MemberID == memberID
+	})
+	return err
+}
+
+// disarmNoSpaceAlarms disarms the NOSPACE alarms raised by any member of the
+// cluster, which otherwise reject writes until disarmed. It is only called on
+// the leader once space has been freed by defragmentation.
+func (m *Manager) disarmNoSpaceAlarms(ctx context.Context) error {
+	c, err := m.newMemberClient(m.cfg.ClientURL.String())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = disarmAlarms(ctx, c, func(a *etcdserverpb.AlarmMember) bool {
+		return a.Alarm == etcdserverpb.AlarmType_NOSPACE
+	})
+	return err
+}
+
+// disarmAlarms disarms each alarm for which match returns true, returning the
+// number of alarms disarmed.
+func disarmAlarms(ctx context.Context, c alarmClient, match func(*etcdserverpb.AlarmMember) bool) (int, error) {
+	resp, err := c.AlarmList(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot list alarms")
+	}
+	n := 0
+	for _, a := range resp.Alarms {
+		if !match(a) {
+			continue
+		}
+		if _, err := c.AlarmDisarm(ctx, (*clientv3.AlarmMember)(a)); err != nil {
+			return n, errors.Wrapf(err, "cannot disarm %s alarm of member %x", a.Alarm, a.MemberID)
+		}
+		log.Info("disarmed alarm",
+			zap.Stringer("alarm", a.Alarm),
+			zap.Stringer("member-id", types.ID(a.MemberID)),
+		)
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+)
+
+// fakeAlarmClient holds a static set of alarms, and records the alarms that
+// are disarmed.
+type fakeAlarmClient struct {
+	alarms   []*etcdserverpb.AlarmMember
+	disarmed []*etcdserverpb.AlarmMember
+}
+
+func (f *fakeAlarmClient) AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error) {
+	return &clientv3.AlarmResponse{Alarms: f.alarms}, nil
+}
+
+func (f *fakeAlarmClient) AlarmDisarm(ctx context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error) {
+	f.disarmed = append(f.disarmed, (*etcdserverpb.AlarmMember)(m))
+	return &clientv3.AlarmResponse{Alarms: []*etcdserverpb.AlarmMember{(*etcdserverpb.AlarmMember)(m)}}, nil
+}
+
+func TestDisarmAlarms(t *testing.T) {
+	f := &fakeAlarmClient{
+		alarms: []*etcdserverpb.AlarmMember{
+			{MemberID: 1, Alarm: etcdserverpb.AlarmType_NOSPACE},
+			{MemberID: 2, Alarm: etcdserverpb.AlarmType_CORRUPT},
+			{MemberID: 3, Alarm: etcdserverpb.AlarmType_NOSPACE},
+		},
+	}
+	n, err := disarmAlarms(context.Background(), f, func(a *etcdserverpb.AlarmMember) bool {
+		return a.Alarm == etcdserverpb.AlarmType_NOSPACE
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 alarms disarmed, received %d", n)
+	}
+	expected := []*etcdserverpb.AlarmMember{
+		{MemberID: 1, Alarm: etcdserverpb.AlarmType_NOSPACE},
+		{MemberID: 3, Alarm: etcdserverpb.AlarmType_NOSPACE},
+	}
+	if diff := cmp.Diff(expected, f.disarmed); diff != "" {
+		t.Errorf("disarmAlarms: disarmed alarms differ: (-want +got)\n%s", diff)
+	}
+}
+
+func TestManagerDisarmNoSpaceAlarms(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr: ":2379",
+		PeerAddr:   ":2380",
+		GossipAddr: ":7980",
+	})
+	c.start("node1")
+	c.wait("node1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	m := c.lookupNode("node1")
+	id := uint64(m.etcd.Server.ID())
+	if _, err := m.etcd.Server.Alarm(ctx, &etcdserverpb.AlarmRequest{
+		Action:   etcdserverpb.AlarmRequest_ACTIVATE,
+		MemberID: id,
+		Alarm:    etcdserverpb.AlarmType_NOSPACE,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	alarms, err := m.ListAlarms(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alarms) != 1 || alarms[0].MemberID != id || alarms[0].Alarm != etcdserverpb.AlarmType_NOSPACE {
+		t.Fatalf("expected NOSPACE alarm, received %v", alarms)
+	}
+	if err := m.disarmNoSpaceAlarms(ctx); err != nil {
+		t.Fatal(err)
+	}
+	alarms, err = m.ListAlarms(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alarms) != 0 {
+		t.Fatalf("expected no alarms, received %v", alarms)
+	}
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// AuditAction is the type of membership change recorded by an AuditEvent.
+type AuditAction string
+
+const (
+	AuditMemberAdd     AuditAction = "member-add"
+	AuditMemberRemove  AuditAction = "member-remove"
+	AuditMemberPromote AuditAction = "member-promote"
+)
+
+// AuditEvent is a record of a change to the etcd cluster membership.
+type AuditEvent struct {
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+
+	// name of the member making the change
+	Actor string `json:"actor"`
+
+	// name of the member being changed, which may be unknown when a member is
+	// first added
+	Member  string `json:"member,omitempty"`
+	PeerURL string `json:"peerURL,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// AuditSink receives audit events for membership changes.
+type AuditSink interface {
+	Audit(*AuditEvent) error
+}
+
+// FileAuditSink appends audit events to a file as JSON, one per line.
+type FileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileAuditSink opens the file at path for appending audit events, creating
+// it if necessary.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open audit log file: %#v", path)
+	}
+	return &FileAuditSink{f: f}, nil
+}
+
+func (s *FileAuditSink) Audit(ev *AuditEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.f.Write(append(data, '\n'))
+	return err
+}
+
+func (s *FileAuditSink) Close() error {
+	return s.f.Close()
+}
+
+// audit records a membership change with the configured AuditSink, if any. A
+// failure to record the event is logged but does not fail the change itself.
+func (m *Manager) audit(action AuditAction, member, peerURL, reason string) {
+	if m.cfg.AuditSink == nil {
+		return
+	}
+	ev := &AuditEvent{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Actor:   m.cfg.Name,
+		Member:  member,
+		PeerURL: peerURL,
+		Reason:  reason,
+	}
+	if err := m.cfg.AuditSink.Audit(ev); err != nil {
+		log.Warn("cannot write audit event",
+			zap.String("action", string(action)),
+			zap.String("member", member),
+			zap.Error(err),
+		)
+	}
+}
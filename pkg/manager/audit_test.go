@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// recordingAuditSink keeps audit events in memory.
+type recordingAuditSink struct {
+	mu     sync.Mutex
+	events []*AuditEvent
+}
+
+func (s *recordingAuditSink) Audit(ev *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *recordingAuditSink) find(action AuditAction, member string) *AuditEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ev := range s.events {
+		if ev.Action == action && ev.Member == member {
+			return ev
+		}
+	}
+	return nil
+}
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "e2d-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{cfg: &Config{Name: "node1", AuditSink: sink}}
+	m.audit(AuditMemberAdd, "node1", "http://127.0.0.1:2380", "joining existing cluster")
+	m.audit(AuditMemberRemove, "node2", "", "unreachable for longer than 5m0s")
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events := make([]*AuditEvent, 0)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ev := &AuditEvent{}
+		if err := json.Unmarshal(s.Bytes(), ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Time.IsZero() || time.Since(ev.Time) > time.Minute {
+			t.Errorf("unexpected audit event time: %v", ev.Time)
+		}
+		events = append(events, ev)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*AuditEvent{
+		{Action: AuditMemberAdd, Actor: "node1", Member: "node1", PeerURL: "http://127.0.0.1:2380", Reason: "joining existing cluster"},
+		{Action: AuditMemberRemove, Actor: "node1", Member: "node2", Reason: "unreachable for longer than 5m0s"},
+	}
+	if diff := cmp.Diff(expected, events, cmpopts.IgnoreFields(AuditEvent{}, "Time")); diff != "" {
+		t.Errorf("audit: events differ: (-want +got)\n%s", diff)
+	}
+}
@@ -2,14 +2,27 @@ package manager
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
+	"os"
 	"time"
 
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.uber.org/zap"
 
 	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/log"
 )
 
+// membersRetryInterval is the amount of time to wait between attempts to query
+// cluster members.
+var membersRetryInterval = 1 * time.Second
+
+// promoteRetryInterval is the amount of time to wait between attempts to
+// promote a learner that has not yet caught up with the leader.
+var promoteRetryInterval = 1 * time.Second
+
 type Client struct {
 	*client.Client
 
@@ -36,8 +49,9 @@ func (c *Client) members(ctx context.Context) (map[string]*Member, error) {
 	members := make(map[string]*Member)
 	for _, member := range resp.Members {
 		m := &Member{
-			ID:   member.ID,
-			Name: member.Name,
+			ID:        member.ID,
+			Name:      member.Name,
+			IsLearner: member.IsLearner,
 		}
 		if len(member.ClientURLs) > 0 {
 			m.ClientURL = member.ClientURLs[0]
@@ -50,17 +64,53 @@ func (c *Client) members(ctx context.Context) (map[string]*Member, error) {
 	return members, nil
 }
 
-func (c *Client) addMember(ctx context.Context, peerURL string) (*Member, error) {
+// membersWithRetry queries the cluster members, retrying up to the provided
+// number of times so that a peer that is momentarily slow to respond does not
+// immediately fail.
+func (c *Client) membersWithRetry(ctx context.Context, retries int) (map[string]*Member, error) {
+	var err error
+	for i := 0; ; i++ {
+		var members map[string]*Member
+		members, err = c.members(ctx)
+		if err == nil {
+			return members, nil
+		}
+		if i >= retries {
+			break
+		}
+		log.Debug("cannot query members, retrying ...",
+			zap.Strings("endpoints", c.cfg.ClientURLs),
+			zap.Int("attempt", i+1),
+			zap.Error(err),
+		)
+		select {
+		case <-time.After(membersRetryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, errors.Wrapf(err, "cannot query members after %d attempts", retries+1)
+}
+
+// addMember adds a member with the provided peer URL to the cluster. When
+// learner is set, the member is added as a non-voting learner, which must be
+// promoted with promoteMember once it has caught up with the leader.
+func (c *Client) addMember(ctx context.Context, peerURL string, learner bool) (*Member, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
 	defer cancel()
 
-	resp, err := c.MemberAdd(ctx, []string{peerURL})
+	add := c.MemberAdd
+	if learner {
+		add = c.MemberAddAsLearner
+	}
+	resp, err := add(ctx, []string{peerURL})
 	if err != nil {
 		return nil, err
 	}
 	m := &Member{
-		ID:   resp.Member.ID,
-		Name: resp.Member.Name,
+		ID:        resp.Member.ID,
+		Name:      resp.Member.Name,
+		IsLearner: resp.Member.IsLearner,
 	}
 	if len(resp.Member.ClientURLs) > 0 {
 		m.ClientURL = resp.Member.ClientURLs[0]
@@ -71,6 +121,34 @@ func (c *Client) addMember(ctx context.Context, peerURL string) (*Member, error)
 	return m, nil
 }
 
+// promoteMember promotes a learner to a voting member. The leader refuses to
+// promote a learner that has not yet caught up, so the promotion is retried
+// until it succeeds or the context is done.
+func (c *Client) promoteMember(ctx context.Context, id uint64) error {
+	for {
+		err := func() error {
+			ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+			defer cancel()
+
+			_, err := c.MemberPromote(ctx, id)
+			return err
+		}()
+		switch err {
+		case nil, rpctypes.ErrMemberNotLearner:
+			return nil
+		case rpctypes.ErrMemberLearnerNotReady:
+		default:
+			return errors.Wrap(err, "PromoteMember")
+		}
+		log.Debug("learner is not yet ready to be promoted, retrying ...", zap.Uint64("id", id))
+		select {
+		case <-time.After(promoteRetryInterval):
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "learner did not catch up with the leader")
+		}
+	}
+}
+
 func (c *Client) removeMember(ctx context.Context, id uint64) error {
 	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
 	defer cancel()
@@ -93,3 +171,47 @@ func (c *Client) removeMemberLocked(ctx context.Context, member *Member) error {
 
 	return c.removeMember(ctx, member.ID)
 }
+
+// tempFileReadCloser removes the underlying temporary file when closed.
+type tempFileReadCloser struct {
+	*os.File
+}
+
+func (f *tempFileReadCloser) Close() error {
+	defer os.Remove(f.Name())
+	return f.File.Close()
+}
+
+// createSnapshot streams a snapshot of the etcd backend from the client
+// endpoint, returning it along with its size and the revision of the member
+// when the snapshot was started. The snapshot is buffered to a temporary file,
+// since the size is required to encrypt it.
+func (c *Client) createSnapshot(ctx context.Context) (io.ReadCloser, int64, int64, error) {
+	if len(c.Endpoints()) == 0 {
+		return nil, 0, 0, errors.New("no client endpoints")
+	}
+	status, err := c.Status(ctx, c.Endpoints()[0])
+	if err != nil {
+		return nil, 0, 0, errors.Wrap(err, "cannot get member status")
+	}
+	r, err := c.Snapshot(ctx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer r.Close()
+
+	f, err := ioutil.TempFile("", "snapshot.save")
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	size, err := io.Copy(f, r)
+	if err == nil {
+		_, err = f.Seek(0, 0)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, 0, errors.Wrap(err, "cannot download snapshot")
+	}
+	return &tempFileReadCloser{f}, size, status.Header.Revision, nil
+}
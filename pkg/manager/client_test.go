@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"google.golang.org/grpc"
+
+	"github.com/criticalstack/e2d/pkg/client"
+)
+
+// slowClusterServer is a fake etcd cluster service that takes too long to
+// respond to the first slowCalls requests for the member list.
+type slowClusterServer struct {
+	etcdserverpb.ClusterServer
+
+	delay     time.Duration
+	slowCalls int32
+	calls     int32
+}
+
+func (s *slowClusterServer) MemberList(ctx context.Context, req *etcdserverpb.MemberListRequest) (*etcdserverpb.MemberListResponse, error) {
+	if atomic.AddInt32(&s.calls, 1) <= s.slowCalls {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &etcdserverpb.MemberListResponse{
+		Header: &etcdserverpb.ResponseHeader{},
+		Members: []*etcdserverpb.Member{
+			{
+				ID:         1,
+				Name:       "node1",
+				PeerURLs:   []string{"http://127.0.0.1:2380"},
+				ClientURLs: []string{"http://127.0.0.1:2379"},
+			},
+		},
+	}, nil
+}
+
+func newSlowPeer(t *testing.T, s *slowClusterServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	etcdserverpb.RegisterClusterServer(srv, s)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(srv.Stop)
+	return (&url.URL{Scheme: "http", Host: l.Addr().String()}).String()
+}
+
+func TestClientMembersWithRetrySlowPeer(t *testing.T) {
+	defer func(d time.Duration) { membersRetryInterval = d }(membersRetryInterval)
+	membersRetryInterval = 10 * time.Millisecond
+
+	cases := []struct {
+		name      string
+		slowCalls int32
+		retries   int
+		expectErr bool
+		calls     int32
+	}{
+		{name: "recovers after retry", slowCalls: 1, retries: 2, calls: 2},
+		{name: "gives up after retries", slowCalls: 3, retries: 2, expectErr: true, calls: 3},
+		{name: "no retries", slowCalls: 1, retries: 0, expectErr: true, calls: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &slowClusterServer{delay: 5 * time.Second, slowCalls: tc.slowCalls}
+			c, err := newClient(&client.Config{
+				ClientURLs: []string{newSlowPeer(t, s)},
+				Timeout:    250 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			members, err := c.membersWithRetry(context.Background(), tc.retries)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if members["node1"] == nil {
+					t.Fatalf("expected member node1, received %v", members)
+				}
+			}
+			if calls := atomic.LoadInt32(&s.calls); calls != tc.calls {
+				t.Fatalf("expected %d calls, received %d", tc.calls, calls)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ package manager
 import (
 	"bytes"
 	"crypto/sha512"
-	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -12,7 +11,9 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/url"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/criticalstack/e2d/pkg/discovery"
 	"github.com/criticalstack/e2d/pkg/log"
 	"github.com/criticalstack/e2d/pkg/netutil"
+	"github.com/criticalstack/e2d/pkg/pki"
 	"github.com/criticalstack/e2d/pkg/snapshot"
 	"github.com/pkg/errors"
 	bolt "go.etcd.io/bbolt"
@@ -46,6 +48,11 @@ type Config struct {
 	// allows for explicit setting of the host ip
 	Host string
 
+	// name of the network interface whose address is used as the host ip,
+	// for hosts with multiple interfaces, like separate management and data
+	// networks, cannot be used with Host
+	Interface string
+
 	// client endpoint for accessing etcd
 	ClientAddr string
 
@@ -58,6 +65,23 @@ type Config struct {
 	// peer url created based upon the peer address and use of TLS
 	PeerURL url.URL
 
+	// client address advertised to clients and the other members, for when
+	// it differs from the ClientAddr that is listened on, such as behind NAT
+	// or a Kubernetes Service fronting etcd, defaults to ClientAddr
+	AdvertiseClientAddr string
+
+	// advertised client url created based upon the advertised client address
+	// and use of TLS
+	AdvertiseClientURL url.URL
+
+	// peer address advertised to the other members, for when it differs from
+	// the PeerAddr that is listened on, defaults to PeerAddr
+	AdvertisePeerAddr string
+
+	// advertised peer url created based upon the advertised peer address and
+	// use of TLS
+	AdvertisePeerURL url.URL
+
 	// address used for gossip network
 	GossipAddr string
 
@@ -67,27 +91,138 @@ type Config struct {
 	// port used for gossip network, derived from GossipAddr
 	GossipPort int
 
+	// optional address used to serve the etcd /metrics and /health
+	// endpoints over plain http, in addition to the client port
+	MetricsAddr string
+
+	// metrics url created based upon the metrics address
+	MetricsURL url.URL
+
+	// profile of the memberlist configuration {lan,wan,local}, which selects
+	// the timeouts used to detect failed members of the gossip network,
+	// defaults to lan. The wan profile tolerates the higher latency between
+	// regions.
+	GossipProfile string
+
+	// override the interval between probes of gossip members and the timeout
+	// of each probe from the GossipProfile
+	GossipProbeInterval time.Duration
+	GossipProbeTimeout  time.Duration
+
 	// addresses used to bootstrap the gossip network
 	BootstrapAddrs []string
 
 	// amount of time to attempt bootstrapping before failing
 	BootstrapTimeout time.Duration
 
-	// interval for creating etcd snapshots
+	// minimum amount of time to observe the gossip network for an existing
+	// cluster before deciding to form a new cluster
+	BootstrapObservationWindow time.Duration
+
+	// keep attempting to join the running members of an existing cluster,
+	// rather than forming a new cluster once enough members are pending, until
+	// PreferExistingTimeout has elapsed. This prevents a cluster that still
+	// has a surviving member from being replaced by a new cluster, such as
+	// during a rolling replacement of all members.
+	PreferExisting bool
+
+	// amount of time PreferExisting waits for running members to be joined
+	// before forming a new cluster, defaults to 10 minutes and must be less
+	// than BootstrapTimeout
+	PreferExistingTimeout time.Duration
+
+	// fractions of the BootstrapTimeout at which to warn that bootstrapping
+	// has yet to succeed
+	BootstrapWarnings []float64
+
+	// amount of time to wait for a peer to respond when joining an existing
+	// cluster
+	JoinTimeout time.Duration
+
+	// number of times to retry querying a peer for cluster members before
+	// giving up on joining through that peer, defaults to 2 when unset and a
+	// negative value disables retries
+	JoinRetries int
+
+	// amount of time to wait between attempts to join or form a cluster
+	// while bootstrapping, defaults to 1 second
+	JoinRetryInterval time.Duration
+
+	// maximum amount of time for a single attempt to join an existing cluster
+	// through a peer, including starting etcd, so that a slow or unreachable
+	// peer cannot consume the whole BootstrapTimeout, defaults to 5 minutes
+	JoinAttemptTimeout time.Duration
+
+	// join an existing cluster as a non-voting learner, which is promoted to
+	// a voting member once it has caught up with the leader, so that a slow
+	// new member does not count towards quorum while catching up. The
+	// promotion must complete within the JoinAttemptTimeout.
+	JoinAsLearner bool
+
+	// check the integrity of an existing data-dir on startup, moving a
+	// corrupt data-dir aside so that it is recovered from a snapshot backup,
+	// or from the other members of a multi-node cluster
+	CheckDataDir bool
+
+	// interval for creating etcd snapshot backups, defaults to 1 minute when
+	// unset. A negative value disables creating snapshot backups, so the
+	// Snapshotter is only used to restore the cluster from an existing
+	// snapshot backup.
 	SnapshotInterval time.Duration
 
+	// zone (or rack) of this member, shared with the other members with the
+	// ZoneTag gossip tag
+	Zone string
+
+	// zone preferred for creating snapshot backups, such as the zone closest
+	// to the snapshot backup bucket. Snapshot backups are created by the
+	// running member in this zone with the lowest name, falling back to the
+	// leader when there are none. By default, the leader creates snapshot
+	// backups.
+	SnapshotPreferredZone string
+
+	// size limit of the etcd backend in bytes, after which etcd raises a
+	// NOSPACE alarm and only accepts reads and deletes, defaults to the etcd
+	// default of 2GiB when unset
+	QuotaBackendBytes int64
+
+	// interval at which the leader defragments each member of the cluster in
+	// turn, releasing the space freed by compaction back to the filesystem.
+	// Defragmentation is disabled when unset.
+	DefragInterval time.Duration
+
+	// certificates of the server and peer that expire within this duration are
+	// renewed, using the CA to sign new key pairs with the same hosts, and are
+	// then reloaded. Renewal is disabled when unset.
+	CertRenewBefore time.Duration
+
+	// name of the snapshot backup restored when the cluster is started from
+	// a snapshot, such as etcd-20200101T120000Z.snapshot, rather than the
+	// latest. This allows an older snapshot backup to be restored when the
+	// latest is corrupt. The Snapshotter must implement
+	// snapshot.NamedLoader.
+	SnapshotName string
+
 	// use gzip compression for snapshot backup
 	SnapshotCompression bool
 
 	// use aes-256 encryption for snapshot backup
 	SnapshotEncryption bool
 
+	// key prefixes within the volatile /_e2d prefix that are preserved when
+	// the cluster is restored from a snapshot backup, rather than deleted
+	PreservePrefixes []string
+
 	// how often to perform a health check
 	HealthCheckInterval time.Duration
 
 	// time until an unreachable member is considered unhealthy
 	HealthCheckTimeout time.Duration
 
+	// maximum amount of time to wait for the etcd server to stop when the
+	// manager is stopped
+	StopTimeout time.Duration
+
 	// configures authentication/transport security for clients
 	ClientSecurity client.SecurityConfig
 
@@ -97,15 +232,74 @@ type Config struct {
 	CACertFile string
 	CAKeyFile  string
 
+	// expected hash of the CA certificate, as printed by "e2d pki hash". When
+	// set, the CA certificate must match, and members of the gossip network
+	// advertising a different CA are ignored, so this member will neither
+	// join them nor form a cluster with them.
+	CACertHash string
+
+	// identity of the cluster shared with the gossip network, so that members
+	// of another cluster reached through overlapping BootstrapAddrs are
+	// ignored rather than joined. Defaults to the hash of the CA certificate
+	// when there is one, so it should be set explicitly to keep the same
+	// identity while rotating the CA. Unlike CACertHash, which must be set to
+	// verify the CA, the identity is checked by default. Members that do not
+	// advertise a cluster identity are also ignored, unless
+	// AllowMissingClusterID is set.
+	ClusterID string
+
+	// accept members of the gossip network that do not advertise a cluster
+	// identity, such as members running an older version during a rolling
+	// upgrade
+	AllowMissingClusterID bool
+
+	// key used to encrypt the gossip network, which must be 16, 24 or 32 bytes
+	// to select AES-128, AES-192 or AES-256. This enables gossip encryption
+	// without etcd PKI, and takes precedence over the key that is otherwise
+	// derived from the CA key.
+	GossipSecretKey []byte
+
+	// PEM-encoded certificates and keys provided inline rather than as file
+	// paths. When set, these take precedence over the corresponding file
+	// paths above (including the TrustedCAFile of ClientSecurity and
+	// PeerSecurity for CACert). The CA key is only used in memory. Since etcd
+	// only accepts file paths, the other inline material is written to a
+	// private temporary directory that is removed when the Manager stops.
+	CACert     []byte
+	CAKey      []byte
+	ServerCert []byte
+	ServerKey  []byte
+	PeerCert   []byte
+	PeerKey    []byte
+
 	// configures the level of the logger used by etcd
 	EtcdLogLevel zapcore.Level
 
+	// path to a file where the etcd and memberlist log output is appended,
+	// instead of stderr
+	EtcdLogFile string
+
+	// destination for the etcd and memberlist log output, takes precedence
+	// over EtcdLogFile
+	EtcdLogOutput io.Writer
+
+	// path to a file where an audit log of membership changes is appended
+	AuditLogFile string
+
+	// destination for audit events of membership changes, takes precedence
+	// over AuditLogFile
+	AuditSink AuditSink
+
 	discovery.PeerGetter
 	snapshot.Snapshotter
 
 	gossipSecretKey       []byte
+	caCertHash            []byte
 	snapshotEncryptionKey *[32]byte
 
+	// temporary directory holding inline certificate material
+	inlineCertDir string
+
 	Debug bool
 }
 
@@ -123,9 +317,56 @@ func (c *Config) validate() error {
 	if c.HealthCheckTimeout == 0 {
 		c.HealthCheckTimeout = 5 * time.Minute
 	}
+	if c.StopTimeout == 0 {
+		c.StopTimeout = 1 * time.Minute
+	}
 	if c.BootstrapTimeout == 0 {
 		c.BootstrapTimeout = 30 * time.Minute
 	}
+	if c.PreferExisting {
+		if c.PreferExistingTimeout == 0 {
+			c.PreferExistingTimeout = 10 * time.Minute
+		}
+		if c.PreferExistingTimeout >= c.BootstrapTimeout {
+			return errors.Errorf("PreferExistingTimeout must be less than BootstrapTimeout (%v), received %v", c.BootstrapTimeout, c.PreferExistingTimeout)
+		}
+	}
+	if c.BootstrapWarnings == nil {
+		c.BootstrapWarnings = []float64{0.25, 0.5, 0.75}
+	}
+	for _, f := range c.BootstrapWarnings {
+		if f <= 0 || f >= 1 {
+			return errors.Errorf("value of BootstrapWarnings must be between 0 and 1, received %v", f)
+		}
+	}
+	sort.Float64s(c.BootstrapWarnings)
+	for _, prefix := range c.PreservePrefixes {
+		if !strings.HasPrefix(prefix, string(volatilePrefix)+"/") {
+			return errors.Errorf("value of PreservePrefixes must be within %#v, received %#v", string(volatilePrefix)+"/", prefix)
+		}
+	}
+	if c.QuotaBackendBytes < 0 {
+		return errors.Errorf("value of QuotaBackendBytes must not be negative, received %d", c.QuotaBackendBytes)
+	}
+	switch c.GossipProfile {
+	case "":
+		c.GossipProfile = GossipProfileLAN
+	case GossipProfileLAN, GossipProfileWAN, GossipProfileLocal:
+	default:
+		return errors.Errorf("value of GossipProfile must be lan, wan, or local, received %#v", c.GossipProfile)
+	}
+	if c.JoinTimeout == 0 {
+		c.JoinTimeout = 3 * time.Second
+	}
+	if c.JoinRetries == 0 {
+		c.JoinRetries = 2
+	}
+	if c.JoinRetryInterval == 0 {
+		c.JoinRetryInterval = 1 * time.Second
+	}
+	if c.JoinAttemptTimeout == 0 {
+		c.JoinAttemptTimeout = 5 * time.Minute
+	}
 	for i, baddr := range c.BootstrapAddrs {
 		addr, err := netutil.FixUnspecifiedHostAddr(baddr)
 		if err != nil {
@@ -134,12 +375,41 @@ func (c *Config) validate() error {
 		c.BootstrapAddrs[i] = addr
 	}
 
+	// the log files are only opened by New, so that validating the
+	// configuration does not create them
+	if c.EtcdLogOutput == nil && c.EtcdLogFile != "" {
+		if err := checkFilePath(c.EtcdLogFile); err != nil {
+			return errors.Wrapf(err, "invalid EtcdLogFile: %#v", c.EtcdLogFile)
+		}
+	}
+	if c.AuditSink == nil && c.AuditLogFile != "" {
+		if err := checkFilePath(c.AuditLogFile); err != nil {
+			return errors.Wrapf(err, "invalid AuditLogFile: %#v", c.AuditLogFile)
+		}
+	}
+
+	if err := c.writeInlineCerts(); err != nil {
+		return err
+	}
+
 	// If the host is not set the IPv4 of the first non-loopback network
-	// adapter is used. This value is only used when the host is unspecified in
-	// an address.
+	// adapter is used, or the IPv6 when the client address is IPv6 (like
+	// [::]:2379). When an interface is set, the address of that interface is
+	// used instead. This value is only used when the host is unspecified in an
+	// address.
+	if c.Host != "" && c.Interface != "" {
+		return errors.New("cannot set both Host and Interface")
+	}
 	if c.Host == "" {
-		var err error
-		c.Host, err = netutil.DetectHostIPv4()
+		chost, _, err := netutil.SplitHostPort(c.ClientAddr)
+		if err != nil {
+			return errors.Wrapf(err, "invalid ClientAddr: %#v", c.ClientAddr)
+		}
+		if c.Interface != "" {
+			c.Host, err = netutil.DetectInterfaceIP(c.Interface, chost)
+		} else {
+			c.Host, err = netutil.DetectHostIP(chost)
+		}
 		if err != nil {
 			return err
 		}
@@ -148,7 +418,7 @@ func (c *Config) validate() error {
 	// parse etcd client address
 	caddr, err := netutil.ParseAddr(c.ClientAddr)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "invalid ClientAddr: %#v", c.ClientAddr)
 	}
 	if caddr.IsUnspecified() {
 		caddr.Host = c.Host
@@ -162,7 +432,7 @@ func (c *Config) validate() error {
 	// parse etcd peer address
 	paddr, err := netutil.ParseAddr(c.PeerAddr)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "invalid PeerAddr: %#v", c.PeerAddr)
 	}
 	if paddr.IsUnspecified() {
 		paddr.Host = c.Host
@@ -173,10 +443,23 @@ func (c *Config) validate() error {
 	c.PeerAddr = paddr.String()
 	c.PeerURL = url.URL{Scheme: c.PeerSecurity.Scheme(), Host: c.PeerAddr}
 
+	// parse the advertised addresses, which default to the addresses that
+	// are listened on
+	c.AdvertiseClientAddr, err = parseAdvertiseAddr(c.AdvertiseClientAddr, caddr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid AdvertiseClientAddr: %#v", c.AdvertiseClientAddr)
+	}
+	c.AdvertiseClientURL = url.URL{Scheme: c.ClientSecurity.Scheme(), Host: c.AdvertiseClientAddr}
+	c.AdvertisePeerAddr, err = parseAdvertiseAddr(c.AdvertisePeerAddr, paddr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid AdvertisePeerAddr: %#v", c.AdvertisePeerAddr)
+	}
+	c.AdvertisePeerURL = url.URL{Scheme: c.PeerSecurity.Scheme(), Host: c.AdvertisePeerAddr}
+
 	// parse gossip address
 	gaddr, err := netutil.ParseAddr(c.GossipAddr)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "invalid GossipAddr: %#v", c.GossipAddr)
 	}
 	if gaddr.IsUnspecified() {
 		gaddr.Host = c.Host
@@ -190,16 +473,45 @@ func (c *Config) validate() error {
 		return errors.Wrapf(err, "cannot split GossipAddr: %#v", c.GossipAddr)
 	}
 
+	// parse metrics address
+	if c.MetricsAddr != "" {
+		maddr, err := netutil.ParseAddr(c.MetricsAddr)
+		if err != nil {
+			return errors.Wrapf(err, "invalid MetricsAddr: %#v", c.MetricsAddr)
+		}
+		if maddr.Port == 0 {
+			return errors.Errorf("invalid MetricsAddr: %#v: must specify a port", c.MetricsAddr)
+		}
+		c.MetricsAddr = maddr.String()
+		c.MetricsURL = url.URL{Scheme: "http", Host: c.MetricsAddr}
+	}
+
+	if err := c.validatePorts(); err != nil {
+		return err
+	}
+
 	// both memberlist security and snapshot encryption are implicitly based
 	// upon the CA key
-	if c.CAKeyFile != "" {
-		data, err := ioutil.ReadFile(c.CAKeyFile)
+	caKey := c.CAKey
+	if len(caKey) > 0 && c.CAKeyFile != "" {
+		log.Warn("inline certificate material overrides file",
+			zap.String("name", "ca.key"),
+			zap.String("file", c.CAKeyFile),
+		)
+	}
+	if len(caKey) == 0 && c.CAKeyFile != "" {
+		caKey, err = ioutil.ReadFile(c.CAKeyFile)
 		if err != nil {
 			return err
 		}
-		block, _ := pem.Decode(data)
-		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
-			return errors.Wrapf(err, "cannot parse ca key file: %#v", c.CAKeyFile)
+	}
+	if len(caKey) > 0 {
+		block, _ := pem.Decode(caKey)
+		if block == nil {
+			return errors.New("cannot decode ca key: no PEM data found")
+		}
+		if _, err := pki.ParsePrivateKeyDER(block.Bytes); err != nil {
+			return errors.Wrap(err, "cannot parse ca key")
 		}
 		h := sha512.New512_256()
 		if _, err := h.Write(block.Bytes); err != nil {
@@ -213,22 +525,74 @@ func (c *Config) validate() error {
 		c.snapshotEncryptionKey = &key
 	}
 
-	if c.SnapshotEncryption && c.CAKeyFile == "" {
+	if len(c.GossipSecretKey) > 0 {
+		switch len(c.GossipSecretKey) {
+		case 16, 24, 32:
+		default:
+			return errors.Errorf("length of GossipSecretKey must be 16, 24, or 32 bytes, received %d", len(c.GossipSecretKey))
+		}
+		c.gossipSecretKey = c.GossipSecretKey
+	}
+
+	if c.CACertHash != "" {
+		c.caCertHash, err = pki.ParseCertHash(c.CACertHash)
+		if err != nil {
+			return err
+		}
+		if c.CACertFile == "" {
+			return errors.New("must provide ca cert to verify CACertHash")
+		}
+		h, err := pki.GenerateCertHash(c.CACertFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot hash ca cert")
+		}
+		if !bytes.Equal(h, c.caCertHash) {
+			return errors.Errorf("ca cert hash %s does not match CACertHash %s", pki.FormatCertHash(h), pki.FormatCertHash(c.caCertHash))
+		}
+	}
+
+	if c.ClusterID == "" && c.CACertFile != "" {
+		h, err := pki.GenerateCertHash(c.CACertFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot hash ca cert for ClusterID")
+		}
+		c.ClusterID = pki.FormatCertHash(h)
+	}
+
+	if c.SnapshotEncryption && c.snapshotEncryptionKey == nil {
 		return errors.New("must provide ca key for snapshot encryption")
 	}
 
+	if c.SnapshotName != "" {
+		if c.Snapshotter == nil {
+			return errors.New("must provide a Snapshotter to restore a named snapshot")
+		}
+		if _, ok := c.Snapshotter.(snapshot.NamedLoader); !ok {
+			return errors.Errorf("cannot restore named snapshot %#v: named snapshots not supported by %T", c.SnapshotName, c.Snapshotter)
+		}
+	}
+
+	if c.CertRenewBefore < 0 {
+		return errors.Errorf("value of CertRenewBefore must not be negative, received %s", c.CertRenewBefore)
+	}
+	if c.CertRenewBefore > 0 {
+		if c.CACertFile == "" || len(caKey) == 0 {
+			return errors.New("must provide ca cert and ca key to renew certificates")
+		}
+	}
+
 	if len(c.BootstrapAddrs) == 0 && c.RequiredClusterSize > 1 {
 		return errors.New("must provide at least 1 BootstrapAddrs when not a single-host cluster")
 	}
 	switch c.RequiredClusterSize {
 	case 0:
 		c.RequiredClusterSize = 1
-	case 1, 3, 5:
+	case 1, 3, 5, 7:
 	default:
-		return errors.New("value of RequiredClusterSize must be 1, 3, or 5")
+		return errors.New("value of RequiredClusterSize must be 1, 3, 5, or 7")
 	}
 	if c.Name == "" {
-		if name, err := getExistingNameFromDataDir(filepath.Join(c.Dir, "member/snap/db"), c.PeerURL); err == nil {
+		if name, err := getExistingNameFromDataDir(filepath.Join(c.Dir, "member/snap/db"), c.AdvertisePeerURL); err == nil {
 			log.Debugf("reusing name from existing data-dir: %v", name)
 			c.Name = name
 		} else {
@@ -239,6 +603,126 @@ func (c *Config) validate() error {
 	return nil
 }
 
+// Validate checks the configuration and sets any defaults, the same as New,
+// without starting etcd. The log files are only checked, rather than opened,
+// and inline certificate material written while validating is removed before
+// returning.
+func (c *Config) Validate() error {
+	defer c.removeInlineCerts()
+	return c.validate()
+}
+
+// checkFilePath checks that a file can be created at path, or that the file
+// already exists, without opening it.
+func checkFilePath(path string) error {
+	fi, err := os.Stat(path)
+	if err == nil {
+		if fi.IsDir() {
+			return errors.New("is a directory")
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	fi, err = os.Stat(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.Errorf("%#v is not a directory", filepath.Dir(path))
+	}
+	return nil
+}
+
+// openLogs opens the etcd log and audit log files, when they are set and no
+// writer or sink is provided instead.
+func (c *Config) openLogs() error {
+	var f *os.File
+	if c.EtcdLogOutput == nil && c.EtcdLogFile != "" {
+		var err error
+		f, err = os.OpenFile(c.EtcdLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Wrapf(err, "cannot open etcd log file: %#v", c.EtcdLogFile)
+		}
+	}
+	if c.AuditSink == nil && c.AuditLogFile != "" {
+		sink, err := NewFileAuditSink(c.AuditLogFile)
+		if err != nil {
+			if f != nil {
+				f.Close()
+			}
+			return err
+		}
+		c.AuditSink = sink
+	}
+	if c.EtcdLogOutput == nil {
+		c.EtcdLogOutput = os.Stderr
+		if f != nil {
+			c.EtcdLogOutput = f
+		}
+	}
+	return nil
+}
+
+// removeInlineCerts removes the temporary directory holding inline certificate
+// material, if one was created.
+func (c *Config) removeInlineCerts() {
+	if c.inlineCertDir == "" {
+		return
+	}
+	if err := os.RemoveAll(c.inlineCertDir); err != nil {
+		log.Error("cannot remove inline certificate directory", zap.String("dir", c.inlineCertDir), zap.Error(err))
+		return
+	}
+	c.inlineCertDir = ""
+}
+
+// writeInlineCerts writes any inline certificate material to files and
+// replaces the corresponding file paths with the newly written files.
+func (c *Config) writeInlineCerts() error {
+	files := []struct {
+		name  string
+		data  []byte
+		paths []*string
+	}{
+		{"ca.crt", c.CACert, []*string{&c.CACertFile, &c.ClientSecurity.TrustedCAFile, &c.PeerSecurity.TrustedCAFile}},
+		{"server.crt", c.ServerCert, []*string{&c.ClientSecurity.CertFile}},
+		{"server.key", c.ServerKey, []*string{&c.ClientSecurity.KeyFile}},
+		{"peer.crt", c.PeerCert, []*string{&c.PeerSecurity.CertFile}},
+		{"peer.key", c.PeerKey, []*string{&c.PeerSecurity.KeyFile}},
+	}
+	for _, f := range files {
+		if len(f.data) == 0 {
+			continue
+		}
+		if block, _ := pem.Decode(f.data); block == nil {
+			return errors.Errorf("cannot decode inline %s: no PEM data found", f.name)
+		}
+		if c.inlineCertDir == "" {
+			dir, err := ioutil.TempDir("", "e2d-pki-")
+			if err != nil {
+				return err
+			}
+			c.inlineCertDir = dir
+		}
+		path := filepath.Join(c.inlineCertDir, f.name)
+		if err := ioutil.WriteFile(path, f.data, 0600); err != nil {
+			return errors.Wrapf(err, "cannot write inline %s", f.name)
+		}
+		if *f.paths[0] != "" && *f.paths[0] != path {
+			log.Warn("inline certificate material overrides file",
+				zap.String("name", f.name),
+				zap.String("file", *f.paths[0]),
+			)
+		}
+		for _, p := range f.paths {
+			*p = path
+		}
+	}
+	return nil
+}
+
 // shortName returns a shorter, lowercase version of the node name. The intent
 // is to make log reading easier.
 func shortName(name string) string {
@@ -248,37 +732,102 @@ func shortName(name string) string {
 	return strings.ToLower(name)
 }
 
-func getExistingNameFromDataDir(path string, peerURL url.URL) (string, error) {
-	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+// dataDirMember is an etcd member as stored in the etcd data-dir.
+type dataDirMember struct {
+	ID       uint64   `json:"id"`
+	Name     string   `json:"name"`
+	PeerURLs []string `json:"peerURLs"`
+}
+
+// getExistingMembersFromDataDir reads the etcd members stored in the db file
+// of an existing etcd data-dir.
+func getExistingMembersFromDataDir(path string) ([]*dataDirMember, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer db.Close()
-	var name string
+	members := make([]*dataDirMember, 0)
 	err = db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("members"))
 		if b == nil {
-			return errors.New("existing name not found")
+			return errors.New("existing members not found")
 		}
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var m struct {
-				ID       uint64   `json:"id"`
-				Name     string   `json:"name"`
-				PeerURLs []string `json:"peerURLs"`
-			}
+			var m *dataDirMember
 			if err := json.Unmarshal(v, &m); err != nil {
 				log.Error("cannot unmarshal etcd member", zap.Error(err))
 				continue
 			}
-			for _, u := range m.PeerURLs {
-				if u == peerURL.String() {
-					name = m.Name
-					return nil
-				}
-			}
+			members = append(members, m)
 		}
-		return errors.New("existing name not found")
+		return nil
 	})
-	return name, err
+	return members, err
+}
+
+func getExistingNameFromDataDir(path string, peerURL url.URL) (string, error) {
+	members, err := getExistingMembersFromDataDir(path)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range members {
+		for _, u := range m.PeerURLs {
+			if u == peerURL.String() {
+				return m.Name, nil
+			}
+		}
+	}
+	return "", errors.New("existing name not found")
+}
+
+// parseAdvertiseAddr parses an address advertised in place of the listen
+// address, using the listen address when it is not set, and the port of the
+// listen address when the port is not set. Unlike the listen addresses, the
+// host cannot be unspecified since other members must be able to reach it.
+func parseAdvertiseAddr(addr string, listen *netutil.Address) (string, error) {
+	if addr == "" {
+		return listen.String(), nil
+	}
+	a, err := netutil.ParseAddr(addr)
+	if err != nil {
+		return "", err
+	}
+	if a.IsUnspecified() {
+		return "", errors.New("host must be specified")
+	}
+	if a.Port == 0 {
+		a.Port = listen.Port
+	}
+	return a.String(), nil
+}
+
+// validatePorts checks that the client, peer, gossip and metrics addresses
+// use different ports, since etcd would otherwise fail to bind with a much
+// less obvious error well into startup.
+func (c *Config) validatePorts() error {
+	addrs := []struct {
+		name, addr string
+	}{
+		{"ClientAddr", c.ClientAddr},
+		{"PeerAddr", c.PeerAddr},
+		{"GossipAddr", c.GossipAddr},
+		{"MetricsAddr", c.MetricsAddr},
+	}
+	ports := make(map[int]string)
+	for _, a := range addrs {
+		if a.addr == "" {
+			continue
+		}
+		_, port, err := netutil.SplitHostPort(a.addr)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s: %#v", a.name, a.addr)
+		}
+		if name, ok := ports[port]; ok {
+			return errors.Errorf("%s and %s must use different ports, both use port %d", name, a.name, port)
+		}
+		ports[port] = a.name
+	}
+	return nil
 }
@@ -1,10 +1,22 @@
 package manager
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/cloudflare/cfssl/csr"
+
+	"github.com/criticalstack/e2d/pkg/client"
 	"github.com/criticalstack/e2d/pkg/netutil"
+	"github.com/criticalstack/e2d/pkg/pki"
+	"github.com/criticalstack/e2d/pkg/snapshot"
 )
 
 func TestConfigUnspecifiedAddr(t *testing.T) {
@@ -34,3 +46,621 @@ func TestConfigUnspecifiedAddr(t *testing.T) {
 		t.Fatalf("BootstrapAddr unspecified address not fixed: %v", cfg.BootstrapAddrs[0])
 	}
 }
+
+func TestConfigIPv6Addr(t *testing.T) {
+	cfg := &Config{
+		Host:           "fd00::10",
+		ClientAddr:     "[::]:2379",
+		PeerAddr:       "[fd00::10]:2380",
+		GossipAddr:     "[::]:",
+		BootstrapAddrs: []string{"[fd00::11]:7980"},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAddr != "[fd00::10]:2379" {
+		t.Fatalf("expected ClientAddr %#v, received %#v", "[fd00::10]:2379", cfg.ClientAddr)
+	}
+	if cfg.ClientURL.String() != "http://[fd00::10]:2379" {
+		t.Fatalf("expected ClientURL %#v, received %#v", "http://[fd00::10]:2379", cfg.ClientURL.String())
+	}
+	if cfg.PeerURL.String() != "http://[fd00::10]:2380" {
+		t.Fatalf("expected PeerURL %#v, received %#v", "http://[fd00::10]:2380", cfg.PeerURL.String())
+	}
+	if cfg.GossipAddr != "[fd00::10]:7980" {
+		t.Fatalf("expected GossipAddr %#v, received %#v", "[fd00::10]:7980", cfg.GossipAddr)
+	}
+	if cfg.GossipHost != "fd00::10" || cfg.GossipPort != 7980 {
+		t.Fatalf("expected gossip host and port %#v %d, received %#v %d", "fd00::10", 7980, cfg.GossipHost, cfg.GossipPort)
+	}
+	if cfg.BootstrapAddrs[0] != "[fd00::11]:7980" {
+		t.Fatalf("expected BootstrapAddrs %#v, received %#v", "[fd00::11]:7980", cfg.BootstrapAddrs[0])
+	}
+}
+
+func TestConfigInterface(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loopback string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+	cfg := &Config{
+		Interface:  loopback,
+		ClientAddr: "0.0.0.0:2379",
+		PeerAddr:   "0.0.0.0:2380",
+		GossipAddr: "0.0.0.0:7980",
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "127.0.0.1" {
+		t.Fatalf("expected Host %#v, received %#v", "127.0.0.1", cfg.Host)
+	}
+	if cfg.ClientAddr != "127.0.0.1:2379" {
+		t.Fatalf("expected ClientAddr %#v, received %#v", "127.0.0.1:2379", cfg.ClientAddr)
+	}
+
+	cfg = &Config{
+		Interface:  "e2d-missing0",
+		ClientAddr: "0.0.0.0:2379",
+		PeerAddr:   "0.0.0.0:2380",
+		GossipAddr: "0.0.0.0:7980",
+	}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error for a missing interface")
+	}
+
+	cfg = &Config{
+		Host:       "10.0.0.10",
+		Interface:  loopback,
+		ClientAddr: "0.0.0.0:2379",
+		PeerAddr:   "0.0.0.0:2380",
+		GossipAddr: "0.0.0.0:7980",
+	}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error setting both Host and Interface")
+	}
+}
+
+func TestConfigAdvertiseAddrs(t *testing.T) {
+	cfg := &Config{
+		ClientAddr: "10.0.0.10:2379",
+		PeerAddr:   "10.0.0.10:2380",
+		GossipAddr: "10.0.0.10:7980",
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdvertiseClientURL.String() != "http://10.0.0.10:2379" {
+		t.Fatalf("expected AdvertiseClientURL %#v, received %#v", "http://10.0.0.10:2379", cfg.AdvertiseClientURL.String())
+	}
+	if cfg.AdvertisePeerURL.String() != "http://10.0.0.10:2380" {
+		t.Fatalf("expected AdvertisePeerURL %#v, received %#v", "http://10.0.0.10:2380", cfg.AdvertisePeerURL.String())
+	}
+
+	cfg = &Config{
+		ClientAddr:          "10.0.0.10:2379",
+		PeerAddr:            "10.0.0.10:2380",
+		GossipAddr:          "10.0.0.10:7980",
+		AdvertiseClientAddr: "etcd.example.com:32379",
+		AdvertisePeerAddr:   "node1.etcd.example.com:",
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientURL.String() != "http://10.0.0.10:2379" {
+		t.Fatalf("expected ClientURL %#v, received %#v", "http://10.0.0.10:2379", cfg.ClientURL.String())
+	}
+	if cfg.AdvertiseClientURL.String() != "http://etcd.example.com:32379" {
+		t.Fatalf("expected AdvertiseClientURL %#v, received %#v", "http://etcd.example.com:32379", cfg.AdvertiseClientURL.String())
+	}
+	if cfg.AdvertisePeerURL.String() != "http://node1.etcd.example.com:2380" {
+		t.Fatalf("expected AdvertisePeerURL %#v, received %#v", "http://node1.etcd.example.com:2380", cfg.AdvertisePeerURL.String())
+	}
+
+	// other members cannot reach an unspecified address
+	cfg = &Config{
+		ClientAddr:        "10.0.0.10:2379",
+		PeerAddr:          "10.0.0.10:2380",
+		GossipAddr:        "10.0.0.10:7980",
+		AdvertisePeerAddr: "0.0.0.0:2380",
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "AdvertisePeerAddr") {
+		t.Fatalf("expected invalid AdvertisePeerAddr, received %v", err)
+	}
+}
+
+func TestConfigInlineCerts(t *testing.T) {
+	r, err := pki.NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCert := func(profile, cn string) *pki.KeyPair {
+		certs, err := r.GenerateCertificates(profile, &csr.CertificateRequest{
+			KeyRequest: &csr.KeyRequest{A: "rsa", S: 2048},
+			Hosts:      []string{"127.0.0.1"},
+			CN:         cn,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return certs
+	}
+	server := newCert(pki.ServerSigningProfile, "etcd server")
+	peer := newCert(pki.PeerSigningProfile, "etcd peer")
+
+	dir, err := ioutil.TempDir("", "e2d-inline-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := New(&Config{
+		Name:       "inline",
+		Dir:        filepath.Join(dir, "data"),
+		Host:       "127.0.0.1",
+		ClientAddr: "127.0.0.1:2379",
+		PeerAddr:   "127.0.0.1:2380",
+		GossipAddr: "127.0.0.1:7980",
+		// inline material takes precedence over file paths
+		ClientSecurity: client.SecurityConfig{
+			TrustedCAFile: "does/not/exist/ca.crt",
+		},
+		CACertFile:         "does/not/exist/ca.crt",
+		CAKeyFile:          "does/not/exist/ca.key",
+		CACert:             r.CA.CertPEM,
+		CAKey:              r.CA.KeyPEM,
+		ServerCert:         server.CertPEM,
+		ServerKey:          server.KeyPEM,
+		PeerCert:           peer.CertPEM,
+		PeerKey:            peer.KeyPEM,
+		SnapshotEncryption: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.cfg.removeInlineCerts()
+
+	if m.cfg.ClientURL.Scheme != "https" || m.cfg.PeerURL.Scheme != "https" {
+		t.Fatalf("expected https urls, received %v and %v", m.cfg.ClientURL, m.cfg.PeerURL)
+	}
+	if m.cfg.gossipSecretKey == nil || m.cfg.snapshotEncryptionKey == nil {
+		t.Fatal("expected keys to be derived from inline ca key")
+	}
+	files := []struct {
+		path string
+		want []byte
+	}{
+		{m.cfg.CACertFile, r.CA.CertPEM},
+		{m.cfg.ClientSecurity.TrustedCAFile, r.CA.CertPEM},
+		{m.cfg.ClientSecurity.CertFile, server.CertPEM},
+		{m.cfg.ClientSecurity.KeyFile, server.KeyPEM},
+		{m.cfg.PeerSecurity.TrustedCAFile, r.CA.CertPEM},
+		{m.cfg.PeerSecurity.CertFile, peer.CertPEM},
+		{m.cfg.PeerSecurity.KeyFile, peer.KeyPEM},
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, f.want) {
+			t.Fatalf("expected %s to contain inline material", f.path)
+		}
+	}
+
+	// the ca key is only used in memory, and the other material is removed
+	// once the manager is stopped
+	if _, err := os.Stat(filepath.Join(filepath.Dir(m.cfg.CACertFile), "ca.key")); !os.IsNotExist(err) {
+		t.Fatalf("expected ca key to not be written, received %v", err)
+	}
+	m.cfg.removeInlineCerts()
+	if _, err := os.Stat(filepath.Dir(m.cfg.CACertFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected inline certificate directory to be removed, received %v", err)
+	}
+
+	if _, err := New(&Config{
+		Host:   "127.0.0.1",
+		CACert: []byte("not pem"),
+	}); err == nil {
+		t.Fatal("expected error for invalid inline ca cert")
+	}
+}
+
+func TestConfigCACertHash(t *testing.T) {
+	r, err := pki.NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := pki.GenerateCertHashFromPEM(r.CA.CertPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newConfig := func(hash string) *Config {
+		return &Config{
+			Host:       "127.0.0.1",
+			ClientAddr: "127.0.0.1:2379",
+			PeerAddr:   "127.0.0.1:2380",
+			GossipAddr: "127.0.0.1:7980",
+			CACert:     r.CA.CertPEM,
+			CACertHash: hash,
+		}
+	}
+
+	cfg := newConfig(pki.FormatCertHash(h))
+	defer cfg.removeInlineCerts()
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cfg.caCertHash, h) {
+		t.Fatalf("expected %x, received %x", h, cfg.caCertHash)
+	}
+
+	cfg = newConfig(pki.FormatCertHash(bytes.Repeat([]byte{0x01}, 32)))
+	defer cfg.removeInlineCerts()
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error for mismatched CACertHash")
+	}
+}
+
+func TestConfigClusterID(t *testing.T) {
+	r, err := pki.NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := pki.GenerateCertHashFromPEM(r.CA.CertPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		caCert    []byte
+		clusterID string
+		expected  string
+	}{
+		{name: "no ca"},
+		{name: "ca", caCert: r.CA.CertPEM, expected: pki.FormatCertHash(h)},
+		{name: "explicit", clusterID: "cluster1", expected: "cluster1"},
+		{name: "explicit with ca", caCert: r.CA.CertPEM, clusterID: "cluster1", expected: "cluster1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := &Config{
+				Host:       "127.0.0.1",
+				ClientAddr: "127.0.0.1:2379",
+				PeerAddr:   "127.0.0.1:2380",
+				GossipAddr: "127.0.0.1:7980",
+				CACert:     c.caCert,
+				ClusterID:  c.clusterID,
+			}
+			defer cfg.removeInlineCerts()
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ClusterID != c.expected {
+				t.Fatalf("expected %#v, received %#v", c.expected, cfg.ClusterID)
+			}
+		})
+	}
+}
+
+func TestConfigSnapshotInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		expected time.Duration
+	}{
+		{name: "unset", interval: 0, expected: 1 * time.Minute},
+		{name: "set", interval: 5 * time.Minute, expected: 5 * time.Minute},
+		{name: "disabled", interval: -1, expected: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Host:             "127.0.0.1",
+				ClientAddr:       "127.0.0.1:2379",
+				PeerAddr:         "127.0.0.1:2380",
+				GossipAddr:       "127.0.0.1:7980",
+				SnapshotInterval: tt.interval,
+			}
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.SnapshotInterval != tt.expected {
+				t.Fatalf("expected %v, received %v", tt.expected, cfg.SnapshotInterval)
+			}
+		})
+	}
+}
+
+func TestConfigSnapshotName(t *testing.T) {
+	fs, err := snapshot.NewFileSnapshotter(filepath.Join("testdata", "snapshot-name", "etcd.snapshot"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Join("testdata", "snapshot-name"))
+
+	tests := []struct {
+		name        string
+		snapshotter snapshot.Snapshotter
+		expectErr   bool
+	}{
+		{name: "file", snapshotter: fs},
+		{name: "multi", snapshotter: snapshot.NewMultiSnapshotter(fs)},
+		{name: "unsupported", snapshotter: snapshot.NewInMemorySnapshotter(), expectErr: true},
+		{name: "missing", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Host:         "127.0.0.1",
+				ClientAddr:   "127.0.0.1:2379",
+				PeerAddr:     "127.0.0.1:2380",
+				GossipAddr:   "127.0.0.1:7980",
+				SnapshotName: "etcd-20200101T120000Z.snapshot",
+				Snapshotter:  tt.snapshotter,
+			}
+			err := cfg.validate()
+			if tt.expectErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestConfigRequiredClusterSize(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 4, 5, 6, 7, 8} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			cfg := &Config{
+				Host:                "127.0.0.1",
+				ClientAddr:          "127.0.0.1:2379",
+				PeerAddr:            "127.0.0.1:2380",
+				GossipAddr:          "127.0.0.1:7980",
+				BootstrapAddrs:      []string{"127.0.0.1:7981"},
+				RequiredClusterSize: size,
+			}
+			err := cfg.validate()
+			switch size {
+			case 0, 1, 3, 5, 7:
+				if err != nil {
+					t.Fatal(err)
+				}
+			default:
+				if err == nil {
+					t.Fatalf("expected RequiredClusterSize %d to be rejected", size)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidateBadPeerAddr(t *testing.T) {
+	cfg := &Config{
+		ClientAddr: "0.0.0.0:2379",
+		PeerAddr:   "0.0.0.0:notaport",
+		GossipAddr: "0.0.0.0:7980",
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error for invalid PeerAddr")
+	}
+	if !strings.Contains(err.Error(), "invalid PeerAddr") {
+		t.Fatalf("expected invalid PeerAddr error, received: %v", err)
+	}
+}
+
+func TestConfigValidateLogFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newConfig := func() *Config {
+		return &Config{
+			Host:         "127.0.0.1",
+			Dir:          dir,
+			ClientAddr:   "127.0.0.1:2379",
+			PeerAddr:     "127.0.0.1:2380",
+			GossipAddr:   "127.0.0.1:7980",
+			EtcdLogFile:  filepath.Join(dir, "etcd.log"),
+			AuditLogFile: filepath.Join(dir, "audit.log"),
+		}
+	}
+
+	// validating only checks the paths, and does not create the files
+	if err := newConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"etcd.log", "audit.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to not be created, received %v", name, err)
+		}
+	}
+
+	cfg := newConfig()
+	cfg.AuditLogFile = filepath.Join(dir, "missing", "audit.log")
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid AuditLogFile") {
+		t.Fatalf("expected invalid AuditLogFile error, received: %v", err)
+	}
+	cfg = newConfig()
+	cfg.EtcdLogFile = dir
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid EtcdLogFile") {
+		t.Fatalf("expected invalid EtcdLogFile error, received: %v", err)
+	}
+
+	// the files are opened when the Manager is created
+	cfg = newConfig()
+	if _, err := New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer cfg.EtcdLogOutput.(*os.File).Close()
+	defer cfg.AuditSink.(*FileAuditSink).Close()
+	for _, name := range []string{"etcd.log", "audit.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigDistinctPorts(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientAddr  string
+		peerAddr    string
+		gossipAddr  string
+		metricsAddr string
+		conflict    string
+	}{
+		{name: "distinct", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:2380", gossipAddr: "127.0.0.1:7980"},
+		{name: "distinct with metrics", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:2380", gossipAddr: "127.0.0.1:7980", metricsAddr: "127.0.0.1:2381"},
+		{name: "defaulted", clientAddr: "127.0.0.1:", peerAddr: "127.0.0.1:", gossipAddr: "127.0.0.1:"},
+		{name: "client and peer", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:2379", gossipAddr: "127.0.0.1:7980", conflict: "ClientAddr and PeerAddr"},
+		{name: "peer and gossip", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:7980", gossipAddr: "127.0.0.1:7980", conflict: "PeerAddr and GossipAddr"},
+		{name: "all the same", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:2379", gossipAddr: "127.0.0.1:2379", conflict: "ClientAddr and PeerAddr"},
+		{name: "different hosts", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.2:2379", gossipAddr: "127.0.0.1:7980", conflict: "ClientAddr and PeerAddr"},
+		{name: "defaulted client", clientAddr: "127.0.0.1:", peerAddr: "127.0.0.1:2379", gossipAddr: "127.0.0.1:7980", conflict: "ClientAddr and PeerAddr"},
+		{name: "defaulted gossip", clientAddr: "127.0.0.1:7980", peerAddr: "127.0.0.1:2380", gossipAddr: "127.0.0.1:", conflict: "ClientAddr and GossipAddr"},
+		{name: "metrics and client", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:2380", gossipAddr: "127.0.0.1:7980", metricsAddr: "0.0.0.0:2379", conflict: "ClientAddr and MetricsAddr"},
+		{name: "metrics and gossip", clientAddr: "127.0.0.1:2379", peerAddr: "127.0.0.1:2380", gossipAddr: "127.0.0.1:7980", metricsAddr: "127.0.0.1:7980", conflict: "GossipAddr and MetricsAddr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Host:        "127.0.0.1",
+				ClientAddr:  tt.clientAddr,
+				PeerAddr:    tt.peerAddr,
+				GossipAddr:  tt.gossipAddr,
+				MetricsAddr: tt.metricsAddr,
+			}
+			err := cfg.validate()
+			if tt.conflict == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error for conflicting %s", tt.conflict)
+			}
+			if !strings.Contains(err.Error(), tt.conflict) {
+				t.Fatalf("expected %s conflict, received: %v", tt.conflict, err)
+			}
+		})
+	}
+}
+
+func TestConfigMetricsAddr(t *testing.T) {
+	cfg := &Config{
+		Host:        "127.0.0.1",
+		ClientAddr:  "127.0.0.1:2379",
+		PeerAddr:    "127.0.0.1:2380",
+		GossipAddr:  "127.0.0.1:7980",
+		MetricsAddr: "127.0.0.1:",
+	}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error for MetricsAddr without a port")
+	}
+	cfg.MetricsAddr = "0.0.0.0:2381"
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MetricsURL.String() != "http://0.0.0.0:2381" {
+		t.Fatalf("expected MetricsURL %#v, received %#v", "http://0.0.0.0:2381", cfg.MetricsURL.String())
+	}
+}
+
+func TestConfigGossipSecretKey(t *testing.T) {
+	r, err := pki.NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{1}, 32)
+	cfg := &Config{
+		ClientAddr:      "0.0.0.0:2379",
+		PeerAddr:        "0.0.0.0:2380",
+		GossipAddr:      "0.0.0.0:7980",
+		CAKey:           r.CA.KeyPEM,
+		GossipSecretKey: key,
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cfg.gossipSecretKey, key) {
+		t.Fatal("expected GossipSecretKey to take precedence over the key derived from the ca key")
+	}
+
+	cfg = &Config{
+		ClientAddr:      "0.0.0.0:2379",
+		PeerAddr:        "0.0.0.0:2380",
+		GossipAddr:      "0.0.0.0:7980",
+		GossipSecretKey: []byte("short"),
+	}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error for invalid GossipSecretKey length")
+	}
+}
+
+func TestConfigGossipProfile(t *testing.T) {
+	for _, profile := range []string{"", GossipProfileLAN, GossipProfileWAN, GossipProfileLocal, "metro"} {
+		cfg := &Config{
+			ClientAddr:    "0.0.0.0:2379",
+			PeerAddr:      "0.0.0.0:2380",
+			GossipAddr:    "0.0.0.0:7980",
+			GossipProfile: profile,
+		}
+		err := cfg.validate()
+		if profile == "metro" {
+			if err == nil {
+				t.Errorf("expected error for GossipProfile %#v", profile)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("GossipProfile %#v: %v", profile, err)
+		}
+	}
+}
+
+func TestConfigQuotaBackendBytes(t *testing.T) {
+	cfg := &Config{
+		ClientAddr:        "0.0.0.0:2379",
+		PeerAddr:          "0.0.0.0:2380",
+		GossipAddr:        "0.0.0.0:7980",
+		QuotaBackendBytes: -1,
+	}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error for negative QuotaBackendBytes")
+	}
+}
+
+func TestConfigECDSACAKey(t *testing.T) {
+	r, err := pki.NewDefaultRootCAWithKeyConfig(&pki.KeyConfig{Algo: pki.KeyAlgoECDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		ClientAddr: "0.0.0.0:2379",
+		PeerAddr:   "0.0.0.0:2380",
+		GossipAddr: "0.0.0.0:7980",
+		CAKey:      r.CA.KeyPEM,
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.gossipSecretKey) != 32 || cfg.snapshotEncryptionKey == nil {
+		t.Fatal("expected keys to be derived from the ecdsa ca key")
+	}
+}
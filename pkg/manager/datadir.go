@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	"go.etcd.io/etcd/pkg/fileutil"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+var errDataDirCorrupt = errors.New("data-dir is corrupt")
+
+// etcd refuses to start with a data-dir that has any other permissions
+const dataDirMode = 0700
+
+// geteuid is replaced in tests to check the ownership of the data-dir
+var geteuid = os.Geteuid
+
+// checkDataDirAccess verifies that the data-dir can be used by etcd before it
+// is started, so that a misconfigured data-dir fails with an error explaining
+// how to fix it, rather than a failure from within etcd. The data-dir is
+// created if it does not exist, and its permissions are changed to the 0700
+// required by etcd.
+func checkDataDirAccess(dir string) error {
+	uid := geteuid()
+	if err := os.MkdirAll(dir, dataDirMode); err != nil {
+		return errors.Wrapf(err, "cannot create data-dir %#v, check that its parent directory exists and is writable by uid %d", dir, uid)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read data-dir: %#v", dir)
+	}
+
+	// root can use a data-dir owned by any user
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && uid != 0 && int(st.Uid) != uid {
+		return errors.Errorf("data-dir %#v is owned by uid %d, but e2d is running as uid %d, change its owner with: chown -R %d %s", dir, st.Uid, uid, uid, dir)
+	}
+	if perm := fi.Mode().Perm(); perm != dataDirMode {
+		log.Info("changing data-dir permissions required by etcd",
+			zap.String("dir", dir),
+			zap.Stringer("mode", perm),
+			zap.Stringer("new-mode", os.FileMode(dataDirMode)),
+		)
+		if err := os.Chmod(dir, dataDirMode); err != nil {
+			return errors.Wrapf(err, "data-dir %#v has permissions %v, but etcd requires %v, change them with: chmod 0700 %s", dir, perm, os.FileMode(dataDirMode), dir)
+		}
+	}
+	if err := fileutil.IsDirWriteable(dir); err != nil {
+		return errors.Wrapf(err, "data-dir %#v is not writable by uid %d, check that it is not on a read-only filesystem", dir, uid)
+	}
+	return nil
+}
+
+// verifyDataDir checks the integrity of an existing etcd data-dir in the same
+// way etcd reads it when starting. This includes the WAL, from the newest
+// valid snapshot onward, and the backend database. A data-dir that does not
+// exist is not considered corrupt.
+func verifyDataDir(dir string) error {
+	memberDir := filepath.Join(dir, "member")
+	if _, err := os.Stat(memberDir); os.IsNotExist(err) {
+		return nil
+	}
+	lg := zap.NewNop()
+	walDir := filepath.Join(memberDir, "wal")
+	walSnaps, err := wal.ValidSnapshotEntries(lg, walDir)
+	if err != nil {
+		return errors.Wrapf(errDataDirCorrupt, "cannot read wal snapshots: %v", err)
+	}
+	var walSnap walpb.Snapshot
+	s, err := snap.New(lg, filepath.Join(memberDir, "snap")).LoadNewestAvailable(walSnaps)
+	switch err {
+	case nil:
+		walSnap.Index, walSnap.Term = s.Metadata.Index, s.Metadata.Term
+	case snap.ErrNoSnapshot:
+	default:
+		return errors.Wrapf(errDataDirCorrupt, "cannot load snapshot: %v", err)
+	}
+
+	// a torn write at the end of the wal is repaired by etcd when starting,
+	// and is not reported here
+	if err := wal.Verify(lg, walDir, walSnap); err != nil {
+		return errors.Wrapf(errDataDirCorrupt, "cannot verify wal: %v", err)
+	}
+
+	// only invalid meta pages mean the backend is corrupt, while other
+	// errors, like a lock held by another process or missing permissions, are
+	// returned as is so that the data-dir is not replaced
+	db, err := bolt.Open(filepath.Join(memberDir, "snap/db"), 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	switch err {
+	case nil:
+	case bolt.ErrInvalid, bolt.ErrVersionMismatch, bolt.ErrChecksum:
+		return errors.Wrapf(errDataDirCorrupt, "cannot open backend: %v", err)
+	default:
+		return errors.Wrap(err, "cannot open backend")
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			return errors.Wrapf(errDataDirCorrupt, "backend check failed: %v", err)
+		}
+		return nil
+	})
+}
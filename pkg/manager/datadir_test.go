@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/wal"
+	"go.uber.org/zap"
+)
+
+func TestCheckDataDirAccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datadir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{name: "missing"},
+		{name: "private", mode: 0700},
+		{name: "wrong perms", mode: 0755},
+		{name: "read-only", mode: 0500},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dataDir := filepath.Join(dir, c.name)
+			if c.mode != 0 {
+				if err := os.Mkdir(dataDir, c.mode); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(dataDir, c.mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := checkDataDirAccess(dataDir); err != nil {
+				t.Fatal(err)
+			}
+			fi, err := os.Stat(dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != dataDirMode {
+				t.Fatalf("expected mode %v, received %v", os.FileMode(dataDirMode), fi.Mode().Perm())
+			}
+		})
+	}
+
+	t.Run("not a directory", func(t *testing.T) {
+		dataDir := filepath.Join(dir, "file")
+		if err := ioutil.WriteFile(dataDir, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkDataDirAccess(dataDir); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("read-only parent", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write to a read-only directory")
+		}
+		parent := filepath.Join(dir, "read-only-parent")
+		if err := os.Mkdir(parent, 0500); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(parent, 0700)
+
+		err := checkDataDirAccess(filepath.Join(parent, "data"))
+		if err == nil || !strings.Contains(err.Error(), "cannot create data-dir") {
+			t.Fatalf("expected error creating data-dir, received %v", err)
+		}
+	})
+
+	t.Run("wrong owner", func(t *testing.T) {
+		dataDir := filepath.Join(dir, "wrong-owner")
+		if err := os.Mkdir(dataDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(dataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owner := int(fi.Sys().(*syscall.Stat_t).Uid)
+		defer func(fn func() int) { geteuid = fn }(geteuid)
+		geteuid = func() int { return owner + 1 }
+
+		err = checkDataDirAccess(dataDir)
+		if err == nil || !strings.Contains(err.Error(), "chown") {
+			t.Fatalf("expected ownership error, received %v", err)
+		}
+
+		// the permissions are not changed for a data-dir owned by another user
+		fi, err = os.Stat(dataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0755 {
+			t.Fatalf("expected mode %v, received %v", os.FileMode(0755), fi.Mode().Perm())
+		}
+	})
+}
+
+func TestVerifyDataDirBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datadir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := wal.Create(zap.NewNop(), filepath.Join(dir, "member", "wal"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "member", "snap"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "member", "snap", "db")
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a backend locked by another process is not corrupt
+	err = verifyDataDir(dir)
+	db.Close()
+	if errors.Cause(err) != bolt.ErrTimeout {
+		t.Fatalf("expected bolt.ErrTimeout, received %v", err)
+	}
+	if err := verifyDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// a backend with invalid meta pages is corrupt
+	if err := ioutil.WriteFile(dbPath, bytes.Repeat([]byte{0xff}, 16*1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyDataDir(dir); errors.Cause(err) != errDataDirCorrupt {
+		t.Fatalf("expected errDataDirCorrupt, received %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// defragTimeout is the maximum amount of time to wait for a single member to
+// be defragmented.
+const defragTimeout = 5 * time.Minute
+
+// Defragment defragments the backend of the local etcd member, releasing the
+// space freed by compaction back to the filesystem. Requests to this member
+// block until defragmentation completes.
+func (m *Manager) Defragment(ctx context.Context) error {
+	if !m.etcd.isRunning() || m.etcd.isRestarting() {
+		return errServerStopped
+	}
+	return m.defragmentMember(ctx, m.cfg.ClientURL.String())
+}
+
+// newMemberClient creates a client connected only to the member with the
+// provided client URL.
+func (m *Manager) newMemberClient(clientURL string) (*Client, error) {
+	return newClient(&client.Config{
+		ClientURLs:     []string{clientURL},
+		SecurityConfig: m.cfg.PeerSecurity,
+	})
+}
+
+func (m *Manager) defragmentMember(ctx context.Context, clientURL string) error {
+	c, err := m.newMemberClient(clientURL)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, defragTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.Defragment(ctx, clientURL); err != nil {
+		return errors.Wrapf(err, "cannot defragment member: %#v", clientURL)
+	}
+	log.Info("defragmented member",
+		zap.String("client-url", clientURL),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+// defragmentCluster defragments each member of the cluster in turn, the
+// followers first and the leader last, so that no more than one member is
+// blocked by defragmentation at a time.
+func (m *Manager) defragmentCluster(ctx context.Context) error {
+	leader := m.etcd.Server.Leader()
+	members := m.etcd.Server.Cluster().Members()
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].ID != leader && members[j].ID == leader
+	})
+	for _, member := range members {
+		if len(member.ClientURLs) == 0 {
+			continue
+		}
+		if m.etcd.isRestarting() {
+			return errServerStopped
+		}
+		if err := m.defragmentMember(ctx, member.ClientURLs[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runDefragmenter periodically defragments the members of the cluster. Only
+// the leader defragments the cluster, so that members are not defragmented
+// concurrently.
+func (m *Manager) runDefragmenter() {
+	if m.cfg.DefragInterval <= 0 {
+		return
+	}
+	log.Debug("starting defragmenter")
+	ticker := time.NewTicker(m.cfg.DefragInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.ctx.Done():
+			log.Debug("stopping defragmenter")
+			return
+		}
+		if m.etcd.isRestarting() {
+			log.Debug("server is restarting, skipping defragmentation")
+			continue
+		}
+		if !m.etcd.isLeader() {
+			log.Debug("not leader, skipping defragmentation")
+			continue
+		}
+		if err := m.defragmentCluster(m.ctx); err != nil {
+			log.Error("cannot defragment cluster", zap.Error(err))
+			continue
+		}
+
+		// space has been freed, so writes can be accepted again
+		if err := m.disarmNoSpaceAlarms(m.ctx); err != nil {
+			log.Error("cannot disarm NOSPACE alarms", zap.Error(err))
+		}
+	}
+}
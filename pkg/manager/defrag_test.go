@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestManagerDefragment(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr: ":2379",
+		PeerAddr:   ":2380",
+		GossipAddr: ":7980",
+	})
+	c.start("node1")
+	c.wait("node1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	m := c.lookupNode("node1")
+	if err := m.Defragment(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.defragmentCluster(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -25,8 +25,46 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 
+type Role int32
+
+const (
+	Role_UNKNOWN  Role = 0
+	Role_LEADER   Role = 1
+	Role_FOLLOWER Role = 2
+	Role_LEARNER  Role = 3
+)
+
+var Role_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "LEADER",
+	2: "FOLLOWER",
+	3: "LEARNER",
+}
+
+var Role_value = map[string]int32{
+	"UNKNOWN":  0,
+	"LEADER":   1,
+	"FOLLOWER": 2,
+	"LEARNER":  3,
+}
+
+func (x Role) String() string {
+	return proto.EnumName(Role_name, int32(x))
+}
+
+func (Role) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_d6214d299197430f, []int{0}
+}
+
 type HealthResponse struct {
 	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	GossipMembers        int32    `protobuf:"varint,2,opt,name=gossip_members,json=gossipMembers,proto3" json:"gossip_members,omitempty"`
+	RunningMembers       int32    `protobuf:"varint,3,opt,name=running_members,json=runningMembers,proto3" json:"running_members,omitempty"`
+	PendingMembers       int32    `protobuf:"varint,4,opt,name=pending_members,json=pendingMembers,proto3" json:"pending_members,omitempty"`
+	RequiredClusterSize  int32    `protobuf:"varint,5,opt,name=required_cluster_size,json=requiredClusterSize,proto3" json:"required_cluster_size,omitempty"`
+	Quorum               bool     `protobuf:"varint,6,opt,name=quorum,proto3" json:"quorum,omitempty"`
+	EtcdHealthy          bool     `protobuf:"varint,7,opt,name=etcd_healthy,json=etcdHealthy,proto3" json:"etcd_healthy,omitempty"`
+	EtcdError            string   `protobuf:"bytes,8,opt,name=etcd_error,json=etcdError,proto3" json:"etcd_error,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -72,6 +110,55 @@ func (m *HealthResponse) GetStatus() string {
 	return ""
 }
 
+func (m *HealthResponse) GetGossipMembers() int32 {
+	if m != nil {
+		return m.GossipMembers
+	}
+	return 0
+}
+
+func (m *HealthResponse) GetRunningMembers() int32 {
+	if m != nil {
+		return m.RunningMembers
+	}
+	return 0
+}
+
+func (m *HealthResponse) GetPendingMembers() int32 {
+	if m != nil {
+		return m.PendingMembers
+	}
+	return 0
+}
+
+func (m *HealthResponse) GetRequiredClusterSize() int32 {
+	if m != nil {
+		return m.RequiredClusterSize
+	}
+	return 0
+}
+
+func (m *HealthResponse) GetQuorum() bool {
+	if m != nil {
+		return m.Quorum
+	}
+	return false
+}
+
+func (m *HealthResponse) GetEtcdHealthy() bool {
+	if m != nil {
+		return m.EtcdHealthy
+	}
+	return false
+}
+
+func (m *HealthResponse) GetEtcdError() string {
+	if m != nil {
+		return m.EtcdError
+	}
+	return ""
+}
+
 type RestartResponse struct {
 	Msg                  string   `protobuf:"bytes,1,opt,name=msg,proto3" json:"msg,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -119,29 +206,365 @@ func (m *RestartResponse) GetMsg() string {
 	return ""
 }
 
+type SnapshotResponse struct {
+	Revision             int64    `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	SizeBytes            int64    `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotResponse) Reset()         { *m = SnapshotResponse{} }
+func (m *SnapshotResponse) String() string { return proto.CompactTextString(m) }
+func (*SnapshotResponse) ProtoMessage()    {}
+func (*SnapshotResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d6214d299197430f, []int{2}
+}
+func (m *SnapshotResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SnapshotResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SnapshotResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SnapshotResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotResponse.Merge(m, src)
+}
+func (m *SnapshotResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *SnapshotResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotResponse proto.InternalMessageInfo
+
+func (m *SnapshotResponse) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *SnapshotResponse) GetSizeBytes() int64 {
+	if m != nil {
+		return m.SizeBytes
+	}
+	return 0
+}
+
+type MemberStatus struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	PeerUrl              string   `protobuf:"bytes,2,opt,name=peer_url,json=peerUrl,proto3" json:"peer_url,omitempty"`
+	ClientUrl            string   `protobuf:"bytes,3,opt,name=client_url,json=clientUrl,proto3" json:"client_url,omitempty"`
+	IsLeader             bool     `protobuf:"varint,4,opt,name=is_leader,json=isLeader,proto3" json:"is_leader,omitempty"`
+	Reachable            bool     `protobuf:"varint,5,opt,name=reachable,proto3" json:"reachable,omitempty"`
+	RaftIndex            uint64   `protobuf:"varint,6,opt,name=raft_index,json=raftIndex,proto3" json:"raft_index,omitempty"`
+	GossipStatus         string   `protobuf:"bytes,7,opt,name=gossip_status,json=gossipStatus,proto3" json:"gossip_status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MemberStatus) Reset()         { *m = MemberStatus{} }
+func (m *MemberStatus) String() string { return proto.CompactTextString(m) }
+func (*MemberStatus) ProtoMessage()    {}
+func (*MemberStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d6214d299197430f, []int{3}
+}
+func (m *MemberStatus) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MemberStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MemberStatus.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MemberStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MemberStatus.Merge(m, src)
+}
+func (m *MemberStatus) XXX_Size() int {
+	return m.Size()
+}
+func (m *MemberStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_MemberStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MemberStatus proto.InternalMessageInfo
+
+func (m *MemberStatus) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *MemberStatus) GetPeerUrl() string {
+	if m != nil {
+		return m.PeerUrl
+	}
+	return ""
+}
+
+func (m *MemberStatus) GetClientUrl() string {
+	if m != nil {
+		return m.ClientUrl
+	}
+	return ""
+}
+
+func (m *MemberStatus) GetIsLeader() bool {
+	if m != nil {
+		return m.IsLeader
+	}
+	return false
+}
+
+func (m *MemberStatus) GetReachable() bool {
+	if m != nil {
+		return m.Reachable
+	}
+	return false
+}
+
+func (m *MemberStatus) GetRaftIndex() uint64 {
+	if m != nil {
+		return m.RaftIndex
+	}
+	return 0
+}
+
+func (m *MemberStatus) GetGossipStatus() string {
+	if m != nil {
+		return m.GossipStatus
+	}
+	return ""
+}
+
+type ClusterStatusResponse struct {
+	Members              []*MemberStatus `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *ClusterStatusResponse) Reset()         { *m = ClusterStatusResponse{} }
+func (m *ClusterStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*ClusterStatusResponse) ProtoMessage()    {}
+func (*ClusterStatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d6214d299197430f, []int{4}
+}
+func (m *ClusterStatusResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ClusterStatusResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ClusterStatusResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ClusterStatusResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClusterStatusResponse.Merge(m, src)
+}
+func (m *ClusterStatusResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *ClusterStatusResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClusterStatusResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClusterStatusResponse proto.InternalMessageInfo
+
+func (m *ClusterStatusResponse) GetMembers() []*MemberStatus {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+type ReadyResponse struct {
+	Ready                bool     `protobuf:"varint,1,opt,name=ready,proto3" json:"ready,omitempty"`
+	Reason               string   `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReadyResponse) Reset()         { *m = ReadyResponse{} }
+func (m *ReadyResponse) String() string { return proto.CompactTextString(m) }
+func (*ReadyResponse) ProtoMessage()    {}
+func (*ReadyResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d6214d299197430f, []int{5}
+}
+func (m *ReadyResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ReadyResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ReadyResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ReadyResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReadyResponse.Merge(m, src)
+}
+func (m *ReadyResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *ReadyResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReadyResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReadyResponse proto.InternalMessageInfo
+
+func (m *ReadyResponse) GetReady() bool {
+	if m != nil {
+		return m.Ready
+	}
+	return false
+}
+
+func (m *ReadyResponse) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type RoleResponse struct {
+	Role                 Role     `protobuf:"varint,1,opt,name=role,proto3,enum=e2dpb.Role" json:"role,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoleResponse) Reset()         { *m = RoleResponse{} }
+func (m *RoleResponse) String() string { return proto.CompactTextString(m) }
+func (*RoleResponse) ProtoMessage()    {}
+func (*RoleResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d6214d299197430f, []int{6}
+}
+func (m *RoleResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RoleResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RoleResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RoleResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoleResponse.Merge(m, src)
+}
+func (m *RoleResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *RoleResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoleResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoleResponse proto.InternalMessageInfo
+
+func (m *RoleResponse) GetRole() Role {
+	if m != nil {
+		return m.Role
+	}
+	return Role_UNKNOWN
+}
+
 func init() {
+	proto.RegisterEnum("e2dpb.Role", Role_name, Role_value)
 	proto.RegisterType((*HealthResponse)(nil), "e2dpb.HealthResponse")
 	proto.RegisterType((*RestartResponse)(nil), "e2dpb.RestartResponse")
+	proto.RegisterType((*SnapshotResponse)(nil), "e2dpb.SnapshotResponse")
+	proto.RegisterType((*MemberStatus)(nil), "e2dpb.MemberStatus")
+	proto.RegisterType((*ClusterStatusResponse)(nil), "e2dpb.ClusterStatusResponse")
+	proto.RegisterType((*ReadyResponse)(nil), "e2dpb.ReadyResponse")
+	proto.RegisterType((*RoleResponse)(nil), "e2dpb.RoleResponse")
 }
 
 func init() { proto.RegisterFile("e2dpb.proto", fileDescriptor_d6214d299197430f) }
 
 var fileDescriptor_d6214d299197430f = []byte{
-	// 224 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4e, 0x35, 0x4a, 0x29,
-	0x48, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x73, 0xa4, 0xa4, 0xd3, 0xf3, 0xf3,
-	0xd3, 0x73, 0x52, 0xf5, 0xc1, 0x82, 0x49, 0xa5, 0x69, 0xfa, 0xa9, 0xb9, 0x05, 0x25, 0x95, 0x10,
-	0x35, 0x52, 0xba, 0xe9, 0x99, 0x25, 0x19, 0xa5, 0x49, 0x7a, 0xc9, 0xf9, 0xb9, 0xfa, 0xe9, 0xf9,
-	0xe9, 0xf9, 0x08, 0x55, 0x20, 0x1e, 0x98, 0x03, 0x66, 0x41, 0x94, 0x2b, 0x69, 0x70, 0xf1, 0x79,
-	0xa4, 0x26, 0xe6, 0x94, 0x64, 0x04, 0xa5, 0x16, 0x17, 0xe4, 0xe7, 0x15, 0xa7, 0x0a, 0x89, 0x71,
-	0xb1, 0x15, 0x97, 0x24, 0x96, 0x94, 0x16, 0x4b, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x41, 0x79,
-	0x4a, 0xca, 0x5c, 0xfc, 0x41, 0xa9, 0xc5, 0x25, 0x89, 0x45, 0x25, 0x70, 0xa5, 0x02, 0x5c, 0xcc,
-	0xb9, 0xc5, 0xe9, 0x50, 0x75, 0x20, 0xa6, 0x51, 0x23, 0x23, 0x17, 0xbb, 0x6f, 0x62, 0x5e, 0x62,
-	0x7a, 0x6a, 0x91, 0x90, 0x25, 0x17, 0x1b, 0xc4, 0x68, 0x21, 0x31, 0x3d, 0x88, 0x8b, 0xf5, 0x60,
-	0x6e, 0xd1, 0x73, 0x05, 0xb9, 0x58, 0x4a, 0x54, 0x0f, 0xe2, 0x3b, 0x54, 0x17, 0x28, 0x31, 0x08,
-	0x59, 0x73, 0xb1, 0x43, 0xed, 0xc2, 0xa9, 0x57, 0x0c, 0xaa, 0x17, 0xcd, 0x4d, 0x4a, 0x0c, 0x4e,
-	0x3c, 0x27, 0x1e, 0xc9, 0x31, 0x5e, 0x78, 0x24, 0xc7, 0xf8, 0xe0, 0x91, 0x1c, 0x63, 0x12, 0x1b,
-	0x58, 0x9f, 0x31, 0x20, 0x00, 0x00, 0xff, 0xff, 0x42, 0x22, 0x08, 0x7d, 0x49, 0x01, 0x00, 0x00,
+	// 712 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x53, 0x5d, 0x6f, 0xe2, 0x56,
+	0x10, 0xc5, 0x7c, 0x9a, 0x81, 0x10, 0x74, 0xf3, 0x51, 0x4a, 0xd2, 0x94, 0x3a, 0xaa, 0x8a, 0x2a,
+	0x05, 0x24, 0xaa, 0xaa, 0x6a, 0xab, 0x3c, 0x24, 0x2d, 0x69, 0xab, 0x12, 0x22, 0xdd, 0x28, 0xca,
+	0xa3, 0x65, 0x60, 0x62, 0x2c, 0x19, 0x5f, 0xe7, 0x5e, 0xbb, 0x2a, 0xf9, 0x53, 0xfb, 0x37, 0xf6,
+	0x71, 0xdf, 0xf7, 0x65, 0x95, 0x7f, 0xb1, 0x6f, 0xab, 0xfb, 0x61, 0x87, 0xac, 0x96, 0x37, 0x9f,
+	0x33, 0x67, 0xe6, 0x7a, 0xce, 0xcc, 0x40, 0x03, 0x47, 0x8b, 0x78, 0x36, 0x88, 0x39, 0x4b, 0x18,
+	0xa9, 0x28, 0xd0, 0x3d, 0xf2, 0x19, 0xf3, 0x43, 0x1c, 0x2a, 0x72, 0x96, 0x3e, 0x0c, 0x71, 0x15,
+	0x27, 0x6b, 0xad, 0xe9, 0x9e, 0xf9, 0x41, 0xb2, 0x4c, 0x67, 0x83, 0x39, 0x5b, 0x0d, 0x7d, 0xe6,
+	0xb3, 0x17, 0x95, 0x44, 0x0a, 0xa8, 0x2f, 0x2d, 0x77, 0xde, 0x14, 0xa1, 0xf5, 0x37, 0x7a, 0x61,
+	0xb2, 0xa4, 0x28, 0x62, 0x16, 0x09, 0x24, 0x87, 0x50, 0x15, 0x89, 0x97, 0xa4, 0xa2, 0x63, 0xf5,
+	0xac, 0x7e, 0x9d, 0x1a, 0x44, 0xbe, 0x87, 0x96, 0xcf, 0x84, 0x08, 0x62, 0x77, 0x85, 0xab, 0x19,
+	0x72, 0xd1, 0x29, 0xf6, 0xac, 0x7e, 0x85, 0xee, 0x68, 0xf6, 0x5a, 0x93, 0xe4, 0x07, 0xd8, 0xe5,
+	0x69, 0x14, 0x05, 0x91, 0x9f, 0xeb, 0x4a, 0x4a, 0xd7, 0x32, 0xf4, 0x86, 0x30, 0xc6, 0x68, 0xb1,
+	0x29, 0x2c, 0x6b, 0xa1, 0xa1, 0x33, 0xe1, 0x08, 0x0e, 0x38, 0x3e, 0xa6, 0x01, 0xc7, 0x85, 0x3b,
+	0x0f, 0x53, 0x91, 0x20, 0x77, 0x45, 0xf0, 0x84, 0x9d, 0x8a, 0x92, 0xef, 0x65, 0xc1, 0x3f, 0x74,
+	0xec, 0x36, 0x78, 0x52, 0x4d, 0x3c, 0xa6, 0x8c, 0xa7, 0xab, 0x4e, 0xb5, 0x67, 0xf5, 0x6d, 0x6a,
+	0x10, 0xf9, 0x0e, 0x9a, 0x98, 0xcc, 0x17, 0xee, 0x52, 0xf5, 0xbc, 0xee, 0xd4, 0x54, 0xb4, 0x21,
+	0x39, 0x6d, 0xc3, 0x9a, 0x7c, 0x03, 0xa0, 0x24, 0xc8, 0x39, 0xe3, 0x1d, 0x5b, 0x79, 0x50, 0x97,
+	0xcc, 0x58, 0x12, 0xce, 0x29, 0xec, 0x52, 0x14, 0x89, 0xc7, 0x93, 0xdc, 0xb1, 0x36, 0x94, 0x56,
+	0xc2, 0x37, 0x76, 0xc9, 0x4f, 0xe7, 0x1a, 0xda, 0xb7, 0x91, 0x17, 0x8b, 0x25, 0x7b, 0x51, 0x75,
+	0xc1, 0xe6, 0xf8, 0x5f, 0x20, 0x02, 0x16, 0x29, 0x69, 0x89, 0xe6, 0x58, 0xbe, 0x29, 0x3b, 0x72,
+	0x67, 0xeb, 0x04, 0xb5, 0xaf, 0x25, 0x5a, 0x97, 0xcc, 0xa5, 0x24, 0x9c, 0xf7, 0x16, 0x34, 0xb5,
+	0x1b, 0xb7, 0x7a, 0x16, 0x04, 0xca, 0x91, 0xb7, 0x42, 0xf3, 0xa4, 0xfa, 0x26, 0x5f, 0x83, 0x1d,
+	0x23, 0x72, 0x37, 0xe5, 0xa1, 0xaa, 0x50, 0xa7, 0x35, 0x89, 0xef, 0x78, 0x28, 0xcb, 0xcf, 0xc3,
+	0x00, 0xa3, 0x44, 0x05, 0x4b, 0xba, 0x25, 0xcd, 0xc8, 0xf0, 0x11, 0xd4, 0x03, 0xe1, 0x86, 0xe8,
+	0x2d, 0x90, 0xab, 0x19, 0xd8, 0xd4, 0x0e, 0xc4, 0x44, 0x61, 0x72, 0x0c, 0x75, 0x8e, 0xde, 0x7c,
+	0xe9, 0xcd, 0x42, 0xed, 0xb8, 0x4d, 0x5f, 0x08, 0x59, 0x99, 0x7b, 0x0f, 0x89, 0x1b, 0x44, 0x0b,
+	0xfc, 0x5f, 0x79, 0x5d, 0xa6, 0x75, 0xc9, 0xfc, 0x23, 0x09, 0x72, 0x0a, 0x66, 0x3b, 0x5c, 0xb3,
+	0x52, 0x35, 0xf5, 0x76, 0x53, 0x93, 0xba, 0x19, 0xe7, 0x0a, 0x0e, 0xb2, 0xd1, 0x29, 0x22, 0x77,
+	0xec, 0x0c, 0x6a, 0xd9, 0x66, 0x58, 0xbd, 0x52, 0xbf, 0x31, 0xda, 0x1b, 0xe8, 0x73, 0xd8, 0xf4,
+	0x82, 0x66, 0x1a, 0xe7, 0x1c, 0x76, 0x28, 0x7a, 0x8b, 0x75, 0x9e, 0xbf, 0x0f, 0x15, 0x2e, 0x09,
+	0x65, 0x93, 0x4d, 0x35, 0x90, 0xab, 0xc1, 0xd1, 0x13, 0x2c, 0x32, 0x2e, 0x19, 0xe4, 0x0c, 0xa1,
+	0x49, 0x59, 0x88, 0x79, 0xf6, 0xb7, 0x50, 0xe6, 0x2c, 0xd4, 0x1e, 0xb7, 0x46, 0x0d, 0xf3, 0xb4,
+	0x92, 0xa8, 0xc0, 0x8f, 0xbf, 0x41, 0x59, 0x22, 0xd2, 0x80, 0xda, 0xdd, 0xf4, 0xdf, 0xe9, 0xcd,
+	0xfd, 0xb4, 0x5d, 0x20, 0x00, 0xd5, 0xc9, 0xf8, 0xe2, 0xcf, 0x31, 0x6d, 0x5b, 0xa4, 0x09, 0xf6,
+	0xd5, 0xcd, 0x64, 0x72, 0x73, 0x3f, 0xa6, 0xed, 0xa2, 0x94, 0x4d, 0xc6, 0x17, 0x74, 0x3a, 0xa6,
+	0xed, 0xd2, 0xe8, 0x63, 0x11, 0x6a, 0xd7, 0x5e, 0xe4, 0xf9, 0xc8, 0xc9, 0xaf, 0x50, 0xd5, 0xbb,
+	0x47, 0x0e, 0x07, 0xfa, 0xb4, 0x07, 0xd9, 0xd1, 0x0e, 0xc6, 0xf2, 0xb4, 0xbb, 0x07, 0xe6, 0xf1,
+	0xd7, 0x97, 0xea, 0x14, 0xc8, 0xef, 0x50, 0x33, 0xcb, 0xb8, 0x35, 0xf7, 0x30, 0xfb, 0xf1, 0xd7,
+	0x4b, 0xeb, 0x14, 0xc8, 0x39, 0xd8, 0xd9, 0x92, 0x6e, 0xcd, 0xfe, 0xca, 0x64, 0x7f, 0xbe, 0xcd,
+	0x4e, 0x81, 0xfc, 0x05, 0x3b, 0xaf, 0xc6, 0xb6, 0xb5, 0xc6, 0xb1, 0xa9, 0xf1, 0xc5, 0x21, 0x3b,
+	0x05, 0xf2, 0x0b, 0x54, 0xa8, 0x9e, 0xcc, 0x96, 0x02, 0xfb, 0x79, 0x0b, 0x1b, 0xd3, 0x75, 0x0a,
+	0xe4, 0x67, 0x33, 0x80, 0x6d, 0x79, 0x7b, 0x9b, 0x33, 0xcb, 0xd3, 0x2e, 0x9b, 0x6f, 0x9f, 0x4f,
+	0xac, 0x77, 0xcf, 0x27, 0xd6, 0x87, 0xe7, 0x13, 0x6b, 0x56, 0x55, 0x49, 0x3f, 0x7d, 0x1a, 0x00,
+	0x29, 0xff, 0x63, 0x48, 0x6a, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -158,6 +581,10 @@ const _ = grpc.SupportPackageIsVersion4
 type ManagerClient interface {
 	Health(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*HealthResponse, error)
 	Restart(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*RestartResponse, error)
+	Snapshot(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*SnapshotResponse, error)
+	ClusterStatus(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*ClusterStatusResponse, error)
+	Ready(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*ReadyResponse, error)
+	Role(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*RoleResponse, error)
 }
 
 type managerClient struct {
@@ -186,10 +613,50 @@ func (c *managerClient) Restart(ctx context.Context, in *types.Empty, opts ...gr
 	return out, nil
 }
 
+func (c *managerClient) Snapshot(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*SnapshotResponse, error) {
+	out := new(SnapshotResponse)
+	err := c.cc.Invoke(ctx, "/e2dpb.Manager/Snapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) ClusterStatus(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*ClusterStatusResponse, error) {
+	out := new(ClusterStatusResponse)
+	err := c.cc.Invoke(ctx, "/e2dpb.Manager/ClusterStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) Ready(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*ReadyResponse, error) {
+	out := new(ReadyResponse)
+	err := c.cc.Invoke(ctx, "/e2dpb.Manager/Ready", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) Role(ctx context.Context, in *types.Empty, opts ...grpc.CallOption) (*RoleResponse, error) {
+	out := new(RoleResponse)
+	err := c.cc.Invoke(ctx, "/e2dpb.Manager/Role", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ManagerServer is the server API for Manager service.
 type ManagerServer interface {
 	Health(context.Context, *types.Empty) (*HealthResponse, error)
 	Restart(context.Context, *types.Empty) (*RestartResponse, error)
+	Snapshot(context.Context, *types.Empty) (*SnapshotResponse, error)
+	ClusterStatus(context.Context, *types.Empty) (*ClusterStatusResponse, error)
+	Ready(context.Context, *types.Empty) (*ReadyResponse, error)
+	Role(context.Context, *types.Empty) (*RoleResponse, error)
 }
 
 func RegisterManagerServer(s *grpc.Server, srv ManagerServer) {
@@ -232,22 +699,110 @@ func _Manager_Restart_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
-var _Manager_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "e2dpb.Manager",
-	HandlerType: (*ManagerServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Health",
-			Handler:    _Manager_Health_Handler,
-		},
-		{
-			MethodName: "Restart",
-			Handler:    _Manager_Restart_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "e2dpb.proto",
-}
+func _Manager_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/e2dpb.Manager/Snapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).Snapshot(ctx, req.(*types.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_ClusterStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).ClusterStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/e2dpb.Manager/ClusterStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).ClusterStatus(ctx, req.(*types.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_Ready_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).Ready(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/e2dpb.Manager/Ready",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).Ready(ctx, req.(*types.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_Role_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).Role(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/e2dpb.Manager/Role",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).Role(ctx, req.(*types.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Manager_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "e2dpb.Manager",
+	HandlerType: (*ManagerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Health",
+			Handler:    _Manager_Health_Handler,
+		},
+		{
+			MethodName: "Restart",
+			Handler:    _Manager_Restart_Handler,
+		},
+		{
+			MethodName: "Snapshot",
+			Handler:    _Manager_Snapshot_Handler,
+		},
+		{
+			MethodName: "ClusterStatus",
+			Handler:    _Manager_ClusterStatus_Handler,
+		},
+		{
+			MethodName: "Ready",
+			Handler:    _Manager_Ready_Handler,
+		},
+		{
+			MethodName: "Role",
+			Handler:    _Manager_Role_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "e2dpb.proto",
+}
 
 func (m *HealthResponse) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
@@ -270,6 +825,52 @@ func (m *HealthResponse) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.Status)))
 		i += copy(dAtA[i:], m.Status)
 	}
+	if m.GossipMembers != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.GossipMembers))
+	}
+	if m.RunningMembers != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.RunningMembers))
+	}
+	if m.PendingMembers != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.PendingMembers))
+	}
+	if m.RequiredClusterSize != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.RequiredClusterSize))
+	}
+	if m.Quorum {
+		dAtA[i] = 0x30
+		i++
+		if m.Quorum {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.EtcdHealthy {
+		dAtA[i] = 0x38
+		i++
+		if m.EtcdHealthy {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.EtcdError) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.EtcdError)))
+		i += copy(dAtA[i:], m.EtcdError)
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -303,6 +904,203 @@ func (m *RestartResponse) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *SnapshotResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SnapshotResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Revision != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.Revision))
+	}
+	if m.SizeBytes != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.SizeBytes))
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
+func (m *MemberStatus) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MemberStatus) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.PeerUrl) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.PeerUrl)))
+		i += copy(dAtA[i:], m.PeerUrl)
+	}
+	if len(m.ClientUrl) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.ClientUrl)))
+		i += copy(dAtA[i:], m.ClientUrl)
+	}
+	if m.IsLeader {
+		dAtA[i] = 0x20
+		i++
+		if m.IsLeader {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Reachable {
+		dAtA[i] = 0x28
+		i++
+		if m.Reachable {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.RaftIndex != 0 {
+		dAtA[i] = 0x30
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.RaftIndex))
+	}
+	if len(m.GossipStatus) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.GossipStatus)))
+		i += copy(dAtA[i:], m.GossipStatus)
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
+func (m *ClusterStatusResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ClusterStatusResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Members) > 0 {
+		for _, msg := range m.Members {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintE2Dpb(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
+func (m *ReadyResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ReadyResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Ready {
+		dAtA[i] = 0x8
+		i++
+		if m.Ready {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Reason) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(len(m.Reason)))
+		i += copy(dAtA[i:], m.Reason)
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
+func (m *RoleResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RoleResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Role != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintE2Dpb(dAtA, i, uint64(m.Role))
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
 func encodeVarintE2Dpb(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -322,6 +1120,28 @@ func (m *HealthResponse) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovE2Dpb(uint64(l))
 	}
+	if m.GossipMembers != 0 {
+		n += 1 + sovE2Dpb(uint64(m.GossipMembers))
+	}
+	if m.RunningMembers != 0 {
+		n += 1 + sovE2Dpb(uint64(m.RunningMembers))
+	}
+	if m.PendingMembers != 0 {
+		n += 1 + sovE2Dpb(uint64(m.PendingMembers))
+	}
+	if m.RequiredClusterSize != 0 {
+		n += 1 + sovE2Dpb(uint64(m.RequiredClusterSize))
+	}
+	if m.Quorum {
+		n += 2
+	}
+	if m.EtcdHealthy {
+		n += 2
+	}
+	l = len(m.EtcdError)
+	if l > 0 {
+		n += 1 + l + sovE2Dpb(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -341,23 +1161,783 @@ func (m *RestartResponse) Size() (n int) {
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
-	return n
-}
+	return n
+}
+
+func (m *SnapshotResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Revision != 0 {
+		n += 1 + sovE2Dpb(uint64(m.Revision))
+	}
+	if m.SizeBytes != 0 {
+		n += 1 + sovE2Dpb(uint64(m.SizeBytes))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *MemberStatus) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovE2Dpb(uint64(l))
+	}
+	l = len(m.PeerUrl)
+	if l > 0 {
+		n += 1 + l + sovE2Dpb(uint64(l))
+	}
+	l = len(m.ClientUrl)
+	if l > 0 {
+		n += 1 + l + sovE2Dpb(uint64(l))
+	}
+	if m.IsLeader {
+		n += 2
+	}
+	if m.Reachable {
+		n += 2
+	}
+	if m.RaftIndex != 0 {
+		n += 1 + sovE2Dpb(uint64(m.RaftIndex))
+	}
+	l = len(m.GossipStatus)
+	if l > 0 {
+		n += 1 + l + sovE2Dpb(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ClusterStatusResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Members) > 0 {
+		for _, e := range m.Members {
+			l = e.Size()
+			n += 1 + l + sovE2Dpb(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ReadyResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Ready {
+		n += 2
+	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovE2Dpb(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *RoleResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Role != 0 {
+		n += 1 + sovE2Dpb(uint64(m.Role))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func sovE2Dpb(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozE2Dpb(x uint64) (n int) {
+	return sovE2Dpb(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *HealthResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowE2Dpb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HealthResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HealthResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Status = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field GossipMembers", wireType)
+			}
+			m.GossipMembers = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.GossipMembers |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RunningMembers", wireType)
+			}
+			m.RunningMembers = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RunningMembers |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PendingMembers", wireType)
+			}
+			m.PendingMembers = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PendingMembers |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequiredClusterSize", wireType)
+			}
+			m.RequiredClusterSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RequiredClusterSize |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Quorum", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Quorum = bool(v != 0)
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EtcdHealthy", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.EtcdHealthy = bool(v != 0)
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EtcdError", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EtcdError = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipE2Dpb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RestartResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowE2Dpb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RestartResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RestartResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Msg", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Msg = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipE2Dpb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SnapshotResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowE2Dpb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SnapshotResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SnapshotResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Revision", wireType)
+			}
+			m.Revision = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Revision |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SizeBytes", wireType)
+			}
+			m.SizeBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SizeBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipE2Dpb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MemberStatus) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowE2Dpb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MemberStatus: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MemberStatus: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerUrl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PeerUrl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientUrl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientUrl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IsLeader", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.IsLeader = bool(v != 0)
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reachable", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Reachable = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RaftIndex", wireType)
+			}
+			m.RaftIndex = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RaftIndex |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field GossipStatus", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.GossipStatus = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipE2Dpb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
 
-func sovE2Dpb(x uint64) (n int) {
-	for {
-		n++
-		x >>= 7
-		if x == 0 {
-			break
-		}
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	return n
-}
-func sozE2Dpb(x uint64) (n int) {
-	return sovE2Dpb(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *HealthResponse) Unmarshal(dAtA []byte) error {
+func (m *ClusterStatusResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -380,17 +1960,17 @@ func (m *HealthResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: HealthResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: ClusterStatusResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: HealthResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ClusterStatusResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Members", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowE2Dpb
@@ -400,23 +1980,25 @@ func (m *HealthResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthE2Dpb
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthE2Dpb
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Status = string(dAtA[iNdEx:postIndex])
+			m.Members = append(m.Members, &MemberStatus{})
+			if err := m.Members[len(m.Members)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -443,7 +2025,7 @@ func (m *HealthResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *RestartResponse) Unmarshal(dAtA []byte) error {
+func (m *ReadyResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -466,15 +2048,35 @@ func (m *RestartResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RestartResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: ReadyResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RestartResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ReadyResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ready", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Ready = bool(v != 0)
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Msg", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -502,7 +2104,7 @@ func (m *RestartResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Msg = string(dAtA[iNdEx:postIndex])
+			m.Reason = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -529,6 +2131,79 @@ func (m *RestartResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *RoleResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowE2Dpb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RoleResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RoleResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Role", wireType)
+			}
+			m.Role = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowE2Dpb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Role |= Role(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipE2Dpb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthE2Dpb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipE2Dpb(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
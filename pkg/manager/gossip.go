@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/gob"
-	"fmt"
+	"io"
 	stdlog "log"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/criticalstack/e2d/pkg/discovery"
 	"github.com/criticalstack/e2d/pkg/log"
 	"github.com/criticalstack/e2d/pkg/netutil"
+	"github.com/criticalstack/e2d/pkg/pki"
 	"github.com/hashicorp/memberlist"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -20,6 +23,10 @@ import (
 
 const (
 	DefaultGossipPort = 7980
+
+	// maximum amount of time to wait for updated member metadata to be
+	// broadcast
+	gossipUpdateTimeout = 5 * time.Second
 )
 
 type NodeStatus int
@@ -52,18 +59,96 @@ type Member struct {
 	GossipAddr     string
 	BootstrapAddrs []string
 	Status         NodeStatus
+
+	// whether the member is a non-voting raft learner, which is only known
+	// for members queried from etcd
+	IsLearner bool
+
+	// hash of the CA certificate, only advertised when the CA is being
+	// verified
+	CACertHash []byte
+
+	// identity of the cluster this member belongs to, members of other
+	// clusters are ignored
+	ClusterID string
+
+	// arbitrary tags set by the embedding application, such as the zone or
+	// role of the member
+	Tags map[string]string
 }
 
+// memberMeta is the gossip metadata of a Member. Only the fields advertised by
+// a member are encoded, since gob includes the name of every field of the
+// type, and the metadata must fit within memberlist.MetaMaxSize. The fields
+// are named the same as those of Member, so that members of earlier versions
+// can still decode the metadata.
+type memberMeta struct {
+	Name       string
+	ClientURL  string
+	PeerURL    string
+	GossipAddr string
+	Status     NodeStatus
+	CACertHash []byte
+	ClusterID  string
+	Tags       map[string]string
+}
+
+// Marshal encodes the gossip metadata of the member. The cluster identity is
+// left out when it is derived from the advertised CA cert hash (the default),
+// since it would only repeat the hash.
 func (m *Member) Marshal() ([]byte, error) {
+	meta := memberMeta{
+		Name:       m.Name,
+		ClientURL:  m.ClientURL,
+		PeerURL:    m.PeerURL,
+		GossipAddr: m.GossipAddr,
+		Status:     m.Status,
+		CACertHash: m.CACertHash,
+		ClusterID:  m.ClusterID,
+		Tags:       m.Tags,
+	}
+	if len(m.CACertHash) > 0 && m.ClusterID == pki.FormatCertHash(m.CACertHash) {
+		meta.ClusterID = ""
+	}
 	var b bytes.Buffer
-	if err := gob.NewEncoder(&b).Encode(*m); err != nil {
+	if err := gob.NewEncoder(&b).Encode(meta); err != nil {
 		return nil, err
 	}
 	return b.Bytes(), nil
 }
 
 func (m *Member) Unmarshal(data []byte) error {
-	return gob.NewDecoder(bytes.NewReader(data)).Decode(m)
+	var meta memberMeta
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&meta); err != nil {
+		return err
+	}
+	if meta.ClusterID == "" && len(meta.CACertHash) > 0 {
+		meta.ClusterID = pki.FormatCertHash(meta.CACertHash)
+	}
+	*m = Member{
+		Name:       meta.Name,
+		ClientURL:  meta.ClientURL,
+		PeerURL:    meta.PeerURL,
+		GossipAddr: meta.GossipAddr,
+		Status:     meta.Status,
+		CACertHash: meta.CACertHash,
+		ClusterID:  meta.ClusterID,
+		Tags:       meta.Tags,
+	}
+	return nil
+}
+
+// marshalMeta returns the gossip metadata of the member, which must fit within
+// memberlist.MetaMaxSize, since memberlist panics when it is larger.
+func marshalMeta(m *Member) ([]byte, error) {
+	data, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > memberlist.MetaMaxSize {
+		return nil, errors.Errorf("member metadata exceeds %d bytes: %d", memberlist.MetaMaxSize, len(data))
+	}
+	return data, nil
 }
 
 type memberlister interface {
@@ -71,6 +156,8 @@ type memberlister interface {
 	LocalNode() *memberlist.Node
 	Members() []*memberlist.Node
 	NumMembers() int
+	UpdateNode(time.Duration) error
+	Leave(time.Duration) error
 	Shutdown() error
 }
 
@@ -92,6 +179,14 @@ func (noopMemberlist) NumMembers() int {
 	return 0
 }
 
+func (noopMemberlist) UpdateNode(time.Duration) error {
+	return nil
+}
+
+func (noopMemberlist) Leave(time.Duration) error {
+	return nil
+}
+
 func (noopMemberlist) Shutdown() error {
 	return nil
 }
@@ -120,14 +215,55 @@ func (l *logger) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// ZoneTag is the gossip tag holding the zone of a member, which is set from
+// Config.Zone.
+const ZoneTag = "zone"
+
+// Profiles of the memberlist configuration, which select the default
+// timeouts used to detect failed members.
+const (
+	GossipProfileLAN   = "lan"
+	GossipProfileWAN   = "wan"
+	GossipProfileLocal = "local"
+)
+
 type gossipConfig struct {
-	Name       string
-	ClientURL  string
-	PeerURL    string
-	GossipHost string
-	GossipPort int
-	SecretKey  []byte
-	Debug      bool
+	Name          string
+	ClientURL     string
+	PeerURL       string
+	GossipHost    string
+	GossipPort    int
+	SecretKey     []byte
+	CACertHash    []byte
+	ClusterID     string
+	Profile       string
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+	LogOutput     io.Writer
+	Debug         bool
+
+	// initial tags of this member
+	Tags map[string]string
+
+	// members that do not advertise a cluster identity are accepted
+	AllowMissingClusterID bool
+
+	// PeerGetter is used to find more peers when the bootstrap addresses
+	// cannot be joined
+	PeerGetter discovery.PeerGetter
+}
+
+// newMemberlistConfig returns the default memberlist configuration for the
+// provided profile, defaulting to the LAN profile.
+func newMemberlistConfig(profile string) *memberlist.Config {
+	switch profile {
+	case GossipProfileWAN:
+		return memberlist.DefaultWANConfig()
+	case GossipProfileLocal:
+		return memberlist.DefaultLocalConfig()
+	default:
+		return memberlist.DefaultLANConfig()
+	}
 }
 
 type gossip struct {
@@ -140,14 +276,27 @@ type gossip struct {
 	mu         sync.RWMutex
 	nodes      map[string]NodeStatus
 	self       *Member
+	peerGetter discovery.PeerGetter
+
+	allowMissingClusterID bool
 }
 
 func newGossip(cfg *gossipConfig) *gossip {
-	c := memberlist.DefaultLANConfig()
+	c := newMemberlistConfig(cfg.Profile)
+	if cfg.ProbeInterval > 0 {
+		c.ProbeInterval = cfg.ProbeInterval
+	}
+	if cfg.ProbeTimeout > 0 {
+		c.ProbeTimeout = cfg.ProbeTimeout
+	}
 	c.Name = cfg.Name
 	c.BindAddr = cfg.GossipHost
 	c.BindPort = cfg.GossipPort
-	c.Logger = stdlog.New(&logger{log.NewLoggerWithLevel("memberlist", zapcore.InfoLevel)}, "", 0)
+	var w io.Writer = os.Stderr
+	if cfg.LogOutput != nil {
+		w = cfg.LogOutput
+	}
+	c.Logger = stdlog.New(&logger{log.NewLoggerWithOutput("memberlist", zapcore.InfoLevel, w)}, "", 0)
 	c.SecretKey = cfg.SecretKey
 
 	g := &gossip{
@@ -159,8 +308,14 @@ func newGossip(cfg *gossipConfig) *gossip {
 			Name:       cfg.Name,
 			ClientURL:  cfg.ClientURL,
 			PeerURL:    cfg.PeerURL,
-			GossipAddr: fmt.Sprintf("%s:%d", cfg.GossipHost, cfg.GossipPort),
+			GossipAddr: netutil.JoinHostPort(cfg.GossipHost, cfg.GossipPort),
+			CACertHash: cfg.CACertHash,
+			ClusterID:  cfg.ClusterID,
+			Tags:       cfg.Tags,
 		},
+		peerGetter: cfg.PeerGetter,
+
+		allowMissingClusterID: cfg.AllowMissingClusterID,
 	}
 	g.broadcasts = &memberlist.TransmitLimitedQueue{
 		NumNodes: func() int {
@@ -187,6 +342,18 @@ func (g *gossip) Shutdown() error {
 	return nil
 }
 
+// Leave broadcasts that this member is leaving the gossip network, waiting up
+// to the provided timeout for the broadcast to be sent, then shuts down the
+// gossip network. Peers observe a graceful leave, rather than having to detect
+// that this member has failed.
+func (g *gossip) Leave(timeout time.Duration) error {
+	err := g.m.Leave(timeout)
+	if serr := g.Shutdown(); serr != nil {
+		return serr
+	}
+	return errors.Wrap(err, "cannot leave gossip network")
+}
+
 // Start attempts to join a gossip network using the given bootstrap addresses.
 func (g *gossip) Start(ctx context.Context, baddrs []string) error {
 	m, err := memberlist.Create(g.config)
@@ -197,19 +364,9 @@ func (g *gossip) Start(ctx context.Context, baddrs []string) error {
 	if err := g.Update(Unknown); err != nil {
 		return err
 	}
-	peers := make([]string, 0)
-	for _, addr := range baddrs {
-		host, port, err := netutil.SplitHostPort(addr)
-		if err != nil {
-			return errors.Wrapf(err, "cannot split bootstrap address: %#v", addr)
-		}
-		if host == "" {
-			host = "127.0.0.1"
-		}
-		if port == 0 {
-			port = DefaultGossipPort
-		}
-		peers = append(peers, fmt.Sprintf("%s:%d", host, port))
+	peers, err := addGossipPeers(nil, baddrs)
+	if err != nil {
+		return err
 	}
 
 	log.Debug("attempting to join gossip network ...",
@@ -224,6 +381,7 @@ func (g *gossip) Start(ctx context.Context, baddrs []string) error {
 			_, err := g.m.Join(peers)
 			if err != nil {
 				log.Errorf("cannot join gossip network: %v", err)
+				peers = g.discoverPeers(ctx, peers)
 				continue
 			}
 			log.Debug("joined gossip network successfully")
@@ -234,6 +392,57 @@ func (g *gossip) Start(ctx context.Context, baddrs []string) error {
 	}
 }
 
+// discoverPeers adds any new peers found with the PeerGetter to the peers
+// being joined, so that peers launched after the bootstrap addresses were
+// determined can be joined.
+func (g *gossip) discoverPeers(ctx context.Context, peers []string) []string {
+	if g.peerGetter == nil {
+		return peers
+	}
+	addrs, err := g.peerGetter.GetAddrs(ctx)
+	if err != nil {
+		log.Debug("cannot discover gossip peers", zap.Error(err))
+		return peers
+	}
+	newPeers, err := addGossipPeers(peers, addrs)
+	if err != nil {
+		log.Debug("cannot discover gossip peers", zap.Error(err))
+		return peers
+	}
+	if len(newPeers) > len(peers) {
+		log.Debug("discovered gossip peers", zap.String("peers", strings.Join(newPeers[len(peers):], ",")))
+	}
+	return newPeers
+}
+
+// addGossipPeers adds the addresses that are not already in peers, defaulting
+// the host to 127.0.0.1 and the port to DefaultGossipPort.
+func addGossipPeers(peers []string, addrs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, peer := range peers {
+		seen[peer] = true
+	}
+	for _, addr := range addrs {
+		host, port, err := netutil.SplitHostPort(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot split bootstrap address: %#v", addr)
+		}
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		if port == 0 {
+			port = DefaultGossipPort
+		}
+		peer := netutil.JoinHostPort(host, port)
+		if seen[peer] {
+			continue
+		}
+		seen[peer] = true
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
 // msg implements the memberlist.Broadcast interface and is required to send
 // messages over the gossip network
 type msg struct {
@@ -253,13 +462,16 @@ type statusMsg struct {
 // broadcast the updated NodeStatus to all currently known members.
 func (g *gossip) Update(status NodeStatus) error {
 	g.mu.Lock()
-	g.nodes[g.self.Name] = status
-	g.self.Status = status
-	g.mu.Unlock()
-	data, err := g.self.Marshal()
+	self := *g.self
+	self.Status = status
+	data, err := marshalMeta(&self)
 	if err != nil {
+		g.mu.Unlock()
 		return err
 	}
+	g.nodes[g.self.Name] = status
+	g.self.Status = status
+	g.mu.Unlock()
 	g.m.LocalNode().Meta = data
 	var b bytes.Buffer
 	if err := gob.NewEncoder(&b).Encode(statusMsg{Name: g.self.Name, Status: status}); err != nil {
@@ -269,6 +481,27 @@ func (g *gossip) Update(status NodeStatus) error {
 	return nil
 }
 
+// UpdateTags replaces the tags of this member, and shares them with all
+// currently known members as part of the member metadata. The metadata,
+// including the tags, must fit within memberlist.MetaMaxSize.
+func (g *gossip) UpdateTags(tags map[string]string) error {
+	g.mu.Lock()
+	self := *g.self
+	self.Tags = make(map[string]string, len(tags))
+	for k, v := range tags {
+		self.Tags[k] = v
+	}
+	data, err := marshalMeta(&self)
+	if err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	g.self.Tags = self.Tags
+	g.mu.Unlock()
+	g.m.LocalNode().Meta = data
+	return g.m.UpdateNode(gossipUpdateTimeout)
+}
+
 // Events returns a read-only channel of memberlist events.
 func (g *gossip) Events() <-chan memberlist.NodeEvent { return g.events }
 
@@ -291,6 +524,23 @@ func (g *gossip) Members() []*Member {
 			continue
 		}
 
+		// members with a different CA belong to another cluster, or are
+		// misconfigured, and must not be joined
+		if len(g.self.CACertHash) > 0 && !bytes.Equal(meta.CACertHash, g.self.CACertHash) {
+			log.Debugf("ignoring member %#v with unexpected ca cert hash: %x", meta.Name, meta.CACertHash)
+			continue
+		}
+
+		// members of another cluster are ignored, so that clusters sharing
+		// bootstrap addresses by mistake are not merged. A member without a
+		// cluster identity cannot be told apart from a member of another
+		// cluster, so is only accepted when explicitly allowed.
+		missing := meta.ClusterID == "" && g.allowMissingClusterID
+		if g.self.ClusterID != "" && meta.ClusterID != g.self.ClusterID && !missing {
+			log.Debugf("ignoring member %#v of another cluster: %#v", meta.Name, meta.ClusterID)
+			continue
+		}
+
 		// status information shared via delegate is presumed to be more
 		// accurate
 		if status, ok := g.nodes[meta.Name]; ok {
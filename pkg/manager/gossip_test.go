@@ -2,24 +2,54 @@
 package manager
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/memberlist"
+
+	"github.com/criticalstack/e2d/pkg/pki"
 )
 
+// fakeMemberlist is a memberlister that reports a static set of members.
+type fakeMemberlist struct {
+	noopMemberlist
+	nodes []*memberlist.Node
+}
+
+func newFakeMemberlist(members ...*Member) *fakeMemberlist {
+	f := &fakeMemberlist{}
+	for _, m := range members {
+		data, err := m.Marshal()
+		if err != nil {
+			panic(err)
+		}
+		f.nodes = append(f.nodes, &memberlist.Node{Name: m.Name, Meta: data})
+	}
+	return f
+}
+
+func (f *fakeMemberlist) Members() []*memberlist.Node {
+	return f.nodes
+}
+
+func (f *fakeMemberlist) NumMembers() int {
+	return len(f.nodes)
+}
+
 func TestMemberEncodeDecode(t *testing.T) {
 	expected := &Member{
-		ID:             1,
-		Name:           "node1",
-		ClientAddr:     ":2379",
-		ClientURL:      "http://127.0.0.1:2379",
-		PeerAddr:       ":2379",
-		PeerURL:        "http://127.0.0.1:2379",
-		GossipAddr:     ":7980",
-		BootstrapAddrs: []string{":7981", ":7982"},
-		Status:         Pending,
+		Name:       "node1",
+		ClientURL:  "http://127.0.0.1:2379",
+		PeerURL:    "http://127.0.0.1:2379",
+		GossipAddr: ":7980",
+		Status:     Pending,
+		ClusterID:  "cluster1",
+		Tags:       map[string]string{"zone": "us-east-1a"},
 	}
 	data, err := expected.Marshal()
 	if err != nil {
@@ -35,6 +65,101 @@ func TestMemberEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestMemberMetaSize(t *testing.T) {
+	h := sha256.Sum256([]byte("ca"))
+	m := &Member{
+		Name:       "ip-10-100-200-123.us-east-2.compute.internal",
+		ClientURL:  "https://10.100.200.123:2379",
+		PeerURL:    "https://10.100.200.123:2380",
+		GossipAddr: "10.100.200.123:7980",
+		Status:     Running,
+		CACertHash: h[:],
+		ClusterID:  pki.FormatCertHash(h[:]),
+		Tags:       map[string]string{ZoneTag: "us-east-2a"},
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leave room for the tags of the embedding application
+	if max := memberlist.MetaMaxSize * 3 / 4; len(data) > max {
+		t.Fatalf("expected member metadata to be at most %d bytes, received %d", max, len(data))
+	}
+
+	// the cluster identity derived from the ca cert hash is not encoded, but
+	// is restored when decoded
+	decoded := &Member{}
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m, decoded); diff != "" {
+		t.Errorf("Member: after Unmarshal differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestGossipUpdateMetaSize(t *testing.T) {
+	g := newGossip(&gossipConfig{
+		Name: "node1",
+		Tags: map[string]string{"large": strings.Repeat("x", memberlist.MetaMaxSize)},
+	})
+	if err := g.Update(Running); err == nil {
+		t.Fatal("expected error for metadata exceeding memberlist.MetaMaxSize")
+	}
+	if g.self.Status != Unknown {
+		t.Fatalf("expected status to not be updated, received %s", g.self.Status)
+	}
+}
+
+func TestGossipClusterID(t *testing.T) {
+	g := newGossip(&gossipConfig{
+		Name:      "node1",
+		ClusterID: "cluster1",
+	})
+	g.m = newFakeMemberlist(
+		&Member{Name: "node1", ClusterID: "cluster1"},
+		&Member{Name: "node2", ClusterID: "cluster1"},
+		&Member{Name: "node3", ClusterID: "cluster2"},
+
+		// members of an older version do not advertise a cluster identity
+		&Member{Name: "node4"},
+	)
+	names := func(g *gossip) []string {
+		names := make([]string, 0)
+		for _, m := range g.Members() {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+	if diff := cmp.Diff([]string{"node1", "node2"}, names(g)); diff != "" {
+		t.Errorf("gossip: after Members differs: (-want +got)\n%s", diff)
+	}
+
+	// members without a cluster identity are accepted during a rolling
+	// upgrade, but members of another cluster are still ignored
+	m := g.m
+	g = newGossip(&gossipConfig{
+		Name:                  "node1",
+		ClusterID:             "cluster1",
+		AllowMissingClusterID: true,
+	})
+	g.m = m
+	if diff := cmp.Diff([]string{"node1", "node2", "node4"}, names(g)); diff != "" {
+		t.Errorf("gossip: after Members differs: (-want +got)\n%s", diff)
+	}
+
+	// a member without a cluster identity does not ignore any members
+	g = newGossip(&gossipConfig{Name: "node4"})
+	g.m = newFakeMemberlist(
+		&Member{Name: "node1", ClusterID: "cluster1"},
+		&Member{Name: "node3", ClusterID: "cluster2"},
+		&Member{Name: "node4"},
+	)
+	if n := len(g.Members()); n != 3 {
+		t.Fatalf("expected 3 members, received %d", n)
+	}
+}
+
 func TestGossipDelegate(t *testing.T) {
 	t.Skip()
 	g1 := newGossip(&gossipConfig{
@@ -88,3 +213,168 @@ func TestGossipDelegate(t *testing.T) {
 		}
 	}
 }
+
+func TestGossipSecretKey(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	key := bytes.Repeat([]byte{1}, 32)
+	newTestGossip := func(name string, port int, key []byte) *gossip {
+		return newGossip(&gossipConfig{
+			Name:       name,
+			GossipHost: "127.0.0.1",
+			GossipPort: port,
+			SecretKey:  key,
+		})
+	}
+	g1 := newTestGossip("node1", 7990, key)
+	if err := g1.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a member with the same key can join
+	g2 := newTestGossip("node2", 7991, key)
+	if err := g2.Start(ctx, []string{"127.0.0.1:7990"}); err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Shutdown()
+
+	// a member with a different key cannot join
+	g3 := newTestGossip("node3", 7992, bytes.Repeat([]byte{2}, 32))
+	if err := g3.Start(ctx, []string{"127.0.0.1:7990"}); err != context.DeadlineExceeded {
+		t.Fatalf("expected join with a different key to fail, received %v", err)
+	}
+	defer g3.Shutdown()
+
+	if n := g1.m.NumMembers(); n != 2 {
+		t.Fatalf("expected 2 members, received %d", n)
+	}
+}
+
+func TestGossipLeave(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	g1 := newGossip(&gossipConfig{
+		Name:       "node1",
+		GossipHost: "127.0.0.1",
+		GossipPort: 7990,
+	})
+	if err := g1.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Shutdown()
+	g2 := newGossip(&gossipConfig{
+		Name:       "node2",
+		GossipHost: "127.0.0.1",
+		GossipPort: 7991,
+	})
+	if err := g2.Start(context.Background(), []string{"127.0.0.1:7990"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g2.Leave(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed member is only detected after probes time out, which takes
+	// several seconds, whereas a leave is observed right away
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev := <-g1.Events():
+			if ev.Event == memberlist.NodeLeave && ev.Node.Name == "node2" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for node2 to leave")
+		}
+	}
+}
+
+func TestGossipUpdateTags(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	g1 := newGossip(&gossipConfig{
+		Name:       "node1",
+		GossipHost: "127.0.0.1",
+		GossipPort: 7990,
+	})
+	if err := g1.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Shutdown()
+	g2 := newGossip(&gossipConfig{
+		Name:       "node2",
+		GossipHost: "127.0.0.1",
+		GossipPort: 7991,
+	})
+	if err := g2.Start(context.Background(), []string{"127.0.0.1:7990"}); err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Shutdown()
+
+	tags := map[string]string{"zone": "us-east-1a", "role": "control-plane"}
+	if err := g1.UpdateTags(tags); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		for _, m := range g2.Members() {
+			if m.Name == "node1" && cmp.Equal(m.Tags, tags) {
+				return
+			}
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out waiting for node1 tags")
+		}
+	}
+}
+
+func TestGossipProfile(t *testing.T) {
+	cases := []struct {
+		cfg              *gossipConfig
+		expectedInterval time.Duration
+		expectedTimeout  time.Duration
+	}{
+		{
+			cfg:              &gossipConfig{},
+			expectedInterval: memberlist.DefaultLANConfig().ProbeInterval,
+			expectedTimeout:  memberlist.DefaultLANConfig().ProbeTimeout,
+		},
+		{
+			cfg:              &gossipConfig{Profile: GossipProfileWAN},
+			expectedInterval: memberlist.DefaultWANConfig().ProbeInterval,
+			expectedTimeout:  memberlist.DefaultWANConfig().ProbeTimeout,
+		},
+		{
+			cfg:              &gossipConfig{Profile: GossipProfileLocal},
+			expectedInterval: memberlist.DefaultLocalConfig().ProbeInterval,
+			expectedTimeout:  memberlist.DefaultLocalConfig().ProbeTimeout,
+		},
+		{
+			cfg: &gossipConfig{
+				Profile:       GossipProfileWAN,
+				ProbeInterval: 10 * time.Second,
+				ProbeTimeout:  4 * time.Second,
+			},
+			expectedInterval: 10 * time.Second,
+			expectedTimeout:  4 * time.Second,
+		},
+	}
+	for _, tc := range cases {
+		g := newGossip(tc.cfg)
+		if g.config.ProbeInterval != tc.expectedInterval {
+			t.Errorf("profile %#v: expected ProbeInterval %v, received %v", tc.cfg.Profile, tc.expectedInterval, g.config.ProbeInterval)
+		}
+		if g.config.ProbeTimeout != tc.expectedTimeout {
+			t.Errorf("profile %#v: expected ProbeTimeout %v, received %v", tc.cfg.Profile, tc.expectedTimeout, g.config.ProbeTimeout)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package manager
 
 import (
 	"context"
-	"io"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/memberlist"
@@ -13,6 +16,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/discovery"
 	"github.com/criticalstack/e2d/pkg/log"
 	"github.com/criticalstack/e2d/pkg/manager/e2dpb"
 	"github.com/criticalstack/e2d/pkg/snapshot"
@@ -30,12 +34,39 @@ type Manager struct {
 	cluster     *clusterMembership
 	snapshotter snapshot.Snapshotter
 
-	removeCh chan string
+	// serializes snapshot backups, which can be triggered both periodically
+	// and through the Snapshot RPC
+	snapshotMu sync.Mutex
+
+	removeCh  chan string
+	restoreCh chan time.Time
+
+	// serializes reloading and renewing certificates, which can be triggered
+	// both by ReloadTLS and the certificate renewer
+	tlsMu sync.Mutex
+
+	// contents of the trusted CA files when etcd was last started, used by
+	// ReloadTLS to detect a changed CA
+	trustedCAs []byte
+
+	// number of snapshot restores, exported by the collector
+	restores  uint64
+	collector *collector
 }
 
 // New creates a new instance of Manager.
 func New(cfg *Config) (*Manager, error) {
 	if err := cfg.validate(); err != nil {
+		cfg.removeInlineCerts()
+		return nil, err
+	}
+	trustedCAs, err := readTrustedCAs(cfg)
+	if err != nil {
+		cfg.removeInlineCerts()
+		return nil, err
+	}
+	if err := cfg.openLogs(); err != nil {
+		cfg.removeInlineCerts()
 		return nil, err
 	}
 
@@ -46,26 +77,43 @@ func New(cfg *Config) (*Manager, error) {
 			Dir:                 cfg.Dir,
 			ClientURL:           cfg.ClientURL,
 			PeerURL:             cfg.PeerURL,
+			AdvertiseClientURL:  cfg.AdvertiseClientURL,
+			AdvertisePeerURL:    cfg.AdvertisePeerURL,
+			MetricsURL:          cfg.MetricsURL,
 			RequiredClusterSize: cfg.RequiredClusterSize,
 			ClientSecurity:      cfg.ClientSecurity,
 			PeerSecurity:        cfg.PeerSecurity,
 			EtcdLogLevel:        cfg.EtcdLogLevel,
+			EtcdLogOutput:       cfg.EtcdLogOutput,
 			Debug:               cfg.Debug,
 			EnableLocalListener: true,
+			QuotaBackendBytes:   cfg.QuotaBackendBytes,
 		}),
 		gossip: newGossip(&gossipConfig{
-			Name:       cfg.Name,
-			ClientURL:  cfg.ClientURL.String(),
-			PeerURL:    cfg.PeerURL.String(),
-			GossipHost: cfg.GossipHost,
-			GossipPort: cfg.GossipPort,
-			SecretKey:  cfg.gossipSecretKey,
+			Name:          cfg.Name,
+			ClientURL:     cfg.AdvertiseClientURL.String(),
+			PeerURL:       cfg.AdvertisePeerURL.String(),
+			GossipHost:    cfg.GossipHost,
+			GossipPort:    cfg.GossipPort,
+			SecretKey:     cfg.gossipSecretKey,
+			CACertHash:    cfg.caCertHash,
+			ClusterID:     cfg.ClusterID,
+			Profile:       cfg.GossipProfile,
+			ProbeInterval: cfg.GossipProbeInterval,
+			ProbeTimeout:  cfg.GossipProbeTimeout,
+			LogOutput:     cfg.EtcdLogOutput,
+			PeerGetter:    cfg.PeerGetter,
+			Tags:          withZoneTag(nil, cfg.Zone),
+
+			AllowMissingClusterID: cfg.AllowMissingClusterID,
 		}),
 		removeCh:    make(chan string, 10),
+		restoreCh:   make(chan time.Time, 1),
 		snapshotter: cfg.Snapshotter,
+		trustedCAs:  trustedCAs,
 	}
 	m.ctx, m.cancel = context.WithCancel(context.Background())
-	m.cluster = newClusterMembership(m.ctx, m.cfg.HealthCheckTimeout, func(name string) error {
+	m.cluster = newClusterMembership(m.ctx, m.cfg.HealthCheckTimeout, func(name, reason string) error {
 		log.Debug("removing member ...",
 			zap.String("name", shortName(m.cfg.Name)),
 			zap.String("removed", shortName(name)),
@@ -77,6 +125,7 @@ func New(cfg *Config) (*Manager, error) {
 			zap.String("name", shortName(m.cfg.Name)),
 			zap.String("removed", shortName(name)),
 		)
+		m.audit(AuditMemberRemove, name, "", reason)
 
 		// TODO(chris): this is mostly used for testing atm and
 		// should evolve in the future to be part of a more
@@ -93,6 +142,34 @@ func New(cfg *Config) (*Manager, error) {
 	return m, nil
 }
 
+// stopEtcd stops the etcd server using the provided stop function and waits
+// for it to stop. A wedged etcd server could otherwise block shutdown forever,
+// so the wait is abandoned after the configured StopTimeout. The abandoned stop
+// is left running in the background and logged if it eventually completes.
+func (m *Manager) stopEtcd(stop func()) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		stop()
+		<-m.etcd.Server.StopNotify()
+	}()
+	select {
+	case <-stopped:
+		log.Debug("etcd server stopped")
+	case <-time.After(m.cfg.StopTimeout):
+		log.Warn("timed out waiting for etcd server to stop, continuing shutdown while it stops in the background",
+			zap.String("name", shortName(m.cfg.Name)),
+			zap.Duration("timeout", m.cfg.StopTimeout),
+		)
+		go func() {
+			<-stopped
+			log.Info("etcd server stopped after exceeding stop timeout",
+				zap.String("name", shortName(m.cfg.Name)),
+			)
+		}()
+	}
+}
+
 // HardStop stops all services and cleans up the Manager state. Unlike
 // GracefulStop, it does not attempt to gracefully shutdown etcd.
 func (m *Manager) HardStop() {
@@ -100,33 +177,125 @@ func (m *Manager) HardStop() {
 		close(m.removeCh)
 		m.removeCh = nil
 	}
+	if m.restoreCh != nil {
+		close(m.restoreCh)
+		m.restoreCh = nil
+	}
 	m.cancel()
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 	log.Debug("attempting hard stop of etcd server ...")
-	m.etcd.hardStop()
-	<-m.etcd.Server.StopNotify()
-	log.Debug("etcd server stopped")
+	m.stopEtcd(m.etcd.hardStop)
+	m.unregisterCollector()
 	if err := m.gossip.Shutdown(); err != nil {
 		log.Debug("gossip shutdown failed", zap.Error(err))
 	}
+	m.cfg.removeInlineCerts()
 }
 
+// gossipLeaveTimeout is the maximum amount of time to wait for the leave
+// message to be broadcast when gracefully leaving the gossip network.
+const gossipLeaveTimeout = 5 * time.Second
+
 // GracefulStop stops all services and cleans up the Manager state. It attempts
-// to gracefully shutdown etcd by waiting for gRPC calls in-flight to finish.
+// to gracefully shutdown etcd by first transferring leadership with StepDown,
+// then waiting for gRPC calls in-flight to finish. The gossip network is left
+// gracefully, so that peers observe the departure promptly.
 func (m *Manager) GracefulStop() {
+	if m.etcd.isRunning() {
+		ctx, cancel := context.WithTimeout(m.ctx, m.cfg.StopTimeout)
+		if err := m.StepDown(ctx); err != nil {
+			log.Warn("cannot transfer leadership before stopping", zap.Error(err))
+		}
+		cancel()
+	}
 	if m.removeCh != nil {
 		close(m.removeCh)
 		m.removeCh = nil
 	}
+	if m.restoreCh != nil {
+		close(m.restoreCh)
+		m.restoreCh = nil
+	}
 	m.cancel()
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 	log.Debug("attempting graceful stop of etcd server ...")
-	m.etcd.gracefulStop()
-	<-m.etcd.Server.StopNotify()
-	log.Debug("etcd server stopped")
-	if err := m.gossip.Shutdown(); err != nil {
-		log.Debug("gossip shutdown failed", zap.Error(err))
+	m.stopEtcd(m.etcd.gracefulStop)
+	m.unregisterCollector()
+	if err := m.gossip.Leave(gossipLeaveTimeout); err != nil {
+		log.Debug("gossip leave failed", zap.Error(err))
 	}
+	m.cfg.removeInlineCerts()
+}
+
+// StepDown transfers leadership of the etcd cluster to a healthy follower when
+// this member is the leader, so that stopping this member for a planned
+// replacement does not interrupt writes until an election times out. The
+// follower with the highest raft index that responds to a status request is
+// chosen, since it has the least to catch up on. It does nothing when this
+// member is not the leader.
+func (m *Manager) StepDown(ctx context.Context) error {
+	if !m.etcd.isLeader() {
+		return nil
+	}
+	transferee, err := m.healthiestFollower(ctx)
+	if err != nil {
+		return err
+	}
+	if transferee == 0 {
+		// a single-member cluster has no one to transfer leadership to
+		return nil
+	}
+	log.Info("transferring leadership before stopping",
+		zap.String("name", shortName(m.cfg.Name)),
+		zap.String("transferee", fmt.Sprintf("%x", transferee)),
+	)
+	return m.etcd.Server.MoveLeader(ctx, m.etcd.Server.Lead(), transferee)
+}
+
+// healthiestFollower returns the ID of the voting member, other than this
+// member, that is reachable and has the highest raft index, or 0 when there
+// are no other voting members.
+func (m *Manager) healthiestFollower(ctx context.Context) (uint64, error) {
+	urls := make(map[uint64]string)
+	for _, member := range m.etcd.Server.Cluster().Members() {
+		if uint64(member.ID) == uint64(m.etcd.Server.ID()) || member.IsLearner || len(member.ClientURLs) == 0 {
+			continue
+		}
+		urls[uint64(member.ID)] = member.ClientURLs[0]
+	}
+	if len(urls) == 0 {
+		return 0, nil
+	}
+	endpoints := make([]string, 0)
+	for _, u := range urls {
+		endpoints = append(endpoints, u)
+	}
+	c, err := newClient(&client.Config{
+		ClientURLs:     endpoints,
+		SecurityConfig: m.cfg.PeerSecurity,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	var transferee, index uint64
+	for id, u := range urls {
+		sctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		resp, err := c.Status(sctx, u)
+		cancel()
+		if err != nil {
+			log.Debug("follower is unreachable", zap.String("url", u), zap.Error(err))
+			continue
+		}
+		if transferee == 0 || resp.RaftIndex > index {
+			transferee, index = id, resp.RaftIndex
+		}
+	}
+	if transferee == 0 {
+		return 0, errors.New("no healthy followers to transfer leadership to")
+	}
+	return transferee, nil
 }
 
 func (m *Manager) Restart() error {
@@ -148,24 +317,16 @@ func (m *Manager) restoreFromSnapshot(peers []*Peer) (bool, error) {
 		return false, nil
 	}
 
-	r, err := m.snapshotter.Load()
-	if err != nil {
-		return false, err
-	}
-	defer r.Close()
-
-	log.Debugf("[%v]: attempting snapshot restore with members: %s", shortName(m.cfg.Name), peers)
 	tmpFile, err := ioutil.TempFile("", "snapshot.load")
 	if err != nil {
 		return false, err
 	}
 	defer tmpFile.Close()
 
-	r = snapshotutil.NewGunzipReadCloser(r)
-	r = snapshotutil.NewDecrypterReadCloser(r, m.cfg.snapshotEncryptionKey)
-	if _, err := io.Copy(tmpFile, r); err != nil {
+	if err := loadSnapshot(m.snapshotter, m.cfg.SnapshotName, m.cfg.snapshotEncryptionKey, tmpFile); err != nil {
 		return false, err
 	}
+	log.Debugf("[%v]: attempting snapshot restore with members: %s", shortName(m.cfg.Name), peers)
 
 	// if the process is restarted, this will fail if the data-dir already
 	// exists, so it must be deleted here
@@ -177,6 +338,7 @@ func (m *Manager) restoreFromSnapshot(peers []*Peer) (bool, error) {
 		return false, err
 	}
 	log.Infof("successfully loaded snapshot from: %#v", tmpFile.Name())
+	atomic.AddUint64(&m.restores, 1)
 	return true, nil
 }
 
@@ -184,13 +346,18 @@ func (m *Manager) restoreFromSnapshot(peers []*Peer) (bool, error) {
 // of peers provided must be inclusive of this prospective instance. An attempt
 // is made to restore from a previous snapshot when one is available.
 //
-// When restoring from a snapshot, all volatile keys are deleted and a snapshot
-// marker is created. This enables clients using e2d to coordinate their
+// When restoring from a snapshot, all volatile keys not matching one of the
+// PreservePrefixes are deleted and a snapshot marker is created. This enables clients using e2d to coordinate their
 // cluster, by conveying information about whether this is a brand new cluster
 // or an existing cluster that recovered from total cluster failure.
 func (m *Manager) startEtcdCluster(peers []*Peer) error {
-	snapshot, err := m.restoreFromSnapshot(peers)
+	restored, err := m.restoreFromSnapshot(peers)
 	if err != nil {
+		// starting an empty cluster in place of a corrupt snapshot would
+		// soon overwrite the snapshot backup, so this is fatal
+		if errors.Cause(err) == snapshot.ErrChecksumMismatch {
+			return err
+		}
 		log.Error("cannot restore snapshot", zap.Error(err))
 	}
 	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Minute)
@@ -199,7 +366,7 @@ func (m *Manager) startEtcdCluster(peers []*Peer) error {
 	if err := m.etcd.startNew(ctx, peers); err != nil {
 		return err
 	}
-	if !snapshot {
+	if !restored {
 		return nil
 	}
 
@@ -207,7 +374,7 @@ func (m *Manager) startEtcdCluster(peers []*Peer) error {
 	// therefore do NOT get committed through the raft log. This is OK
 	// since all servers that recover from a snapshot will perform the same
 	// operations and the outcome is deterministic.
-	rev, deleted, err := m.etcd.clearVolatilePrefix()
+	rev, deleted, err := m.etcd.clearVolatilePrefix(m.cfg.PreservePrefixes)
 	if err != nil {
 		if errors.Cause(err) != errServerStopped {
 			return err
@@ -219,7 +386,8 @@ func (m *Manager) startEtcdCluster(peers []*Peer) error {
 		zap.Int64("deleted-keys", deleted),
 		zap.Int64("revision", rev),
 	)
-	v := []byte(time.Now().Format(time.RFC3339))
+	now := time.Now()
+	v := []byte(now.Format(time.RFC3339))
 	rev, err = m.etcd.placeSnapshotMarker(v)
 	if err != nil {
 		if errors.Cause(err) != errServerStopped {
@@ -233,48 +401,111 @@ func (m *Manager) startEtcdCluster(peers []*Peer) error {
 		zap.String("value", string(v)),
 		zap.Int64("rev", rev),
 	)
+	select {
+	case m.restoreCh <- now:
+	default:
+	}
 	return nil
 }
 
+// UpdateTags replaces the tags shared with the other members of the gossip
+// network, which are available from the Tags of each gossip Member. The
+// ZoneTag is always set to the configured Zone.
+func (m *Manager) UpdateTags(tags map[string]string) error {
+	return m.gossip.UpdateTags(withZoneTag(tags, m.cfg.Zone))
+}
+
+// withZoneTag returns a copy of the tags with the ZoneTag set to zone, or the
+// tags unchanged when zone is empty.
+func withZoneTag(tags map[string]string, zone string) map[string]string {
+	if zone == "" {
+		return tags
+	}
+	t := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		t[k] = v
+	}
+	t[ZoneTag] = zone
+	return t
+}
+
+// RestoreCh returns a channel that receives the time the cluster was restored
+// from a snapshot, once the snapshot marker has been placed. The channel is
+// closed when the Manager is stopped.
+func (m *Manager) RestoreCh() <-chan time.Time {
+	return m.restoreCh
+}
+
 // joinEtcdCluster attempts to join an etcd cluster by establishing a client
 // connection with the provided peer URL.
 func (m *Manager) joinEtcdCluster(peerURL string) error {
-	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Minute)
+	ctx, cancel := context.WithTimeout(m.ctx, m.cfg.JoinAttemptTimeout)
 	defer cancel()
 
+	// connecting to the peer cannot take longer than the attempt itself
+	timeout := m.cfg.JoinTimeout
+	if timeout > m.cfg.JoinAttemptTimeout {
+		timeout = m.cfg.JoinAttemptTimeout
+	}
 	c, err := newClient(&client.Config{
 		ClientURLs:     []string{peerURL},
 		SecurityConfig: m.cfg.PeerSecurity,
-		Timeout:        1 * time.Second,
+		Timeout:        timeout,
 	})
 	if err != nil {
 		return err
 	}
 	defer c.Close()
 
-	members, err := c.members(ctx)
+	retries := m.cfg.JoinRetries
+	if retries < 0 {
+		retries = 0
+	}
+	members, err := c.membersWithRetry(ctx, retries)
 	if err != nil {
 		return err
 	}
 
+	// A cluster being grown from a single node is joined before it has
+	// reached the RequiredClusterSize, which is otherwise not allowed.
+	growing := false
+	if len(members) < m.cfg.RequiredClusterSize {
+		growing, err = c.Exists(string(growthMarkerKey))
+		if err != nil {
+			return err
+		}
+	}
+	join := m.etcd.joinExisting
+	if growing {
+		join = m.etcd.joinGrowing
+	}
+
 	// In cases where the existing cluster identifies this instance to already
 	// be a member of the cluster, we attempt to start right away. This case
 	// happens when restarting a node and specifying the previous node name.
 	// The previous node name MUST be specified since otherwise a new Name is
 	// generated.
-	if members[m.cfg.Name] != nil {
+	if member := members[m.cfg.Name]; member != nil {
 		peers := make([]*Peer, 0)
 		for _, m := range members {
 			peers = append(peers, &Peer{m.Name, m.PeerURL})
 		}
+
+		// a member that joined as a learner, but was never promoted, is
+		// promoted once it has started
+		var promote func(context.Context) error
+		if member.IsLearner {
+			promote = m.promoteLearner(c, member)
+		}
 		log.Infof("%s is already considered a member, attempting to start ...", m.cfg.Name)
-		if err := m.etcd.joinExisting(ctx, peers); err == nil {
+		if err := join(ctx, peers, promote); err == nil {
 			return nil
 		}
 		log.Infof("%s is already considered a member, but failed to start, attempting to remove ...", m.cfg.Name)
 		if err := c.removeMemberLocked(ctx, members[m.cfg.Name]); err != nil {
 			return err
 		}
+		m.audit(AuditMemberRemove, m.cfg.Name, members[m.cfg.Name].PeerURL, "already a member but failed to start")
 	}
 
 	log.Infof("%s is NOT a member, attempting to add member and start ...", m.cfg.Name)
@@ -287,37 +518,92 @@ func (m *Manager) joinEtcdCluster(peerURL string) error {
 	}
 	defer unlock()
 
-	member, err := c.addMember(ctx, m.cfg.PeerURL.String())
+	member, err := c.addMember(ctx, m.cfg.AdvertisePeerURL.String(), m.cfg.JoinAsLearner)
 	if err != nil {
 		return err
 	}
+	reason := "joining existing cluster"
+	if growing {
+		reason = "joining single-node cluster being grown"
+	}
+	var promote func(context.Context) error
+	if member.IsLearner {
+		reason += " as a learner"
+		promote = m.promoteLearner(c, member)
+	}
+	m.audit(AuditMemberAdd, m.cfg.Name, member.PeerURL, reason)
 
 	// The name will not be available immediately after adding a new member.
 	// Since the member missing is this member, we can safely use the local
 	// member name.
-	peers := []*Peer{{m.cfg.Name, m.cfg.PeerURL.String()}}
+	peers := []*Peer{{m.cfg.Name, m.cfg.AdvertisePeerURL.String()}}
 	for _, m := range members {
 		peers = append(peers, &Peer{m.Name, m.PeerURL})
 	}
-	if err := m.etcd.joinExisting(ctx, peers); err != nil {
+	if err := join(ctx, peers, promote); err != nil {
 		if err := c.removeMember(m.ctx, member.ID); err != nil {
 			log.Debug("unable to remove member", zap.Error(err))
+		} else {
+			m.audit(AuditMemberRemove, m.cfg.Name, member.PeerURL, "failed to start after being added")
 		}
 		return err
 	}
+	if growing && len(peers) >= m.cfg.RequiredClusterSize {
+		log.Info("cluster has finished growing",
+			zap.String("name", shortName(m.cfg.Name)),
+			zap.Int("required-cluster-size", m.cfg.RequiredClusterSize),
+		)
+		if err := c.Delete(string(growthMarkerKey)); err != nil {
+			log.Error("cannot clear cluster growth marker", zap.Error(err))
+		}
+	}
 	return nil
 }
 
+// promoteLearner returns a function that promotes this member from a learner
+// to a voting member through the provided client, once it has caught up with
+// the leader.
+func (m *Manager) promoteLearner(c *Client, member *Member) func(context.Context) error {
+	return func(ctx context.Context) error {
+		log.Info("waiting for learner to catch up with the leader before promoting ...",
+			zap.String("name", shortName(m.cfg.Name)),
+		)
+		if err := c.promoteMember(ctx, member.ID); err != nil {
+			return err
+		}
+		m.audit(AuditMemberPromote, m.cfg.Name, member.PeerURL, "learner caught up with the leader")
+		return nil
+	}
+}
+
 func (m *Manager) startOrJoinEtcdCluster() error {
 	ctx, cancel := context.WithTimeout(m.ctx, m.cfg.BootstrapTimeout)
 	defer cancel()
 
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(m.cfg.JoinRetryInterval)
 	defer ticker.Stop()
 
+	start := time.Now()
+	escalation := &bootstrapEscalation{
+		timeout:   m.cfg.BootstrapTimeout,
+		fractions: m.cfg.BootstrapWarnings,
+	}
+
 	for {
 		select {
 		case <-ticker.C:
+			elapsed := time.Since(start)
+			for _, f := range escalation.check(elapsed) {
+				log.Warn("cluster bootstrap has not yet succeeded",
+					zap.String("name", shortName(m.cfg.Name)),
+					zap.Float64("fraction", f),
+					zap.Duration("elapsed", elapsed.Round(time.Second)),
+					zap.Duration("timeout", m.cfg.BootstrapTimeout),
+					zap.Int("members", len(m.gossip.Members())),
+					zap.Int("required", m.cfg.RequiredClusterSize),
+				)
+			}
+
 			// first use peers to attempt joining an existing cluster
 			for _, member := range m.gossip.Members() {
 				if member.Name == m.cfg.Name {
@@ -343,11 +629,7 @@ func (m *Manager) startOrJoinEtcdCluster() error {
 				log.Debugf("[%v]: cannot update member metadata: %v", shortName(m.cfg.Name), err)
 			}
 
-			// when enough members are reporting in as pending, it means that a
-			// majority of members were unable to connect to an existing
-			// cluster
-			if len(m.gossip.pendingMembers()) < m.cfg.RequiredClusterSize {
-				log.Debugf("[%v]: members pending: %d", shortName(m.cfg.Name), len(m.gossip.pendingMembers()))
+			if !m.readyToFormCluster(start) {
 				continue
 			}
 			peers := make([]*Peer, 0)
@@ -361,6 +643,65 @@ func (m *Manager) startOrJoinEtcdCluster() error {
 	}
 }
 
+// bootstrapEscalation tracks the time spent attempting to bootstrap relative
+// to the bootstrap timeout, so that warnings can be given before bootstrapping
+// ultimately fails.
+type bootstrapEscalation struct {
+	timeout   time.Duration
+	fractions []float64
+
+	// index of the next fraction of the timeout to be reached
+	next int
+}
+
+// check returns the fractions of the timeout that have been reached since the
+// last check. Each fraction is only ever returned once.
+func (e *bootstrapEscalation) check(elapsed time.Duration) []float64 {
+	reached := make([]float64, 0)
+	for ; e.next < len(e.fractions); e.next++ {
+		if elapsed < time.Duration(float64(e.timeout)*e.fractions[e.next]) {
+			break
+		}
+		reached = append(reached, e.fractions[e.next])
+	}
+	return reached
+}
+
+// readyToFormCluster determines if enough members are pending, and the gossip
+// network has been observed for long enough, to safely form a new cluster.
+func (m *Manager) readyToFormCluster(start time.Time) bool {
+	// when enough members are reporting in as pending, it means that a
+	// majority of members were unable to connect to an existing cluster
+	if n := len(m.gossip.pendingMembers()); n < m.cfg.RequiredClusterSize {
+		log.Debugf("[%v]: members pending: %d", shortName(m.cfg.Name), n)
+		return false
+	}
+
+	// A running member means that an existing cluster has survived, which
+	// forming a new cluster would replace, so joining it is preferred until
+	// the longer PreferExistingTimeout has elapsed.
+	if n := len(m.gossip.runningMembers()); m.cfg.PreferExisting && n > 0 {
+		if remaining := m.cfg.PreferExistingTimeout - time.Since(start); remaining > 0 {
+			log.Debugf("[%v]: waiting %v to join %d running members before forming a new cluster", shortName(m.cfg.Name), remaining.Round(time.Second), n)
+			return false
+		}
+		log.Warn("cannot join running members of an existing cluster, forming a new cluster",
+			zap.String("name", shortName(m.cfg.Name)),
+			zap.Int("running", n),
+			zap.Duration("timeout", m.cfg.PreferExistingTimeout),
+		)
+	}
+
+	// Discovery can be slow to report running members of an existing cluster
+	// (e.g. during a rolling replacement), so an observation window can be
+	// used to ensure that a new cluster is not formed prematurely.
+	if remaining := m.cfg.BootstrapObservationWindow - time.Since(start); remaining > 0 {
+		log.Debugf("[%v]: waiting %v before forming a new cluster", shortName(m.cfg.Name), remaining.Round(time.Second))
+		return false
+	}
+	return true
+}
+
 func (m *Manager) runMembershipCleanup() {
 	if m.cfg.RequiredClusterSize == 1 {
 		return
@@ -404,7 +745,7 @@ func (m *Manager) runMembershipCleanup() {
 			// than the name or gossip address as it better represents a
 			// distinct member of the cluster as only one PeerURL will ever be
 			// present on a network.
-			if member.PeerURL == m.cfg.PeerURL.String() {
+			if member.PeerURL == m.cfg.AdvertisePeerURL.String() {
 				continue
 			}
 			switch ev.Event {
@@ -425,7 +766,7 @@ func (m *Manager) runMembershipCleanup() {
 					log.Debugf("[%v]: member %v peerAddr in use by member %v", shortName(m.cfg.Name), member.Name, oldName)
 					if oldName != member.Name {
 						log.Debugf("[%v]: members name mismatched, evicting %v", shortName(m.cfg.Name), oldName)
-						if err := m.cluster.removeMember(oldName); err != nil {
+						if err := m.cluster.removeMember(oldName, fmt.Sprintf("peer url reused by member %#v", member.Name)); err != nil {
 							log.Debug("unable to remove member", zap.Error(err))
 						}
 					}
@@ -442,57 +783,233 @@ func (m *Manager) runMembershipCleanup() {
 	}
 }
 
+// snapshotProgressInterval is the number of bytes uploaded between reports of
+// snapshot upload progress.
+const snapshotProgressInterval = 16 << 20
+
+// backupSnapshot creates a snapshot of the etcd database and saves it to the
+// configured snapshot backup. Snapshots are only created when the revision has
+// advanced past minRevision, and the revision and size of the saved snapshot
+// are returned.
+func (m *Manager) backupSnapshot(minRevision int64) (int64, int64, error) {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	log.Debug("starting snapshot backup")
+	start := time.Now()
+	snapshotData, snapshotSize, rev, err := m.etcd.createSnapshot(minRevision)
+	if err != nil {
+		// an unchanged revision is expected when the cluster is idle, so it
+		// isn't counted as a failure
+		switch errors.Cause(err) {
+		case errRevisionTooOld:
+		case ErrBackendSnapshotUnavailable:
+			snapshotFailuresTotal.WithLabelValues("unavailable").Inc()
+			snapshotSaveTotal.WithLabelValues("failure").Inc()
+		default:
+			snapshotFailuresTotal.WithLabelValues("create").Inc()
+			snapshotSaveTotal.WithLabelValues("failure").Inc()
+		}
+		log.Debug("cannot create snapshot",
+			zap.String("name", shortName(m.cfg.Name)),
+			zap.Error(err),
+		)
+		return 0, 0, err
+	}
+	snapshotData = encodeSnapshot(m.cfg, snapshotData, snapshotSize)
+	snapshotUploadedBytes.Set(0)
+	snapshotData = snapshotutil.NewProgressReadCloser(snapshotData, snapshotProgressInterval, func(n int64) {
+		snapshotUploadedBytes.Set(float64(n))
+		log.Debug("snapshot upload progress",
+			zap.String("name", shortName(m.cfg.Name)),
+			zap.Int64("uploaded", n),
+			// uploaded bytes are counted after compression and encryption,
+			// so they are not directly comparable to the database size
+			zap.Int64("raw-size", snapshotSize),
+			zap.Duration("elapsed", time.Since(start)),
+		)
+	})
+	if err := m.snapshotter.Save(snapshotData); err != nil {
+		snapshotFailuresTotal.WithLabelValues("save").Inc()
+		snapshotSaveTotal.WithLabelValues("failure").Inc()
+		log.Debug("cannot save snapshot",
+			zap.String("name", shortName(m.cfg.Name)),
+			zap.Error(err),
+		)
+		return 0, 0, err
+	}
+	elapsed := time.Since(start)
+	snapshotSizeBytes.Set(float64(snapshotSize))
+	snapshotSaveDurationSeconds.Observe(elapsed.Seconds())
+	snapshotSaveTotal.WithLabelValues("success").Inc()
+	snapshotLastSuccessTimestamp.SetToCurrentTime()
+	log.Info("wrote snapshot to backup",
+		zap.Int64("rev", rev),
+		zap.Int64("size", snapshotSize),
+		zap.Duration("elapsed", elapsed),
+	)
+	return rev, snapshotSize, nil
+}
+
+// snapshotRetryBackoff is the initial amount of time to wait before retrying a
+// snapshot backup when the etcd backend is unavailable.
+var snapshotRetryBackoff = 1 * time.Second
+
+// nextSnapshotBackoff doubles the previous backoff, starting from
+// snapshotRetryBackoff and bounded by max.
+func nextSnapshotBackoff(prev, max time.Duration) time.Duration {
+	next := snapshotRetryBackoff
+	if prev > 0 {
+		next = prev * 2
+	}
+	if next > max {
+		next = max
+	}
+	return next
+}
+
 func (m *Manager) runSnapshotter() {
 	if m.snapshotter == nil {
 		log.Info("snapshotting disabled: no snapshot backup set")
 		return
 	}
+	if m.cfg.SnapshotInterval < 0 {
+		log.Info("snapshotting disabled: snapshot backup is only restored")
+		return
+	}
 	log.Debug("starting snapshotter")
 	ticker := time.NewTicker(m.cfg.SnapshotInterval)
 	defer ticker.Stop()
 
 	var latestRev int64
 
+	// set when a snapshot backup is retried before the next interval
+	var retry <-chan time.Time
+	var backoff time.Duration
+
 	for {
 		select {
 		case <-ticker.C:
-			if m.etcd.isRestarting() {
-				log.Debug("server is restarting, skipping snapshot backup")
-				continue
-			}
-			if !m.etcd.isLeader() {
-				log.Debug("not leader, skipping snapshot backup")
-				continue
-			}
-			log.Debug("starting snapshot backup")
-			snapshotData, snapshotSize, rev, err := m.etcd.createSnapshot(latestRev)
-			if err != nil {
-				log.Debug("cannot create snapshot",
-					zap.String("name", shortName(m.cfg.Name)),
-					zap.Error(err),
-				)
-				continue
-			}
-			if m.cfg.SnapshotEncryption {
-				snapshotData = snapshotutil.NewEncrypterReadCloser(snapshotData, m.cfg.snapshotEncryptionKey, snapshotSize)
-			}
-			if m.cfg.SnapshotCompression {
-				snapshotData = snapshotutil.NewGzipReadCloser(snapshotData)
-			}
-			if err := m.snapshotter.Save(snapshotData); err != nil {
-				log.Debug("cannot save snapshot",
-					zap.String("name", shortName(m.cfg.Name)),
-					zap.Error(err),
-				)
-				continue
-			}
-			latestRev = rev
-			log.Infof("wrote snapshot (rev %d) to backup", latestRev)
+		case <-retry:
 		case <-m.ctx.Done():
 			log.Debug("stopping snapshotter")
 			return
 		}
+		retry = nil
+		if m.etcd.isRestarting() {
+			log.Debug("server is restarting, skipping snapshot backup")
+			continue
+		}
+		if !m.isSnapshotTaker() {
+			log.Debug("not selected to create snapshot backups, skipping snapshot backup")
+			continue
+		}
+		rev, _, err := m.backupSnapshot(latestRev)
+
+		// the backend is expected to be unavailable while etcd is not ready,
+		// so it is retried sooner than the next interval
+		if errors.Cause(err) == ErrBackendSnapshotUnavailable {
+			backoff = nextSnapshotBackoff(backoff, m.cfg.SnapshotInterval)
+			log.Info("etcd backend not ready, retrying snapshot backup",
+				zap.Duration("backoff", backoff),
+			)
+			retry = time.After(backoff)
+			continue
+		}
+		backoff = 0
+		if err != nil {
+			continue
+		}
+		latestRev = rev
+
+		// older snapshots are only removed once a newer one has been saved
+		pruneSnapshots(m.snapshotter)
+	}
+}
+
+// promoteSingleNodeCluster starts an existing single-node cluster that is being
+// grown into a multi-node cluster. This is determined by the data-dir having
+// only this member, and is validated against the stored cluster-info once etcd
+// has started. Once running, new members join the cluster through the gossip
+// network like they would for any existing cluster.
+func (m *Manager) promoteSingleNodeCluster() (bool, error) {
+	path := filepath.Join(m.cfg.Dir, "member/snap/db")
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("cannot read data-dir, not promoting", zap.String("path", path), zap.Error(err))
+		}
+		return false, nil
+	}
+	members, err := getExistingMembersFromDataDir(path)
+	if err != nil {
+		log.Error("cannot read existing members from data-dir, not promoting", zap.String("path", path), zap.Error(err))
+		return false, nil
+	}
+	if len(members) != 1 || members[0].Name != m.cfg.Name {
+		return false, nil
+	}
+	log.Info("existing single-node cluster found, attempting to promote ...",
+		zap.String("name", shortName(m.cfg.Name)),
+		zap.Int("required-cluster-size", m.cfg.RequiredClusterSize),
+	)
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Minute)
+	defer cancel()
+
+	if err := m.etcd.promote(ctx, &Peer{m.cfg.Name, m.cfg.AdvertisePeerURL.String()}); err != nil {
+		return false, errors.Wrap(err, "cannot promote single-node cluster")
+	}
+	m.audit(AuditMemberPromote, m.cfg.Name, m.cfg.AdvertisePeerURL.String(), fmt.Sprintf("growing single-node cluster to %d members", m.cfg.RequiredClusterSize))
+
+	// new members are only allowed to join with fewer than the
+	// RequiredClusterSize peers while this marker is set
+	c, err := newClient(&client.Config{
+		ClientURLs:     []string{m.cfg.ClientURL.String()},
+		SecurityConfig: m.cfg.PeerSecurity,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	if err := c.Set(string(growthMarkerKey), m.cfg.Name); err != nil {
+		return false, errors.Wrap(err, "cannot mark cluster as growing")
 	}
+	return true, nil
+}
+
+// checkDataDir moves an existing data-dir aside when it fails an integrity
+// check, which would otherwise prevent etcd from starting. A single-node
+// cluster can only be recovered from a snapshot backup, so unless a backup can
+// be loaded the data-dir is left in place and an error is returned instead.
+func (m *Manager) checkDataDir() error {
+	if !m.cfg.CheckDataDir {
+		return nil
+	}
+	err := verifyDataDir(m.cfg.Dir)
+	if err == nil {
+		return nil
+	}
+	if errors.Cause(err) != errDataDirCorrupt {
+		return err
+	}
+	if m.cfg.RequiredClusterSize == 1 {
+		if m.snapshotter == nil {
+			return errors.Wrap(err, "cannot recover without a snapshot backup")
+		}
+		r, lerr := snapshot.LoadNamed(m.snapshotter, m.cfg.SnapshotName)
+		if lerr != nil {
+			return errors.Wrapf(err, "cannot recover without a loadable snapshot backup: %v", lerr)
+		}
+		r.Close()
+	}
+	corruptDir := fmt.Sprintf("%s.corrupt-%d", filepath.Clean(m.cfg.Dir), time.Now().Unix())
+	log.Warn("data-dir failed integrity check, moving aside to recover",
+		zap.String("name", shortName(m.cfg.Name)),
+		zap.String("dir", m.cfg.Dir),
+		zap.String("moved-to", corruptDir),
+		zap.Error(err),
+	)
+	return os.Rename(m.cfg.Dir, corruptDir)
 }
 
 // Run starts and manages an etcd node based upon the provided configuration.
@@ -502,15 +1019,35 @@ func (m *Manager) Run() error {
 	if m.etcd.isRunning() {
 		return errors.New("etcd is already running")
 	}
+	if err := checkDataDirAccess(m.cfg.Dir); err != nil {
+		return err
+	}
+	if err := m.checkDataDir(); err != nil {
+		return err
+	}
+	m.registerCollector()
 
 	switch m.cfg.RequiredClusterSize {
 	case 1:
 		// a single-node etcd cluster does not require gossip or need to wait for
 		// other members and therefore can start immediately
-		if err := m.startEtcdCluster([]*Peer{{m.cfg.Name, m.cfg.PeerURL.String()}}); err != nil {
+		if err := m.startEtcdCluster([]*Peer{{m.cfg.Name, m.cfg.AdvertisePeerURL.String()}}); err != nil {
 			return err
 		}
-	case 3, 5:
+	case 3, 5, 7:
+		// an existing single-node cluster being grown starts right away, since
+		// joining the gossip network must wait for the new members
+		promoted, err := m.promoteSingleNodeCluster()
+		if err != nil {
+			return err
+		}
+
+		// cached peer addresses are kept up-to-date while the manager is
+		// running, so that newly-launched peers can be joined
+		if pg, ok := m.cfg.PeerGetter.(*discovery.CachingPeerGetter); ok {
+			go pg.Run(m.ctx)
+		}
+
 		// all multi-node clusters require the gossip network to be started
 		if err := m.gossip.Start(m.ctx, m.cfg.BootstrapAddrs); err != nil {
 			return err
@@ -518,8 +1055,10 @@ func (m *Manager) Run() error {
 
 		// a multi-node etcd cluster will either be created or an existing one will
 		// be joined
-		if err := m.startOrJoinEtcdCluster(); err != nil {
-			return err
+		if !promoted {
+			if err := m.startOrJoinEtcdCluster(); err != nil {
+				return err
+			}
 		}
 
 		if err := m.gossip.Update(Running); err != nil {
@@ -530,6 +1069,8 @@ func (m *Manager) Run() error {
 	// cluster is ready so start maintenance loops
 	go m.runMembershipCleanup()
 	go m.runSnapshotter()
+	go m.runDefragmenter()
+	go m.runCertRenewer()
 
 	for {
 		select {
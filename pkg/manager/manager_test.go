@@ -2,25 +2,42 @@
 package manager
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/cloudflare/cfssl/csr"
+	"github.com/gogo/protobuf/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/etcdserver"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
 
 	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/e2db"
 	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/criticalstack/e2d/pkg/manager/e2dpb"
 	"github.com/criticalstack/e2d/pkg/netutil"
 	"github.com/criticalstack/e2d/pkg/pki"
 	"github.com/criticalstack/e2d/pkg/snapshot"
-	snapshotutil "github.com/criticalstack/e2d/pkg/snapshot/util"
 )
 
 func writeFile(filename string, data []byte, perm os.FileMode) error {
@@ -90,18 +107,7 @@ func (n *testCluster) startAll() {
 }
 
 func (n *testCluster) saveSnapshot(name string) {
-	node := n.lookupNode(name)
-	data, size, _, err := node.etcd.createSnapshot(0)
-	if err != nil {
-		n.t.Fatal(err)
-	}
-	if node.cfg.SnapshotEncryption {
-		data = snapshotutil.NewEncrypterReadCloser(data, node.cfg.snapshotEncryptionKey, size)
-	}
-	if node.cfg.SnapshotCompression {
-		data = snapshotutil.NewGzipReadCloser(data)
-	}
-	if err := node.snapshotter.Save(data); err != nil {
+	if _, _, err := n.lookupNode(name).backupSnapshot(0); err != nil {
 		n.t.Fatal(err)
 	}
 }
@@ -200,7 +206,7 @@ func newSecureTestClient(addr, certFile, clientCertFile, clientKeyFile string) *
 }
 
 func newFileSnapshotter(path string) *snapshot.FileSnapshotter {
-	s, _ := snapshot.NewFileSnapshotter(path)
+	s, _ := snapshot.NewFileSnapshotter(path, 0)
 	return s
 }
 
@@ -215,6 +221,158 @@ func init() {
 	log.SetLevel(zapcore.DebugLevel)
 }
 
+func TestManagerBootstrapObservationWindow(t *testing.T) {
+	m := &Manager{
+		cfg: &Config{
+			Name:                       "node1",
+			RequiredClusterSize:        3,
+			BootstrapObservationWindow: 1 * time.Minute,
+		},
+		gossip: newGossip(&gossipConfig{Name: "node1"}),
+	}
+	m.gossip.m = newFakeMemberlist(
+		&Member{Name: "node1", Status: Pending},
+		&Member{Name: "node2", Status: Pending},
+	)
+
+	// not enough members have reported in yet
+	if m.readyToFormCluster(time.Now().Add(-2 * time.Minute)) {
+		t.Fatal("expected to wait for pending members")
+	}
+	m.gossip.m = newFakeMemberlist(
+		&Member{Name: "node1", Status: Pending},
+		&Member{Name: "node2", Status: Pending},
+		&Member{Name: "node3", Status: Pending},
+	)
+	if m.readyToFormCluster(time.Now()) {
+		t.Fatal("expected to wait for the observation window to elapse")
+	}
+	if !m.readyToFormCluster(time.Now().Add(-2 * time.Minute)) {
+		t.Fatal("expected to form cluster after the observation window elapsed")
+	}
+}
+
+func TestManagerBootstrapPreferExisting(t *testing.T) {
+	members := newFakeMemberlist(
+		&Member{Name: "node1", Status: Pending},
+		&Member{Name: "node2", Status: Pending},
+		&Member{Name: "node3", Status: Pending},
+		&Member{Name: "survivor", Status: Running},
+	)
+	m := &Manager{
+		cfg: &Config{
+			Name:                "node1",
+			RequiredClusterSize: 3,
+		},
+		gossip: newGossip(&gossipConfig{Name: "node1"}),
+	}
+	m.gossip.m = members
+
+	// by default enough pending members form a new cluster, replacing the
+	// surviving member
+	if !m.readyToFormCluster(time.Now()) {
+		t.Fatal("expected to form cluster without PreferExisting")
+	}
+
+	m.cfg.PreferExisting = true
+	m.cfg.PreferExistingTimeout = 10 * time.Minute
+	if m.readyToFormCluster(time.Now().Add(-5 * time.Minute)) {
+		t.Fatal("expected to wait to join the surviving member")
+	}
+	if !m.readyToFormCluster(time.Now().Add(-11 * time.Minute)) {
+		t.Fatal("expected to form cluster after PreferExistingTimeout elapsed")
+	}
+
+	// without a running member there is nothing to prefer
+	m.gossip.m = newFakeMemberlist(
+		&Member{Name: "node1", Status: Pending},
+		&Member{Name: "node2", Status: Pending},
+		&Member{Name: "node3", Status: Pending},
+	)
+	if !m.readyToFormCluster(time.Now()) {
+		t.Fatal("expected to form cluster without running members")
+	}
+}
+
+func TestManagerCACertHashBlocksJoin(t *testing.T) {
+	expected := bytes.Repeat([]byte{0x01}, 32)
+	unexpected := bytes.Repeat([]byte{0x02}, 32)
+	m := &Manager{
+		cfg: &Config{
+			Name:                "node1",
+			RequiredClusterSize: 3,
+		},
+		gossip: newGossip(&gossipConfig{Name: "node1", CACertHash: expected}),
+	}
+	m.gossip.m = newFakeMemberlist(
+		&Member{Name: "node1", Status: Pending, CACertHash: expected},
+		&Member{Name: "node2", Status: Pending, CACertHash: expected},
+		&Member{Name: "node3", Status: Pending, CACertHash: unexpected},
+		&Member{Name: "node4", Status: Running, CACertHash: unexpected},
+		&Member{Name: "node5", Status: Running},
+	)
+
+	names := make([]string, 0)
+	for _, member := range m.gossip.Members() {
+		names = append(names, member.Name)
+	}
+	if diff := cmp.Diff([]string{"node1", "node2"}, names); diff != "" {
+		t.Errorf("gossip: members differ: (-want +got)\n%s", diff)
+	}
+	if running := m.gossip.runningMembers(); len(running) != 0 {
+		t.Fatalf("expected no running members to join, received %d", len(running))
+	}
+	if m.readyToFormCluster(time.Now()) {
+		t.Fatal("expected members with an unexpected ca to not count towards forming a cluster")
+	}
+}
+
+func TestManagerBootstrapEscalation(t *testing.T) {
+	e := &bootstrapEscalation{
+		timeout:   100 * time.Second,
+		fractions: []float64{0.25, 0.5, 0.75},
+	}
+	cases := []struct {
+		elapsed  time.Duration
+		expected []float64
+	}{
+		{elapsed: 10 * time.Second, expected: []float64{}},
+		{elapsed: 25 * time.Second, expected: []float64{0.25}},
+		{elapsed: 30 * time.Second, expected: []float64{}},
+		{elapsed: 80 * time.Second, expected: []float64{0.5, 0.75}},
+		{elapsed: 100 * time.Second, expected: []float64{}},
+	}
+	for _, tc := range cases {
+		if diff := cmp.Diff(tc.expected, e.check(tc.elapsed)); diff != "" {
+			t.Errorf("escalation: after check(%v) differs: (-want +got)\n%s", tc.elapsed, diff)
+		}
+	}
+}
+
+func TestManagerJoinAttemptTimeout(t *testing.T) {
+	m, err := New(&Config{
+		Dir:                filepath.Join("testdata", "join-attempt-timeout"),
+		ClientAddr:         ":2379",
+		PeerAddr:           ":2380",
+		GossipAddr:         ":7980",
+		JoinTimeout:        30 * time.Second,
+		JoinRetries:        10,
+		JoinAttemptTimeout: 500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing listens on this port, so the peer is unreachable
+	start := time.Now()
+	if err := m.joinEtcdCluster("http://127.0.0.1:1"); err == nil {
+		t.Fatal("expected joining an unreachable peer to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the join attempt to be bounded by JoinAttemptTimeout, took %v", elapsed)
+	}
+}
+
 // TODO(chris): a lot of cases here create a healthy 3 node cluster, so create
 // a function to do that to make the test code more succinct
 
@@ -303,6 +461,133 @@ func TestManagerSingleFaultRecovery(t *testing.T) {
 	}
 }
 
+func TestManagerMembershipAudit(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	sink := &recordingAuditSink{}
+	newConfig := func(clientAddr, peerAddr, gossipAddr, bootstrapAddr string) *Config {
+		return &Config{
+			ClientAddr:          clientAddr,
+			PeerAddr:            peerAddr,
+			GossipAddr:          gossipAddr,
+			BootstrapAddrs:      []string{bootstrapAddr},
+			RequiredClusterSize: 3,
+			HealthCheckInterval: 1 * time.Second,
+			HealthCheckTimeout:  5 * time.Second,
+			AuditSink:           sink,
+		}
+	}
+	c.addNode("node1", newConfig(":2379", ":2380", ":7980", ":7981"))
+	c.addNode("node2", newConfig(":2479", ":2480", ":7981", ":7980"))
+	c.addNode("node3", newConfig(":2579", ":2580", ":7982", ":7981"))
+	c.startAll()
+	c.wait("node1", "node2", "node3")
+
+	c.stop("node1")
+	c.waitRemoved("node1", "node2", "node3")
+	ev := sink.find(AuditMemberRemove, "node1")
+	if ev == nil {
+		t.Fatal("expected an audit event for removing node1")
+	}
+	if ev.Actor != "node2" && ev.Actor != "node3" {
+		t.Fatalf("expected node1 to be removed by a peer, received %#v", ev.Actor)
+	}
+	if ev.Reason == "" || ev.Time.IsZero() {
+		t.Fatalf("expected reason and time to be set: %+v", ev)
+	}
+
+	c.addNode("node4", newConfig(":2379", ":2380", ":7980", ":7981"))
+	c.start("node4")
+	c.wait("node2", "node3", "node4")
+	ev = sink.find(AuditMemberAdd, "node4")
+	if ev == nil {
+		t.Fatal("expected an audit event for adding node4")
+	}
+	if ev.Actor != "node4" || ev.PeerURL != c.lookupNode("node4").cfg.PeerURL.String() || ev.Reason == "" {
+		t.Fatalf("unexpected audit event: %+v", ev)
+	}
+}
+
+func TestManagerJoinAsLearner(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	sink := &recordingAuditSink{}
+	newConfig := func(clientAddr, peerAddr, gossipAddr, bootstrapAddr string) *Config {
+		return &Config{
+			ClientAddr:          clientAddr,
+			PeerAddr:            peerAddr,
+			GossipAddr:          gossipAddr,
+			BootstrapAddrs:      []string{bootstrapAddr},
+			RequiredClusterSize: 3,
+			HealthCheckInterval: 1 * time.Second,
+			HealthCheckTimeout:  5 * time.Second,
+			AuditSink:           sink,
+		}
+	}
+	c.addNode("node1", newConfig(":2379", ":2380", ":7980", ":7981"))
+	c.addNode("node2", newConfig(":2479", ":2480", ":7981", ":7980"))
+	c.addNode("node3", newConfig(":2579", ":2580", ":7982", ":7981"))
+	c.startAll()
+	c.wait("node1", "node2", "node3")
+
+	c.stop("node1")
+	c.waitRemoved("node1", "node2", "node3")
+	cfg := newConfig(":2379", ":2380", ":7980", ":7981")
+	cfg.JoinAsLearner = true
+	c.addNode("node4", cfg)
+	c.start("node4")
+	c.wait("node2", "node3", "node4")
+
+	ev := sink.find(AuditMemberAdd, "node4")
+	if ev == nil || !strings.Contains(ev.Reason, "learner") {
+		t.Fatalf("expected an audit event for adding node4 as a learner, received %+v", ev)
+	}
+	if ev := sink.find(AuditMemberPromote, "node4"); ev == nil {
+		t.Fatal("expected an audit event for promoting node4")
+	}
+
+	cl := newTestClient(":2479")
+	defer cl.Close()
+
+	members, err := cl.members(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, ok := members["node4"]
+	if !ok {
+		t.Fatalf("expected node4 to be a member, received %v", members)
+	}
+	if member.IsLearner {
+		t.Fatal("expected node4 to be promoted to a voting member")
+	}
+
+	// the promoted member serves writes
+	cl4 := newTestClient(":2379")
+	defer cl4.Close()
+
+	if err := cl4.Set("testkey1", "testvalue1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestManagerRestoreClusterFromSnapshotNoCompression(t *testing.T) {
 	if !*testLong {
 		t.Skip()
@@ -372,9 +657,8 @@ func TestManagerRestoreClusterFromSnapshotNoCompression(t *testing.T) {
 	// need to wait a bit to ensure the port is free to bind
 	time.Sleep(1 * time.Second)
 
-	// SnapshotInterval is 0 so creating snapshots is disabled, however,
-	// SnapshotDir is being replaced with default SnapshotDir from node1 so
-	// that this new node can restore that snapshot
+	// SnapshotInterval is negative so creating snapshots is disabled, however,
+	// the snapshot backup saved by node1 is still restored
 	c.addNode("node4", &Config{
 		ClientAddr:          ":2379",
 		PeerAddr:            ":2380",
@@ -384,6 +668,7 @@ func TestManagerRestoreClusterFromSnapshotNoCompression(t *testing.T) {
 		HealthCheckInterval: 1 * time.Second,
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		SnapshotInterval:    -1,
 	})
 	c.addNode("node5", &Config{
 		ClientAddr:          ":2479",
@@ -394,6 +679,7 @@ func TestManagerRestoreClusterFromSnapshotNoCompression(t *testing.T) {
 		HealthCheckInterval: 1 * time.Second,
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		SnapshotInterval:    -1,
 	})
 	c.addNode("node6", &Config{
 		ClientAddr:          ":2579",
@@ -404,6 +690,7 @@ func TestManagerRestoreClusterFromSnapshotNoCompression(t *testing.T) {
 		HealthCheckInterval: 1 * time.Second,
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		SnapshotInterval:    -1,
 	})
 	c.start("node4", "node5", "node6")
 	c.wait("node4", "node5", "node6")
@@ -418,6 +705,55 @@ func TestManagerRestoreClusterFromSnapshotNoCompression(t *testing.T) {
 	}
 }
 
+func TestManagerRestoreCh(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:  ":2379",
+		PeerAddr:    ":2380",
+		GossipAddr:  ":7980",
+		Snapshotter: newFileSnapshotter("testdata/snapshots"),
+	})
+	c.start("node1")
+	c.wait("node1")
+	select {
+	case <-c.lookupNode("node1").RestoreCh():
+		t.Fatal("expected no restore without a snapshot backup")
+	default:
+	}
+	c.saveSnapshot("node1")
+	c.stop("node1")
+
+	// need to wait a bit to ensure the port is free to bind
+	time.Sleep(1 * time.Second)
+
+	c.addNode("node2", &Config{
+		ClientAddr:       ":2379",
+		PeerAddr:         ":2380",
+		GossipAddr:       ":7980",
+		Snapshotter:      newFileSnapshotter("testdata/snapshots"),
+		SnapshotInterval: -1,
+	})
+	start := time.Now().Truncate(time.Second)
+	c.start("node2")
+	select {
+	case restored := <-c.lookupNode("node2").RestoreCh():
+		if restored.Before(start) {
+			t.Fatalf("expected restore time after %v, received %v", start, restored)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for restore")
+	}
+}
+
 func TestManagerRestoreClusterFromSnapshotCompression(t *testing.T) {
 	if !*testLong {
 		t.Skip()
@@ -491,9 +827,8 @@ func TestManagerRestoreClusterFromSnapshotCompression(t *testing.T) {
 	// need to wait a bit to ensure the port is free to bind
 	time.Sleep(1 * time.Second)
 
-	// SnapshotInterval is 0 so creating snapshots is disabled, however,
-	// SnapshotDir is being replaced with default SnapshotDir from node1 so
-	// that this new node can restore that snapshot
+	// SnapshotInterval is negative so creating snapshots is disabled, however,
+	// the snapshot backup saved by node1 is still restored
 	c.addNode("node4", &Config{
 		ClientAddr:          ":2379",
 		PeerAddr:            ":2380",
@@ -504,6 +839,7 @@ func TestManagerRestoreClusterFromSnapshotCompression(t *testing.T) {
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
 		SnapshotCompression: true,
+		SnapshotInterval:    -1,
 	})
 	c.addNode("node5", &Config{
 		ClientAddr:          ":2479",
@@ -515,6 +851,7 @@ func TestManagerRestoreClusterFromSnapshotCompression(t *testing.T) {
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
 		SnapshotCompression: true,
+		SnapshotInterval:    -1,
 	})
 	c.addNode("node6", &Config{
 		ClientAddr:          ":2579",
@@ -526,6 +863,7 @@ func TestManagerRestoreClusterFromSnapshotCompression(t *testing.T) {
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
 		SnapshotCompression: true,
+		SnapshotInterval:    -1,
 	})
 	c.start("node4", "node5", "node6")
 	c.wait("node4", "node5", "node6")
@@ -664,9 +1002,8 @@ func TestManagerRestoreClusterFromSnapshotEncryption(t *testing.T) {
 	// need to wait a bit to ensure the port is free to bind
 	time.Sleep(1 * time.Second)
 
-	// SnapshotInterval is 0 so creating snapshots is disabled, however,
-	// SnapshotDir is being replaced with default SnapshotDir from node1 so
-	// that this new node can restore that snapshot
+	// SnapshotInterval is negative so creating snapshots is disabled, however,
+	// the snapshot backup saved by node1 is still restored
 	c.addNode("node4", &Config{
 		ClientAddr:          ":2379",
 		PeerAddr:            ":2380",
@@ -687,8 +1024,9 @@ func TestManagerRestoreClusterFromSnapshotEncryption(t *testing.T) {
 			KeyFile:       peerKeyFile,
 			TrustedCAFile: caCertFile,
 		},
-		CACertFile: caCertFile,
-		CAKeyFile:  caKeyFile,
+		CACertFile:       caCertFile,
+		CAKeyFile:        caKeyFile,
+		SnapshotInterval: -1,
 	})
 	c.addNode("node5", &Config{
 		ClientAddr:          ":2479",
@@ -710,8 +1048,9 @@ func TestManagerRestoreClusterFromSnapshotEncryption(t *testing.T) {
 			KeyFile:       peerKeyFile,
 			TrustedCAFile: caCertFile,
 		},
-		CACertFile: caCertFile,
-		CAKeyFile:  caKeyFile,
+		CACertFile:       caCertFile,
+		CAKeyFile:        caKeyFile,
+		SnapshotInterval: -1,
 	})
 	c.addNode("node6", &Config{
 		ClientAddr:          ":2579",
@@ -733,8 +1072,9 @@ func TestManagerRestoreClusterFromSnapshotEncryption(t *testing.T) {
 			KeyFile:       peerKeyFile,
 			TrustedCAFile: caCertFile,
 		},
-		CACertFile: caCertFile,
-		CAKeyFile:  caKeyFile,
+		CACertFile:       caCertFile,
+		CAKeyFile:        caKeyFile,
+		SnapshotInterval: -1,
 	})
 	c.start("node4", "node5", "node6")
 	c.wait("node4", "node5", "node6")
@@ -952,6 +1292,12 @@ func writeTestingCerts() error {
 	if err := writeFile("testdata/ca.key", r.CA.KeyPEM, 0600); err != nil {
 		return err
 	}
+	return writeTestingLeafCerts(r)
+}
+
+// writeTestingLeafCerts writes new server, peer and client certificates
+// signed by the provided CA.
+func writeTestingLeafCerts(r *pki.RootCA) error {
 	certs, err := r.GenerateCertificates(pki.ServerSigningProfile, &csr.CertificateRequest{
 		Names: []csr.Name{
 			{
@@ -1245,9 +1591,10 @@ func TestManagerDeleteVolatile(t *testing.T) {
 	cl.Close()
 
 	// cluster-info is added by e2db which contains a key with the cluster-info
-	// itself, and another key for the e2db table schema
-	if n != int64(nkeys+2) {
-		t.Fatalf("expected %d keys, received %d", nkeys+2, n)
+	// itself, another key for the e2db table schema, and the range index key
+	// of its ID
+	if n != int64(nkeys+3) {
+		t.Fatalf("expected %d keys, received %d", nkeys+3, n)
 	}
 	c.saveSnapshot("node1")
 	c.stop("node1")
@@ -1255,9 +1602,8 @@ func TestManagerDeleteVolatile(t *testing.T) {
 	// need to wait a bit to ensure the port is free to bind
 	time.Sleep(1 * time.Second)
 
-	// SnapshotInterval is 0 so creating snapshots is disabled, however,
-	// SnapshotDir is being replaced with default SnapshotDir from node1 so
-	// that this new node can restore that snapshot
+	// SnapshotInterval is negative so creating snapshots is disabled, however,
+	// the snapshot backup saved by node1 is still restored
 	c.addNode("node2", &Config{
 		ClientAddr:          ":2379",
 		PeerAddr:            ":2380",
@@ -1267,6 +1613,7 @@ func TestManagerDeleteVolatile(t *testing.T) {
 		HealthCheckInterval: 1 * time.Second,
 		HealthCheckTimeout:  10 * time.Second,
 		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		SnapshotInterval:    -1,
 	})
 	c.start("node2")
 	c.wait("node2")
@@ -1285,7 +1632,7 @@ func TestManagerDeleteVolatile(t *testing.T) {
 	cl.Close()
 }
 
-func TestManagerServerRestartCertRenewal(t *testing.T) {
+func TestManagerPreservePrefixes(t *testing.T) {
 	if !*testLong {
 		t.Skip()
 	}
@@ -1293,18 +1640,62 @@ func TestManagerServerRestartCertRenewal(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := writeTestingCerts(); err != nil {
-		t.Fatal(err)
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	newConfig := func() *Config {
+		return &Config{
+			ClientAddr:          ":2379",
+			PeerAddr:            ":2380",
+			GossipAddr:          ":7980",
+			BootstrapAddrs:      []string{":7981"},
+			RequiredClusterSize: 1,
+			HealthCheckInterval: 1 * time.Second,
+			HealthCheckTimeout:  10 * time.Second,
+			PreservePrefixes:    []string{"/_e2d/app/"},
+			Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		}
+	}
+	c.addNode("node1", newConfig())
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	for _, k := range []string{"/_e2d/app/id", "/_e2d/app/owner", "/_e2d/other/id", "/_e2d/application"} {
+		if err := cl.Set(k, "testvalue1"); err != nil {
+			t.Fatal(err)
+		}
 	}
+	cl.Close()
+	c.saveSnapshot("node1")
+	c.stop("node1")
 
-	caCertFile := "testdata/ca.crt"
-	caKeyFile := "testdata/ca.key"
-	serverCertFile := "testdata/server.crt"
-	serverKeyFile := "testdata/server.key"
-	peerCertFile := "testdata/peer.crt"
-	peerKeyFile := "testdata/peer.key"
-	clientCertFile := "testdata/client.crt"
-	clientKeyFile := "testdata/client.key"
+	// need to wait a bit to ensure the port is free to bind
+	time.Sleep(1 * time.Second)
+
+	c.addNode("node2", newConfig())
+	c.start("node2")
+	c.wait("node2")
+	cl = newTestClient(":2379")
+	defer cl.Close()
+	for _, k := range []string{"/_e2d/app/id", "/_e2d/app/owner", "/_e2d/snapshot"} {
+		if _, err := cl.Get(k); err != nil {
+			t.Fatalf("expected %#v to be preserved: %v", k, err)
+		}
+	}
+	for _, k := range []string{"/_e2d/other/id", "/_e2d/application"} {
+		if _, err := cl.Get(k); errors.Cause(err) != client.ErrKeyNotFound {
+			t.Fatalf("expected %#v to be cleared, received %v", k, err)
+		}
+	}
+}
+
+func TestManagerSnapshotMetrics(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
 
 	c := newTestCluster(t)
 	defer c.cleanup()
@@ -1314,81 +1705,858 @@ func TestManagerServerRestartCertRenewal(t *testing.T) {
 		PeerAddr:            ":2380",
 		GossipAddr:          ":7980",
 		BootstrapAddrs:      []string{":7981"},
-		RequiredClusterSize: 3,
-		HealthCheckInterval: 1 * time.Second,
-		HealthCheckTimeout:  5 * time.Second,
-		ClientSecurity: client.SecurityConfig{
-			CertFile:      serverCertFile,
-			KeyFile:       serverKeyFile,
-			TrustedCAFile: caCertFile,
-		},
-		PeerSecurity: client.SecurityConfig{
-			CertFile:      peerCertFile,
-			KeyFile:       peerKeyFile,
-			TrustedCAFile: caCertFile,
-		},
-		CACertFile: caCertFile,
-		CAKeyFile:  caKeyFile,
-	})
-	c.addNode("node2", &Config{
-		ClientAddr:          ":2479",
-		PeerAddr:            ":2480",
-		GossipAddr:          ":7981",
-		BootstrapAddrs:      []string{":7980"},
-		RequiredClusterSize: 3,
-		HealthCheckInterval: 1 * time.Second,
-		HealthCheckTimeout:  5 * time.Second,
-		ClientSecurity: client.SecurityConfig{
-			CertFile:      serverCertFile,
-			KeyFile:       serverKeyFile,
-			TrustedCAFile: caCertFile,
-		},
-		PeerSecurity: client.SecurityConfig{
-			CertFile:      peerCertFile,
-			KeyFile:       peerKeyFile,
-			TrustedCAFile: caCertFile,
-		},
-		CACertFile: caCertFile,
-		CAKeyFile:  caKeyFile,
-	})
-	c.addNode("node3", &Config{
-		ClientAddr:          ":2579",
-		PeerAddr:            ":2580",
-		GossipAddr:          ":7982",
-		BootstrapAddrs:      []string{":7981"},
-		RequiredClusterSize: 3,
+		RequiredClusterSize: 1,
 		HealthCheckInterval: 1 * time.Second,
-		HealthCheckTimeout:  5 * time.Second,
-		ClientSecurity: client.SecurityConfig{
-			CertFile:      serverCertFile,
-			KeyFile:       serverKeyFile,
-			TrustedCAFile: caCertFile,
-		},
-		PeerSecurity: client.SecurityConfig{
-			CertFile:      peerCertFile,
-			KeyFile:       peerKeyFile,
-			TrustedCAFile: caCertFile,
-		},
-		CACertFile: caCertFile,
-		CAKeyFile:  caKeyFile,
+		HealthCheckTimeout:  10 * time.Second,
+		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
 	})
 
-	c.start("node1", "node2", "node3")
-	c.wait("node1", "node2", "node3")
-	fmt.Println("ready")
-	cl := newSecureTestClient(":2379", caCertFile, clientCertFile, clientKeyFile)
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	if err := cl.Set("testkey1", "testvalue1"); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+
+	var before dto.Metric
+	if err := snapshotSaveDurationSeconds.Write(&before); err != nil {
+		t.Fatal(err)
+	}
+	successes := testutil.ToFloat64(snapshotSaveTotal.WithLabelValues("success"))
+	start := time.Now()
+	rev, _, err := c.lookupNode("node1").backupSnapshot(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var after dto.Metric
+	if err := snapshotSaveDurationSeconds.Write(&after); err != nil {
+		t.Fatal(err)
+	}
+	if got := after.GetHistogram().GetSampleCount() - before.GetHistogram().GetSampleCount(); got != 1 {
+		t.Fatalf("expected 1 snapshot duration observation, received %d", got)
+	}
+	if got := testutil.ToFloat64(snapshotSaveTotal.WithLabelValues("success")) - successes; got != 1 {
+		t.Fatalf("expected 1 successful snapshot save, received %v", got)
+	}
+	if last := testutil.ToFloat64(snapshotLastSuccessTimestamp); last < float64(start.Unix()) {
+		t.Fatalf("expected last success timestamp after %d, received %v", start.Unix(), last)
+	}
+	if size := testutil.ToFloat64(snapshotSizeBytes); size <= 0 {
+		t.Fatalf("expected snapshot size to be set, received %v", size)
+	}
+	if uploaded := testutil.ToFloat64(snapshotUploadedBytes); uploaded <= 0 {
+		t.Fatalf("expected snapshot upload progress to be reported, received %v", uploaded)
+	}
+
+	// the revision has not changed so this is skipped, and should not be
+	// counted as a failure
+	failures := testutil.ToFloat64(snapshotFailuresTotal.WithLabelValues("create"))
+	saveFailures := testutil.ToFloat64(snapshotSaveTotal.WithLabelValues("failure"))
+	if _, _, err := c.lookupNode("node1").backupSnapshot(rev); err == nil {
+		t.Fatal("expected snapshot with unchanged revision to be skipped")
+	}
+	if got := testutil.ToFloat64(snapshotFailuresTotal.WithLabelValues("create")); got != failures {
+		t.Fatalf("expected %v create failures, received %v", failures, got)
+	}
+	if got := testutil.ToFloat64(snapshotSaveTotal.WithLabelValues("failure")); got != saveFailures {
+		t.Fatalf("expected %v failed snapshot saves, received %v", saveFailures, got)
+	}
+}
+
+func TestManagerCollector(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		MetricsAddr:         ":2381",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+		SnapshotInterval:    1 * time.Hour,
+		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+	})
+
+	c.startAll()
+	c.wait("node1")
+	c.saveSnapshot("node1")
+
+	resp, err := http.Get("http://127.0.0.1:2381/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "e2d_") {
+			fields := strings.Fields(line)
+			metrics[fields[0]] = fields[1]
+		}
+	}
+	for _, name := range []string{"e2d_gossip_members", "e2d_gossip_pending_members", "e2d_snapshot_restores_total"} {
+		if _, ok := metrics[name]; !ok {
+			t.Fatalf("expected %s to be scraped, received:\n%s", name, data)
+		}
+	}
+	if v := metrics["e2d_snapshot_last_success_timestamp"]; v == "" || v == "0" {
+		t.Fatalf("expected e2d_snapshot_last_success_timestamp to be set, received %#v", v)
+	}
+}
+
+func TestManagerSnapshotRPC(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+		SnapshotInterval:    1 * time.Hour,
+		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+	})
+
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	if err := cl.Set("testkey1", "testvalue1"); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+
+	conn, err := grpc.Dial("127.0.0.1:2379", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := e2dpb.NewManagerClient(conn).Snapshot(ctx, &types.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Revision == 0 || resp.SizeBytes == 0 {
+		t.Fatalf("expected snapshot revision and size, received %v", resp)
+	}
+	fi, err := os.Stat("testdata/snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() == 0 {
+		t.Fatal("expected snapshot to be saved to backup")
+	}
+}
+
+func TestManagerSnapshotRPCPrune(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	s, err := snapshot.NewFileSnapshotter("testdata/snapshots/snapshot", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+		SnapshotInterval:    1 * time.Hour,
+		Snapshotter:         s,
+	})
+
+	c.startAll()
+	c.wait("node1")
+	c.saveSnapshot("node1")
+
+	// snapshots are timestamped to the second
+	time.Sleep(1 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := (&ManagerService{c.lookupNode("node1")}).Snapshot(ctx, &types.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the snapshot saved by the rpc is kept, along with its checksum
+	files, err := ioutil.ReadDir("testdata/snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected older snapshots to be pruned, received %d files", len(files))
+	}
+}
+
+func TestManagerReadyRPC(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+	})
+
+	// a member that has not started is not ready
+	s := &ManagerService{c.lookupNode("node1")}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := s.Ready(ctx, &types.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&e2dpb.ReadyResponse{Reason: "etcd server is not running"}, resp); diff != "" {
+		t.Errorf("ReadyResponse: (-want +got)\n%s", diff)
+	}
+
+	c.startAll()
+	c.wait("node1")
+
+	conn, err := grpc.Dial("127.0.0.1:2379", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	mc := e2dpb.NewManagerClient(conn)
+	resp, err = mc.Ready(ctx, &types.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&e2dpb.ReadyResponse{Ready: true}, resp); diff != "" {
+		t.Errorf("ReadyResponse: (-want +got)\n%s", diff)
+	}
+	health, err := mc.Health(ctx, &types.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.EtcdHealthy {
+		t.Fatalf("expected etcd to be healthy, received %#v", health.EtcdError)
+	}
+
+	c.stop("node1")
+	resp, err = s.Ready(ctx, &types.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Ready {
+		t.Fatal("expected stopped member not to be ready")
+	}
+}
+
+func TestManagerClusterStatusRPC(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	for i, name := range []string{"node1", "node2", "node3"} {
+		c.addNode(name, &Config{
+			ClientAddr:          fmt.Sprintf(":%d", 2379+i*100),
+			PeerAddr:            fmt.Sprintf(":%d", 2380+i*100),
+			GossipAddr:          fmt.Sprintf(":%d", 7980+i),
+			BootstrapAddrs:      []string{":7980", ":7981", ":7982"},
+			RequiredClusterSize: 3,
+			HealthCheckInterval: 1 * time.Second,
+			HealthCheckTimeout:  5 * time.Second,
+		})
+	}
+	c.startAll()
+	c.wait("node1", "node2", "node3")
+
+	conn, err := grpc.Dial("127.0.0.1:2479", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := e2dpb.NewManagerClient(conn).ClusterStatus(ctx, &types.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := c.leader()
+	if leader == nil {
+		t.Fatal("expected cluster to have a leader")
+	}
+	expected := make([]*e2dpb.MemberStatus, 0)
+	for _, name := range []string{"node1", "node2", "node3"} {
+		node := c.lookupNode(name)
+		expected = append(expected, &e2dpb.MemberStatus{
+			Name:      name,
+			PeerUrl:   node.cfg.PeerURL.String(),
+			ClientUrl: node.cfg.ClientURL.String(),
+			IsLeader:  node == leader,
+			Reachable: true,
+		})
+	}
+	for _, ms := range resp.Members {
+		if ms.RaftIndex == 0 {
+			t.Fatalf("expected raft index for member %s", ms.Name)
+		}
+
+		// the gossip status of other members is eventually consistent
+		if ms.GossipStatus == "" {
+			t.Fatalf("expected gossip status for member %s", ms.Name)
+		}
+		ms.RaftIndex, ms.GossipStatus = 0, ""
+	}
+	if diff := cmp.Diff(expected, resp.Members); diff != "" {
+		t.Errorf("ClusterStatus differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestManagerRole(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	names := []string{"node1", "node2", "node3"}
+	for i, name := range names {
+		c.addNode(name, &Config{
+			ClientAddr:          fmt.Sprintf(":%d", 2379+i*100),
+			PeerAddr:            fmt.Sprintf(":%d", 2380+i*100),
+			GossipAddr:          fmt.Sprintf(":%d", 7980+i),
+			BootstrapAddrs:      []string{":7980", ":7981", ":7982"},
+			RequiredClusterSize: 3,
+			HealthCheckInterval: 1 * time.Second,
+			HealthCheckTimeout:  5 * time.Second,
+		})
+	}
+	if _, err := c.lookupNode("node1").Role(); err == nil {
+		t.Fatal("expected error before etcd is running")
+	}
+	c.startAll()
+	c.wait(names...)
+
+	roles := make(map[Role]int)
+	for i, name := range names {
+		role, err := c.lookupNode(name).Role()
+		if err != nil {
+			t.Fatal(err)
+		}
+		roles[role]++
+
+		conn, err := grpc.Dial(fmt.Sprintf("127.0.0.1:%d", 2379+i*100), grpc.WithInsecure())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		resp, err := e2dpb.NewManagerClient(conn).Role(ctx, &types.Empty{})
+		cancel()
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Role != e2dpb.Role(role) {
+			t.Fatalf("expected %s role %v, received %v", name, e2dpb.Role(role), resp.Role)
+		}
+	}
+	if diff := cmp.Diff(map[Role]int{RoleLeader: 1, RoleFollower: 2}, roles); diff != "" {
+		t.Errorf("roles differ: (-want +got)\n%s", diff)
+	}
+	if leader := c.leader(); leader == nil {
+		t.Fatal("expected cluster to have a leader")
+	} else if role, err := leader.Role(); err != nil || role != RoleLeader {
+		t.Fatalf("expected leader role, received %v: %v", role, err)
+	}
+}
+
+func TestManagerCorruptDataDirRecovery(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	newConfig := func() *Config {
+		return &Config{
+			ClientAddr:          ":2379",
+			PeerAddr:            ":2380",
+			GossipAddr:          ":7980",
+			BootstrapAddrs:      []string{":7981"},
+			RequiredClusterSize: 1,
+			SnapshotInterval:    1 * time.Hour,
+			CheckDataDir:        true,
+			Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		}
+	}
+	c.addNode("node1", newConfig())
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	if err := cl.Set("testkey1", "testvalue1"); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+	c.saveSnapshot("node1")
+	c.stop("node1")
+
+	if err := verifyDataDir("testdata/node1"); err != nil {
+		t.Fatalf("expected data-dir to be valid before corruption: %v", err)
+	}
+	walFiles, err := filepath.Glob("testdata/node1/member/wal/*.wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walFiles) == 0 {
+		t.Fatal("expected wal files")
+	}
+	f, err := os.OpenFile(walFiles[0], os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xff}, 64), 128); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := verifyDataDir("testdata/node1"); errors.Cause(err) != errDataDirCorrupt {
+		t.Fatalf("expected errDataDirCorrupt, received %v", err)
+	}
+
+	// without a snapshot backup the corrupt data-dir must be left in place
+	cfg := newConfig()
+	cfg.Snapshotter = nil
+	c.addNode("node1", cfg)
+	if err := c.lookupNode("node1").checkDataDir(); errors.Cause(err) != errDataDirCorrupt {
+		t.Fatalf("expected errDataDirCorrupt, received %v", err)
+	}
+
+	// a snapshotter without a backup cannot recover the data-dir either
+	cfg = newConfig()
+	cfg.Snapshotter = newFileSnapshotter("testdata/missing-snapshots")
+	c.addNode("node1", cfg)
+	if err := c.lookupNode("node1").checkDataDir(); errors.Cause(err) != errDataDirCorrupt {
+		t.Fatalf("expected errDataDirCorrupt, received %v", err)
+	}
+
+	c.addNode("node1", newConfig())
+	c.start("node1")
+	c.wait("node1")
+	cl = newTestClient(":2379")
+	v, err := cl.Get("testkey1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+	if string(v) != "testvalue1" {
+		t.Fatalf("expected %#v, received %#v", "testvalue1", string(v))
+	}
+	corrupt, err := filepath.Glob("testdata/node1.corrupt-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupt) != 1 {
+		t.Fatalf("expected corrupt data-dir to be moved aside, received %v", corrupt)
+	}
+}
+
+func TestManagerEtcdLogFile(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+		EtcdLogFile:         "testdata/etcd.log",
+	})
+
+	c.startAll()
+	c.wait("node1")
+
+	data, err := ioutil.ReadFile("testdata/etcd.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("starting an etcd server")) {
+		t.Fatalf("expected etcd logs in file, received:\n%s", data)
+	}
+}
+
+func TestManagerGrowSingleNodeCluster(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		RequiredClusterSize: 1,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  10 * time.Second,
+	})
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	testKey1 := "testkey1"
+	testValue1 := "testvalue1"
+	if err := cl.Set(testKey1, testValue1); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+	c.stop("node1")
+
+	// need to wait a bit to ensure the port is free to bind
+	time.Sleep(1 * time.Second)
+
+	// node1 is restarted with the same data-dir but with the new cluster size,
+	// allowing the new members to join
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 3,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  10 * time.Second,
+	})
+	c.addNode("node2", &Config{
+		ClientAddr:          ":2479",
+		PeerAddr:            ":2480",
+		GossipAddr:          ":7981",
+		BootstrapAddrs:      []string{":7980"},
+		RequiredClusterSize: 3,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  10 * time.Second,
+	})
+	c.addNode("node3", &Config{
+		ClientAddr:          ":2579",
+		PeerAddr:            ":2580",
+		GossipAddr:          ":7982",
+		BootstrapAddrs:      []string{":7980"},
+		RequiredClusterSize: 3,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  10 * time.Second,
+	})
+	c.start("node1")
+	c.wait("node1")
+	c.start("node2")
+	c.wait("node2")
+	c.start("node3")
+	c.wait("node1", "node2", "node3")
+
+	cl = newTestClient(":2579")
+	defer cl.Close()
+	v, err := cl.Get(testKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != testValue1 {
+		t.Fatalf("expected %#v, received %#v", testValue1, string(v))
+	}
+	members, err := cl.members(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("expected 3 members, received %d", len(members))
+	}
+	db, err := e2db.New(context.Background(), &e2db.Config{
+		ClientAddr: ":2579",
+		Namespace:  string(volatilePrefix),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var cluster *Cluster
+	if err := db.Table(new(Cluster)).Find("ID", 1, &cluster); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.RequiredClusterSize != 3 {
+		t.Fatalf("expected RequiredClusterSize 3, received %d", cluster.RequiredClusterSize)
+	}
+
+	// the cluster is no longer marked as growing once it is fully formed
+	ok, err := cl.Exists(string(growthMarkerKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected growth marker to be cleared")
+	}
+}
+
+func TestManagerServerRestartCertRenewal(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeTestingCerts(); err != nil {
+		t.Fatal(err)
+	}
+
+	caCertFile := "testdata/ca.crt"
+	caKeyFile := "testdata/ca.key"
+	serverCertFile := "testdata/server.crt"
+	serverKeyFile := "testdata/server.key"
+	peerCertFile := "testdata/peer.crt"
+	peerKeyFile := "testdata/peer.key"
+	clientCertFile := "testdata/client.crt"
+	clientKeyFile := "testdata/client.key"
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 3,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+		ClientSecurity: client.SecurityConfig{
+			CertFile:      serverCertFile,
+			KeyFile:       serverKeyFile,
+			TrustedCAFile: caCertFile,
+		},
+		PeerSecurity: client.SecurityConfig{
+			CertFile:      peerCertFile,
+			KeyFile:       peerKeyFile,
+			TrustedCAFile: caCertFile,
+		},
+		CACertFile: caCertFile,
+		CAKeyFile:  caKeyFile,
+	})
+	c.addNode("node2", &Config{
+		ClientAddr:          ":2479",
+		PeerAddr:            ":2480",
+		GossipAddr:          ":7981",
+		BootstrapAddrs:      []string{":7980"},
+		RequiredClusterSize: 3,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+		ClientSecurity: client.SecurityConfig{
+			CertFile:      serverCertFile,
+			KeyFile:       serverKeyFile,
+			TrustedCAFile: caCertFile,
+		},
+		PeerSecurity: client.SecurityConfig{
+			CertFile:      peerCertFile,
+			KeyFile:       peerKeyFile,
+			TrustedCAFile: caCertFile,
+		},
+		CACertFile: caCertFile,
+		CAKeyFile:  caKeyFile,
+	})
+	c.addNode("node3", &Config{
+		ClientAddr:          ":2579",
+		PeerAddr:            ":2580",
+		GossipAddr:          ":7982",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 3,
+		HealthCheckInterval: 1 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+		ClientSecurity: client.SecurityConfig{
+			CertFile:      serverCertFile,
+			KeyFile:       serverKeyFile,
+			TrustedCAFile: caCertFile,
+		},
+		PeerSecurity: client.SecurityConfig{
+			CertFile:      peerCertFile,
+			KeyFile:       peerKeyFile,
+			TrustedCAFile: caCertFile,
+		},
+		CACertFile: caCertFile,
+		CAKeyFile:  caKeyFile,
+	})
+
+	c.start("node1", "node2", "node3")
+	c.wait("node1", "node2", "node3")
+	fmt.Println("ready")
+	cl := newSecureTestClient(":2379", caCertFile, clientCertFile, clientKeyFile)
+	testKey1 := "testkey1"
+	testValue1 := "testvalue1"
+	if err := cl.Set(testKey1, testValue1); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+	if err := writeTestingCerts(); err != nil {
+		t.Fatal(err)
+	}
+	c.restart("node1", "node2", "node3")
+	cl = newSecureTestClient(":2479", caCertFile, clientCertFile, clientKeyFile)
+	v, err := cl.Get(testKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+	if string(v) != testValue1 {
+		t.Fatalf("expected %#v, received %#v", testValue1, string(v))
+	}
+}
+
+func TestManagerReloadTLS(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeTestingCerts(); err != nil {
+		t.Fatal(err)
+	}
+
+	caCertFile := "testdata/ca.crt"
+	caKeyFile := "testdata/ca.key"
+	clientCertFile := "testdata/client.crt"
+	clientKeyFile := "testdata/client.key"
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr: ":2379",
+		PeerAddr:   ":2380",
+		GossipAddr: ":7980",
+		ClientSecurity: client.SecurityConfig{
+			CertFile:      "testdata/server.crt",
+			KeyFile:       "testdata/server.key",
+			TrustedCAFile: caCertFile,
+		},
+		PeerSecurity: client.SecurityConfig{
+			CertFile:      "testdata/peer.crt",
+			KeyFile:       "testdata/peer.key",
+			TrustedCAFile: caCertFile,
+		},
+		CACertFile: caCertFile,
+		CAKeyFile:  caKeyFile,
+	})
+	c.start("node1")
+	c.wait("node1")
+	cl := newSecureTestClient(":2379", caCertFile, clientCertFile, clientKeyFile)
 	testKey1 := "testkey1"
 	testValue1 := "testvalue1"
 	if err := cl.Set(testKey1, testValue1); err != nil {
 		t.Fatal(err)
 	}
+
+	// with the ca key available, the server and peer certificates are
+	// regenerated from the same ca, which does not restart etcd, and the
+	// established client connection keeps working
+	node := c.lookupNode("node1")
+	srv := node.etcd.Server
+	old := presentedCert(t, "127.0.0.1:2379", caCertFile, clientCertFile, clientKeyFile)
+	if err := node.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+	if node.etcd.Server != srv {
+		t.Fatal("expected etcd server to not be restarted")
+	}
+	if _, err := cl.Get(testKey1); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+
+	// new connections are presented the regenerated certificate
+	cert := presentedCert(t, "127.0.0.1:2379", caCertFile, clientCertFile, clientKeyFile)
+	if bytes.Equal(cert.Raw, old.Raw) {
+		t.Fatal("expected the server to present a new certificate")
+	}
+	expectCertFile(t, cert, "testdata/server.crt")
+	cl = newSecureTestClient(":2379", caCertFile, clientCertFile, clientKeyFile)
+	v, err := cl.Get(testKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
 	cl.Close()
+	if string(v) != testValue1 {
+		t.Fatalf("expected %#v, received %#v", testValue1, string(v))
+	}
+
+	// rotating the ca regenerates the certificates from the new ca, and
+	// restarts etcd to trust it
 	if err := writeTestingCerts(); err != nil {
 		t.Fatal(err)
 	}
-	c.restart("node1", "node2", "node3")
-	cl = newSecureTestClient(":2479", caCertFile, clientCertFile, clientKeyFile)
-	v, err := cl.Get(testKey1)
+	if err := node.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+	c.wait("node1")
+	cert = presentedCert(t, "127.0.0.1:2379", caCertFile, clientCertFile, clientKeyFile)
+	expectCertFile(t, cert, "testdata/server.crt")
+	cl = newSecureTestClient(":2379", caCertFile, clientCertFile, clientKeyFile)
+	v, err = cl.Get(testKey1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1398,6 +2566,65 @@ func TestManagerServerRestartCertRenewal(t *testing.T) {
 	}
 }
 
+// presentedCert returns the certificate presented by the server at addr,
+// which must be signed by the CA in caCertFile.
+func presentedCert(t *testing.T, addr, caCertFile, clientCertFile, clientKeyFile string) *x509.Certificate {
+	t.Helper()
+	caCertPEM, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCertPEM) {
+		t.Fatalf("cannot parse ca cert: %#v", caCertFile)
+	}
+	kp, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{kp},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0]
+}
+
+// expectCertFile fails the test if the certificate is not the one in certFile.
+func expectCertFile(t *testing.T, cert *x509.Certificate, certFile string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("cannot decode cert: %#v", certFile)
+	}
+	if !bytes.Equal(cert.Raw, block.Bytes) {
+		t.Fatalf("expected the server to present the certificate in %#v, received serial %s", certFile, cert.SerialNumber)
+	}
+}
+
+func TestManagerReloadTLSNotRunning(t *testing.T) {
+	m, err := New(&Config{
+		Name:       "node1",
+		Dir:        filepath.Join("testdata", "node1"),
+		ClientAddr: ":2379",
+		PeerAddr:   ":2380",
+		GossipAddr: ":7980",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReloadTLS(); err == nil {
+		t.Fatal("expected error reloading certificates before etcd is started")
+	}
+}
+
 func TestManagerRollingUpdate(t *testing.T) {
 	if !*testLong {
 		t.Skip()
@@ -1480,3 +2707,385 @@ func TestManagerRollingUpdate(t *testing.T) {
 		t.Fatalf("expected %#v, received %#v", testValue1, string(v))
 	}
 }
+
+func TestManagerStepDown(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	for i, name := range []string{"node1", "node2", "node3"} {
+		c.addNode(name, &Config{
+			ClientAddr:          fmt.Sprintf(":%d", 2379+i*100),
+			PeerAddr:            fmt.Sprintf(":%d", 2380+i*100),
+			GossipAddr:          fmt.Sprintf(":%d", 7980+i),
+			BootstrapAddrs:      []string{":7980", ":7981", ":7982"},
+			RequiredClusterSize: 3,
+			HealthCheckInterval: 1 * time.Second,
+			HealthCheckTimeout:  5 * time.Second,
+		})
+	}
+	c.startAll()
+	c.wait("node1", "node2", "node3")
+
+	leader := c.leader()
+	if leader == nil {
+		t.Fatal("expected cluster to have a leader")
+	}
+
+	// writes are made through a follower, so that they are not interrupted
+	// by stopping the leader's client connections
+	var follower *Manager
+	for _, node := range c.nodes {
+		if node != leader {
+			follower = node
+			break
+		}
+	}
+	cl := newTestClient(follower.cfg.ClientURL.Host)
+	defer cl.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var writes int
+	var errs []error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ctx.Err() == nil; i++ {
+			wctx, wcancel := context.WithTimeout(ctx, 1*time.Second)
+			_, err := cl.Put(wctx, "stepdown", fmt.Sprint(i))
+			wcancel()
+			if err != nil && ctx.Err() == nil {
+				errs = append(errs, err)
+			}
+			writes++
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	if err := leader.StepDown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if leader.etcd.isLeader() {
+		t.Fatal("expected leadership to be transferred")
+	}
+	time.Sleep(500 * time.Millisecond)
+	leader.GracefulStop()
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	if len(errs) > 0 {
+		t.Fatalf("expected writes to continue during step-down, %d of %d writes failed: %v", len(errs), writes, errs)
+	}
+	if l := c.leader(); l == nil || l == leader {
+		t.Fatal("expected a follower to become the leader")
+	}
+}
+
+func TestManagerHardStopTimeout(t *testing.T) {
+	m, err := New(&Config{
+		Name:        "node1",
+		Dir:         filepath.Join("testdata", "node1"),
+		ClientAddr:  ":2379",
+		PeerAddr:    ":2380",
+		GossipAddr:  ":7980",
+		StopTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a zero value etcd server is never started, so it will never signal
+	// StopNotify, simulating a wedged server
+	m.etcd.Etcd = &embed.Etcd{Server: &etcdserver.EtcdServer{}}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.HardStop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for HardStop to return")
+	}
+}
+
+func TestCheckDataDirWithoutSnapshotBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "e2d-datadir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	walDir := filepath.Join(dir, "node1", "member", "wal")
+	if err := os.MkdirAll(walDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(walDir, "0000000000000000-0000000000000000.wal"), bytes.Repeat([]byte{0xff}, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(&Config{
+		Name:                "node1",
+		Dir:                 filepath.Join(dir, "node1"),
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		RequiredClusterSize: 1,
+		CheckDataDir:        true,
+		Snapshotter:         newFileSnapshotter(filepath.Join(dir, "snapshots")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.checkDataDir(); errors.Cause(err) != errDataDirCorrupt {
+		t.Fatalf("expected errDataDirCorrupt, received %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "node1")); err != nil {
+		t.Fatalf("expected data-dir to be left in place: %v", err)
+	}
+}
+
+func TestNextSnapshotBackoff(t *testing.T) {
+	var backoff time.Duration
+	got := make([]time.Duration, 0)
+	for i := 0; i < 6; i++ {
+		backoff = nextSnapshotBackoff(backoff, 10*time.Second)
+		got = append(got, backoff)
+	}
+	expected := []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("nextSnapshotBackoff: differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestSelectSnapshotTaker(t *testing.T) {
+	zone := func(name, zone string) *Member {
+		return &Member{Name: name, Status: Running, Tags: map[string]string{ZoneTag: zone}}
+	}
+	members := []*Member{
+		zone("node3", "us-east-1b"),
+		zone("node1", "us-east-1a"),
+		zone("node4", "us-east-1b"),
+		zone("node2", "us-east-1b"),
+		{Name: "node0", Status: Running},
+	}
+	cases := []struct {
+		zone     string
+		expected string
+	}{
+		{"us-east-1a", "node1"},
+		{"us-east-1b", "node2"},
+		{"us-east-1c", ""},
+	}
+	for _, tc := range cases {
+		if name := selectSnapshotTaker(members, tc.zone); name != tc.expected {
+			t.Errorf("selectSnapshotTaker(%#v) = %#v, want %#v", tc.zone, name, tc.expected)
+		}
+	}
+}
+
+func TestManagerIsSnapshotTaker(t *testing.T) {
+	newManager := func(name string) *Manager {
+		m := &Manager{
+			cfg: &Config{
+				Name:                  name,
+				SnapshotPreferredZone: "us-east-1b",
+			},
+			gossip: newGossip(&gossipConfig{Name: name}),
+			etcd:   newServer(&serverConfig{}),
+		}
+		m.gossip.m = newFakeMemberlist(
+			&Member{Name: "node1", Status: Running, Tags: map[string]string{ZoneTag: "us-east-1a"}},
+			&Member{Name: "node2", Status: Pending, Tags: map[string]string{ZoneTag: "us-east-1b"}},
+			&Member{Name: "node3", Status: Running, Tags: map[string]string{ZoneTag: "us-east-1b"}},
+		)
+		atomic.StoreUint64(&m.etcd.started, 1)
+		return m
+	}
+
+	// the member in the preferred zone creates the snapshot backups, rather
+	// than the leader, and members that are not yet running are not selected
+	for name, expected := range map[string]bool{"node1": false, "node2": false, "node3": true} {
+		if got := newManager(name).isSnapshotTaker(); got != expected {
+			t.Errorf("%s: isSnapshotTaker() = %v, want %v", name, got, expected)
+		}
+	}
+
+	// a member that is not running never creates snapshot backups
+	m := newManager("node3")
+	atomic.StoreUint64(&m.etcd.started, 0)
+	if m.isSnapshotTaker() {
+		t.Fatal("expected a member that is not running to not create snapshot backups")
+	}
+}
+
+func TestWithZoneTag(t *testing.T) {
+	tags := map[string]string{"role": "control-plane", ZoneTag: "us-east-1a"}
+	expected := map[string]string{"role": "control-plane", ZoneTag: "us-east-1b"}
+	if diff := cmp.Diff(expected, withZoneTag(tags, "us-east-1b")); diff != "" {
+		t.Errorf("withZoneTag: differs: (-want +got)\n%s", diff)
+	}
+
+	// the provided tags are not changed
+	if tags[ZoneTag] != "us-east-1a" {
+		t.Fatalf("expected tags to not be changed, received %v", tags)
+	}
+	if diff := cmp.Diff(tags, withZoneTag(tags, "")); diff != "" {
+		t.Errorf("withZoneTag: differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	newConfig := func() *Config {
+		return &Config{
+			ClientAddr:          ":2379",
+			PeerAddr:            ":2380",
+			GossipAddr:          ":7980",
+			BootstrapAddrs:      []string{":7981"},
+			RequiredClusterSize: 1,
+			SnapshotInterval:    1 * time.Hour,
+			Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+		}
+	}
+	c.addNode("node1", newConfig())
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	if err := cl.Set("testkey1", "testvalue1"); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+	c.saveSnapshot("node1")
+	c.stop("node1")
+
+	cfg := newConfig()
+	cfg.Name = "node2"
+	cfg.Dir = "testdata/node1"
+	if err := RestoreSnapshot(cfg); err == nil {
+		t.Fatal("expected error restoring into an existing data-dir")
+	}
+	cfg = newConfig()
+	cfg.Name = "node2"
+	cfg.Dir = "testdata/node2"
+	if err := RestoreSnapshot(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// the restored data-dir is started like any existing data-dir, without a
+	// snapshot backup to restore from
+	cfg = newConfig()
+	cfg.Snapshotter = nil
+	c.addNode("node2", cfg)
+	c.start("node2")
+	c.wait("node2")
+	cl = newTestClient(":2379")
+	defer cl.Close()
+	v, err := cl.Get("testkey1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "testvalue1" {
+		t.Fatalf("expected %#v, received %#v", "testvalue1", string(v))
+	}
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+		SnapshotInterval:    1 * time.Hour,
+	})
+	c.startAll()
+	c.wait("node1")
+	cl := newTestClient(":2379")
+	if err := cl.Set("testkey1", "testvalue1"); err != nil {
+		t.Fatal(err)
+	}
+	cl.Close()
+
+	rev, err := SaveSnapshot(&Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		SnapshotCompression: true,
+		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev < 2 {
+		t.Fatalf("expected revision of at least 2, received %d", rev)
+	}
+	c.stop("node1")
+
+	if err := RestoreSnapshot(&Config{
+		Name:                "node2",
+		Dir:                 "testdata/node2",
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		RequiredClusterSize: 1,
+		Snapshotter:         newFileSnapshotter("testdata/snapshots"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.addNode("node2", &Config{
+		ClientAddr:          ":2379",
+		PeerAddr:            ":2380",
+		GossipAddr:          ":7980",
+		BootstrapAddrs:      []string{":7981"},
+		RequiredClusterSize: 1,
+		SnapshotInterval:    1 * time.Hour,
+	})
+	c.start("node2")
+	c.wait("node2")
+	cl = newTestClient(":2379")
+	defer cl.Close()
+	v, err := cl.Get("testkey1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "testvalue1" {
+		t.Fatalf("expected %#v, received %#v", "testvalue1", string(v))
+	}
+}
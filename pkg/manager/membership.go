@@ -2,6 +2,7 @@ package manager
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -9,7 +10,7 @@ import (
 	"go.uber.org/zap"
 )
 
-type removerFunc func(string) error
+type removerFunc func(name, reason string) error
 
 type clusterMembership struct {
 	timeout time.Duration
@@ -39,7 +40,7 @@ func newClusterMembership(ctx context.Context, d time.Duration, fn removerFunc)
 					if t.Add(c.timeout).After(time.Now()) {
 						continue
 					}
-					if err := c.removeMember(name); err != nil {
+					if err := c.removeMember(name, fmt.Sprintf("unreachable for longer than %v", c.timeout)); err != nil {
 						log.Debug("cannot remove member", zap.Error(err))
 					}
 				}
@@ -63,14 +64,14 @@ func (c *clusterMembership) removeSuspect(name string) {
 	c.mu.Unlock()
 }
 
-func (c *clusterMembership) removeMember(name string) error {
+func (c *clusterMembership) removeMember(name, reason string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if !c.hasQuorum {
 		return nil
 	}
-	if err := c.fn(name); err != nil {
+	if err := c.fn(name, reason); err != nil {
 		return err
 	}
 	delete(c.suspects, name)
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+var (
+	snapshotSizeBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "e2d",
+		Subsystem: "snapshot",
+		Name:      "size_bytes",
+		Help:      "The size of the most recent snapshot saved to the backup.",
+	})
+
+	snapshotSaveDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "e2d",
+		Subsystem: "snapshot",
+		Name:      "save_duration_seconds",
+		Help:      "The time taken to create and upload a snapshot to the backup.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+	})
+
+	snapshotUploadedBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "e2d",
+		Subsystem: "snapshot",
+		Name:      "uploaded_bytes",
+		Help:      "The number of bytes of the current snapshot uploaded to the backup so far.",
+	})
+
+	snapshotFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "e2d",
+		Subsystem: "snapshot",
+		Name:      "failures_total",
+		Help:      "The total number of failed snapshot backups by reason.",
+	}, []string{"reason"})
+
+	snapshotSaveTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "e2d",
+		Subsystem: "snapshot",
+		Name:      "save_total",
+		Help:      "The total number of snapshot backups by result {success,failure}, not including those skipped for an unchanged revision.",
+	}, []string{"result"})
+
+	snapshotLastSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "e2d",
+		Subsystem: "snapshot",
+		Name:      "last_success_timestamp",
+		Help:      "The time the most recent snapshot was saved to the backup, as a unix timestamp in seconds.",
+	})
+)
+
+func init() {
+	// etcd serves the default registry at /metrics on the client port
+	prometheus.MustRegister(snapshotSizeBytes)
+	prometheus.MustRegister(snapshotSaveDurationSeconds)
+	prometheus.MustRegister(snapshotUploadedBytes)
+	prometheus.MustRegister(snapshotFailuresTotal)
+	prometheus.MustRegister(snapshotSaveTotal)
+	prometheus.MustRegister(snapshotLastSuccessTimestamp)
+}
+
+var (
+	gossipMembersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("e2d", "gossip", "members"),
+		"The number of members of the gossip network, including this member.",
+		nil, nil,
+	)
+
+	gossipPendingMembersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("e2d", "gossip", "pending_members"),
+		"The number of members of the gossip network waiting to start or join the etcd cluster.",
+		nil, nil,
+	)
+
+	snapshotRestoresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("e2d", "snapshot", "restores_total"),
+		"The total number of times this member restored the cluster from a snapshot backup.",
+		nil, nil,
+	)
+)
+
+// collector exports the state of a Manager, which is read when the metrics
+// are scraped rather than updated as it changes.
+type collector struct {
+	m *Manager
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- gossipMembersDesc
+	ch <- gossipPendingMembersDesc
+	ch <- snapshotRestoresDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	members := c.m.gossip.Members()
+	pending := 0
+	for _, member := range members {
+		if member.Status == Pending {
+			pending++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(gossipMembersDesc, prometheus.GaugeValue, float64(len(members)))
+	ch <- prometheus.MustNewConstMetric(gossipPendingMembersDesc, prometheus.GaugeValue, float64(pending))
+	ch <- prometheus.MustNewConstMetric(snapshotRestoresDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.m.restores)))
+}
+
+// registerCollector registers the collector of the Manager with the default
+// registry, which etcd serves at /metrics on the client and metrics
+// addresses. It is only registered when MetricsAddr is set, and only one
+// Manager in a process can be registered at a time.
+func (m *Manager) registerCollector() {
+	if m.cfg.MetricsAddr == "" || m.collector != nil {
+		return
+	}
+	c := &collector{m: m}
+	if err := prometheus.Register(c); err != nil {
+		log.Warn("cannot register metrics collector", zap.Error(err))
+		return
+	}
+	m.collector = c
+}
+
+func (m *Manager) unregisterCollector() {
+	if m.collector == nil {
+		return
+	}
+	prometheus.Unregister(m.collector)
+	m.collector = nil
+}
@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/criticalstack/e2d/pkg/pki"
+)
+
+// how often the expiry of the certificates is checked
+const certRenewCheckInterval = 1 * time.Hour
+
+// runCertRenewer periodically renews the server and peer certificates that
+// are within CertRenewBefore of expiring.
+func (m *Manager) runCertRenewer() {
+	if m.cfg.CertRenewBefore <= 0 {
+		return
+	}
+	log.Debug("starting certificate renewer")
+	ticker := time.NewTicker(certRenewCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.renewCerts(); err != nil {
+			log.Error("cannot renew certificates", zap.Error(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-m.ctx.Done():
+			log.Debug("stopping certificate renewer")
+			return
+		}
+	}
+}
+
+// renewCerts renews the server and peer certificates that expire within
+// CertRenewBefore, writing the new key pairs in place of the old ones, and
+// then reloads them.
+func (m *Manager) renewCerts() error {
+	m.tlsMu.Lock()
+	defer m.tlsMu.Unlock()
+
+	var r *pki.RootCA
+	renewed := false
+	for _, c := range m.leafCerts() {
+		certPEM, err := ioutil.ReadFile(c.certFile)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read cert: %#v", c.certFile)
+		}
+		expiry, err := pki.CertExpiry(certPEM)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read cert: %#v", c.certFile)
+		}
+		if time.Until(expiry) > m.cfg.CertRenewBefore {
+			continue
+		}
+		if r == nil {
+			r, err = m.loadCertificateAuthority()
+			if err != nil {
+				return err
+			}
+			if r == nil {
+				return errors.New("cannot renew certificates without the ca key")
+			}
+		}
+		if err := m.renewCert(r, c); err != nil {
+			return err
+		}
+		renewed = true
+	}
+	// etcd reads the certificates when it starts, so they only need to be
+	// reloaded while it is running
+	if !renewed || !m.etcd.isRunning() {
+		return nil
+	}
+	return m.reloadTLS()
+}
+
+// renewCert signs a new key pair for the certificate with the CA, keeping the
+// hosts of the certificate, and writes it in place of the old one.
+func (m *Manager) renewCert(r *pki.RootCA, c leafCert) error {
+	certPEM, err := ioutil.ReadFile(c.certFile)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read cert: %#v", c.certFile)
+	}
+	keyPEM, err := ioutil.ReadFile(c.keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read key: %#v", c.keyFile)
+	}
+	kp, err := pki.NewKeyPairFromPEM(certPEM, keyPEM)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load key pair: %#v", c.certFile)
+	}
+	newKP, err := r.RenewCertificates(c.profile, kp)
+	if err != nil {
+		return errors.Wrapf(err, "cannot renew cert: %#v", c.certFile)
+	}
+
+	// the key is written first, so that a mismatched key pair is only
+	// possible until the cert is written
+	if err := writeFileAtomic(c.keyFile, newKP.KeyPEM, 0600); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.certFile, newKP.CertPEM, 0644); err != nil {
+		return err
+	}
+	log.Info("renewed certificate",
+		zap.String("name", shortName(m.cfg.Name)),
+		zap.String("cert", c.certFile),
+		zap.Time("expiry", kp.Cert.NotAfter),
+		zap.Time("new-expiry", newKP.Cert.NotAfter),
+	)
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory,
+// which then replaces the named file, so that readers never see a partially
+// written file.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.Wrapf(os.Rename(f.Name(), name), "cannot write file: %#v", name)
+}
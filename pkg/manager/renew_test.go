@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/csr"
+
+	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/pki"
+)
+
+func TestManagerRenewCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "renew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := pki.NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCertFile := filepath.Join(dir, "ca.crt")
+	if err := writeFile(caCertFile, r.CA.CertPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	kp, err := r.GenerateCertificates(pki.ServerSigningProfile, &csr.CertificateRequest{
+		KeyRequest: &csr.KeyRequest{A: "ecdsa", S: 256},
+		Hosts:      []string{"127.0.0.1", "etcd.example.com"},
+		CN:         "etcd server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	if err := writeFile(certFile, kp.CertPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(keyFile, kp.KeyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		Dir:        dir,
+		ClientAddr: "127.0.0.1:2379",
+		PeerAddr:   "127.0.0.1:2380",
+		GossipAddr: "127.0.0.1:7980",
+		ClientSecurity: client.SecurityConfig{
+			CertFile:      certFile,
+			KeyFile:       keyFile,
+			TrustedCAFile: caCertFile,
+		},
+		CACertFile: caCertFile,
+		CAKey:      r.CA.KeyPEM,
+
+		// the certificate expires in 5 years, so it is not yet renewed
+		CertRenewBefore: 4 * 365 * 24 * time.Hour,
+	}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.renewCerts(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry, err := pki.CertExpiry(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(kp.Cert.NotAfter) {
+		t.Fatalf("expected certificate not to be renewed, expiry %v, received %v", kp.Cert.NotAfter, expiry)
+	}
+
+	// the certificate is within the window once it is longer than the
+	// remaining validity
+	m.cfg.CertRenewBefore = 6 * 365 * 24 * time.Hour
+	if err := m.renewCerts(); err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := pki.NewKeyPairFromPEM(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.Cert.SerialNumber.Cmp(kp.Cert.SerialNumber) == 0 {
+		t.Fatal("expected certificate to be renewed")
+	}
+	if err := renewed.Cert.CheckSignatureFrom(r.CA.Cert); err != nil {
+		t.Fatal(err)
+	}
+	if err := renewed.Cert.VerifyHostname("etcd.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := renewed.Cert.VerifyHostname("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if renewed.Cert.Subject.CommonName != "etcd server" {
+		t.Fatalf("expected common name %#v, received %#v", "etcd server", renewed.Cert.Subject.CommonName)
+	}
+	if renewed.Cert.PublicKeyAlgorithm != kp.Cert.PublicKeyAlgorithm {
+		t.Fatalf("expected public key algorithm %v, received %v", kp.Cert.PublicKeyAlgorithm, renewed.Cert.PublicKeyAlgorithm)
+	}
+}
+
+func TestConfigCertRenewBefore(t *testing.T) {
+	cfg := &Config{
+		ClientAddr:      "0.0.0.0:2379",
+		PeerAddr:        "0.0.0.0:2380",
+		GossipAddr:      "0.0.0.0:7980",
+		CertRenewBefore: 30 * 24 * time.Hour,
+	}
+	if err := cfg.validate(); err == nil {
+		t.Fatal("expected error renewing certificates without a ca")
+	}
+}
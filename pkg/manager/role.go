@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Role is the raft role of a member of the etcd cluster. The values match
+// e2dpb.Role, so that they can be converted for the gRPC service.
+type Role int
+
+const (
+	RoleUnknown Role = iota
+	RoleLeader
+	RoleFollower
+	RoleLearner
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleUnknown:
+		return "Unknown"
+	case RoleLeader:
+		return "Leader"
+	case RoleFollower:
+		return "Follower"
+	case RoleLearner:
+		return "Learner"
+	}
+	return ""
+}
+
+// Role returns the current raft role of this member, which is RoleUnknown
+// while the cluster has no leader. An error is returned while the etcd server
+// is not running.
+func (m *Manager) Role() (Role, error) {
+	if !m.etcd.isRunning() || m.etcd.isRestarting() {
+		return RoleUnknown, errors.New("etcd server is not running")
+	}
+	return m.etcd.role(), nil
+}
+
+// role returns the raft role of the running etcd server.
+func (s *server) role() Role {
+	if s.Etcd.Server.IsLearner() {
+		return RoleLearner
+	}
+	switch s.Etcd.Server.Leader() {
+	case 0:
+		return RoleUnknown
+	case s.Etcd.Server.ID():
+		return RoleLeader
+	default:
+		return RoleFollower
+	}
+}
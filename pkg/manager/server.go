@@ -48,6 +48,13 @@ type serverConfig struct {
 	// address used for traffic within the cluster
 	PeerURL url.URL
 
+	// client endpoint advertised to clients and the other members, defaults
+	// to ClientURL
+	AdvertiseClientURL url.URL
+
+	// peer address advertised to the other members, defaults to PeerURL
+	AdvertisePeerURL url.URL
+
 	// the required number of nodes that must be present to start a cluster
 	RequiredClusterSize int
 
@@ -57,12 +64,22 @@ type serverConfig struct {
 	// configures authentication/transport security within the etcd cluster
 	PeerSecurity client.SecurityConfig
 
+	// optional url used to serve /metrics and /health over plain http
+	MetricsURL url.URL
+
 	// add a local client listener (i.e. 127.0.0.1)
 	EnableLocalListener bool
 
+	// size limit of the etcd backend in bytes, which raises a NOSPACE alarm
+	// when exceeded, uses the etcd default when unset
+	QuotaBackendBytes int64
+
 	// configures the level of the logger used by etcd
 	EtcdLogLevel zapcore.Level
 
+	// destination for the etcd log output
+	EtcdLogOutput io.Writer
+
 	ServiceRegister func(*grpc.Server)
 
 	Debug bool
@@ -105,7 +122,14 @@ func (s *server) restart(ctx context.Context, peers []*Peer) error {
 	defer atomic.StoreUint64(&s.restarting, 0)
 
 	s.hardStop()
-	return s.startEtcd(ctx, embed.ClusterStateFlagNew, peers)
+	return s.startEtcd(ctx, embed.ClusterStateFlagNew, peers, notGrowing, nil)
+}
+
+// promote starts an existing single-node cluster so that it can grow to the
+// configured RequiredClusterSize. The stored cluster-info is updated with the
+// new size, allowing new members to join.
+func (s *server) promote(ctx context.Context, self *Peer) error {
+	return s.startEtcd(ctx, embed.ClusterStateFlagNew, []*Peer{self}, promoting, nil)
 }
 
 func (s *server) hardStop() {
@@ -187,8 +211,34 @@ func validatePeers(peers []*Peer, requiredClusterSize int) error {
 	return nil
 }
 
-func (s *server) startEtcd(ctx context.Context, state string, peers []*Peer) error {
-	if err := validatePeers(peers, s.cfg.RequiredClusterSize); err != nil {
+// growthState describes the part a server plays in growing an existing
+// single-node cluster into a multi-node cluster.
+type growthState int
+
+const (
+	notGrowing growthState = iota
+
+	// promoting is the existing single-node member being grown
+	promoting
+
+	// joiningGrowth is a new member joining a cluster that is being grown,
+	// which can have fewer members than the RequiredClusterSize
+	joiningGrowth
+)
+
+// startEtcd starts the etcd server and waits for it to be ready. A member that
+// joined as a learner is promoted with promoteLearner before it is considered
+// ready, since a learner cannot serve writes, such as writing the
+// cluster-info.
+func (s *server) startEtcd(ctx context.Context, state string, peers []*Peer, growth growthState, promoteLearner func(context.Context) error) error {
+	// While a single-node cluster is being grown, the peers are the current
+	// members of that cluster, which can be fewer than the
+	// RequiredClusterSize.
+	requiredPeers := s.cfg.RequiredClusterSize
+	if growth != notGrowing {
+		requiredPeers = 1
+	}
+	if err := validatePeers(peers, requiredPeers); err != nil {
 		return err
 	}
 
@@ -212,18 +262,19 @@ func (s *server) startEtcd(ctx context.Context, state string, peers []*Peer) err
 	cfg.Logger = "zap"
 	cfg.Debug = s.cfg.Debug
 	cfg.ZapLoggerBuilder = func(c *embed.Config) error {
-		l := log.NewLoggerWithLevel("etcd", s.cfg.EtcdLogLevel)
-		return embed.NewZapCoreLoggerBuilder(l, l.Core(), zapcore.AddSync(os.Stderr))(c)
+		var w io.Writer = os.Stderr
+		if s.cfg.EtcdLogOutput != nil {
+			w = s.cfg.EtcdLogOutput
+		}
+		l := log.NewLoggerWithOutput("etcd", s.cfg.EtcdLogLevel, w)
+		return embed.NewZapCoreLoggerBuilder(l, l.Core(), zapcore.AddSync(w))(c)
 	}
 	cfg.AutoCompactionMode = embed.CompactorModePeriodic
-	cfg.LPUrls = []url.URL{s.cfg.PeerURL}
-	cfg.APUrls = []url.URL{s.cfg.PeerURL}
-	cfg.LCUrls = []url.URL{s.cfg.ClientURL}
-	if s.cfg.EnableLocalListener {
-		_, port, _ := netutil.SplitHostPort(s.cfg.ClientURL.Host)
-		cfg.LCUrls = append(cfg.LCUrls, url.URL{Scheme: s.cfg.ClientSecurity.Scheme(), Host: fmt.Sprintf("127.0.0.1:%d", port)})
+	cfg.QuotaBackendBytes = s.cfg.QuotaBackendBytes
+	s.setURLs(cfg)
+	if s.cfg.MetricsURL.Host != "" {
+		cfg.ListenMetricsUrls = []url.URL{s.cfg.MetricsURL}
 	}
-	cfg.ACUrls = []url.URL{s.cfg.ClientURL}
 	cfg.ClientAutoTLS = s.cfg.ClientSecurity.AutoTLS
 	cfg.PeerAutoTLS = s.cfg.PeerSecurity.AutoTLS
 	if s.cfg.ClientSecurity.Enabled() {
@@ -256,7 +307,14 @@ func (s *server) startEtcd(ctx context.Context, state string, peers []*Peer) err
 	}
 	select {
 	case <-s.Server.ReadyNotify():
-		if err := s.writeClusterInfo(ctx); err != nil {
+		if promoteLearner != nil {
+			if err := promoteLearner(ctx); err != nil {
+				s.Server.Stop()
+				return errors.Wrap(err, "cannot promote learner")
+			}
+			log.Info("learner promoted to voting member", zap.String("name", s.cfg.Name))
+		}
+		if err := s.writeClusterInfo(ctx, growth == promoting); err != nil {
 			return errors.Wrap(err, "cannot write cluster-info")
 		}
 		log.Debug("write cluster-info successful!")
@@ -277,12 +335,51 @@ func (s *server) startEtcd(ctx context.Context, state string, peers []*Peer) err
 	}
 }
 
+// setURLs sets the listen and advertise URLs of the etcd config. The
+// advertised URLs are what the other members and clients use to reach this
+// member, so can differ from the listen URLs when the member is behind NAT or
+// a load balancer.
+func (s *server) setURLs(cfg *embed.Config) {
+	cfg.LPUrls = []url.URL{s.cfg.PeerURL}
+	cfg.APUrls = []url.URL{s.advertisePeerURL()}
+	cfg.LCUrls = []url.URL{s.cfg.ClientURL}
+	if s.cfg.EnableLocalListener {
+		host, port, _ := netutil.SplitHostPort(s.cfg.ClientURL.Host)
+		loopback := "127.0.0.1"
+		if netutil.IsIPv6(host) {
+			loopback = "::1"
+		}
+		cfg.LCUrls = append(cfg.LCUrls, url.URL{Scheme: s.cfg.ClientSecurity.Scheme(), Host: netutil.JoinHostPort(loopback, port)})
+	}
+	cfg.ACUrls = []url.URL{s.advertiseClientURL()}
+}
+
+func (s *server) advertiseClientURL() url.URL {
+	if s.cfg.AdvertiseClientURL.Host == "" {
+		return s.cfg.ClientURL
+	}
+	return s.cfg.AdvertiseClientURL
+}
+
+func (s *server) advertisePeerURL() url.URL {
+	if s.cfg.AdvertisePeerURL.Host == "" {
+		return s.cfg.PeerURL
+	}
+	return s.cfg.AdvertisePeerURL
+}
+
 func (s *server) startNew(ctx context.Context, peers []*Peer) error {
-	return s.startEtcd(ctx, embed.ClusterStateFlagNew, peers)
+	return s.startEtcd(ctx, embed.ClusterStateFlagNew, peers, notGrowing, nil)
 }
 
-func (s *server) joinExisting(ctx context.Context, peers []*Peer) error {
-	return s.startEtcd(ctx, embed.ClusterStateFlagExisting, peers)
+func (s *server) joinExisting(ctx context.Context, peers []*Peer, promoteLearner func(context.Context) error) error {
+	return s.startEtcd(ctx, embed.ClusterStateFlagExisting, peers, notGrowing, promoteLearner)
+}
+
+// joinGrowing joins an existing cluster that is being grown from a single-node
+// cluster, and may not yet have RequiredClusterSize members.
+func (s *server) joinGrowing(ctx context.Context, peers []*Peer, promoteLearner func(context.Context) error) error {
+	return s.startEtcd(ctx, embed.ClusterStateFlagExisting, peers, joiningGrowth, promoteLearner)
 }
 
 func newSnapshotReadCloser(snapshot backend.Snapshot) io.ReadCloser {
@@ -298,15 +395,35 @@ func newSnapshotReadCloser(snapshot backend.Snapshot) io.ReadCloser {
 	return pr
 }
 
+var errRevisionTooOld = errors.New("member revision too old")
+
+// ErrBackendSnapshotUnavailable is returned when the etcd backend cannot
+// provide a snapshot, which is expected while the server is restarting or not
+// yet ready.
+var ErrBackendSnapshotUnavailable = errors.New("backend snapshot unavailable")
+
+// backendSnapshot creates a snapshot of the provided etcd backend, returning
+// ErrBackendSnapshotUnavailable if either the backend or snapshot is missing.
+func backendSnapshot(be backend.Backend) (backend.Snapshot, error) {
+	if be == nil {
+		return nil, errors.Wrap(ErrBackendSnapshotUnavailable, "backend not initialized")
+	}
+	sp := be.Snapshot()
+	if sp == nil {
+		return nil, errors.Wrap(ErrBackendSnapshotUnavailable, "backend returned nil snapshot")
+	}
+	return sp, nil
+}
+
 func (s *server) createSnapshot(minRevision int64) (io.ReadCloser, int64, int64, error) {
 	// Get the current revision and compare with the minimum requested revision.
 	revision := s.Etcd.Server.KV().Rev()
 	if revision <= minRevision {
-		return nil, 0, revision, errors.Errorf("member revision too old, wanted %d, received: %d", minRevision, revision)
+		return nil, 0, revision, errors.Wrapf(errRevisionTooOld, "wanted %d, received: %d", minRevision, revision)
 	}
-	sp := s.Etcd.Server.Backend().Snapshot()
-	if sp == nil {
-		return nil, 0, revision, errors.New("no snappy")
+	sp, err := backendSnapshot(s.Etcd.Server.Backend())
+	if err != nil {
+		return nil, 0, revision, err
 	}
 	return newSnapshotReadCloser(sp), sp.Size(), revision, nil
 }
@@ -315,6 +432,7 @@ func (s *server) restoreSnapshot(snapshotFilename string, peers []*Peer) error {
 	if err := validatePeers(peers, s.cfg.RequiredClusterSize); err != nil {
 		return err
 	}
+	peerURL := s.advertisePeerURL()
 	snapshotMgr := snapshot.NewV3(nil)
 	return snapshotMgr.Restore(snapshot.RestoreConfig{
 		// SnapshotPath is the path of snapshot file to restore from.
@@ -331,7 +449,7 @@ func (s *server) restoreSnapshot(snapshotFilename string, peers []*Peer) error {
 		OutputDataDir: s.cfg.Dir,
 
 		// PeerURLs is a list of member's peer URLs to advertise to the rest of the cluster.
-		PeerURLs: []string{s.cfg.PeerURL.String()},
+		PeerURLs: []string{peerURL.String()},
 
 		// InitialCluster is the initial cluster configuration for restore bootstrap.
 		InitialCluster: initialClusterStringFromPeers(peers),
@@ -389,8 +507,9 @@ type Cluster struct {
 // starts or joins a new cluster. The e2db namespace matches the volatile
 // prefix so that this information will not survive being restored from
 // snapshot. This is because the cluster requirements could change for the
-// restored cluster (e.g. going from RequiredClusterSize 1 -> 3).
-func (s *server) writeClusterInfo(ctx context.Context) error {
+// restored cluster (e.g. going from RequiredClusterSize 1 -> 3). When promote
+// is set, a single-node cluster is updated to the configured size.
+func (s *server) writeClusterInfo(ctx context.Context, promote bool) error {
 	// NOTE(chrism): As the naming can be confusing it is worth pointing out
 	// that the ClientSecurity field is specifying the server certs and NOT the
 	// client certs. Since the server certs do not have client auth key usage,
@@ -401,6 +520,10 @@ func (s *server) writeClusterInfo(ctx context.Context) error {
 		CertFile:   s.cfg.PeerSecurity.CertFile,
 		KeyFile:    s.cfg.PeerSecurity.KeyFile,
 		Namespace:  string(volatilePrefix),
+
+		// the cluster may still be electing a leader when a member has just
+		// started
+		NoLeaderRetryTimeout: 10 * time.Second,
 	})
 	if err != nil {
 		return err
@@ -414,6 +537,17 @@ func (s *server) writeClusterInfo(ctx context.Context) error {
 		}
 
 		if cluster != nil {
+			// a single-node cluster is the only cluster that can be grown,
+			// since it can safely add members without losing quorum
+			if promote && cluster.RequiredClusterSize == 1 && s.cfg.RequiredClusterSize > 1 {
+				log.Info("promoting single-node cluster",
+					zap.String("name", s.cfg.Name),
+					zap.Int("required-cluster-size", s.cfg.RequiredClusterSize),
+				)
+				cluster.RequiredClusterSize = s.cfg.RequiredClusterSize
+				return tx.Update(cluster)
+			}
+
 			// check RequiredClusterSize for discrepancies
 			if cluster.RequiredClusterSize != s.cfg.RequiredClusterSize {
 				return errors.Errorf("server %s attempted to join cluster with incorrect RequiredClusterSize, cluster expects %d, this server is configured with %d", s.cfg.Name, cluster.RequiredClusterSize, s.cfg.RequiredClusterSize)
@@ -437,11 +571,18 @@ var (
 	// snapshotMarkerKey is the key used to indicate when a cluster recovered
 	// from snapshot
 	snapshotMarkerKey = []byte("/_e2d/snapshot")
+
+	// growthMarkerKey is the key used to indicate that a single-node cluster
+	// is being grown, allowing new members to join before the cluster has
+	// reached its RequiredClusterSize
+	growthMarkerKey = []byte("/_e2d/growth")
 )
 
 var errServerStopped = errors.New("server stopped")
 
-func (s *server) clearVolatilePrefix() (rev, deleted int64, err error) {
+// clearVolatilePrefix deletes all keys within the volatile prefix, except for
+// those matching one of the preserve prefixes.
+func (s *server) clearVolatilePrefix(preserve []string) (rev, deleted int64, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -453,7 +594,7 @@ func (s *server) clearVolatilePrefix() (rev, deleted int64, err error) {
 		return 0, 0, err
 	}
 	for _, kv := range res.KVs {
-		if bytes.HasPrefix(kv.Key, volatilePrefix) {
+		if bytes.HasPrefix(kv.Key, volatilePrefix) && !hasAnyPrefix(kv.Key, preserve) {
 			n, _ := s.Server.KV().DeleteRange(kv.Key, nil)
 			deleted += n
 		}
@@ -461,6 +602,15 @@ func (s *server) clearVolatilePrefix() (rev, deleted int64, err error) {
 	return res.Rev, deleted, nil
 }
 
+func hasAnyPrefix(key []byte, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *server) placeSnapshotMarker(v []byte) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
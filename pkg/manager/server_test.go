@@ -0,0 +1,132 @@
+package manager
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/mvcc/backend"
+)
+
+// nilSnapshotBackend is an etcd backend that is unable to provide snapshots.
+type nilSnapshotBackend struct {
+	backend.Backend
+}
+
+func (nilSnapshotBackend) Snapshot() backend.Snapshot { return nil }
+
+func TestServerBackendSnapshotUnavailable(t *testing.T) {
+	cases := []struct {
+		name string
+		be   backend.Backend
+	}{
+		{name: "nil backend", be: nil},
+		{name: "nil snapshot", be: nilSnapshotBackend{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sp, err := backendSnapshot(tc.be)
+			if errors.Cause(err) != ErrBackendSnapshotUnavailable {
+				t.Fatalf("expected ErrBackendSnapshotUnavailable, received %v", err)
+			}
+			if sp != nil {
+				t.Fatalf("expected nil snapshot, received %v", sp)
+			}
+		})
+	}
+}
+
+func TestServerStartEtcdValidatesPeers(t *testing.T) {
+	s := newServer(&serverConfig{Name: "node2", RequiredClusterSize: 3})
+	peers := []*Peer{{"node1", "http://127.0.0.1:2380"}, {"node2", "http://127.0.0.1:2480"}}
+
+	// joining a cluster with fewer peers than required is only allowed while
+	// the cluster is being grown
+	err := s.startEtcd(context.Background(), embed.ClusterStateFlagExisting, peers, notGrowing, nil)
+	if err == nil || !strings.Contains(err.Error(), "expected 3 members") {
+		t.Fatalf("expected peer validation error, received %v", err)
+	}
+}
+
+func TestServerSetURLs(t *testing.T) {
+	clientURL := url.URL{Scheme: "http", Host: "10.0.0.10:2379"}
+	peerURL := url.URL{Scheme: "http", Host: "10.0.0.10:2380"}
+	localURL := url.URL{Scheme: "http", Host: "127.0.0.1:2379"}
+	cases := []struct {
+		name                   string
+		cfg                    *serverConfig
+		lpurls, apurls, lcurls []url.URL
+		acurls                 []url.URL
+	}{
+		{
+			name:   "defaults to listen urls",
+			cfg:    &serverConfig{ClientURL: clientURL, PeerURL: peerURL},
+			lpurls: []url.URL{peerURL},
+			apurls: []url.URL{peerURL},
+			lcurls: []url.URL{clientURL},
+			acurls: []url.URL{clientURL},
+		},
+		{
+			name: "advertise urls",
+			cfg: &serverConfig{
+				ClientURL:           clientURL,
+				PeerURL:             peerURL,
+				AdvertiseClientURL:  url.URL{Scheme: "http", Host: "etcd.example.com:2379"},
+				AdvertisePeerURL:    url.URL{Scheme: "http", Host: "node1.etcd.example.com:2380"},
+				EnableLocalListener: true,
+			},
+			lpurls: []url.URL{peerURL},
+			apurls: []url.URL{{Scheme: "http", Host: "node1.etcd.example.com:2380"}},
+			lcurls: []url.URL{clientURL, localURL},
+			acurls: []url.URL{{Scheme: "http", Host: "etcd.example.com:2379"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := embed.NewConfig()
+			newServer(tc.cfg).setURLs(cfg)
+			if diff := cmp.Diff(tc.lpurls, cfg.LPUrls); diff != "" {
+				t.Errorf("LPUrls differs: (-want +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.apurls, cfg.APUrls); diff != "" {
+				t.Errorf("APUrls differs: (-want +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.lcurls, cfg.LCUrls); diff != "" {
+				t.Errorf("LCUrls differs: (-want +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.acurls, cfg.ACUrls); diff != "" {
+				t.Errorf("ACUrls differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServerQuotaBackendBytes(t *testing.T) {
+	if !*testLong {
+		t.Skip()
+	}
+	if err := os.RemoveAll("testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCluster(t)
+	defer c.cleanup()
+
+	c.addNode("node1", &Config{
+		ClientAddr:        ":2379",
+		PeerAddr:          ":2380",
+		GossipAddr:        ":7980",
+		QuotaBackendBytes: 16 * 1024 * 1024,
+	})
+	c.start("node1")
+	c.wait("node1")
+
+	if got := c.lookupNode("node1").etcd.Config().QuotaBackendBytes; got != 16*1024*1024 {
+		t.Fatalf("expected QuotaBackendBytes %d, received %d", 16*1024*1024, got)
+	}
+}
@@ -2,9 +2,14 @@ package manager
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/gogo/protobuf/types"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/criticalstack/e2d/pkg/client"
 	"github.com/criticalstack/e2d/pkg/e2db"
@@ -12,14 +17,83 @@ import (
 	"github.com/criticalstack/e2d/pkg/manager/e2dpb"
 )
 
+// memberStatusTimeout is the amount of time to wait for each member to respond
+// to a status request before it is considered unreachable.
+var memberStatusTimeout = 2 * time.Second
+
 type ManagerService struct {
 	m *Manager
 }
 
+// Health reports the health of the etcd cluster and the gossip network as
+// observed by this member. It always responds while the process is up, so it
+// is suited to liveness checks, while Ready is suited to readiness checks.
 func (s *ManagerService) Health(ctx context.Context, _ *types.Empty) (*e2dpb.HealthResponse, error) {
 	resp := &e2dpb.HealthResponse{
 		Status: "not great, bob",
 	}
+	s.setGossipHealth(resp)
+
+	// etcd failures are reported in EtcdError rather than returned since
+	// gRPC drops the response on error, and the gossip status is most useful
+	// when etcd is unhealthy
+	ok, err := s.etcdHealthy(ctx)
+	if err != nil {
+		log.Debug("etcd health check failed", zap.Error(err))
+		resp.EtcdError = err.Error()
+		return resp, nil
+	}
+	if ok {
+		resp.Status = "It cool"
+		resp.EtcdHealthy = true
+	}
+	return resp, nil
+}
+
+// Ready reports whether this member is ready to serve clients, which requires
+// the etcd server to be running, this member to be part of the etcd cluster,
+// and the cluster to have a leader, which implies a quorum. Members that are
+// still starting, joining or restarting are not ready. A member that is not
+// ready is reported in the response, rather than as an error.
+func (s *ManagerService) Ready(ctx context.Context, _ *types.Empty) (*e2dpb.ReadyResponse, error) {
+	if reason := s.notReadyReason(); reason != "" {
+		return &e2dpb.ReadyResponse{Reason: reason}, nil
+	}
+	return &e2dpb.ReadyResponse{Ready: true}, nil
+}
+
+// Role reports the current raft role of this member, so that external
+// controllers can tell the leader from the followers and learners. The role is
+// UNKNOWN while the cluster has no leader.
+func (s *ManagerService) Role(ctx context.Context, _ *types.Empty) (*e2dpb.RoleResponse, error) {
+	role, err := s.m.Role()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &e2dpb.RoleResponse{Role: e2dpb.Role(role)}, nil
+}
+
+// notReadyReason returns why this member is not ready, or an empty string
+// when it is ready.
+func (s *ManagerService) notReadyReason() string {
+	if !s.m.etcd.isRunning() {
+		return "etcd server is not running"
+	}
+	if s.m.etcd.isRestarting() {
+		return "etcd server is restarting"
+	}
+	if s.m.etcd.Server.Cluster().Member(s.m.etcd.Server.ID()) == nil {
+		return "member is not part of the etcd cluster"
+	}
+	if s.m.etcd.Server.Leader() == 0 {
+		return "etcd cluster has no leader"
+	}
+	return ""
+}
+
+// etcdHealthy determines if the etcd cluster is healthy and has reached the
+// required cluster size.
+func (s *ManagerService) etcdHealthy(ctx context.Context) (bool, error) {
 	db, err := e2db.New(ctx, &e2db.Config{
 		ClientAddr: s.m.cfg.ClientURL.String(),
 		CAFile:     s.m.cfg.PeerSecurity.TrustedCAFile,
@@ -28,32 +102,48 @@ func (s *ManagerService) Health(ctx context.Context, _ *types.Empty) (*e2dpb.Hea
 		Namespace:  string(volatilePrefix),
 	})
 	if err != nil {
-		return resp, err
+		return false, err
 	}
 	defer db.Close()
 
 	var cluster *Cluster
 	if err := db.Table(new(Cluster)).Find("ID", 1, &cluster); err != nil {
-		return resp, err
+		return false, err
 	}
 	c, err := client.New(&client.Config{
 		ClientURLs:     []string{s.m.cfg.ClientURL.String()},
 		SecurityConfig: s.m.cfg.PeerSecurity,
 	})
 	if err != nil {
-		return resp, err
+		return false, err
 	}
+	defer c.Close()
+
 	if err := c.IsHealthy(ctx); err != nil {
-		return resp, err
+		return false, err
 	}
 	cresp, err := c.MemberList(ctx)
 	if err != nil {
-		return resp, err
+		return false, err
 	}
-	if len(cresp.Members) >= cluster.RequiredClusterSize {
-		resp.Status = "It cool"
+	return len(cresp.Members) >= cluster.RequiredClusterSize, nil
+}
+
+// setGossipHealth populates the response with the state of the gossip network
+// as observed by this member.
+func (s *ManagerService) setGossipHealth(resp *e2dpb.HealthResponse) {
+	members := s.m.gossip.Members()
+	resp.GossipMembers = int32(len(members))
+	for _, member := range members {
+		switch member.Status {
+		case Running:
+			resp.RunningMembers++
+		case Pending:
+			resp.PendingMembers++
+		}
 	}
-	return resp, nil
+	resp.RequiredClusterSize = int32(s.m.cfg.RequiredClusterSize)
+	resp.Quorum = resp.RunningMembers >= resp.RequiredClusterSize/2+1
 }
 
 func (s *ManagerService) Restart(ctx context.Context, _ *types.Empty) (*e2dpb.RestartResponse, error) {
@@ -71,3 +161,106 @@ func (s *ManagerService) Restart(ctx context.Context, _ *types.Empty) (*e2dpb.Re
 	}()
 	return resp, nil
 }
+
+// Snapshot immediately creates a snapshot and saves it to the snapshot backup,
+// using the same pipeline as the periodic snapshotter, and then prunes the
+// older snapshot backups. Only the member selected to create snapshot backups
+// saves snapshots, so this fails when called on any other member.
+func (s *ManagerService) Snapshot(ctx context.Context, _ *types.Empty) (*e2dpb.SnapshotResponse, error) {
+	if s.m.snapshotter == nil {
+		return nil, errors.New("snapshotting disabled: no snapshot backup set")
+	}
+	if s.m.etcd.isRestarting() {
+		return nil, errors.New("server is restarting")
+	}
+	if !s.m.isSnapshotTaker() {
+		return nil, errors.New("not selected to create snapshot backups")
+	}
+	rev, size, err := s.m.backupSnapshot(0)
+	if err != nil {
+		// the backend is expected to be unavailable while etcd is not ready,
+		// so callers are told they can retry
+		if errors.Cause(err) == ErrBackendSnapshotUnavailable {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return nil, err
+	}
+	pruneSnapshots(s.m.snapshotter)
+	return &e2dpb.SnapshotResponse{
+		Revision:  rev,
+		SizeBytes: size,
+	}, nil
+}
+
+// ClusterStatus returns the state of each member of the etcd cluster, along
+// with any members of the gossip network that are not yet part of the etcd
+// cluster, as observed by this member. Members are reachable when they respond
+// to a status request, which also provides their raft index.
+func (s *ManagerService) ClusterStatus(ctx context.Context, _ *types.Empty) (*e2dpb.ClusterStatusResponse, error) {
+	if !s.m.etcd.isRunning() {
+		return nil, status.Error(codes.Unavailable, "etcd server is not running")
+	}
+	gossipMembers := make(map[string]*Member)
+	for _, member := range s.m.gossip.Members() {
+		gossipMembers[member.Name] = member
+	}
+
+	members := s.m.etcd.Server.Cluster().Members()
+	endpoints := make([]string, 0)
+	for _, member := range members {
+		endpoints = append(endpoints, member.ClientURLs...)
+	}
+	var c *client.Client
+	if len(endpoints) > 0 {
+		var err error
+		c, err = client.New(&client.Config{
+			ClientURLs:     endpoints,
+			SecurityConfig: s.m.cfg.PeerSecurity,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+	}
+
+	resp := &e2dpb.ClusterStatusResponse{}
+	leader := s.m.etcd.Server.Leader()
+	for _, member := range members {
+		ms := &e2dpb.MemberStatus{
+			Name:     member.Name,
+			IsLeader: member.ID == leader,
+		}
+		if len(member.PeerURLs) > 0 {
+			ms.PeerUrl = member.PeerURLs[0]
+		}
+		if len(member.ClientURLs) > 0 {
+			ms.ClientUrl = member.ClientURLs[0]
+			sctx, cancel := context.WithTimeout(ctx, memberStatusTimeout)
+			sresp, err := c.Status(sctx, ms.ClientUrl)
+			cancel()
+			if err != nil {
+				log.Debug("member is unreachable", zap.String("name", member.Name), zap.Error(err))
+			} else {
+				ms.Reachable = true
+				ms.RaftIndex = sresp.RaftIndex
+			}
+		}
+		if gm, ok := gossipMembers[member.Name]; ok {
+			ms.GossipStatus = gm.Status.String()
+			delete(gossipMembers, member.Name)
+		}
+		resp.Members = append(resp.Members, ms)
+	}
+	for _, gm := range gossipMembers {
+		resp.Members = append(resp.Members, &e2dpb.MemberStatus{
+			Name:         gm.Name,
+			PeerUrl:      gm.PeerURL,
+			ClientUrl:    gm.ClientURL,
+			GossipStatus: gm.Status.String(),
+		})
+	}
+	sort.Slice(resp.Members, func(i, j int) bool {
+		return resp.Members[i].Name < resp.Members[j].Name
+	})
+	return resp, nil
+}
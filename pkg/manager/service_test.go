@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"context"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/criticalstack/e2d/pkg/manager/e2dpb"
+)
+
+func TestManagerServiceGossipHealth(t *testing.T) {
+	cases := []struct {
+		name     string
+		members  []*Member
+		expected *e2dpb.HealthResponse
+	}{
+		{
+			name: "healthy",
+			members: []*Member{
+				{Name: "node1", Status: Running},
+				{Name: "node2", Status: Running},
+				{Name: "node3", Status: Running},
+			},
+			expected: &e2dpb.HealthResponse{
+				GossipMembers:       3,
+				RunningMembers:      3,
+				RequiredClusterSize: 3,
+				Quorum:              true,
+			},
+		},
+		{
+			name: "replacing member",
+			members: []*Member{
+				{Name: "node1", Status: Running},
+				{Name: "node2", Status: Running},
+				{Name: "node4", Status: Pending},
+			},
+			expected: &e2dpb.HealthResponse{
+				GossipMembers:       3,
+				RunningMembers:      2,
+				PendingMembers:      1,
+				RequiredClusterSize: 3,
+				Quorum:              true,
+			},
+		},
+		{
+			name: "lost quorum",
+			members: []*Member{
+				{Name: "node1", Status: Running},
+				{Name: "node2", Status: Unknown},
+			},
+			expected: &e2dpb.HealthResponse{
+				GossipMembers:       2,
+				RunningMembers:      1,
+				RequiredClusterSize: 3,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &ManagerService{
+				m: &Manager{
+					cfg:    &Config{Name: "node1", RequiredClusterSize: 3},
+					gossip: newGossip(&gossipConfig{Name: "node1"}),
+				},
+			}
+			s.m.gossip.m = newFakeMemberlist(tc.members...)
+			resp := &e2dpb.HealthResponse{}
+			s.setGossipHealth(resp)
+			if diff := cmp.Diff(tc.expected, resp); diff != "" {
+				t.Errorf("HealthResponse: after setGossipHealth differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManagerServiceHealthEtcdUnavailable(t *testing.T) {
+	s := &ManagerService{
+		m: &Manager{
+			cfg: &Config{
+				Name:                "node1",
+				RequiredClusterSize: 3,
+				ClientURL:           url.URL{Scheme: "http", Host: "127.0.0.1:1"},
+			},
+			gossip: newGossip(&gossipConfig{Name: "node1"}),
+		},
+	}
+	s.m.gossip.m = newFakeMemberlist(
+		&Member{Name: "node1", Status: Running},
+		&Member{Name: "node2", Status: Running},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	// the gossip status must still be returned when etcd cannot be reached
+	resp, err := s.Health(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.EtcdError == "" {
+		t.Fatal("expected etcd error to be reported")
+	}
+	resp.EtcdError = ""
+	if diff := cmp.Diff(&e2dpb.HealthResponse{
+		Status:              "not great, bob",
+		EtcdHealthy:         false,
+		GossipMembers:       2,
+		RunningMembers:      2,
+		RequiredClusterSize: 3,
+		Quorum:              true,
+	}, resp); diff != "" {
+		t.Errorf("HealthResponse: after Health differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestManagerServiceSnapshotNotSnapshotTaker(t *testing.T) {
+	m := &Manager{
+		cfg: &Config{
+			Name:                  "node1",
+			SnapshotPreferredZone: "us-east-1b",
+		},
+		gossip:      newGossip(&gossipConfig{Name: "node1"}),
+		etcd:        newServer(&serverConfig{}),
+		snapshotter: newFileSnapshotter("testdata/snapshots"),
+	}
+	m.gossip.m = newFakeMemberlist(
+		&Member{Name: "node1", Status: Running, Tags: map[string]string{ZoneTag: "us-east-1a"}},
+		&Member{Name: "node2", Status: Running, Tags: map[string]string{ZoneTag: "us-east-1b"}},
+	)
+	atomic.StoreUint64(&m.etcd.started, 1)
+
+	// a member outside of the preferred zone does not save snapshots, even
+	// when it would be the leader
+	s := &ManagerService{m}
+	if _, err := s.Snapshot(context.Background(), &types.Empty{}); err == nil {
+		t.Fatal("expected error creating a snapshot from a member not selected to create snapshot backups")
+	}
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/client"
+	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/criticalstack/e2d/pkg/snapshot"
+	snapshotutil "github.com/criticalstack/e2d/pkg/snapshot/util"
+)
+
+// encodeSnapshot applies the snapshot encryption and compression of the config
+// to a snapshot of the provided size, before it is saved as a backup.
+func encodeSnapshot(cfg *Config, r io.ReadCloser, size int64) io.ReadCloser {
+	if cfg.SnapshotEncryption {
+		r = snapshotutil.NewEncrypterReadCloser(r, cfg.snapshotEncryptionKey, size)
+	}
+	if cfg.SnapshotCompression {
+		r = snapshotutil.NewGzipReadCloser(r)
+	}
+	return r
+}
+
+// isSnapshotTaker returns whether this member creates the snapshot backups,
+// which is the leader unless a SnapshotPreferredZone is configured.
+func (m *Manager) isSnapshotTaker() bool {
+	if m.cfg.SnapshotPreferredZone == "" {
+		return m.etcd.isLeader()
+	}
+	if !m.etcd.isRunning() {
+		return false
+	}
+	name := selectSnapshotTaker(m.gossip.runningMembers(), m.cfg.SnapshotPreferredZone)
+	if name == "" {
+		return m.etcd.isLeader()
+	}
+	return name == m.cfg.Name
+}
+
+// selectSnapshotTaker returns the name of the member in the zone that creates
+// the snapshot backups, or an empty string when no member is in the zone. The
+// member with the lowest name is selected, so that every member selects the
+// same one from the same members.
+func selectSnapshotTaker(members []*Member, zone string) string {
+	name := ""
+	for _, member := range members {
+		if member.Tags[ZoneTag] != zone {
+			continue
+		}
+		if name == "" || member.Name < name {
+			name = member.Name
+		}
+	}
+	return name
+}
+
+// pruneSnapshots removes snapshot backups beyond the retention of the
+// Snapshotter, if it keeps a history of snapshots.
+func pruneSnapshots(s snapshot.Snapshotter) {
+	p, ok := s.(snapshot.Pruner)
+	if !ok {
+		return
+	}
+	if err := p.Prune(); err != nil {
+		log.Error("cannot prune snapshot backups", zap.Error(err))
+	}
+}
+
+// loadSnapshot loads the named snapshot backup, or the latest when name is
+// empty, decompressing and decrypting it as needed, and writes the etcd
+// snapshot to w.
+func loadSnapshot(s snapshot.Snapshotter, name string, key *[32]byte, w io.Writer) error {
+	r, err := snapshot.LoadNamed(s, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	r = snapshotutil.NewGunzipReadCloser(r)
+	r = snapshotutil.NewDecrypterReadCloser(r, key)
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// RestoreSnapshot restores the latest snapshot backup from the Snapshotter,
+// or the one named by SnapshotName, into a new data-dir without starting etcd,
+// so that a replacement member can be prepared offline. The restored data-dir holds a single member using the
+// Name, Dir and PeerAddr of the config, and is started the same as any other
+// existing data-dir by a Manager with the same config. The data-dir must not
+// already exist.
+func RestoreSnapshot(cfg *Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	defer cfg.removeInlineCerts()
+
+	if cfg.Snapshotter == nil {
+		return errors.New("must provide a Snapshotter to restore from")
+	}
+	if cfg.RequiredClusterSize != 1 {
+		return errors.Errorf("snapshot can only be restored for a single member, RequiredClusterSize is %d", cfg.RequiredClusterSize)
+	}
+	if _, err := os.Stat(cfg.Dir); err == nil {
+		return errors.Errorf("data-dir already exists: %#v", cfg.Dir)
+	}
+	tmpFile, err := ioutil.TempFile("", "snapshot.load")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	if err := loadSnapshot(cfg.Snapshotter, cfg.SnapshotName, cfg.snapshotEncryptionKey, tmpFile); err != nil {
+		return errors.Wrap(err, "cannot load snapshot")
+	}
+	s := newServer(&serverConfig{
+		Name:                cfg.Name,
+		Dir:                 cfg.Dir,
+		PeerURL:             cfg.PeerURL,
+		AdvertisePeerURL:    cfg.AdvertisePeerURL,
+		RequiredClusterSize: cfg.RequiredClusterSize,
+	})
+	return s.restoreSnapshot(tmpFile.Name(), []*Peer{{Name: cfg.Name, URL: cfg.AdvertisePeerURL.String()}})
+}
+
+// SaveSnapshot saves a snapshot backup of a running cluster on demand, using
+// the Snapshotter and the snapshot compression and encryption of the config.
+// The snapshot is streamed from the member at ClientAddr, using the
+// ClientSecurity of the config to connect. The revision of the member when the
+// snapshot was started is returned.
+func SaveSnapshot(cfg *Config) (int64, error) {
+	if err := cfg.validate(); err != nil {
+		return 0, err
+	}
+	defer cfg.removeInlineCerts()
+
+	if cfg.Snapshotter == nil {
+		return 0, errors.New("must provide a Snapshotter to save to")
+	}
+	c, err := newClient(&client.Config{
+		ClientURLs:     []string{cfg.ClientURL.String()},
+		SecurityConfig: cfg.ClientSecurity,
+		Timeout:        5 * time.Second,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	r, size, rev, err := c.createSnapshot(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot create snapshot")
+	}
+	if err := cfg.Snapshotter.Save(encodeSnapshot(cfg, r, size)); err != nil {
+		return 0, errors.Wrap(err, "cannot save snapshot")
+	}
+	pruneSnapshots(cfg.Snapshotter)
+	return rev, nil
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+	"github.com/criticalstack/e2d/pkg/pki"
+)
+
+// ReloadTLS reloads the CA and the certificates used by etcd, without
+// restarting the Manager.
+//
+// The CA certificate and key are read again, and when the CA key is available
+// the server and peer certificates are regenerated from the CA, keeping their
+// hosts, and written in place of the old ones. Without the CA key, the
+// certificates are expected to have been rotated on disk, and are only checked
+// to be valid key pairs.
+//
+// etcd reads the certificate and key files again for every new connection, so
+// the new key pairs are used by all new client and peer connections.
+// Connections that are already established, including in-flight requests,
+// keep the certificate negotiated during their handshake until they are
+// closed.
+//
+// The trusted CA is only read when etcd starts, so when the CA file has
+// changed the etcd server is restarted to trust the new CA. Restarting closes
+// the established connections to this member, and clients must reconnect.
+func (m *Manager) ReloadTLS() error {
+	m.tlsMu.Lock()
+	defer m.tlsMu.Unlock()
+
+	if !m.etcd.isRunning() {
+		return errors.New("cannot reload certificates: etcd server is not running")
+	}
+	r, err := m.loadCertificateAuthority()
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		for _, c := range m.leafCerts() {
+			if err := m.renewCert(r, c); err != nil {
+				return err
+			}
+		}
+	}
+	return m.reloadTLS()
+}
+
+// reloadTLS checks the key pairs used by etcd, and restarts the etcd server
+// when the trusted CA has changed. It must be called with tlsMu held, while
+// the etcd server is running.
+func (m *Manager) reloadTLS() error {
+	for _, c := range m.leafCerts() {
+		if _, err := tls.LoadX509KeyPair(c.certFile, c.keyFile); err != nil {
+			return errors.Wrapf(err, "cannot load key pair: %#v", c.certFile)
+		}
+	}
+	if m.cfg.caCertHash != nil {
+		if err := m.verifyCACertHash(); err != nil {
+			return err
+		}
+	}
+	trustedCAs, err := readTrustedCAs(m.cfg)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(trustedCAs, m.trustedCAs) {
+		log.Info("reloaded certificates", zap.String("name", shortName(m.cfg.Name)))
+		return nil
+	}
+	log.Info("trusted ca changed, restarting etcd server ...", zap.String("name", shortName(m.cfg.Name)))
+	if err := m.Restart(); err != nil {
+		return err
+	}
+	m.trustedCAs = trustedCAs
+	return nil
+}
+
+// leafCert is a certificate used by etcd, along with the signing profile used
+// to generate it.
+type leafCert struct {
+	certFile, keyFile, profile string
+}
+
+// leafCerts returns the server and peer certificates used by etcd.
+func (m *Manager) leafCerts() []leafCert {
+	certs := make([]leafCert, 0)
+	for _, c := range []leafCert{
+		{m.cfg.ClientSecurity.CertFile, m.cfg.ClientSecurity.KeyFile, pki.ServerSigningProfile},
+		{m.cfg.PeerSecurity.CertFile, m.cfg.PeerSecurity.KeyFile, pki.PeerSigningProfile},
+	} {
+		if c.certFile == "" || c.keyFile == "" {
+			continue
+		}
+		certs = append(certs, c)
+	}
+	return certs
+}
+
+// loadCertificateAuthority reads the CA certificate and key again, since
+// either may have been rotated since the Manager was created. It returns nil
+// when the CA key is not available, since certificates cannot be signed
+// without it.
+func (m *Manager) loadCertificateAuthority() (*pki.RootCA, error) {
+	if m.cfg.CACertFile == "" {
+		return nil, nil
+	}
+	keyPEM := m.cfg.CAKey
+	if len(keyPEM) == 0 && m.cfg.CAKeyFile != "" {
+		data, err := ioutil.ReadFile(m.cfg.CAKeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read ca key: %#v", m.cfg.CAKeyFile)
+		}
+		keyPEM = data
+	}
+	if len(keyPEM) == 0 {
+		return nil, nil
+	}
+	if m.cfg.caCertHash != nil {
+		if err := m.verifyCACertHash(); err != nil {
+			return nil, err
+		}
+	}
+	certPEM, err := ioutil.ReadFile(m.cfg.CACertFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read ca cert: %#v", m.cfg.CACertFile)
+	}
+	r, err := pki.NewRootCAFromPEM(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load ca")
+	}
+	return r, nil
+}
+
+// verifyCACertHash checks that the CA certificate still matches CACertHash,
+// so that certificates are neither signed by nor trusted from another CA.
+func (m *Manager) verifyCACertHash() error {
+	h, err := pki.GenerateCertHash(m.cfg.CACertFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot hash ca cert")
+	}
+	if !bytes.Equal(h, m.cfg.caCertHash) {
+		return errors.Errorf("ca cert hash %s does not match CACertHash %s", pki.FormatCertHash(h), pki.FormatCertHash(m.cfg.caCertHash))
+	}
+	return nil
+}
+
+// readTrustedCAs reads the contents of the trusted CA files, which are
+// compared to detect when the CA has changed.
+func readTrustedCAs(cfg *Config) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, name := range []string{cfg.ClientSecurity.TrustedCAFile, cfg.PeerSecurity.TrustedCAFile} {
+		if name == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read trusted ca file: %#v", name)
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -1,7 +1,6 @@
 package netutil
 
 import (
-	"fmt"
 	"net"
 	"strconv"
 	"strings"
@@ -37,6 +36,85 @@ func DetectHostIPv4() (string, error) {
 	return "", errors.New("cannot detect host IPv4 address")
 }
 
+// DetectHostIPv6 attempts to determine the host IPv6 address by finding the
+// first non-loopback device with an assigned global unicast IPv6 address.
+// Link-local addresses are skipped, since they are not usable without a zone.
+func DetectHostIPv6() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+			if ipnet.IP.To4() != nil || !ipnet.IP.IsGlobalUnicast() {
+				continue
+			}
+			return ipnet.IP.String(), nil
+		}
+	}
+	return "", errors.New("cannot detect host IPv6 address")
+}
+
+// IsIPv6 checks that the passed string is an IPv6 address, rather than an
+// IPv4 or IPv4-mapped address.
+func IsIPv6(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil
+}
+
+// DetectHostIP determines the host address of the same family as the passed
+// host, using DetectHostIPv6 for IPv6 hosts (like ::) and DetectHostIPv4
+// otherwise.
+func DetectHostIP(host string) (string, error) {
+	if IsIPv6(host) {
+		return DetectHostIPv6()
+	}
+	return DetectHostIPv4()
+}
+
+// interfaceAddrs returns the addresses assigned to the named network
+// interface, and is replaced in tests.
+var interfaceAddrs = func(name string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
+
+// DetectInterfaceIP determines the address of the named network interface,
+// for hosts with multiple interfaces where the first one is not the one to be
+// used. The address is of the same family as the passed host, an IPv4 address
+// or a global unicast IPv6 address for IPv6 hosts (like ::).
+func DetectInterfaceIP(name, host string) (string, error) {
+	addrs, err := interfaceAddrs(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read addresses of interface: %#v", name)
+	}
+	ipv6 := IsIPv6(host)
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipv6 {
+			if ipnet.IP.To4() != nil || !ipnet.IP.IsGlobalUnicast() {
+				continue
+			}
+		} else if ipnet.IP.To4() == nil {
+			continue
+		}
+		return ipnet.IP.String(), nil
+	}
+	if ipv6 {
+		return "", errors.Errorf("cannot detect IPv6 address of interface: %#v", name)
+	}
+	return "", errors.Errorf("cannot detect IPv4 address of interface: %#v", name)
+}
+
+// SplitHostPort splits an address of the form host:port, or [host]:port for
+// IPv6 hosts, defaulting the host to 127.0.0.1 and allowing the port to be
+// empty.
 func SplitHostPort(addr string) (string, int, error) {
 	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
@@ -45,7 +123,13 @@ func SplitHostPort(addr string) (string, int, error) {
 	if host == "" {
 		host = "127.0.0.1"
 	}
-	p, _ := strconv.Atoi(port)
+	if port == "" {
+		return host, 0, nil
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return "", 0, errors.Errorf("invalid port: %#v", port)
+	}
 	return host, p, nil
 }
 
@@ -54,8 +138,14 @@ type Address struct {
 	Port int
 }
 
+// String returns the address as host:port, bracketing IPv6 hosts like
+// [::1]:2379.
 func (a *Address) String() string {
-	return fmt.Sprintf("%s:%d", a.Host, a.Port)
+	return JoinHostPort(a.Host, a.Port)
+}
+
+func (a *Address) IsIPv6() bool {
+	return IsIPv6(a.Host)
 }
 
 func (a *Address) IsUnspecified() bool {
@@ -80,9 +170,15 @@ func FixUnspecifiedHostAddr(addr string) (string, error) {
 	if !net.ParseIP(host).IsUnspecified() {
 		return addr, nil
 	}
-	host, err = DetectHostIPv4()
+	host, err = DetectHostIP(host)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s:%d", host, port), nil
+	return JoinHostPort(host, port), nil
+}
+
+// JoinHostPort combines the host and port into an address, bracketing IPv6
+// hosts.
+func JoinHostPort(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
 }
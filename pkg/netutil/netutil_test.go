@@ -1,6 +1,11 @@
 package netutil
 
-import "testing"
+import (
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+)
 
 func TestIsRoutableIPv4(t *testing.T) {
 	tests := []struct {
@@ -30,3 +35,131 @@ func TestIsRoutableIPv4(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct {
+		addr    string
+		host    string
+		port    int
+		wantErr bool
+	}{
+		{"10.0.0.1:2380", "10.0.0.1", 2380, false},
+		{":2380", "127.0.0.1", 2380, false},
+		{"10.0.0.1:", "10.0.0.1", 0, false},
+		{"10.0.0.1:bad", "", 0, true},
+		{"10.0.0.1:70000", "", 0, true},
+		{"10.0.0.1", "", 0, true},
+		{"[::1]:2379", "::1", 2379, false},
+		{"[fd00::1]:", "fd00::1", 0, false},
+		{"[::]:2380", "::", 2380, false},
+		{"::1:2379", "", 0, true},
+	}
+	for _, tt := range tests {
+		host, port, err := SplitHostPort(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitHostPort(%s) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if host != tt.host || port != tt.port {
+			t.Errorf("SplitHostPort(%s) = %s, %d, want %s, %d", tt.addr, host, port, tt.host, tt.port)
+		}
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		addr        string
+		want        string
+		ipv6        bool
+		unspecified bool
+	}{
+		{"10.0.0.1:2379", "10.0.0.1:2379", false, false},
+		{"0.0.0.0:2379", "0.0.0.0:2379", false, true},
+		{"[::1]:2379", "[::1]:2379", true, false},
+		{"[fd00::1]:2380", "[fd00::1]:2380", true, false},
+		{"[::]:7980", "[::]:7980", true, true},
+		{"[::ffff:10.0.0.1]:2379", "[::ffff:10.0.0.1]:2379", false, false},
+	}
+	for _, tt := range tests {
+		a, err := ParseAddr(tt.addr)
+		if err != nil {
+			t.Errorf("ParseAddr(%s) error = %v", tt.addr, err)
+			continue
+		}
+		if got := a.String(); got != tt.want {
+			t.Errorf("ParseAddr(%s).String() = %s, want %s", tt.addr, got, tt.want)
+		}
+		if got := a.IsIPv6(); got != tt.ipv6 {
+			t.Errorf("ParseAddr(%s).IsIPv6() = %v, want %v", tt.addr, got, tt.ipv6)
+		}
+		if got := a.IsUnspecified(); got != tt.unspecified {
+			t.Errorf("ParseAddr(%s).IsUnspecified() = %v, want %v", tt.addr, got, tt.unspecified)
+		}
+	}
+}
+
+func TestJoinHostPort(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"10.0.0.1", 7980, "10.0.0.1:7980"},
+		{"::1", 2379, "[::1]:2379"},
+		{"fd00::1", 7980, "[fd00::1]:7980"},
+		{"etcd.example.com", 2379, "etcd.example.com:2379"},
+	}
+	for _, tt := range tests {
+		if got := JoinHostPort(tt.host, tt.port); got != tt.want {
+			t.Errorf("JoinHostPort(%s, %d) = %s, want %s", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestDetectInterfaceIP(t *testing.T) {
+	defer func(f func(string) ([]net.Addr, error)) { interfaceAddrs = f }(interfaceAddrs)
+	interfaceAddrs = func(name string) ([]net.Addr, error) {
+		mustParseCIDR := func(s string) net.Addr {
+			ip, ipnet, err := net.ParseCIDR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ipnet.IP = ip
+			return ipnet
+		}
+		switch name {
+		case "eth0":
+			return []net.Addr{mustParseCIDR("10.0.0.10/24"), mustParseCIDR("fe80::10/64")}, nil
+		case "eth1":
+			return []net.Addr{mustParseCIDR("fe80::20/64"), mustParseCIDR("fd00::20/64"), mustParseCIDR("192.168.0.20/24")}, nil
+		case "eth2":
+			return []net.Addr{mustParseCIDR("fe80::30/64")}, nil
+		}
+		return nil, errors.New("no such network interface")
+	}
+
+	tests := []struct {
+		name    string
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{"eth0", "0.0.0.0", "10.0.0.10", false},
+		{"eth1", "0.0.0.0", "192.168.0.20", false},
+		{"eth1", "", "192.168.0.20", false},
+		{"eth1", "::", "fd00::20", false},
+		{"eth0", "::", "", true},
+		{"eth2", "0.0.0.0", "", true},
+		{"eth3", "0.0.0.0", "", true},
+	}
+	for _, tt := range tests {
+		got, err := DetectInterfaceIP(tt.name, tt.host)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("DetectInterfaceIP(%s, %s) error = %v, wantErr %v", tt.name, tt.host, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("DetectInterfaceIP(%s, %s) = %v, want %v", tt.name, tt.host, got, tt.want)
+		}
+	}
+}
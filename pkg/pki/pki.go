@@ -1,11 +1,17 @@
 package pki
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"io/ioutil"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/cloudflare/cfssl/cli/genkey"
@@ -88,27 +94,110 @@ func init() {
 	clog.SetLogger(&logger{log.NewLoggerWithLevel("cfssl", zapcore.ErrorLevel)})
 }
 
+const (
+	KeyAlgoRSA   = "rsa"
+	KeyAlgoECDSA = "ecdsa"
+)
+
+// KeyConfig selects the algorithm and size of generated private keys.
+type KeyConfig struct {
+	// Algo is either KeyAlgoRSA or KeyAlgoECDSA, and defaults to KeyAlgoRSA.
+	Algo string
+
+	// Size is the RSA modulus size in bits (2048, 3072 or 4096) or the ECDSA
+	// curve size (256, 384 or 521). It defaults to 2048 for RSA and 256 (the
+	// P-256 curve) for ECDSA.
+	Size int
+}
+
+// KeyRequest returns the cfssl key request for the KeyConfig, applying the
+// defaults. A nil KeyConfig returns the default RSA-2048 key request.
+func (kc *KeyConfig) KeyRequest() (*csr.KeyRequest, error) {
+	var algo string
+	var size int
+	if kc != nil {
+		algo, size = strings.ToLower(kc.Algo), kc.Size
+	}
+	switch algo {
+	case "", KeyAlgoRSA:
+		if size == 0 {
+			size = 2048
+		}
+		switch size {
+		case 2048, 3072, 4096:
+		default:
+			return nil, errors.Errorf("invalid rsa key size: %d (must be 2048, 3072 or 4096)", size)
+		}
+		return &csr.KeyRequest{A: KeyAlgoRSA, S: size}, nil
+	case KeyAlgoECDSA:
+		if size == 0 {
+			size = 256
+		}
+		switch size {
+		case 256, 384, 521:
+		default:
+			return nil, errors.Errorf("invalid ecdsa key size: %d (must be 256, 384 or 521)", size)
+		}
+		return &csr.KeyRequest{A: KeyAlgoECDSA, S: size}, nil
+	default:
+		return nil, errors.Errorf("invalid key algorithm: %#v (must be rsa or ecdsa)", kc.Algo)
+	}
+}
+
+// ParsePrivateKeyDER parses a DER-encoded RSA or ECDSA private key, in either
+// PKCS #1, SEC 1 or PKCS #8 form.
+func ParsePrivateKeyDER(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("cannot parse private key: must be an rsa or ecdsa key")
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, errors.Errorf("unsupported private key type: %T", key)
+	}
+}
+
 type KeyPair struct {
 	Cert    *x509.Certificate
 	CertPEM []byte
 	Key     crypto.Signer
 	KeyPEM  []byte
+
+	// certificates of the issuing CAs that follow Cert in CertPEM, when the
+	// certificate was issued by an intermediate CA
+	Chain []*x509.Certificate
 }
 
+// NewKeyPairFromPEM loads a key pair from PEM. The certificate PEM can be a
+// bundle, where the certificate is followed by its chain of CA certificates.
 func NewKeyPairFromPEM(certPEM, keyPEM []byte) (*KeyPair, error) {
-	cert, err := helpers.ParseCertificatePEM(certPEM)
+	certs, err := helpers.ParseCertificatesPEM(certPEM)
 	if err != nil {
 		return nil, err
 	}
+	if len(certs) == 0 {
+		return nil, errors.New("cannot parse certificate: no PEM data found")
+	}
 	key, err := helpers.ParsePrivateKeyPEM(keyPEM)
 	if err != nil {
 		return nil, err
 	}
 	return &KeyPair{
-		Cert:    cert,
+		Cert:    certs[0],
 		CertPEM: certPEM,
 		Key:     key,
 		KeyPEM:  keyPEM,
+		Chain:   certs[1:],
 	}, nil
 }
 
@@ -144,6 +233,10 @@ func NewRootCAFromFile(certpath, keypath string) (*RootCA, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewRootCAFromPEM(certPEM, keyPEM)
+}
+
+func NewRootCAFromPEM(certPEM, keyPEM []byte) (*RootCA, error) {
 	ca, err := NewKeyPairFromPEM(certPEM, keyPEM)
 	if err != nil {
 		return nil, err
@@ -156,8 +249,54 @@ func NewRootCAFromFile(certpath, keypath string) (*RootCA, error) {
 	return r, nil
 }
 
+// NewIntermediateCA creates a CA signed by the parent CA, such as a corporate
+// root or intermediate CA, rather than a self-signed root. The parent
+// certificate PEM can include the parent's own chain. The certificates
+// generated by the intermediate CA are bundled with the chain, so that they
+// can be verified by trusting only the parent's root.
+func NewIntermediateCA(parentCertPEM, parentKeyPEM []byte, cr *csr.CertificateRequest) (*RootCA, error) {
+	parent, err := NewRootCAFromPEM(parentCertPEM, parentKeyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load parent ca")
+	}
+	csrPEM, keyPEM, err := parent.g.ProcessRequest(cr)
+	if err != nil {
+		return nil, err
+	}
+	s, err := local.NewSigner(parent.CA.Key, parent.CA.Cert, signer.DefaultSigAlgo(parent.CA.Key), initca.CAPolicy())
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := s.Sign(signer.SignRequest{Request: string(csrPEM)})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot sign intermediate ca")
+	}
+	return NewRootCAFromPEM(append(certPEM, parent.CA.CertPEM...), keyPEM)
+}
+
 func NewDefaultRootCA() (*RootCA, error) {
-	return NewRootCA(&csr.CertificateRequest{
+	return NewDefaultRootCAWithKeyConfig(nil)
+}
+
+// NewDefaultRootCAWithKeyConfig creates the default CA with a private key of
+// the provided algorithm and size. A nil KeyConfig uses the default RSA-2048
+// key.
+func NewDefaultRootCAWithKeyConfig(kc *KeyConfig) (*RootCA, error) {
+	cr, err := NewDefaultCACertificateRequest(kc)
+	if err != nil {
+		return nil, err
+	}
+	return NewRootCA(cr)
+}
+
+// NewDefaultCACertificateRequest returns the certificate request used for the
+// default CA, with a private key of the provided algorithm and size.
+func NewDefaultCACertificateRequest(kc *KeyConfig) (*csr.CertificateRequest, error) {
+	kr, err := kc.KeyRequest()
+	if err != nil {
+		return nil, err
+	}
+	return &csr.CertificateRequest{
 		Names: []csr.Name{
 			{
 				C:  "US",
@@ -166,12 +305,9 @@ func NewDefaultRootCA() (*RootCA, error) {
 				O:  "Critical Stack",
 			},
 		},
-		KeyRequest: &csr.KeyRequest{
-			A: "rsa",
-			S: 2048,
-		},
-		CN: "e2d-ca",
-	})
+		KeyRequest: kr,
+		CN:         "e2d-ca",
+	}, nil
 }
 
 func (r *RootCA) GenerateCertificates(profile string, cr *csr.CertificateRequest) (*KeyPair, error) {
@@ -190,14 +326,168 @@ func (r *RootCA) GenerateCertificates(profile string, cr *csr.CertificateRequest
 	if err != nil {
 		return nil, err
 	}
-	return NewKeyPairFromPEM(certPEM, keyPEM)
+	return NewKeyPairFromPEM(append(certPEM, r.chainPEM()...), keyPEM)
+}
+
+// chainPEM returns the certificates bundled with generated certificates. When
+// the CA is an intermediate, these are the CA certificate and its chain, but
+// not the self-signed root, which must already be trusted.
+func (r *RootCA) chainPEM() []byte {
+	if isSelfSigned(r.CA.Cert) {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, cert := range append([]*x509.Certificate{r.CA.Cert}, r.CA.Chain...) {
+		if isSelfSigned(cert) {
+			continue
+		}
+		buf.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+	return buf.Bytes()
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// NewCertificateRequest returns a request for a certificate with the common
+// name and subject alternative names, and a private key of the provided
+// algorithm and size. A nil KeyConfig uses the default RSA-2048 key.
+func NewCertificateRequest(kc *KeyConfig, commonName string, dnsNames []string, ips []net.IP) (*csr.CertificateRequest, error) {
+	kr, err := kc.KeyRequest()
+	if err != nil {
+		return nil, err
+	}
+	hosts := append([]string{}, dnsNames...)
+	for _, ip := range ips {
+		hosts = append(hosts, ip.String())
+	}
+	return &csr.CertificateRequest{
+		Names: []csr.Name{
+			{
+				C:  "US",
+				ST: "Boston",
+				L:  "MA",
+			},
+		},
+		KeyRequest: kr,
+		Hosts:      hosts,
+		CN:         commonName,
+	}, nil
+}
+
+// ParseSANs splits subject alternative names into DNS names and IP
+// addresses, removing duplicates. DNS names may have a wildcard as the first
+// label, like *.etcd.internal.
+func ParseSANs(sans []string) ([]string, []net.IP, error) {
+	seen := make(map[string]bool)
+	dnsNames := make([]string, 0)
+	ips := make([]net.IP, 0)
+	for _, san := range sans {
+		san = strings.TrimSpace(san)
+		if san == "" || seen[san] {
+			continue
+		}
+		seen[san] = true
+		if ip := net.ParseIP(san); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+		if !isDNSName(san) {
+			return nil, nil, errors.Errorf("invalid subject alternative name: %#v (must be a DNS name or IP address)", san)
+		}
+		dnsNames = append(dnsNames, strings.ToLower(san))
+	}
+	return dnsNames, ips, nil
+}
+
+func isDNSName(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+	for i, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if i == 0 && label == "*" {
+			continue
+		}
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RenewCertificates generates a new key pair replacing kp, with the same
+// subject, hosts and type of key, signed using the provided profile.
+func (r *RootCA) RenewCertificates(profile string, kp *KeyPair) (*KeyPair, error) {
+	kr := &csr.KeyRequest{}
+	switch key := kp.Key.(type) {
+	case *rsa.PrivateKey:
+		kr.A, kr.S = KeyAlgoRSA, key.N.BitLen()
+	case *ecdsa.PrivateKey:
+		kr.A, kr.S = KeyAlgoECDSA, key.Curve.Params().BitSize
+	default:
+		return nil, errors.Errorf("unsupported private key type: %T", kp.Key)
+	}
+	cr := &csr.CertificateRequest{
+		CN:         kp.Cert.Subject.CommonName,
+		KeyRequest: kr,
+	}
+	first := func(s []string) string {
+		if len(s) == 0 {
+			return ""
+		}
+		return s[0]
+	}
+	name := csr.Name{
+		C:  first(kp.Cert.Subject.Country),
+		ST: first(kp.Cert.Subject.Province),
+		L:  first(kp.Cert.Subject.Locality),
+		O:  first(kp.Cert.Subject.Organization),
+		OU: first(kp.Cert.Subject.OrganizationalUnit),
+	}
+	if name != (csr.Name{}) {
+		cr.Names = []csr.Name{name}
+	}
+	cr.Hosts = append(cr.Hosts, kp.Cert.DNSNames...)
+	for _, ip := range kp.Cert.IPAddresses {
+		cr.Hosts = append(cr.Hosts, ip.String())
+	}
+	cr.Hosts = append(cr.Hosts, kp.Cert.EmailAddresses...)
+	return r.GenerateCertificates(profile, cr)
+}
+
+// CertExpiry returns the time that the PEM-encoded certificate expires.
+func CertExpiry(certPEM []byte) (time.Time, error) {
+	certs, err := helpers.ParseCertificatesPEM(certPEM)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "cannot parse certificate")
+	}
+	if len(certs) == 0 {
+		return time.Time{}, errors.New("cannot parse certificate: no PEM data found")
+	}
+	return certs[0].NotAfter, nil
 }
 
+// GenerateCertHash computes the SHA-256 hash of the SubjectPublicKeyInfo of the
+// PEM-encoded certificate at caCertPath.
 func GenerateCertHash(caCertPath string) ([]byte, error) {
 	data, err := ioutil.ReadFile(caCertPath)
 	if err != nil {
 		return nil, err
 	}
+	return GenerateCertHashFromPEM(data)
+}
+
+// GenerateCertHashFromPEM computes the SHA-256 hash of the
+// SubjectPublicKeyInfo of a PEM-encoded certificate.
+func GenerateCertHashFromPEM(data []byte) ([]byte, error) {
 	block, _ := pem.Decode(data)
 	if block == nil {
 		return nil, errors.New("cannot parse PEM formatted block")
@@ -209,3 +499,24 @@ func GenerateCertHash(caCertPath string) ([]byte, error) {
 	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
 	return h[:], nil
 }
+
+const certHashPrefix = "sha256:"
+
+// FormatCertHash formats a certificate hash as a hex string prefixed with the
+// hash algorithm, e.g. "sha256:<hex>".
+func FormatCertHash(h []byte) string {
+	return certHashPrefix + hex.EncodeToString(h)
+}
+
+// ParseCertHash parses a certificate hash formatted by FormatCertHash. The
+// "sha256:" prefix is optional.
+func ParseCertHash(s string) ([]byte, error) {
+	h, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s), certHashPrefix))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decode cert hash: %#v", s)
+	}
+	if len(h) != sha256.Size {
+		return nil, errors.Errorf("cert hash must be %d bytes, received %d", sha256.Size, len(h))
+	}
+	return h, nil
+}
@@ -1,10 +1,21 @@
 package pki
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"net"
+	"strings"
 	"testing"
 
 	"github.com/cloudflare/cfssl/csr"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestGenerateCertificates(t *testing.T) {
@@ -36,3 +47,213 @@ func TestGenerateCertificates(t *testing.T) {
 	fmt.Printf("kp.certPEM = %s\n", kp.CertPEM)
 	fmt.Printf("kp.keyPEM = %s\n", kp.KeyPEM)
 }
+
+func TestCertHash(t *testing.T) {
+	r, err := NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := GenerateCertHashFromPEM(r.CA.CertPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{FormatCertHash(h), strings.TrimPrefix(FormatCertHash(h), "sha256:")} {
+		parsed, err := ParseCertHash(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(parsed, h) {
+			t.Fatalf("expected %x, received %x", h, parsed)
+		}
+	}
+	for _, s := range []string{"sha256:zz", "sha256:abcd", ""} {
+		if _, err := ParseCertHash(s); err == nil {
+			t.Fatalf("expected error parsing %#v", s)
+		}
+	}
+}
+
+func TestKeyConfig(t *testing.T) {
+	tests := []struct {
+		kc      *KeyConfig
+		check   func(crypto.Signer) bool
+		wantErr bool
+	}{
+		{
+			kc: nil,
+			check: func(k crypto.Signer) bool {
+				key, ok := k.(*rsa.PrivateKey)
+				return ok && key.N.BitLen() == 2048
+			},
+		},
+		{
+			kc: &KeyConfig{Algo: "rsa", Size: 4096},
+			check: func(k crypto.Signer) bool {
+				key, ok := k.(*rsa.PrivateKey)
+				return ok && key.N.BitLen() == 4096
+			},
+		},
+		{
+			kc: &KeyConfig{Algo: "ecdsa"},
+			check: func(k crypto.Signer) bool {
+				key, ok := k.(*ecdsa.PrivateKey)
+				return ok && key.Curve == elliptic.P256()
+			},
+		},
+		{
+			kc: &KeyConfig{Algo: "ecdsa", Size: 384},
+			check: func(k crypto.Signer) bool {
+				key, ok := k.(*ecdsa.PrivateKey)
+				return ok && key.Curve == elliptic.P384()
+			},
+		},
+		{
+			kc:      &KeyConfig{Algo: "rsa", Size: 1024},
+			wantErr: true,
+		},
+		{
+			kc:      &KeyConfig{Algo: "ecdsa", Size: 2048},
+			wantErr: true,
+		},
+		{
+			kc:      &KeyConfig{Algo: "ed25519"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		r, err := NewDefaultRootCAWithKeyConfig(tt.kc)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%+v: expected error %v, received %v", tt.kc, tt.wantErr, err)
+		}
+		if tt.wantErr {
+			continue
+		}
+		kr, err := tt.kc.KeyRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		kp, err := r.GenerateCertificates(PeerSigningProfile, &csr.CertificateRequest{
+			KeyRequest: kr,
+			Hosts:      []string{"127.0.0.1"},
+			CN:         "etcd peer",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// both the CA and the generated key pair are loaded from PEM
+		for _, pair := range []*KeyPair{r.CA, kp} {
+			loaded, err := NewKeyPairFromPEM(pair.CertPEM, pair.KeyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(loaded.Key) {
+				t.Errorf("%+v: unexpected key %T", tt.kc, loaded.Key)
+			}
+			block, _ := pem.Decode(pair.KeyPEM)
+			if _, err := ParsePrivateKeyDER(block.Bytes); err != nil {
+				t.Errorf("%+v: %v", tt.kc, err)
+			}
+		}
+		if err := kp.Cert.CheckSignatureFrom(r.CA.Cert); err != nil {
+			t.Errorf("%+v: %v", tt.kc, err)
+		}
+	}
+}
+
+func TestIntermediateCA(t *testing.T) {
+	corporate, err := NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, err := NewDefaultCACertificateRequest(&KeyConfig{Algo: KeyAlgoECDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.CN = "e2d-intermediate-ca"
+	r, err := NewIntermediateCA(corporate.CA.CertPEM, corporate.CA.KeyPEM, cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.CA.Cert.IsCA {
+		t.Fatal("expected intermediate to be a ca")
+	}
+
+	// the intermediate is loaded again with its chain, like "e2d pki gencerts"
+	r, err = NewRootCAFromPEM(r.CA.CertPEM, r.CA.KeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.CA.Chain) != 1 {
+		t.Fatalf("expected chain of 1 certificate, received %d", len(r.CA.Chain))
+	}
+	kp, err := r.GenerateCertificates(ServerSigningProfile, &csr.CertificateRequest{
+		KeyRequest: &csr.KeyRequest{A: "rsa", S: 2048},
+		Hosts:      []string{"127.0.0.1"},
+		CN:         "etcd server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server cert is bundled with the intermediate, but not the root
+	if len(kp.Chain) != 1 || !kp.Chain[0].Equal(r.CA.Cert) {
+		t.Fatalf("expected server cert to be bundled with the intermediate, received chain of %d", len(kp.Chain))
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(corporate.CA.Cert)
+	intermediates := x509.NewCertPool()
+	for _, cert := range kp.Chain {
+		intermediates.AddCert(cert)
+	}
+	if _, err := kp.Cert.Verify(x509.VerifyOptions{
+		DNSName:       "127.0.0.1",
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.X509KeyPair(kp.CertPEM, kp.KeyPEM); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateSANs(t *testing.T) {
+	dnsNames, ips, err := ParseSANs([]string{"etcd.internal", "*.etcd.internal", "10.0.0.1", "fd00::1", "etcd.internal", " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"etcd.internal", "*.etcd.internal"}, dnsNames); diff != "" {
+		t.Errorf("dns names: (-want +got)\n%s", diff)
+	}
+	if len(ips) != 2 || !ips[0].Equal(net.ParseIP("10.0.0.1")) || !ips[1].Equal(net.ParseIP("fd00::1")) {
+		t.Errorf("unexpected ips: %v", ips)
+	}
+	for _, san := range []string{"etcd_internal", "-etcd.internal", "etcd..internal", "etcd.*.internal"} {
+		if _, _, err := ParseSANs([]string{san}); err == nil {
+			t.Errorf("expected error parsing %#v", san)
+		}
+	}
+
+	r, err := NewDefaultRootCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, err := NewCertificateRequest(nil, "etcd server", dnsNames, ips)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp, err := r.GenerateCertificates(ServerSigningProfile, cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"etcd.internal", "*.etcd.internal"}, kp.Cert.DNSNames); diff != "" {
+		t.Errorf("cert dns names: (-want +got)\n%s", diff)
+	}
+	for _, name := range []string{"etcd.internal", "node1.etcd.internal", "10.0.0.1", "fd00::1"} {
+		if err := kp.Cert.VerifyHostname(name); err != nil {
+			t.Error(err)
+		}
+	}
+}
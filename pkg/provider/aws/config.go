@@ -19,17 +19,39 @@ import (
 )
 
 func NewConfig() (*aws.Config, error) {
-	sess, err := session.NewSession()
+	return NewConfigWithCredentials("", "")
+}
+
+// NewConfigWithCredentials returns a config using the provided static
+// credentials, which allows running outside of EC2 without an instance
+// profile. The default credential chain is used when they are unset. The
+// region is taken from the environment or shared config when set there, and
+// otherwise from the instance metadata.
+func NewConfigWithCredentials(accessKey, secretKey string) (*aws.Config, error) {
+	cfg := &aws.Config{}
+	if accessKey != "" || secretKey != "" {
+		if accessKey == "" || secretKey == "" {
+			return nil, errors.New("must provide both the AWS access key and secret key")
+		}
+		cfg.Credentials = credentials.NewStaticCredentials(accessKey, secretKey, "")
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
 	if err != nil {
 		return nil, err
 	}
+	if region := aws.StringValue(sess.Config.Region); region != "" {
+		cfg.Region = aws.String(region)
+		log.Debugf("%#v", cfg)
+		return cfg, nil
+	}
 	doc, err := ec2metadata.New(sess).GetInstanceIdentityDocument()
 	if err != nil {
 		return nil, err
 	}
-	cfg := &aws.Config{
-		Region: aws.String(doc.Region),
-	}
+	cfg.Region = aws.String(doc.Region)
 	log.Debugf("%#v", cfg)
 	return cfg, nil
 }
@@ -0,0 +1,36 @@
+package aws
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestNewConfigWithCredentials(t *testing.T) {
+	// the region is set so that the instance metadata is not used
+	os.Setenv("AWS_REGION", "us-east-1")
+	defer os.Unsetenv("AWS_REGION")
+
+	cfg, err := NewConfigWithCredentials("AKIAEXAMPLE", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(cfg.Region); got != "us-east-1" {
+		t.Errorf("Region = %q, want %q", got, "us-east-1")
+	}
+	if cfg.Credentials == nil {
+		t.Fatal("expected static credentials")
+	}
+	v, err := cfg.Credentials.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.AccessKeyID != "AKIAEXAMPLE" || v.SecretAccessKey != "secret" {
+		t.Errorf("credentials = %q/%q, want %q/%q", v.AccessKeyID, v.SecretAccessKey, "AKIAEXAMPLE", "secret")
+	}
+
+	if _, err := NewConfigWithCredentials("AKIAEXAMPLE", ""); err == nil {
+		t.Error("expected error without the secret key")
+	}
+}
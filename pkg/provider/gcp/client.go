@@ -0,0 +1,232 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/criticalstack/e2d/pkg/netutil"
+)
+
+const (
+	DefaultMetadataEndpoint = "http://metadata.google.internal/computeMetadata/v1"
+	DefaultComputeEndpoint  = "https://compute.googleapis.com/compute/v1"
+)
+
+type Config struct {
+	// MetadataEndpoint is the URL of the GCE metadata server, which defaults
+	// to DefaultMetadataEndpoint.
+	MetadataEndpoint string
+
+	// ComputeEndpoint is the URL of the Compute Engine API, which defaults to
+	// DefaultComputeEndpoint.
+	ComputeEndpoint string
+}
+
+// Client makes requests to the GCE metadata server and the Compute Engine
+// API. Requests to the Compute Engine API are authorized with the tokens of
+// the default service account of the instance.
+type Client struct {
+	metadata *http.Client
+	compute  *http.Client
+
+	metadataEndpoint, computeEndpoint string
+}
+
+func NewClient(cfg *Config) (*Client, error) {
+	c := &Client{
+		metadata:         &http.Client{Timeout: 10 * time.Second},
+		metadataEndpoint: strings.TrimSuffix(cfg.MetadataEndpoint, "/"),
+		computeEndpoint:  strings.TrimSuffix(cfg.ComputeEndpoint, "/"),
+	}
+	if c.metadataEndpoint == "" {
+		c.metadataEndpoint = DefaultMetadataEndpoint
+	}
+	if c.computeEndpoint == "" {
+		c.computeEndpoint = DefaultComputeEndpoint
+	}
+	c.compute = oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, c))
+	c.compute.Timeout = 30 * time.Second
+	return c, nil
+}
+
+// getMetadata returns the value of the metadata key, like instance/name.
+func (c *Client) getMetadata(ctx context.Context, key string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.metadataEndpoint+"/"+key, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := c.metadata.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get metadata: %s", key)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get metadata: %s", key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("cannot get metadata: %s: %s", key, resp.Status)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Token gets an access token for the default service account of the instance
+// from the metadata server.
+func (c *Client) Token() (*oauth2.Token, error) {
+	data, err := c.getMetadata(context.Background(), "instance/service-accounts/default/token")
+	if err != nil {
+		return nil, err
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return nil, errors.Wrap(err, "cannot decode token from metadata server")
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("metadata server returned an empty token")
+	}
+	return &oauth2.Token{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+		Expiry:      time.Now().Add(time.Duration(t.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// do makes a request to the Compute Engine API, decoding the JSON response
+// into v.
+func (c *Client) do(ctx context.Context, method, u string, v interface{}) error {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := c.compute.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("%s %s: %s: %s", method, u, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return errors.WithStack(json.NewDecoder(resp.Body).Decode(v))
+}
+
+// computeURL returns the URL of a Compute Engine resource on the configured
+// endpoint. Resources may be provided as a full URL, like the self links
+// returned by the API, or as a path relative to the API version, like
+// projects/my-project/zones/us-east1-b/instances/my-instance.
+func (c *Client) computeURL(resource string) string {
+	if u, err := url.Parse(resource); err == nil && u.Host != "" {
+		if i := strings.Index(u.Path, "/compute/v1/"); i >= 0 {
+			resource = u.Path[i+len("/compute/v1/"):]
+		}
+	}
+	return c.computeEndpoint + "/" + strings.TrimPrefix(resource, "/")
+}
+
+// getInstanceGroupManager returns the managed instance group that created the
+// instance, like projects/123/zones/us-east1-b/instanceGroupManagers/etcd.
+// Regional managed instance groups are under regions instead of zones.
+func (c *Client) getInstanceGroupManager(ctx context.Context) (string, error) {
+	createdBy, err := c.getMetadata(ctx, "instance/attributes/created-by")
+	if err != nil {
+		return "", errors.Wrap(err, "instance is not part of a managed instance group")
+	}
+	if !strings.Contains(createdBy, "/instanceGroupManagers/") {
+		return "", errors.Errorf("instance was not created by a managed instance group: %#v", createdBy)
+	}
+	return createdBy, nil
+}
+
+// listManagedInstances returns the URLs of the instances in the managed
+// instance group.
+func (c *Client) listManagedInstances(ctx context.Context, igm string) ([]string, error) {
+	instances := make([]string, 0)
+	pageToken := ""
+	for {
+		u := c.computeURL(igm + "/listManagedInstances")
+		if pageToken != "" {
+			u += "?" + url.Values{"pageToken": {pageToken}}.Encode()
+		}
+		var page struct {
+			ManagedInstances []struct {
+				Instance string `json:"instance"`
+			} `json:"managedInstances"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := c.do(ctx, http.MethodPost, u, &page); err != nil {
+			return nil, errors.Wrap(err, "cannot list managed instances")
+		}
+		for _, mi := range page.ManagedInstances {
+			instances = append(instances, mi.Instance)
+		}
+		if page.NextPageToken == "" {
+			return instances, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// getInstanceIPAddress returns the internal IP address of the first network
+// interface of the instance.
+func (c *Client) getInstanceIPAddress(ctx context.Context, instance string) (string, error) {
+	var i struct {
+		NetworkInterfaces []struct {
+			NetworkIP string `json:"networkIP"`
+		} `json:"networkInterfaces"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.computeURL(instance), &i); err != nil {
+		return "", errors.Wrapf(err, "cannot get instance: %s", path.Base(instance))
+	}
+	if len(i.NetworkInterfaces) == 0 {
+		return "", errors.Errorf("instance has no network interfaces: %s", path.Base(instance))
+	}
+	return i.NetworkInterfaces[0].NetworkIP, nil
+}
+
+// GetInstanceGroupAddresses returns the internal IP addresses of the other
+// instances in the managed instance group of this instance.
+func (c *Client) GetInstanceGroupAddresses(ctx context.Context) ([]string, error) {
+	name, err := c.getMetadata(ctx, "instance/name")
+	if err != nil {
+		return nil, err
+	}
+	igm, err := c.getInstanceGroupManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instances, err := c.listManagedInstances(ctx, igm)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0)
+	for _, i := range instances {
+		if path.Base(i) == name {
+			continue
+		}
+		addr, err := c.getInstanceIPAddress(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+		if !netutil.IsRoutableIPv4(addr) {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,124 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/netutil"
+)
+
+// Client makes requests to the Kubernetes API server. Only the few read-only
+// requests needed for peer discovery are implemented, to avoid depending on
+// client-go.
+type Client struct {
+	cfg *Config
+	hc  *http.Client
+}
+
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("must provide the kubernetes api server host")
+	}
+	if _, err := url.Parse(cfg.Host); err != nil {
+		return nil, errors.Wrapf(err, "invalid kubernetes api server host: %#v", cfg.Host)
+	}
+	return &Client{
+		cfg: cfg,
+		hc: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: cfg.TLSConfig,
+			},
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// Pod is the subset of a Kubernetes Pod used for peer discovery.
+type Pod struct {
+	Metadata struct {
+		Name              string     `json:"name"`
+		DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	} `json:"metadata"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+type podList struct {
+	Items []Pod `json:"items"`
+}
+
+// ListPods lists the pods in the namespace matching the label selector (like
+// app=e2d,tier=etcd). The namespace of the Config is used when namespace is
+// empty.
+func (c *Client) ListPods(ctx context.Context, namespace, selector string) ([]Pod, error) {
+	if namespace == "" {
+		namespace = c.cfg.Namespace
+	}
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/pods", strings.TrimSuffix(c.cfg.Host, "/"), url.PathEscape(namespace))
+	if selector != "" {
+		u += "?" + url.Values{"labelSelector": {selector}}.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.BearerToken)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list pods")
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list pods")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("cannot list pods: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var pods podList
+	if err := json.Unmarshal(data, &pods); err != nil {
+		return nil, errors.Wrap(err, "cannot decode pod list")
+	}
+	return pods.Items, nil
+}
+
+// GetAddrsByLabels returns the pod IPs of the running pods in the namespace
+// matching the label selector. The pod this is running in, which is found by
+// its hostname, is excluded.
+func (c *Client) GetAddrsByLabels(ctx context.Context, namespace, selector string) ([]string, error) {
+	pods, err := c.ListPods(ctx, namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	addrs := make([]string, 0)
+	for _, p := range pods {
+		if p.Metadata.Name == hostname || p.Metadata.DeletionTimestamp != nil {
+			continue
+		}
+		if p.Status.Phase != "Running" || !netutil.IsRoutableIPv4(p.Status.PodIP) {
+			continue
+		}
+		addrs = append(addrs, p.Status.PodIP)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
@@ -0,0 +1,206 @@
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config is the connection to the Kubernetes API server.
+type Config struct {
+	// Host is the URL of the API server, like https://10.96.0.1:443.
+	Host string
+
+	// Namespace is the default namespace, from the service account or the
+	// current kubeconfig context.
+	Namespace string
+
+	// BearerToken authenticates requests to the API server.
+	BearerToken string
+
+	// TLSConfig is used to connect to the API server.
+	TLSConfig *tls.Config
+}
+
+// NewConfig returns the Config from the kubeconfig file at the provided path,
+// or, when the path is empty, the in-cluster config of the service account
+// mounted into the pod.
+func NewConfig(kubeconfig string) (*Config, error) {
+	if kubeconfig != "" {
+		return NewConfigFromKubeconfig(kubeconfig)
+	}
+	return NewInClusterConfig()
+}
+
+// NewInClusterConfig returns the Config from the environment and service
+// account that Kubernetes provides to every pod.
+func NewInClusterConfig() (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read service account token")
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read service account ca")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("cannot parse service account ca")
+	}
+	cfg := &Config{
+		Host:        "https://" + net.JoinHostPort(host, port),
+		Namespace:   "default",
+		BearerToken: strings.TrimSpace(string(token)),
+		TLSConfig:   &tls.Config{RootCAs: pool},
+	}
+	if ns, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+		cfg.Namespace = strings.TrimSpace(string(ns))
+	}
+	return cfg, nil
+}
+
+// kubeconfig holds the fields of a kubeconfig file needed to connect to the
+// cluster of the current context.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster   string `json:"cluster"`
+			User      string `json:"user"`
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string `json:"token"`
+			TokenFile             string `json:"tokenFile"`
+			ClientCertificate     string `json:"client-certificate"`
+			ClientCertificateData string `json:"client-certificate-data"`
+			ClientKey             string `json:"client-key"`
+			ClientKeyData         string `json:"client-key-data"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// NewConfigFromKubeconfig returns the Config for the current context of the
+// kubeconfig file at the provided path. Only token and client certificate
+// authentication are supported.
+func NewConfigFromKubeconfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read kubeconfig: %#v", path)
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse kubeconfig: %#v", path)
+	}
+	cfg := &Config{Namespace: "default", TLSConfig: &tls.Config{}}
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+			if c.Context.Namespace != "" {
+				cfg.Namespace = c.Context.Namespace
+			}
+			break
+		}
+	}
+	if clusterName == "" {
+		return nil, errors.Errorf("kubeconfig current context not found: %#v", kc.CurrentContext)
+	}
+
+	// relative file paths in a kubeconfig are relative to the kubeconfig
+	resolve := func(name string) string {
+		if name == "" || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(filepath.Dir(path), name)
+	}
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		cfg.Host = c.Cluster.Server
+		cfg.TLSConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		ca, err := readData(c.Cluster.CertificateAuthorityData, resolve(c.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read kubeconfig certificate authority")
+		}
+		if ca != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, errors.New("cannot parse kubeconfig certificate authority")
+			}
+			cfg.TLSConfig.RootCAs = pool
+		}
+	}
+	if cfg.Host == "" {
+		return nil, errors.Errorf("kubeconfig cluster not found: %#v", clusterName)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cfg.BearerToken = u.User.Token
+		if u.User.TokenFile != "" {
+			token, err := ioutil.ReadFile(resolve(u.User.TokenFile))
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot read kubeconfig token file")
+			}
+			cfg.BearerToken = strings.TrimSpace(string(token))
+		}
+		cert, err := readData(u.User.ClientCertificateData, resolve(u.User.ClientCertificate))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read kubeconfig client certificate")
+		}
+		key, err := readData(u.User.ClientKeyData, resolve(u.User.ClientKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read kubeconfig client key")
+		}
+		if cert != nil || key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot load kubeconfig client certificate")
+			}
+			cfg.TLSConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	return cfg, nil
+}
+
+// readData returns the base64-decoded data when set, or otherwise the
+// contents of the named file. It returns nil when neither are set.
+func readData(data, name string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if name != "" {
+		return ioutil.ReadFile(name)
+	}
+	return nil, nil
+}
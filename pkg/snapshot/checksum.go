@@ -0,0 +1,78 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// ErrChecksumMismatch is returned by Load when a snapshot does not match the
+// checksum stored alongside it, for example because an upload was truncated.
+var ErrChecksumMismatch = errors.New("snapshot checksum mismatch")
+
+// checksumKey is the key of the sidecar object holding the sha256 checksum of
+// the snapshot stored at key.
+func checksumKey(key string) string {
+	return key + ".sha256"
+}
+
+// checksumReadCloser computes the sha256 checksum of everything read from the
+// wrapped ReadCloser.
+type checksumReadCloser struct {
+	io.ReadCloser
+	h hash.Hash
+}
+
+func newChecksumReadCloser(r io.ReadCloser) *checksumReadCloser {
+	return &checksumReadCloser{ReadCloser: r, h: sha256.New()}
+}
+
+func (c *checksumReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+// checksum returns the contents of the sidecar object for the snapshot at
+// key, which uses the same format as sha256sum so it can be checked by hand.
+func (c *checksumReadCloser) checksum(key string) []byte {
+	return []byte(fmt.Sprintf("%x  %s\n", c.h.Sum(nil), path.Base(key)))
+}
+
+// verifyChecksum compares the contents of f with the checksum read from a
+// sidecar object, and rewinds f so it can be read again. The check is skipped
+// when there is no checksum, such as for snapshots saved by older versions.
+func verifyChecksum(f *os.File, checksum []byte) error {
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	if checksum == nil {
+		return nil
+	}
+	fields := bytes.Fields(checksum)
+	if len(fields) == 0 {
+		return errors.Wrap(ErrChecksumMismatch, "checksum is empty")
+	}
+	expected, err := hex.DecodeString(string(fields[0]))
+	if err != nil {
+		return errors.Wrapf(ErrChecksumMismatch, "cannot decode checksum: %v", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); !bytes.Equal(expected, actual) {
+		return errors.Wrapf(ErrChecksumMismatch, "expected %x, got %x", expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestFileSnapshotterChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "etcd.snapshot")
+	s, err := NewFileSnapshotter(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ioutil.NopCloser(bytes.NewReader([]byte("snapshot data")))); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	// a truncated snapshot is rejected
+	if err := ioutil.WriteFile(path, []byte("snapshot"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); errors.Cause(err) != ErrChecksumMismatch {
+		t.Fatalf("expected %v, received %v", ErrChecksumMismatch, err)
+	}
+
+	// a snapshot without a checksum is loaded as-is
+	if err := os.Remove(checksumKey(path)); err != nil {
+		t.Fatal(err)
+	}
+	r, err = s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
+
+func TestGCSSnapshotterChecksum(t *testing.T) {
+	f := &fakeGCS{bucket: "abc", objects: make(map[string][]byte)}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	s, err := newGCSSnapshotter(ts.Client(), ts.URL, "abc", "etcd.snapshot", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ioutil.NopCloser(bytes.NewReader([]byte("snapshot data")))); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.objects["etcd.snapshot"][0] ^= 0xff
+	f.mu.Unlock()
+
+	if _, err := s.Load(); errors.Cause(err) != ErrChecksumMismatch {
+		t.Fatalf("expected %v, received %v", ErrChecksumMismatch, err)
+	}
+}
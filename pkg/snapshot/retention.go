@@ -0,0 +1,95 @@
+package snapshot
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Pruner is implemented by Snapshotters that keep a history of snapshots
+// rather than overwriting a single snapshot. Prune removes all but the most
+// recent snapshots allowed by the configured retention.
+type Pruner interface {
+	Prune() error
+}
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+// timestampedPrefix returns the common prefix of all timestamped snapshots
+// for key.
+func timestampedPrefix(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "-"
+}
+
+// timestampedKey inserts the time before the extension of key, so that
+// backups/etcd.snapshot becomes backups/etcd-20200101T120000Z.snapshot.
+func timestampedKey(key string, t time.Time) string {
+	return timestampedPrefix(key) + t.UTC().Format(snapshotTimeFormat) + path.Ext(key)
+}
+
+type timestampedSnapshot struct {
+	key string
+	t   time.Time
+}
+
+// sortTimestamped returns the keys that are timestamped snapshots for key,
+// ordered newest first. Any other keys are ignored.
+func sortTimestamped(key string, keys []string) []string {
+	prefix, ext := timestampedPrefix(key), path.Ext(key)
+	snapshots := make([]timestampedSnapshot, 0)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, ext) {
+			continue
+		}
+		t, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(strings.TrimPrefix(k, prefix), ext))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, timestampedSnapshot{key: k, t: t})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].t.After(snapshots[j].t)
+	})
+	sorted := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		sorted = append(sorted, s.key)
+	}
+	return sorted
+}
+
+// latestSnapshot returns the newest timestamped snapshot for key. When there
+// are none, key itself is returned so that a snapshot saved before retention
+// was enabled can still be loaded.
+func latestSnapshot(key string, keys []string) string {
+	if sorted := sortTimestamped(key, keys); len(sorted) > 0 {
+		return sorted[0]
+	}
+	return key
+}
+
+// namedSnapshot returns the snapshot for key with the base name, which is
+// either one of the timestamped snapshots or key itself.
+func namedSnapshot(key string, keys []string, name string) (string, error) {
+	if name == path.Base(key) {
+		return key, nil
+	}
+	for _, k := range sortTimestamped(key, keys) {
+		if path.Base(k) == name {
+			return k, nil
+		}
+	}
+	return "", errors.Wrapf(ErrSnapshotNotFound, "%#v", name)
+}
+
+// expiredSnapshots returns the timestamped snapshots for key that are older
+// than the newest keep snapshots.
+func expiredSnapshots(key string, keys []string, keep int) []string {
+	sorted := sortTimestamped(key, keys)
+	if len(sorted) <= keep {
+		return nil
+	}
+	return sorted[keep:]
+}
@@ -0,0 +1,227 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestTimestampedKey(t *testing.T) {
+	ts := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "etcd.snapshot", expected: "etcd-20200101T120000Z.snapshot"},
+		{key: "backups/snapshot.gz", expected: "backups/snapshot-20200101T120000Z.gz"},
+		{key: "backups.d/snapshot", expected: "backups.d/snapshot-20200101T120000Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expected, timestampedKey(tt.key, ts)); diff != "" {
+				t.Errorf("snapshot: after timestampedKey differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortTimestamped(t *testing.T) {
+	keys := []string{
+		"backups/etcd-20200101T120000Z.snapshot",
+		"backups/etcd.snapshot",
+		"backups/etcd-20200103T120000Z.snapshot",
+		"backups/etcd-notatime.snapshot",
+		"backups/other-20200104T120000Z.snapshot",
+		"backups/etcd-20200102T120000Z.snapshot",
+	}
+	expected := []string{
+		"backups/etcd-20200103T120000Z.snapshot",
+		"backups/etcd-20200102T120000Z.snapshot",
+		"backups/etcd-20200101T120000Z.snapshot",
+	}
+	if diff := cmp.Diff(expected, sortTimestamped("backups/etcd.snapshot", keys)); diff != "" {
+		t.Errorf("snapshot: after sortTimestamped differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff(expected[1:], expiredSnapshots("backups/etcd.snapshot", keys, 1)); diff != "" {
+		t.Errorf("snapshot: after expiredSnapshots differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff("backups/etcd.snapshot", latestSnapshot("backups/etcd.snapshot", keys[1:2])); diff != "" {
+		t.Errorf("snapshot: after latestSnapshot differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestFileSnapshotterRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "etcd.snapshot")
+	s, err := NewFileSnapshotter(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range []string{"a", "b", "c"} {
+		p := timestampedKey(path, time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC))
+		if err := ioutil.WriteFile(p, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(dir, "etcd-20200101T000001Z.snapshot"),
+		filepath.Join(dir, "etcd-20200101T000002Z.snapshot"),
+	}
+	if diff := cmp.Diff(expected, files); diff != "" {
+		t.Errorf("snapshot: after Prune differs: (-want +got)\n%s", diff)
+	}
+	if err := s.Save(ioutil.NopCloser(bytes.NewReader([]byte("d")))); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("d", string(data)); diff != "" {
+		t.Errorf("snapshot: after Load differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestFileSnapshotterLoadNamed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "etcd.snapshot")
+	s, err := NewFileSnapshotter(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("untimestamped"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range []string{"a", "b", "c"} {
+		p := timestampedKey(path, time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC))
+		if err := ioutil.WriteFile(p, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the latest snapshot is corrupt, so only a named snapshot can be loaded
+	latest := timestampedKey(path, time.Date(2020, 1, 1, 0, 0, 2, 0, time.UTC))
+	if err := ioutil.WriteFile(checksumKey(latest), []byte("0000  etcd-20200101T000002Z.snapshot\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); errors.Cause(err) != ErrChecksumMismatch {
+		t.Fatalf("expected %v, received %v", ErrChecksumMismatch, err)
+	}
+	if _, err := LoadNamed(s, "etcd-20200101T000002Z.snapshot"); errors.Cause(err) != ErrChecksumMismatch {
+		t.Fatalf("expected %v, received %v", ErrChecksumMismatch, err)
+	}
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"etcd-20200101T000000Z.snapshot", "a"},
+		{"etcd-20200101T000001Z.snapshot", "b"},
+		{"etcd.snapshot", "untimestamped"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := LoadNamed(s, c.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.expected, string(data)); diff != "" {
+				t.Errorf("snapshot: after LoadNamed differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+
+	// only snapshots for the snapshot file can be loaded, not any file in the
+	// same directory
+	if err := ioutil.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"other", "etcd-20200102T000000Z.snapshot", "../etcd.snapshot"} {
+		if _, err := LoadNamed(s, name); errors.Cause(err) != ErrSnapshotNotFound {
+			t.Fatalf("expected %v for %#v, received %v", ErrSnapshotNotFound, name, err)
+		}
+	}
+
+	// a Snapshotter that cannot load named snapshots only loads the latest
+	m := NewInMemorySnapshotter()
+	if err := m.Save(ioutil.NopCloser(bytes.NewReader([]byte("d")))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadNamed(m, "etcd.snapshot"); err == nil {
+		t.Fatal("expected error loading named snapshot")
+	}
+	r, err := LoadNamed(m, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
+
+func TestMultiSnapshotterLoadNamed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "etcd.snapshot")
+	fs, err := NewFileSnapshotter(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Base(timestampedKey(path, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshotter without named snapshots is skipped
+	m := NewMultiSnapshotter(&memSnapshotter{data: []byte("mem")}, fs)
+	r, err := m.LoadNamed(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("a", string(data)); diff != "" {
+		t.Errorf("snapshot: after LoadNamed differs: (-want +got)\n%s", diff)
+	}
+}
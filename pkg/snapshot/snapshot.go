@@ -14,9 +14,36 @@ type Snapshotter interface {
 	Save(io.ReadCloser) error
 }
 
+// ErrSnapshotNotFound is returned when loading a named snapshot that does not
+// exist.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// NamedLoader is implemented by Snapshotters that can load a specific
+// snapshot, rather than the latest. The name is the base name of the
+// snapshot, such as etcd-20200101T120000Z.snapshot for a timestamped snapshot
+// kept with retention.
+type NamedLoader interface {
+	LoadNamed(name string) (io.ReadCloser, error)
+}
+
+// LoadNamed loads the named snapshot from s, or the latest snapshot when name
+// is empty. An error is returned for a name when s does not implement
+// NamedLoader, rather than silently loading a different snapshot.
+func LoadNamed(s Snapshotter, name string) (io.ReadCloser, error) {
+	if name == "" {
+		return s.Load()
+	}
+	nl, ok := s.(NamedLoader)
+	if !ok {
+		return nil, errors.Errorf("cannot load snapshot %#v: named snapshots not supported by %T", name, s)
+	}
+	return nl.LoadNamed(name)
+}
+
 var schemes = []string{
 	"file://",
 	"s3://",
+	"gs://",
 	"http://",
 	"https://",
 }
@@ -36,6 +63,7 @@ const (
 	FileType Type = iota
 	S3Type
 	SpacesType
+	GCSType
 )
 
 type URL struct {
@@ -44,51 +72,101 @@ type URL struct {
 	Path   string
 }
 
+// The errors returned for snapshot backup URLs that cannot be used, which are
+// wrapped with the URL.
 var (
-	ErrInvalidScheme  = errors.New("invalid scheme")
+	// ErrInvalidScheme is returned for a URL without one of the supported
+	// schemes, or an http(s) URL that is not for DigitalOcean Spaces.
+	ErrInvalidScheme = errors.New("invalid scheme")
+
+	// ErrCannotParseURL is returned for a URL that is malformed.
 	ErrCannotParseURL = errors.New("cannot parse url")
+
+	// ErrMissingBucket is returned for a cloud storage URL without a bucket.
+	ErrMissingBucket = errors.New("missing bucket")
+
+	// ErrMissingPath is returned for a URL without a file path, or with a
+	// path to a directory rather than a file.
+	ErrMissingPath = errors.New("missing path")
+
+	// ErrRelativePath is returned for a file URL with a relative path, such
+	// as file://etcd.snapshot, where the first part of the path is parsed as
+	// the host of the URL.
+	ErrRelativePath = errors.New("relative path")
 )
 
 // ParseSnapshotBackupURL deconstructs a uri into a type prefix and a bucket
 // example inputs and outputs:
-//   file://file                                -> file://, file
+//   file:///file                               -> file://, /file
 //   s3://bucket                                -> s3://, bucket
+//   gs://bucket                                -> gs://, bucket
 //   https://nyc3.digitaloceanspaces.com/bucket -> digitaloceanspaces, bucket
+//
+// The URL is validated, so that a URL that cannot be used fails when parsed
+// rather than when the first snapshot backup is saved.
 func ParseSnapshotBackupURL(s string) (*URL, error) {
 	if !hasValidScheme(s) {
 		return nil, errors.Wrapf(ErrInvalidScheme, "url does not specify valid scheme: %#v", s)
 	}
 	u, err := url.Parse(s)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(ErrCannotParseURL, "%#v: %v", s, err)
 	}
 
+	var result *URL
 	switch strings.ToLower(u.Scheme) {
 	case "file":
-		return &URL{
+		if u.Host != "" {
+			return nil, errors.Wrapf(ErrRelativePath, "file url must have an absolute path, like file:///%s: %#v", filepath.Join(u.Host, u.Path), s)
+		}
+
+		// the path is cleaned after checking for a directory, which would
+		// remove the trailing slash
+		if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+			return nil, errors.Wrapf(ErrMissingPath, "url does not specify a file: %#v", s)
+		}
+		result = &URL{
 			Type: FileType,
-			Path: filepath.Join(u.Host, u.Path),
-		}, nil
+			Path: filepath.Clean(u.Path),
+		}
 	case "s3":
 		if u.Path == "" {
 			u.Path = "etcd.snapshot"
 		}
-		return &URL{
+		result = &URL{
 			Type:   S3Type,
 			Bucket: u.Host,
 			Path:   strings.TrimPrefix(u.Path, "/"),
-		}, nil
+		}
+	case "gs":
+		if u.Path == "" {
+			u.Path = "etcd.snapshot"
+		}
+		result = &URL{
+			Type:   GCSType,
+			Bucket: u.Host,
+			Path:   strings.TrimPrefix(u.Path, "/"),
+		}
 	case "http", "https":
-		if strings.Contains(u.Host, "digitaloceanspaces") {
-			bucket, path := parseBucketKey(strings.TrimPrefix(u.Path, "/"))
-			return &URL{
-				Type:   SpacesType,
-				Bucket: bucket,
-				Path:   path,
-			}, nil
+		if !strings.Contains(u.Host, "digitaloceanspaces") {
+			return nil, errors.Wrapf(ErrInvalidScheme, "http(s) urls are only supported for DigitalOcean Spaces: %#v", s)
+		}
+		bucket, path := parseBucketKey(strings.TrimPrefix(u.Path, "/"))
+		result = &URL{
+			Type:   SpacesType,
+			Bucket: bucket,
+			Path:   path,
 		}
+	default:
+		return nil, errors.Wrap(ErrCannotParseURL, s)
+	}
+	if result.Type != FileType && result.Bucket == "" {
+		return nil, errors.Wrapf(ErrMissingBucket, "url does not specify a bucket: %#v", s)
+	}
+	if result.Path == "" || strings.HasSuffix(result.Path, "/") {
+		return nil, errors.Wrapf(ErrMissingPath, "url does not specify a file: %#v", s)
 	}
-	return nil, errors.Wrap(ErrCannotParseURL, s)
+	return result, nil
 }
 
 func parseBucketKey(s string) (string, string) {
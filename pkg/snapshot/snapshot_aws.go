@@ -1,6 +1,7 @@
 package snapshot
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
@@ -27,6 +28,32 @@ type AmazonConfig struct {
 	RoleSessionName string
 	Bucket          string
 	Key             string
+
+	// number of timestamped snapshots to keep, when zero a single snapshot
+	// is overwritten
+	Retention int
+
+	// size in bytes of each part of a multipart upload, defaults to 5MiB
+	// which is the minimum allowed by S3
+	PartSize int64
+
+	// number of parts of a multipart upload sent concurrently, defaults to 5
+	Concurrency int
+
+	// time an upload is allowed to go without making progress before it is
+	// canceled, defaults to 1 minute. Since it is only reached when an upload
+	// stalls, the total time allowed grows with the size of the snapshot.
+	UploadTimeout time.Duration
+
+	// server-side encryption of uploaded snapshots, either "AES256" or
+	// "aws:kms". This is independent of SnapshotEncryption, which encrypts
+	// snapshots before they are uploaded.
+	ServerSideEncryption string
+
+	// id of the KMS key used when ServerSideEncryption is "aws:kms", which
+	// is implied when only this is set. When unset, the AWS managed key for
+	// S3 is used.
+	SSEKMSKeyID string
 }
 
 type AmazonSnapshotter struct {
@@ -34,7 +61,11 @@ type AmazonSnapshotter struct {
 	*s3manager.Downloader
 	*s3manager.Uploader
 
-	bucket, key string
+	bucket, key   string
+	retention     int
+	uploadTimeout time.Duration
+
+	sse, sseKMSKeyID string
 }
 
 func NewAmazonSnapshotter(cfg *AmazonConfig) (*AmazonSnapshotter, error) {
@@ -42,20 +73,49 @@ func NewAmazonSnapshotter(cfg *AmazonConfig) (*AmazonSnapshotter, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newAmazonSnapshotter(awsCfg, cfg.Bucket, cfg.Key)
+	return newAmazonSnapshotter(awsCfg, cfg)
 }
 
-func newAmazonSnapshotter(cfg *aws.Config, bucket, key string) (*AmazonSnapshotter, error) {
-	sess, err := session.NewSession(cfg)
+func newAmazonSnapshotter(awsCfg *aws.Config, cfg *AmazonConfig) (*AmazonSnapshotter, error) {
+	if cfg.PartSize != 0 && cfg.PartSize < s3manager.MinUploadPartSize {
+		return nil, errors.Errorf("upload part size must be at least %d bytes, received %d", s3manager.MinUploadPartSize, cfg.PartSize)
+	}
+	if cfg.UploadTimeout == 0 {
+		cfg.UploadTimeout = 1 * time.Minute
+	}
+	if cfg.SSEKMSKeyID != "" && cfg.ServerSideEncryption == "" {
+		cfg.ServerSideEncryption = s3.ServerSideEncryptionAwsKms
+	}
+	switch cfg.ServerSideEncryption {
+	case "", s3.ServerSideEncryptionAes256:
+		if cfg.SSEKMSKeyID != "" {
+			return nil, errors.Errorf("SSEKMSKeyID requires ServerSideEncryption %#v, received %#v", s3.ServerSideEncryptionAwsKms, cfg.ServerSideEncryption)
+		}
+	case s3.ServerSideEncryptionAwsKms:
+	default:
+		return nil, errors.Errorf("invalid ServerSideEncryption: %#v", cfg.ServerSideEncryption)
+	}
+	sess, err := session.NewSession(awsCfg)
 	if err != nil {
 		return nil, err
 	}
 	s := &AmazonSnapshotter{
 		S3:         s3.New(sess),
 		Downloader: s3manager.NewDownloader(sess),
-		Uploader:   s3manager.NewUploader(sess),
-		bucket:     bucket,
-		key:        key,
+		Uploader: s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+			if cfg.PartSize > 0 {
+				u.PartSize = cfg.PartSize
+			}
+			if cfg.Concurrency > 0 {
+				u.Concurrency = cfg.Concurrency
+			}
+		}),
+		bucket:        cfg.Bucket,
+		key:           cfg.Key,
+		retention:     cfg.Retention,
+		uploadTimeout: cfg.UploadTimeout,
+		sse:           cfg.ServerSideEncryption,
+		sseKMSKeyID:   cfg.SSEKMSKeyID,
 	}
 
 	// Ensure that the bucket exists
@@ -67,9 +127,9 @@ func newAmazonSnapshotter(cfg *aws.Config, bucket, key string) (*AmazonSnapshott
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			switch reqErr.StatusCode() {
 			case http.StatusNotFound:
-				return nil, errors.Errorf("bucket %s does not exist", bucket)
+				return nil, errors.Errorf("bucket %s does not exist", s.bucket)
 			case http.StatusForbidden:
-				return nil, errors.Errorf("access to bucket %s forbidden", bucket)
+				return nil, errors.Errorf("access to bucket %s forbidden", s.bucket)
 			default:
 				return nil, errors.Errorf("bucket could not be accessed: %v", err)
 			}
@@ -78,34 +138,183 @@ func newAmazonSnapshotter(cfg *aws.Config, bucket, key string) (*AmazonSnapshott
 	return s, nil
 }
 
+func (s *AmazonSnapshotter) list(ctx context.Context) ([]string, error) {
+	keys := make([]string, 0)
+	err := s.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(timestampedPrefix(s.key)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list snapshots: %v", s.key)
+	}
+	return keys, nil
+}
+
 func (s *AmazonSnapshotter) Load() (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	key := s.key
+	if s.retention > 0 {
+		keys, err := s.list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		key = latestSnapshot(s.key, keys)
+	}
+	return s.load(ctx, key)
+}
+
+// LoadNamed loads the snapshot with the base name, either one of the
+// timestamped snapshots or the snapshot key itself.
+func (s *AmazonSnapshotter) LoadNamed(name string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	keys, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := namedSnapshot(s.key, keys, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(ctx, key)
+}
+
+func (s *AmazonSnapshotter) load(ctx context.Context, key string) (io.ReadCloser, error) {
 	tmpFile, err := ioutil.TempFile("", "snapshot.download")
 	if err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
 	if _, err = s.DownloadWithContext(ctx, tmpFile, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(s.key),
+		Key:    aws.String(key),
 	}); err != nil {
 		tmpFile.Close()
-		return nil, errors.Wrapf(err, "cannot download file: %v", s.key)
+		return nil, errors.Wrapf(err, "cannot download file: %v", key)
 	}
-	if _, err := tmpFile.Seek(0, 0); err != nil {
+	checksum, err := s.loadChecksum(ctx, key)
+	if err != nil {
+		tmpFile.Close()
 		return nil, err
 	}
+	if err := verifyChecksum(tmpFile, checksum); err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrapf(err, "cannot verify snapshot: %v", key)
+	}
 	return tmpFile, nil
 }
 
+// loadChecksum downloads the checksum stored alongside the snapshot at key,
+// returning nil if there is none.
+func (s *AmazonSnapshotter) loadChecksum(ctx context.Context, key string) ([]byte, error) {
+	buf := aws.NewWriteAtBuffer(nil)
+	if _, err := s.DownloadWithContext(ctx, buf, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(checksumKey(key)),
+	}); err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "cannot download file: %v", checksumKey(key))
+	}
+	return buf.Bytes(), nil
+}
+
 func (s *AmazonSnapshotter) Save(r io.ReadCloser) error {
 	defer r.Close()
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	_, err := s.UploadWithContext(ctx, &s3manager.UploadInput{
-		Body:   r,
+	cr := newChecksumReadCloser(r)
+	ir := newIdleTimeoutReader(cr, s.uploadTimeout, cancel)
+	defer ir.stop()
+	key := s.key
+	if s.retention > 0 {
+		key = timestampedKey(s.key, time.Now())
+	}
+
+	// the previous checksum is removed first, so that a failure to upload the
+	// new checksum doesn't leave a stale one for a different snapshot
+	if _, err := s.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(s.key),
-	})
+		Key:    aws.String(checksumKey(key)),
+	}); err != nil {
+		return errors.Wrapf(err, "cannot delete file: %v", checksumKey(key))
+	}
+	if _, err := s.UploadWithContext(ctx, s.uploadInput(key, ir)); err != nil {
+		return err
+	}
+	_, err := s.UploadWithContext(ctx, s.uploadInput(checksumKey(key), bytes.NewReader(cr.checksum(key))))
 	return err
 }
+
+func (s *AmazonSnapshotter) uploadInput(key string, r io.Reader) *s3manager.UploadInput {
+	input := &s3manager.UploadInput{
+		Body:   r,
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if s.sse != "" {
+		input.ServerSideEncryption = aws.String(s.sse)
+	}
+	if s.sseKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+	}
+	return input
+}
+
+func (s *AmazonSnapshotter) Prune() error {
+	if s.retention == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	keys, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range expiredSnapshots(s.key, keys, s.retention) {
+		for _, k := range []string{key, checksumKey(key)} {
+			if _, err := s.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(k),
+			}); err != nil {
+				return errors.Wrapf(err, "cannot delete snapshot: %v", k)
+			}
+		}
+	}
+	return nil
+}
+
+// idleTimeoutReader calls cancel when nothing has been read for the timeout,
+// rather than after a fixed amount of time, so that uploads of any size are
+// allowed to finish as long as they keep making progress.
+type idleTimeoutReader struct {
+	io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func newIdleTimeoutReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	return &idleTimeoutReader{
+		Reader:  r,
+		timer:   time.AfterFunc(timeout, cancel),
+		timeout: timeout,
+	}
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) stop() {
+	r.timer.Stop()
+}
@@ -0,0 +1,72 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.r.Read(p)
+}
+
+func TestIdleTimeoutReader(t *testing.T) {
+	// reading takes much longer than the timeout in total, but is never idle
+	// for longer than the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := newIdleTimeoutReader(&slowReader{r: strings.NewReader("0123456789"), delay: 20 * time.Millisecond}, 100*time.Millisecond, cancel)
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	r.stop()
+	if ctx.Err() != nil {
+		t.Fatalf("expected context to not be canceled, received %v", ctx.Err())
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	r = newIdleTimeoutReader(&slowReader{r: strings.NewReader("0"), delay: 200 * time.Millisecond}, 50*time.Millisecond, cancel)
+	defer r.stop()
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected context to be canceled, received %v", ctx.Err())
+	}
+}
+
+func TestAmazonSnapshotterSSE(t *testing.T) {
+	for _, cfg := range []*AmazonConfig{
+		{ServerSideEncryption: "bogus"},
+		{ServerSideEncryption: s3.ServerSideEncryptionAes256, SSEKMSKeyID: "key"},
+	} {
+		if _, err := newAmazonSnapshotter(&aws.Config{}, cfg); err == nil {
+			t.Fatalf("expected error for invalid server-side encryption: %+v", cfg)
+		}
+	}
+
+	s := &AmazonSnapshotter{bucket: "abc", sse: s3.ServerSideEncryptionAwsKms, sseKMSKeyID: "key"}
+	input := s.uploadInput("etcd.snapshot", strings.NewReader(""))
+	if aws.StringValue(input.ServerSideEncryption) != s3.ServerSideEncryptionAwsKms {
+		t.Fatalf("expected %#v, received %#v", s3.ServerSideEncryptionAwsKms, aws.StringValue(input.ServerSideEncryption))
+	}
+	if aws.StringValue(input.SSEKMSKeyId) != "key" {
+		t.Fatalf("expected %#v, received %#v", "key", aws.StringValue(input.SSEKMSKeyId))
+	}
+}
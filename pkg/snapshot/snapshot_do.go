@@ -13,6 +13,10 @@ type DigitalOceanConfig struct {
 	SpacesURL       string
 	SpacesAccessKey string
 	SpacesSecretKey string
+
+	// number of timestamped snapshots to keep, when zero a single snapshot
+	// is overwritten
+	Retention int
 }
 
 func parseSpacesURL(s string) (string, string, string, error) {
@@ -39,7 +43,11 @@ func NewDigitalOceanSnapshotter(cfg *DigitalOceanConfig) (*DigitalOceanSnapshott
 		// This is counter intuitive, but it will fail with a non-AWS region name.
 		Region: aws.String("us-east-1"),
 	}
-	s, err := newAmazonSnapshotter(awsCfg, spaceName, key)
+	s, err := newAmazonSnapshotter(awsCfg, &AmazonConfig{
+		Bucket:    spaceName,
+		Key:       key,
+		Retention: cfg.Retention,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -2,35 +2,125 @@ package snapshot
 
 import (
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type FileSnapshotter struct {
 	file string
+
+	// number of timestamped snapshots to keep, when zero a single snapshot
+	// is overwritten
+	retention int
 }
 
-func NewFileSnapshotter(path string) (*FileSnapshotter, error) {
+func NewFileSnapshotter(path string, retention int) (*FileSnapshotter, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil && !os.IsExist(err) {
 		return nil, errors.Wrapf(err, "cannot create snapshot directory: %#v", filepath.Dir(path))
 	}
-	return &FileSnapshotter{file: path}, nil
+	return &FileSnapshotter{file: path, retention: retention}, nil
+}
+
+func (fs *FileSnapshotter) list() ([]string, error) {
+	dir := filepath.Dir(fs.file)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, f := range files {
+		if f.Mode().IsRegular() {
+			names = append(names, filepath.Join(dir, f.Name()))
+		}
+	}
+	return names, nil
 }
 
 func (fs *FileSnapshotter) Load() (io.ReadCloser, error) {
-	return os.Open(fs.file)
+	path := fs.file
+	if fs.retention > 0 {
+		names, err := fs.list()
+		if err != nil {
+			return nil, err
+		}
+		path = latestSnapshot(fs.file, names)
+	}
+	return fs.load(path)
+}
+
+// LoadNamed loads the snapshot in the same directory as the snapshot file
+// with the base name, which is checked against the checksum the same as the
+// latest snapshot.
+func (fs *FileSnapshotter) LoadNamed(name string) (io.ReadCloser, error) {
+	names, err := fs.list()
+	if err != nil {
+		return nil, err
+	}
+	path, err := namedSnapshot(fs.file, names, name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.load(path)
+}
+
+func (fs *FileSnapshotter) load(path string) (io.ReadCloser, error) {
+	checksum, err := ioutil.ReadFile(checksumKey(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(f, checksum); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "cannot verify snapshot: %#v", path)
+	}
+	return f, nil
 }
 
 func (fs *FileSnapshotter) Save(r io.ReadCloser) error {
 	defer r.Close()
-	f, err := os.OpenFile(fs.file, os.O_RDWR|os.O_CREATE, 0600)
+	path := fs.file
+	if fs.retention > 0 {
+		path = timestampedKey(fs.file, time.Now())
+	}
+	// the previous checksum is removed first, so that a failure to write the
+	// new checksum doesn't leave a stale one for a different snapshot
+	if err := os.Remove(checksumKey(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	_, err = io.Copy(f, r)
-	return err
+	cr := newChecksumReadCloser(r)
+	if _, err := io.Copy(f, cr); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(checksumKey(path), cr.checksum(path), 0600)
+}
+
+func (fs *FileSnapshotter) Prune() error {
+	if fs.retention == 0 {
+		return nil
+	}
+	names, err := fs.list()
+	if err != nil {
+		return err
+	}
+	for _, name := range expiredSnapshots(fs.file, names, fs.retention) {
+		for _, path := range []string{name, checksumKey(name)} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "cannot remove snapshot: %#v", path)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,377 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	gcsEndpoint  = "https://storage.googleapis.com"
+	gcsScope     = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsTokenURL  = "https://oauth2.googleapis.com/token"
+	gceTokenURL  = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcsCredsEnv  = "GOOGLE_APPLICATION_CREDENTIALS"
+	gcsUserAgent = "e2d"
+)
+
+type GCSConfig struct {
+	Bucket string
+	Object string
+
+	// path to a service account JSON key file, if unset the application
+	// default credentials are used
+	CredentialsFile string
+
+	// number of timestamped snapshots to keep, when zero a single snapshot
+	// is overwritten
+	Retention int
+}
+
+// GCSSnapshotter saves snapshots to a Google Cloud Storage bucket using the
+// JSON API.
+type GCSSnapshotter struct {
+	client   *http.Client
+	endpoint string
+
+	bucket, object string
+	retention      int
+}
+
+func NewGCSSnapshotter(cfg *GCSConfig) (*GCSSnapshotter, error) {
+	ts, err := newGCSTokenSource(cfg.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	return newGCSSnapshotter(oauth2.NewClient(context.Background(), ts), gcsEndpoint, cfg.Bucket, cfg.Object, cfg.Retention)
+}
+
+func newGCSSnapshotter(client *http.Client, endpoint, bucket, object string, retention int) (*GCSSnapshotter, error) {
+	s := &GCSSnapshotter{
+		client:    client,
+		endpoint:  endpoint,
+		bucket:    bucket,
+		object:    object,
+		retention: retention,
+	}
+
+	// Ensure that the bucket exists
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resp, err := s.do(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s", s.endpoint, url.PathEscape(bucket)), nil)
+	if err != nil {
+		return nil, errors.Errorf("bucket could not be accessed: %v", err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return s, nil
+	case http.StatusNotFound:
+		return nil, errors.Errorf("bucket %s does not exist", bucket)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, errors.Errorf("access to bucket %s forbidden", bucket)
+	default:
+		return nil, errors.Errorf("bucket could not be accessed: %s", resp.Status)
+	}
+}
+
+func (s *GCSSnapshotter) do(ctx context.Context, method, u string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", gcsUserAgent)
+	return s.client.Do(req.WithContext(ctx))
+}
+
+func (s *GCSSnapshotter) list(ctx context.Context) ([]string, error) {
+	names := make([]string, 0)
+	pageToken := ""
+	for {
+		q := url.Values{}
+		q.Set("prefix", timestampedPrefix(s.object))
+		q.Set("fields", "items(name),nextPageToken")
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		resp, err := s.do(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), q.Encode()), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list snapshots: %v", s.object)
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return errors.Errorf("cannot list snapshots: %v: %s", s.object, resp.Status)
+			}
+			return errors.Wrapf(json.NewDecoder(resp.Body).Decode(&page), "cannot list snapshots: %v", s.object)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			names = append(names, item.Name)
+		}
+		if page.NextPageToken == "" {
+			return names, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (s *GCSSnapshotter) Load() (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	object := s.object
+	if s.retention > 0 {
+		names, err := s.list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		object = latestSnapshot(s.object, names)
+	}
+	return s.load(ctx, object)
+}
+
+// LoadNamed loads the snapshot with the base name, either one of the
+// timestamped snapshots or the snapshot object itself.
+func (s *GCSSnapshotter) LoadNamed(name string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	names, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	object, err := namedSnapshot(s.object, names, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(ctx, object)
+}
+
+func (s *GCSSnapshotter) load(ctx context.Context, object string) (io.ReadCloser, error) {
+	tmpFile, err := ioutil.TempFile("", "snapshot.download")
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(object))
+	resp, err := s.do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrapf(err, "cannot download file: %v", object)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		tmpFile.Close()
+		return nil, errors.Errorf("cannot download file: %v: %s", object, resp.Status)
+	}
+	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrapf(err, "cannot download file: %v", object)
+	}
+	checksum, err := s.loadChecksum(ctx, object)
+	if err != nil {
+		tmpFile.Close()
+		return nil, err
+	}
+	if err := verifyChecksum(tmpFile, checksum); err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrapf(err, "cannot verify snapshot: %v", object)
+	}
+	return tmpFile, nil
+}
+
+// loadChecksum downloads the checksum stored alongside the snapshot object,
+// returning nil if there is none.
+func (s *GCSSnapshotter) loadChecksum(ctx context.Context, object string) ([]byte, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(checksumKey(object)))
+	resp, err := s.do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot download file: %v", checksumKey(object))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("cannot download file: %v: %s", checksumKey(object), resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot download file: %v", checksumKey(object))
+	}
+	return data, nil
+}
+
+func (s *GCSSnapshotter) upload(ctx context.Context, object string, r io.Reader) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", s.endpoint, url.PathEscape(s.bucket), url.QueryEscape(object))
+	resp, err := s.do(ctx, http.MethodPost, u, r)
+	if err != nil {
+		return errors.Wrapf(err, "cannot upload file: %v", object)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("cannot upload file: %v: %s", object, resp.Status)
+	}
+	return nil
+}
+
+func (s *GCSSnapshotter) delete(ctx context.Context, object string) error {
+	resp, err := s.do(ctx, http.MethodDelete, fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(object)), nil)
+	if err != nil {
+		return errors.Wrapf(err, "cannot delete file: %v", object)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return errors.Errorf("cannot delete file: %v: %s", object, resp.Status)
+	}
+	return nil
+}
+
+func (s *GCSSnapshotter) Save(r io.ReadCloser) error {
+	defer r.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	object := s.object
+	if s.retention > 0 {
+		object = timestampedKey(s.object, time.Now())
+	}
+
+	// the previous checksum is removed first, so that a failure to upload the
+	// new checksum doesn't leave a stale one for a different snapshot
+	if err := s.delete(ctx, checksumKey(object)); err != nil {
+		return err
+	}
+	cr := newChecksumReadCloser(r)
+	if err := s.upload(ctx, object, cr); err != nil {
+		return err
+	}
+	return s.upload(ctx, checksumKey(object), bytes.NewReader(cr.checksum(object)))
+}
+
+func (s *GCSSnapshotter) Prune() error {
+	if s.retention == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	names, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range expiredSnapshots(s.object, names, s.retention) {
+		for _, object := range []string{name, checksumKey(name)} {
+			if err := s.delete(ctx, object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newGCSTokenSource finds credentials in the same order as the Google
+// application default credentials: the provided service account file, then
+// the file named by GOOGLE_APPLICATION_CREDENTIALS, and finally the service
+// account of the GCE instance (or GKE node) from the metadata server.
+func newGCSTokenSource(credentialsFile string) (oauth2.TokenSource, error) {
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv(gcsCredsEnv)
+	}
+	if credentialsFile != "" {
+		data, err := ioutil.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read gcs credentials file: %#v", credentialsFile)
+		}
+		return newServiceAccountTokenSource(data)
+	}
+	return oauth2.ReuseTokenSource(nil, &metadataTokenSource{client: &http.Client{Timeout: 10 * time.Second}, url: gceTokenURL}), nil
+}
+
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+func newServiceAccountTokenSource(data []byte) (oauth2.TokenSource, error) {
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, errors.Wrap(err, "cannot parse gcs credentials")
+	}
+	if key.Type != "service_account" {
+		return nil, errors.Errorf("unsupported gcs credentials type: %#v", key.Type)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = gcsTokenURL
+	}
+	cfg := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		Scopes:       []string{gcsScope},
+		TokenURL:     key.TokenURI,
+	}
+	return cfg.TokenSource(context.Background()), nil
+}
+
+// metadataTokenSource gets access tokens for the default service account from
+// the GCE metadata server.
+type metadataTokenSource struct {
+	client *http.Client
+	url    string
+}
+
+func (ts *metadataTokenSource) Token() (*oauth2.Token, error) {
+	req, err := http.NewRequest(http.MethodGet, ts.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get token from metadata server")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("cannot get token from metadata server: %s", resp.Status)
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return nil, errors.Wrap(err, "cannot decode token from metadata server")
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("metadata server returned an empty token")
+	}
+	return &oauth2.Token{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+		Expiry:      time.Now().Add(time.Duration(t.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,157 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeGCS struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/"+f.bucket:
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/"+f.bucket+"/o":
+		type item struct {
+			Name string `json:"name"`
+		}
+		items := make([]item, 0)
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, item{Name: name})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/storage/v1/b/"+f.bucket+"/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"+f.bucket+"/o/")
+		if _, ok := f.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/"+f.bucket+"/o/"):
+		data, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"+f.bucket+"/o/")]
+		if !ok || r.URL.Query().Get("alt") != "media" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/"+f.bucket+"/o":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.objects[r.URL.Query().Get("name")] = data
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGCSSnapshotter(t *testing.T) {
+	f := &fakeGCS{bucket: "abc", objects: make(map[string][]byte)}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	s, err := newGCSSnapshotter(ts.Client(), ts.URL, "abc", "backups/etcd.snapshot", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); err == nil {
+		t.Fatal("expected error loading missing snapshot")
+	}
+	expected := []byte("snapshot data")
+	if err := s.Save(ioutil.NopCloser(bytes.NewReader(expected))); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expected, data); diff != "" {
+		t.Errorf("snapshot: after Load differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestGCSSnapshotterMissingBucket(t *testing.T) {
+	f := &fakeGCS{bucket: "abc", objects: make(map[string][]byte)}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	if _, err := newGCSSnapshotter(ts.Client(), ts.URL, "def", "etcd.snapshot", 0); err == nil {
+		t.Fatal("expected error for missing bucket")
+	}
+}
+
+func TestGCSSnapshotterRetention(t *testing.T) {
+	f := &fakeGCS{bucket: "abc", objects: map[string][]byte{
+		"backups/etcd.snapshot": []byte("untimestamped"),
+	}}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	s, err := newGCSSnapshotter(ts.Client(), ts.URL, "abc", "backups/etcd.snapshot", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		f.mu.Lock()
+		f.objects[timestampedKey("backups/etcd.snapshot", time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC))] = []byte(fmt.Sprint(i))
+		f.mu.Unlock()
+	}
+	if err := s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"backups/etcd-20200101T000001Z.snapshot",
+		"backups/etcd-20200101T000002Z.snapshot",
+		"backups/etcd.snapshot",
+	}
+	names := make([]string, 0)
+	for name := range f.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Errorf("snapshot: after Prune differs: (-want +got)\n%s", diff)
+	}
+	r, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("2", string(data)); diff != "" {
+		t.Errorf("snapshot: after Load differs: (-want +got)\n%s", diff)
+	}
+}
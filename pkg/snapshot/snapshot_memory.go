@@ -0,0 +1,53 @@
+package snapshot
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// InMemorySnapshotter keeps the last snapshot saved in memory, so that
+// applications embedding the manager can save and restore snapshots in tests
+// without touching the disk or a cloud provider. It is safe for concurrent
+// use.
+type InMemorySnapshotter struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func NewInMemorySnapshotter() *InMemorySnapshotter {
+	return &InMemorySnapshotter{}
+}
+
+// Load returns the last snapshot saved, or an error if no snapshot has been
+// saved yet.
+func (s *InMemorySnapshotter) Load() (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data == nil {
+		return nil, errors.New("no snapshot has been saved")
+	}
+	return ioutil.NopCloser(bytes.NewReader(s.data)), nil
+}
+
+// Save replaces the snapshot with the one read from r. The previous snapshot
+// is kept when r cannot be read completely.
+func (s *InMemorySnapshotter) Save(r io.ReadCloser) error {
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = data
+	return nil
+}
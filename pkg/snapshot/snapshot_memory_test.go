@@ -0,0 +1,91 @@
+package snapshot
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/criticalstack/e2d/pkg/snapshot/crypto"
+	snapshotutil "github.com/criticalstack/e2d/pkg/snapshot/util"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestInMemorySnapshotter(t *testing.T) {
+	s := NewInMemorySnapshotter()
+	if _, err := s.Load(); err == nil {
+		t.Fatal("expected error loading before a snapshot is saved")
+	}
+
+	data := bytes.Repeat([]byte("snapshot data"), 1024)
+	key := crypto.NewEncryptionKey()
+	cases := []struct {
+		name     string
+		compress bool
+		encrypt  bool
+	}{
+		{"plain", false, false},
+		{"compression", true, false},
+		{"encryption", false, true},
+		{"compression and encryption", true, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the wrappers are applied the same as the manager applies them
+			var r io.ReadCloser = ioutil.NopCloser(bytes.NewReader(data))
+			if tc.encrypt {
+				r = snapshotutil.NewEncrypterReadCloser(r, key, int64(len(data)))
+			}
+			if tc.compress {
+				r = snapshotutil.NewGzipReadCloser(r)
+			}
+			if err := s.Save(r); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = snapshotutil.NewGunzipReadCloser(r)
+			r = snapshotutil.NewDecrypterReadCloser(r, key)
+			defer r.Close()
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(data, got); diff != "" {
+				t.Errorf("snapshot: after Load differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+
+	// a snapshot that cannot be read does not replace the previous one
+	if err := s.Save(ioutil.NopCloser(failingReader{})); err == nil {
+		t.Fatal("expected error saving a snapshot that cannot be read")
+	}
+	r, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = snapshotutil.NewGunzipReadCloser(r)
+	r = snapshotutil.NewDecrypterReadCloser(r, key)
+	defer r.Close()
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, got) {
+		t.Fatal("expected the previous snapshot to be kept")
+	}
+}
@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/criticalstack/e2d/pkg/log"
+)
+
+// MultiSnapshotter mirrors snapshots to multiple Snapshotters, so that a
+// snapshot backup is still available when one of them cannot be reached.
+type MultiSnapshotter struct {
+	snapshotters []Snapshotter
+}
+
+func NewMultiSnapshotter(snapshotters ...Snapshotter) *MultiSnapshotter {
+	return &MultiSnapshotter{snapshotters: snapshotters}
+}
+
+// Load loads the snapshot from the first Snapshotter that succeeds, trying
+// each in order.
+func (m *MultiSnapshotter) Load() (io.ReadCloser, error) {
+	return m.load(func(s Snapshotter) (io.ReadCloser, error) {
+		return s.Load()
+	})
+}
+
+// LoadNamed loads the named snapshot from the first Snapshotter that
+// succeeds, trying each in order.
+func (m *MultiSnapshotter) LoadNamed(name string) (io.ReadCloser, error) {
+	return m.load(func(s Snapshotter) (io.ReadCloser, error) {
+		return LoadNamed(s, name)
+	})
+}
+
+func (m *MultiSnapshotter) load(fn func(Snapshotter) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	errs := make([]error, 0)
+	for _, s := range m.snapshotters {
+		r, err := fn(s)
+		if err == nil {
+			return r, nil
+		}
+		errs = append(errs, err)
+	}
+
+	// a corrupt snapshot must not be mistaken for a missing one, since
+	// starting a new cluster would overwrite the other snapshot backups
+	for _, err := range errs {
+		if errors.Cause(err) == ErrChecksumMismatch {
+			return nil, errors.Wrap(ErrChecksumMismatch, multiError("cannot load snapshot", errs).Error())
+		}
+	}
+	return nil, multiError("cannot load snapshot", errs)
+}
+
+// Save saves the snapshot to all of the Snapshotters concurrently. The
+// snapshot is buffered to a temporary file so that it can be read by each of
+// them, and it only fails when the snapshot could not be saved to any of them.
+func (m *MultiSnapshotter) Save(r io.ReadCloser) error {
+	defer r.Close()
+	f, err := ioutil.TempFile("", "snapshot.multi")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(m.snapshotters))
+	for i, s := range m.snapshotters {
+		wg.Add(1)
+		go func(i int, s Snapshotter) {
+			defer wg.Done()
+
+			sf, err := os.Open(f.Name())
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = s.Save(sf)
+		}(i, s)
+	}
+	wg.Wait()
+
+	failed := make([]error, 0)
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == len(m.snapshotters) {
+		return multiError("cannot save snapshot", failed)
+	}
+	for _, err := range failed {
+		log.Warn("cannot save snapshot to all backends", zap.Error(err))
+	}
+	return nil
+}
+
+// Prune prunes each of the Snapshotters that keep a history of snapshots.
+func (m *MultiSnapshotter) Prune() error {
+	errs := make([]error, 0)
+	for _, s := range m.snapshotters {
+		if p, ok := s.(Pruner); ok {
+			if err := p.Prune(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return multiError("cannot prune snapshots", errs)
+	}
+	return nil
+}
+
+func multiError(msg string, errs []error) error {
+	if len(errs) == 0 {
+		return errors.New(msg)
+	}
+	s := make([]string, 0, len(errs))
+	for _, err := range errs {
+		s = append(s, err.Error())
+	}
+	return errors.Errorf("%s: %s", msg, strings.Join(s, "; "))
+}
@@ -0,0 +1,76 @@
+package snapshot
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type memSnapshotter struct {
+	data []byte
+	err  error
+}
+
+func (m *memSnapshotter) Load() (io.ReadCloser, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.data == nil {
+		return nil, errors.New("no snapshot")
+	}
+	return ioutil.NopCloser(bytes.NewReader(m.data)), nil
+}
+
+func (m *memSnapshotter) Save(r io.ReadCloser) error {
+	defer r.Close()
+	if m.err != nil {
+		return m.err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.data = data
+	return nil
+}
+
+func TestMultiSnapshotter(t *testing.T) {
+	failed := &memSnapshotter{err: errors.New("unavailable")}
+	working := &memSnapshotter{}
+	s := NewMultiSnapshotter(failed, working)
+	if err := s.Save(ioutil.NopCloser(bytes.NewReader([]byte("snapshot data")))); err != nil {
+		t.Fatal(err)
+	}
+	if string(working.data) != "snapshot data" {
+		t.Fatalf("expected %#v, received %#v", "snapshot data", string(working.data))
+	}
+	r, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "snapshot data" {
+		t.Fatalf("expected %#v, received %#v", "snapshot data", string(data))
+	}
+
+	s = NewMultiSnapshotter(failed, &memSnapshotter{err: errors.New("unavailable")})
+	if err := s.Save(ioutil.NopCloser(bytes.NewReader([]byte("snapshot data")))); err == nil {
+		t.Fatal("expected error when all snapshotters fail")
+	}
+	if _, err := s.Load(); err == nil {
+		t.Fatal("expected error when all snapshotters fail")
+	}
+
+	s = NewMultiSnapshotter(&memSnapshotter{err: errors.Wrap(ErrChecksumMismatch, "corrupt")}, &memSnapshotter{})
+	if _, err := s.Load(); errors.Cause(err) != ErrChecksumMismatch {
+		t.Fatalf("expected %v, received %v", ErrChecksumMismatch, err)
+	}
+}
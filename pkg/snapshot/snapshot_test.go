@@ -1,6 +1,7 @@
 package snapshot
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -21,19 +22,22 @@ func TestParseSnapshotBackupURL(t *testing.T) {
 			expectedErr: ErrInvalidScheme,
 		},
 		{
-			name:     "file (empty)",
-			url:      "file://",
-			expected: &URL{Type: FileType},
+			name:        "file (empty)",
+			url:         "file://",
+			expected:    nil,
+			expectedErr: ErrMissingPath,
 		},
 		{
-			name:     "file",
-			url:      "file://abc",
-			expected: &URL{Type: FileType, Path: "abc"},
+			name:        "file (relative)",
+			url:         "file://abc",
+			expected:    nil,
+			expectedErr: ErrRelativePath,
 		},
 		{
-			name:     "file",
-			url:      "file://abc/snapshot.gz",
-			expected: &URL{Type: FileType, Path: "abc/snapshot.gz"},
+			name:        "file (relative)",
+			url:         "file://abc/snapshot.gz",
+			expected:    nil,
+			expectedErr: ErrRelativePath,
 		},
 		{
 			name:     "file",
@@ -50,6 +54,16 @@ func TestParseSnapshotBackupURL(t *testing.T) {
 			url:      "s3://abc/snapshot.gz",
 			expected: &URL{Type: S3Type, Bucket: "abc", Path: "snapshot.gz"},
 		},
+		{
+			name:     "gcs",
+			url:      "gs://abc",
+			expected: &URL{Type: GCSType, Bucket: "abc", Path: "etcd.snapshot"},
+		},
+		{
+			name:     "gcs",
+			url:      "gs://abc/backups/snapshot.gz",
+			expected: &URL{Type: GCSType, Bucket: "abc", Path: "backups/snapshot.gz"},
+		},
 		{
 			name:     "s3",
 			url:      "s3://abc/backupdir/snapshot.gz",
@@ -78,3 +92,44 @@ func TestParseSnapshotBackupURL(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSnapshotBackupURLErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr error
+	}{
+		{"unknown scheme", "ftp://abc/snapshot.gz", ErrInvalidScheme},
+		{"no scheme", "/abc/snapshot.gz", ErrInvalidScheme},
+		{"http not spaces", "https://example.com/abc/snapshot.gz", ErrInvalidScheme},
+		{"malformed", "s3://abc%zz/snapshot.gz", ErrCannotParseURL},
+		{"file no path", "file://", ErrMissingPath},
+		{"file directory", "file:///abc/", ErrMissingPath},
+		{"file root", "file:///", ErrMissingPath},
+		{"file relative", "file://abc", ErrRelativePath},
+		{"file relative dot", "file://./abc/snapshot.gz", ErrRelativePath},
+		{"s3 no bucket", "s3://", ErrMissingBucket},
+		{"s3 no bucket with path", "s3:///snapshot.gz", ErrMissingBucket},
+		{"s3 directory", "s3://abc/backups/", ErrMissingPath},
+		{"s3 trailing slash", "s3://abc/", ErrMissingPath},
+		{"gcs no bucket", "gs://", ErrMissingBucket},
+		{"gcs directory", "gs://abc/backups/", ErrMissingPath},
+		{"spaces no bucket", "https://nyc3.digitaloceanspaces.com", ErrMissingBucket},
+		{"spaces no bucket slash", "https://nyc3.digitaloceanspaces.com/", ErrMissingBucket},
+		{"spaces directory", "https://nyc3.digitaloceanspaces.com/abc/backups/", ErrMissingPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseSnapshotBackupURL(tt.url)
+			if errors.Cause(err) != tt.expectedErr {
+				t.Fatalf("expected %v, received %v", tt.expectedErr, err)
+			}
+			if u != nil {
+				t.Fatalf("expected no url, received %+v", u)
+			}
+			if !strings.Contains(err.Error(), tt.url) {
+				t.Fatalf("expected error to include the url %#v: %v", tt.url, err)
+			}
+		})
+	}
+}
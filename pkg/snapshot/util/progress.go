@@ -0,0 +1,30 @@
+package util
+
+import (
+	"io"
+)
+
+type progressReadCloser struct {
+	io.ReadCloser
+	interval int64
+	fn       func(int64)
+	n        int64
+	last     int64
+}
+
+// NewProgressReadCloser wraps a data stream, calling fn with the total number
+// of bytes read each time at least interval more bytes have been read. It is
+// also called once the end of the stream is reached.
+func NewProgressReadCloser(r io.ReadCloser, interval int64, fn func(n int64)) io.ReadCloser {
+	return &progressReadCloser{ReadCloser: r, interval: interval, fn: fn}
+}
+
+func (r *progressReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if r.n-r.last >= r.interval || (err == io.EOF && r.n != r.last) {
+		r.last = r.n
+		r.fn(r.n)
+	}
+	return n, err
+}
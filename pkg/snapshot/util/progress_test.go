@@ -0,0 +1,34 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProgressReadCloser(t *testing.T) {
+	data := make([]byte, 10*1024+512)
+	var progress []int64
+	r := NewProgressReadCloser(ioutil.NopCloser(bytes.NewReader(data)), 1024, func(n int64) {
+		progress = append(progress, n)
+	})
+	defer r.Close()
+
+	// read in chunks smaller than the interval so that multiple reads are
+	// needed for each report
+	buf := make([]byte, 256)
+	var out bytes.Buffer
+	if _, err := io.CopyBuffer(struct{ io.Writer }{&out}, struct{ io.Reader }{r}, buf); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != len(data) {
+		t.Fatalf("expected %d bytes, received %d", len(data), out.Len())
+	}
+	expected := []int64{1024, 2048, 3072, 4096, 5120, 6144, 7168, 8192, 9216, 10240, 10752}
+	if diff := cmp.Diff(expected, progress); diff != "" {
+		t.Errorf("after Read progress differs: (-want +got)\n%s", diff)
+	}
+}